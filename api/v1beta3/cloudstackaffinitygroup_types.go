@@ -18,10 +18,21 @@ package v1beta3
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 const AffinityGroupFinalizer = "affinitygroup.infrastructure.cluster.x-k8s.io"
 
+const (
+	// AffinityGroupReadyCondition reports whether the affinity group exists in CloudStack.
+	AffinityGroupReadyCondition clusterv1.ConditionType = "AffinityGroupReady"
+
+	// AffinityGroupProvisioningFailedReason is used when the affinity group can't be found or created.
+	AffinityGroupProvisioningFailedReason = "AffinityGroupProvisioningFailed"
+	// WaitingForAffinityGroupReason is used while the affinity group of a machine isn't ready.
+	WaitingForAffinityGroupReason = "WaitingForAffinityGroup"
+)
+
 // CloudStackAffinityGroupSpec defines the desired state of CloudStackAffinityGroup
 type CloudStackAffinityGroupSpec struct {
 	// Mutually exclusive parameter with AffinityGroupIDs.
@@ -44,6 +55,10 @@ type CloudStackAffinityGroupSpec struct {
 type CloudStackAffinityGroupStatus struct {
 	// Reflects the readiness of the CS Affinity Group.
 	Ready bool `json:"ready"`
+
+	// Conditions defines current service state of the CloudStackAffinityGroup.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -59,6 +74,16 @@ type CloudStackAffinityGroup struct {
 	Status CloudStackAffinityGroupStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the conditions of the CloudStackAffinityGroup.
+func (r *CloudStackAffinityGroup) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the conditions of the CloudStackAffinityGroup.
+func (r *CloudStackAffinityGroup) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
 //+kubebuilder:object:root=true
 
 // CloudStackAffinityGroupList contains a list of CloudStackAffinityGroup
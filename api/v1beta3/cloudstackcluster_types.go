@@ -26,6 +26,31 @@ const (
 	ClusterFinalizer = "cloudstackcluster.infrastructure.cluster.x-k8s.io"
 )
 
+const (
+	// QuotaHeadroomCondition reports whether the accounts, domains and projects of the cluster's failure domains have
+	// enough CPU, memory and VMs left under their limits.
+	QuotaHeadroomCondition clusterv1.ConditionType = "QuotaHeadroom"
+
+	// LowQuotaHeadroomReason is used when less than the configured share of a resource limit is still available.
+	LowQuotaHeadroomReason = "LowQuotaHeadroom"
+	// QuotaCollectionFailedReason is used when the limits of a failure domain couldn't be read from CloudStack.
+	QuotaCollectionFailedReason = "QuotaCollectionFailed"
+
+	// QuotaSufficientCondition reports whether the accounts, domains and projects of the cluster's failure domains have
+	// enough CPU, memory and VMs available to deploy the cluster's pending machines.
+	QuotaSufficientCondition clusterv1.ConditionType = "QuotaSufficient"
+
+	// InsufficientQuotaReason is used when the pending machines of a failure domain need more of a resource than is
+	// available.
+	InsufficientQuotaReason = "InsufficientQuota"
+
+	// FailureDomainsReadyCondition reports whether the CloudStackFailureDomains of the cluster exist and are ready.
+	FailureDomainsReadyCondition clusterv1.ConditionType = "FailureDomainsReady"
+
+	// WaitingForFailureDomainsReason is used while a failure domain of the cluster is missing or not ready.
+	WaitingForFailureDomainsReason = "WaitingForFailureDomains"
+)
+
 var K8sClient client.Client
 
 // CloudStackClusterSpec defines the desired state of CloudStackCluster.
@@ -53,6 +78,14 @@ type CloudStackClusterStatus struct {
 
 	// Reflects the readiness of the CS cluster.
 	Ready bool `json:"ready"`
+
+	// Conditions defines current service state of the CloudStackCluster.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// QuotasCollectedAt is when CAPC last collected the resource limits of the failure domains.
+	// +optional
+	QuotasCollectedAt *metav1.Time `json:"quotasCollectedAt,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -70,6 +103,16 @@ type CloudStackCluster struct {
 	Status CloudStackClusterStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the conditions of the CloudStackCluster.
+func (r *CloudStackCluster) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the conditions of the CloudStackCluster.
+func (r *CloudStackCluster) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
 //+kubebuilder:object:root=true
 
 // CloudStackClusterList contains a list of CloudStackCluster
@@ -22,6 +22,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 // FailureDomainHashedMetaName returns an MD5 name generated from the FailureDomain and Cluster name.
@@ -32,11 +33,38 @@ func FailureDomainHashedMetaName(fdName, clusterName string) string {
 	return fmt.Sprintf("%x", md5.Sum([]byte(fdName+clusterName))) // #nosec G401 -- weak cryptographic primitive doesn't matter here. Not security related.
 }
 
+// AccountCredentialsSecretName returns the name of the Secret holding the API keys provisioned for the account of the
+// FailureDomain with the passed name.
+func AccountCredentialsSecretName(fdName string) string {
+	return fdName + "-account-credentials"
+}
+
 const (
 	FailureDomainFinalizer = "cloudstackfailuredomain.infrastructure.cluster.x-k8s.io"
 	FailureDomainLabelName = "cloudstackfailuredomain.infrastructure.cluster.x-k8s.io/name"
 )
 
+const (
+	// CredentialsValidCondition reports whether the credentials in the ACS endpoint secret are accepted by CloudStack.
+	CredentialsValidCondition clusterv1.ConditionType = "CredentialsValid"
+
+	// EndpointSecretUnavailableReason is used when the ACS endpoint secret can't be read or parsed.
+	EndpointSecretUnavailableReason = "EndpointSecretUnavailable"
+	// InvalidCredentialsReason is used when CloudStack rejects the credentials in the ACS endpoint secret.
+	InvalidCredentialsReason = "InvalidCredentials"
+	// CredentialsCheckFailedReason is used when the credentials couldn't be checked, e.g. because CloudStack is
+	// unreachable.
+	CredentialsCheckFailedReason = "CredentialsCheckFailed"
+
+	// ZoneResolvedCondition reports whether the zone of the failure domain was found in CloudStack.
+	ZoneResolvedCondition clusterv1.ConditionType = "ZoneResolved"
+
+	// ZoneResolutionFailedReason is used when the zone can't be found or read.
+	ZoneResolutionFailedReason = "ZoneResolutionFailed"
+	// WaitingForIsolatedNetworkReason is used while the isolated network of the failure domain isn't ready.
+	WaitingForIsolatedNetworkReason = "WaitingForIsolatedNetwork"
+)
+
 const (
 	NetworkTypeIsolated = "Isolated"
 	NetworkTypeShared   = "Shared"
@@ -89,6 +117,11 @@ type CloudStackFailureDomainSpec struct {
 	// +optional
 	Project string `json:"project,omitempty"`
 
+	// Generate API keys in the account when none of its users have any, instead of failing. The keys are stored in a
+	// Secret owned by the failure domain, which later reconciliations use.
+	// +optional
+	ProvisionAccountCredentials bool `json:"provisionAccountCredentials,omitempty"`
+
 	// Apache CloudStack Endpoint secret reference.
 	ACSEndpoint corev1.SecretReference `json:"acsEndpoint"`
 }
@@ -97,6 +130,14 @@ type CloudStackFailureDomainSpec struct {
 type CloudStackFailureDomainStatus struct {
 	// Reflects the readiness of the CloudStack Failure Domain.
 	Ready bool `json:"ready"`
+
+	// The API URL of the ACS endpoint management server currently in use.
+	// +optional
+	ActiveAPIURL string `json:"activeAPIURL,omitempty"`
+
+	// Conditions defines current service state of the CloudStackFailureDomain.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -112,6 +153,16 @@ type CloudStackFailureDomain struct {
 	Status CloudStackFailureDomainStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the conditions of the CloudStackFailureDomain.
+func (r *CloudStackFailureDomain) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the conditions of the CloudStackFailureDomain.
+func (r *CloudStackFailureDomain) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
 //+kubebuilder:object:root=true
 
 // CloudStackFailureDomainList contains a list of CloudStackFailureDomain
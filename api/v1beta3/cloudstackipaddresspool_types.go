@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta3
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// CloudStackIPAddressPoolSpec defines the desired state of CloudStackIPAddressPool
+type CloudStackIPAddressPoolSpec struct {
+	// FailureDomainName -- the name of the FailureDomain whose network the addresses are on.
+	FailureDomainName string `json:"failureDomainName"`
+
+	// Addresses machines claim from, as single IP addresses or as inclusive ranges like 10.0.0.10-10.0.0.20.
+	Addresses []string `json:"addresses"`
+}
+
+// CloudStackIPAddressAllocation is an address of a pool claimed by a machine.
+type CloudStackIPAddressAllocation struct {
+	// The claimed IP address.
+	Address string `json:"address"`
+
+	// Name of the CloudStackMachine that claimed the address.
+	Machine string `json:"machine"`
+
+	// UID of the CloudStackMachine that claimed the address.
+	MachineUID types.UID `json:"machineUID"`
+}
+
+// CloudStackIPAddressPoolStatus defines the observed state of CloudStackIPAddressPool
+type CloudStackIPAddressPoolStatus struct {
+	// Allocations lists the addresses claimed by machines.
+	// +optional
+	Allocations []CloudStackIPAddressAllocation `json:"allocations,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="FailureDomain",type="string",JSONPath=".spec.failureDomainName",description="Failure domain whose network the addresses are on"
+
+// CloudStackIPAddressPool is the Schema for the cloudstackipaddresspools API
+type CloudStackIPAddressPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CloudStackIPAddressPoolSpec   `json:"spec,omitempty"`
+	Status CloudStackIPAddressPoolStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CloudStackIPAddressPoolList contains a list of CloudStackIPAddressPool
+type CloudStackIPAddressPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CloudStackIPAddressPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CloudStackIPAddressPool{}, &CloudStackIPAddressPoolList{})
+}
+
+// ParseIPAddressRange parses an entry of a pool's addresses: an address or an inclusive range of addresses like
+// 10.0.0.10-10.0.0.20.
+func ParseIPAddressRange(entry string) (first, last netip.Addr, err error) {
+	start, end, isRange := strings.Cut(entry, "-")
+	if first, err = netip.ParseAddr(strings.TrimSpace(start)); err != nil {
+		return first, last, fmt.Errorf("parsing address %q: %w", entry, err)
+	}
+	if !isRange {
+		return first, first, nil
+	}
+	if last, err = netip.ParseAddr(strings.TrimSpace(end)); err != nil {
+		return first, last, fmt.Errorf("parsing address range %q: %w", entry, err)
+	}
+	if first.Is4() != last.Is4() || first.Compare(last) > 0 {
+		return first, last, fmt.Errorf("invalid address range %q", entry)
+	}
+	return first, last, nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta3
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/webhookutil"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var cloudstackipaddresspoollog = logf.Log.WithName("cloudstackipaddresspool-resource")
+
+func (r *CloudStackIPAddressPool) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-infrastructure-cluster-x-k8s-io-v1beta3-cloudstackipaddresspool,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=cloudstackipaddresspools,verbs=create;update,versions=v1beta3,name=vcloudstackipaddresspool.kb.io,admissionReviewVersions=v1;v1beta1
+
+var _ webhook.Validator = &CloudStackIPAddressPool{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *CloudStackIPAddressPool) ValidateCreate() error {
+	cloudstackipaddresspoollog.V(1).Info("entered validate create webhook", "api resource name", r.Name)
+	return r.validateSpec()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *CloudStackIPAddressPool) ValidateUpdate(_ runtime.Object) error {
+	cloudstackipaddresspoollog.V(1).Info("entered validate update webhook", "api resource name", r.Name)
+	return r.validateSpec()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *CloudStackIPAddressPool) ValidateDelete() error {
+	cloudstackipaddresspoollog.V(1).Info("entered validate delete webhook", "api resource name", r.Name)
+	// No deletion validations.  Deletion webhook not enabled.
+	return nil
+}
+
+// validateSpec checks that the pool is in a failure domain, and that its addresses are addresses or valid ranges.
+func (r *CloudStackIPAddressPool) validateSpec() error {
+	var errorList field.ErrorList
+	if r.Spec.FailureDomainName == "" {
+		errorList = append(errorList, field.Required(field.NewPath("spec", "failureDomainName"), "failureDomainName"))
+	}
+	path := field.NewPath("spec", "addresses")
+	if len(r.Spec.Addresses) == 0 {
+		errorList = append(errorList, field.Required(path, "addresses"))
+	}
+	for i, entry := range r.Spec.Addresses {
+		if _, _, err := ParseIPAddressRange(entry); err != nil {
+			errorList = append(errorList, field.Invalid(path.Index(i), entry,
+				"must be an IP address or an inclusive range of addresses like 10.0.0.10-10.0.0.20"))
+		}
+	}
+	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, errorList)
+}
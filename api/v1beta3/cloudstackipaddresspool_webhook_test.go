@@ -0,0 +1,78 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta3_test
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CloudStackIPAddressPool webhook", func() {
+	var ctx context.Context
+	var pool *infrav1.CloudStackIPAddressPool
+	requiredRegex := "admission webhook.*denied the request.*Required value\\: %s"
+	invalidRegex := "admission webhook.*denied the request.*Invalid value.*%s"
+
+	BeforeEach(func() { // Reset test vars to initial state.
+		ctx = context.Background()
+		pool = &infrav1.CloudStackIPAddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "control-plane", Namespace: dummies.ClusterNameSpace},
+			Spec: infrav1.CloudStackIPAddressPoolSpec{
+				FailureDomainName: "zone1",
+				Addresses:         []string{"10.0.0.10-10.0.0.19", "10.0.0.25", "fd00::10-fd00::1f"},
+			},
+		}
+		_ = k8sClient.Delete(ctx, pool) // Delete any remnants.
+	})
+
+	Context("When creating a CloudStackIPAddressPool", func() {
+		It("Should accept a pool of addresses and address ranges", func() {
+			Expect(k8sClient.Create(ctx, pool)).Should(Succeed())
+		})
+
+		It("Should reject a pool without addresses", func() {
+			pool.Spec.Addresses = nil
+			Expect(k8sClient.Create(ctx, pool)).Should(MatchError(MatchRegexp(requiredRegex, "addresses")))
+		})
+
+		It("Should reject a pool without a failure domain", func() {
+			pool.Spec.FailureDomainName = ""
+			Expect(k8sClient.Create(ctx, pool)).Should(MatchError(MatchRegexp(requiredRegex, "failureDomainName")))
+		})
+
+		It("Should reject addresses that aren't IP addresses or ranges", func() {
+			for _, entry := range []string{"10.0.0.300", "10.0.0.20-10.0.0.10", "10.0.0.10-fd00::1", "node-1"} {
+				pool.Spec.Addresses = []string{entry}
+				Expect(k8sClient.Create(ctx, pool)).Should(MatchError(MatchRegexp(invalidRegex, "must be an IP address")), entry)
+			}
+		})
+	})
+
+	Context("When updating a CloudStackIPAddressPool", func() {
+		It("Should reject invalid addresses", func() {
+			Expect(k8sClient.Create(ctx, pool)).Should(Succeed())
+			pool.Spec.Addresses = append(pool.Spec.Addresses, "10.0.0.30-")
+			Expect(k8sClient.Update(ctx, pool)).Should(MatchError(MatchRegexp(invalidRegex, "must be an IP address")))
+		})
+	})
+})
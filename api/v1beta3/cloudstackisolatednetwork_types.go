@@ -24,6 +24,19 @@ import (
 // The presence of a finalizer prevents CAPI from deleting the corresponding CAPI data.
 const IsolatedNetworkFinalizer = "cloudstackisolatednetwork.infrastructure.cluster.x-k8s.io"
 
+const (
+	// NetworkReadyCondition reports whether the network of a failure domain exists, and for isolated networks,
+	// whether its public IP, firewall and load balancer rules are set up.
+	NetworkReadyCondition clusterv1.ConditionType = "NetworkReady"
+
+	// NetworkResolutionFailedReason is used when the network can't be found or read.
+	NetworkResolutionFailedReason = "NetworkResolutionFailed"
+	// NetworkProvisioningFailedReason is used when the isolated network or its rules can't be created.
+	NetworkProvisioningFailedReason = "NetworkProvisioningFailed"
+	// WaitingForZoneReason is used while the zone the network is in isn't resolved yet.
+	WaitingForZoneReason = "WaitingForZone"
+)
+
 // CloudStackIsolatedNetworkSpec defines the desired state of CloudStackIsolatedNetwork
 type CloudStackIsolatedNetworkSpec struct {
 	// Name.
@@ -51,6 +64,10 @@ type CloudStackIsolatedNetworkStatus struct {
 
 	// Ready indicates the readiness of this provider resource.
 	Ready bool `json:"ready"`
+
+	// Conditions defines current service state of the CloudStackIsolatedNetwork.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 func (n *CloudStackIsolatedNetwork) Network() *Network {
@@ -73,6 +90,16 @@ type CloudStackIsolatedNetwork struct {
 	Status CloudStackIsolatedNetworkStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the conditions of the CloudStackIsolatedNetwork.
+func (n *CloudStackIsolatedNetwork) GetConditions() clusterv1.Conditions {
+	return n.Status.Conditions
+}
+
+// SetConditions sets the conditions of the CloudStackIsolatedNetwork.
+func (n *CloudStackIsolatedNetwork) SetConditions(conditions clusterv1.Conditions) {
+	n.Status.Conditions = conditions
+}
+
 //+kubebuilder:object:root=true
 
 // CloudStackIsolatedNetworkList contains a list of CloudStackIsolatedNetwork
@@ -21,11 +21,47 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
 // The presence of a finalizer prevents CAPI from deleting the corresponding CAPI data.
 const MachineFinalizer = "cloudstackmachine.infrastructure.cluster.x-k8s.io"
 
+// InstanceOwnerUIDAnnotation records the UID the machine's instance was tagged as owned by. It's kept when the machine
+// is moved to another management cluster, where the machine gets a new UID, so that the machine can still delete its
+// instance.
+const InstanceOwnerUIDAnnotation = "cloudstackmachine.infrastructure.cluster.x-k8s.io/instance-owner-uid"
+
+// InstanceCreatedByCAPCAnnotation marks a machine whose instance CAPC deployed, rather than adopted by its ID.
+const InstanceCreatedByCAPCAnnotation = "cloudstackmachine.infrastructure.cluster.x-k8s.io/instance-created-by-capc"
+
+const (
+	// InstanceProvisionedCondition reports whether the CloudStack instance of the machine is deployed and running.
+	InstanceProvisionedCondition clusterv1.ConditionType = "InstanceProvisioned"
+
+	// WaitingForBootstrapDataReason is used while the bootstrap data of the machine isn't available yet.
+	WaitingForBootstrapDataReason = "WaitingForBootstrapData"
+	// WaitingForDeployJobReason is used while the async job deploying the instance is running.
+	WaitingForDeployJobReason = "WaitingForDeployJob"
+	// WaitingForIPAddressReason is used while the machine can't claim an address from its IP address pools.
+	WaitingForIPAddressReason = "WaitingForIPAddress"
+
+	// InstanceProvisioningFailedReason is used when the instance can't be deployed.
+	InstanceProvisioningFailedReason = "InstanceProvisioningFailed"
+	// InstanceNotRunningReason is used while the instance is deployed but not running.
+	InstanceNotRunningReason = "InstanceNotRunning"
+	// InstanceErrorReason is used when CloudStack reports the instance in the Error state.
+	InstanceErrorReason = "InstanceError"
+
+	// LoadBalancerAttachedCondition reports whether a control plane machine in an isolated network is assigned to the
+	// load balancer rule of the API server.
+	LoadBalancerAttachedCondition clusterv1.ConditionType = "LoadBalancerAttached"
+
+	// LoadBalancerAttachFailedReason is used when the instance can't be assigned to the load balancer rule.
+	LoadBalancerAttachFailedReason = "LoadBalancerAttachFailed"
+)
+
 const (
 	ProAffinity  = "pro"
 	AntiAffinity = "anti"
@@ -55,6 +91,16 @@ type CloudStackMachineSpec struct {
 	// +optional
 	DiskOffering CloudStackResourceDiskOffering `json:"diskOffering,omitempty"`
 
+	// Root disk of the machine: its size and where it's placed. Defaults to the size of the template, on the storage of
+	// the service offering.
+	// +optional
+	RootDisk CloudStackMachineRootDisk `json:"rootDisk,omitempty"`
+
+	// Data disks of the machine, in order. They come after the disk of DiskOffering if there's one. The first data disk
+	// is deployed with the VM, the others are attached to it before it's started.
+	// +optional
+	DataDisks []CloudStackMachineDataDisk `json:"dataDisks,omitempty"`
+
 	// CloudStack ssh key to use.
 	// +optional
 	SSHKey string `json:"sshKey"`
@@ -62,6 +108,11 @@ type CloudStackMachineSpec struct {
 	// Optional details map for deployVirtualMachine
 	Details map[string]string `json:"details,omitempty"`
 
+	// Settings that differ between failure domains, like the ID of a template in each zone, by failure domain name. The
+	// overrides of the failure domain the machine is assigned to replace the settings of the spec.
+	// +optional
+	FailureDomainOverrides map[string]CloudStackMachineOverrides `json:"failureDomainOverrides,omitempty"`
+
 	// Optional affinitygroupids for deployVirtualMachine
 	// +optional
 	AffinityGroupIDs []string `json:"affinityGroupIDs,omitempty"`
@@ -89,12 +140,89 @@ type CloudStackMachineSpec struct {
 	//
 	// +optional
 	UncompressedUserData *bool `json:"uncompressedUserData,omitempty"`
+
+	// Networks the machine gets a NIC on in addition to the network of its failure domain, in order.
+	// +optional
+	AdditionalNetworks []CloudStackMachineNetwork `json:"additionalNetworks,omitempty"`
+
+	// Static IP address of the machine on the network of its failure domain.
+	// Mutually exclusive parameter with IPAddressPools.
+	// +optional
+	IPAddress string `json:"ipAddress,omitempty"`
+
+	// Names of CloudStackIPAddressPools the machine claims its IP address on the network of its failure domain from.
+	// The pool of the machine's failure domain is used.
+	// Mutually exclusive parameter with IPAddress.
+	// +optional
+	IPAddressPools []string `json:"ipAddressPools,omitempty"`
+}
+
+// CloudStackMachineNetwork is a network a machine gets an additional NIC on.
+type CloudStackMachineNetwork struct {
+	// Cloudstack Network ID.
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// Cloudstack Network Name, looked up in the zone of the machine's failure domain.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Static IP address of the NIC. CloudStack allocates one when unset.
+	// +optional
+	IP string `json:"ip,omitempty"`
 }
 
 func (c *CloudStackMachine) CompressUserdata() bool {
 	return c.Spec.UncompressedUserData == nil || !*c.Spec.UncompressedUserData
 }
 
+// CloudStackMachineOverrides are the settings of a machine in a failure domain.
+type CloudStackMachineOverrides struct {
+	// CloudStack compute offering in the failure domain.
+	// +optional
+	Offering CloudStackResourceIdentifier `json:"offering,omitempty"`
+
+	// CloudStack template in the failure domain.
+	// +optional
+	Template CloudStackResourceIdentifier `json:"template,omitempty"`
+
+	// CloudStack disk offering in the failure domain.
+	// +optional
+	DiskOffering *CloudStackResourceDiskOffering `json:"diskOffering,omitempty"`
+
+	// Details for deployVirtualMachine in the failure domain, added to those of the spec.
+	// +optional
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// InFailureDomain returns the spec with the overrides of the named failure domain applied.
+func (s CloudStackMachineSpec) InFailureDomain(name string) CloudStackMachineSpec {
+	overrides, found := s.FailureDomainOverrides[name]
+	if !found {
+		return s
+	}
+	if overrides.Offering.ID != "" || overrides.Offering.Name != "" {
+		s.Offering = overrides.Offering
+	}
+	if overrides.Template.ID != "" || overrides.Template.Name != "" {
+		s.Template = overrides.Template
+	}
+	if overrides.DiskOffering != nil {
+		s.DiskOffering = *overrides.DiskOffering
+	}
+	if len(overrides.Details) > 0 {
+		details := make(map[string]string, len(s.Details)+len(overrides.Details))
+		for k, v := range s.Details {
+			details[k] = v
+		}
+		for k, v := range overrides.Details {
+			details[k] = v
+		}
+		s.Details = details
+	}
+	return s
+}
+
 type CloudStackResourceIdentifier struct {
 	// Cloudstack resource ID.
 	// +optional
@@ -120,9 +248,59 @@ type CloudStackResourceDiskOffering struct {
 	Label string `json:"label"`
 }
 
+// CloudStackMachineRootDisk is the root disk of a machine.
+type CloudStackMachineRootDisk struct {
+	// Size of the root disk in GB. Must be at least the size of the template.
+	// +optional
+	SizeInGB int64 `json:"sizeInGB,omitempty"`
+
+	// CloudStack disk offering of the root disk, overriding that of the service offering.
+	// +optional
+	Offering CloudStackResourceIdentifier `json:"offering,omitempty"`
+
+	// Storage tag of the primary storage to place the root disk on. The root disk gets a disk offering with the tag.
+	// +optional
+	StorageTag string `json:"storageTag,omitempty"`
+}
+
+// CloudStackMachineDataDisk is a data disk of a machine.
+type CloudStackMachineDataDisk struct {
+	// CloudStack disk offering of the disk.
+	Offering CloudStackResourceIdentifier `json:"offering"`
+
+	// Desired disk size. Used if disk offering is customizable as indicated by the ACS field 'Custom Disk Size'.
+	// +optional
+	CustomSize int64 `json:"customSizeInGB,omitempty"`
+
+	// Minimum IOPS of the disk. Used if disk offering has customizable IOPS.
+	// +optional
+	MinIOPS int64 `json:"minIOPS,omitempty"`
+
+	// Maximum IOPS of the disk. Used if disk offering has customizable IOPS.
+	// +optional
+	MaxIOPS int64 `json:"maxIOPS,omitempty"`
+
+	// mount point the data disk uses to mount. The actual partition, mkfs and mount are done by cloud-init generated by kubeadmConfig.
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+
+	// device name of data disk, for example /dev/vdc
+	// +optional
+	Device string `json:"device,omitempty"`
+
+	// filesystem used by data disk, for example, ext4, xfs
+	// +optional
+	Filesystem string `json:"filesystem,omitempty"`
+
+	// label of data disk, used by mkfs as label parameter
+	// +optional
+	Label string `json:"label,omitempty"`
+}
+
 // Type pulled mostly from the CloudStack API.
 type CloudStackMachineStatus struct {
-	// Addresses contains a CloudStack VM instance's IP addresses.
+	// Addresses contains a CloudStack VM instance's IPv4 and IPv6 addresses, the public IPs statically NATed to it,
+	// and its hostname and DNS name.
 	Addresses []corev1.NodeAddress `json:"addresses,omitempty"`
 
 	// InstanceState is the state of the CloudStack instance for this machine.
@@ -133,6 +311,20 @@ type CloudStackMachineStatus struct {
 	// +optional
 	InstanceStateLastUpdated metav1.Time `json:"instanceStateLastUpdated,omitempty"`
 
+	// DeployJobID is the ID of the CloudStack async job deploying the instance, set until the job completes and the
+	// instance it deployed is adopted.
+	// +optional
+	DeployJobID *string `json:"deployJobID,omitempty"`
+
+	// IPAddress is the address claimed for the machine from one of its IP address pools.
+	// +optional
+	IPAddress string `json:"ipAddress,omitempty"`
+
+	// DataDisksPending is set when the instance is deployed stopped for data disks to be attached to it before it's
+	// first started, and cleared once it's started. An instance stopped later on isn't started again.
+	// +optional
+	DataDisksPending bool `json:"dataDisksPending,omitempty"`
+
 	// Ready indicates the readiness of the provider resource.
 	Ready bool `json:"ready"`
 
@@ -143,6 +335,21 @@ type CloudStackMachineStatus struct {
 	// Reason indicates the reason of status failure
 	// +optional
 	Reason *string `json:"reason,omitempty"`
+
+	// FailureReason will be set in the event that there is a terminal problem reconciling the CloudStackMachine, such
+	// as a template or offering that doesn't exist, and will contain a succinct value suitable for machine
+	// interpretation.
+	// +optional
+	FailureReason *capierrors.MachineStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage will be set in the event that there is a terminal problem reconciling the CloudStackMachine and
+	// will contain a more verbose string suitable for logging and human consumption.
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Conditions defines current service state of the CloudStackMachine.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 // TimeSinceLastStateChange returns the amount of time that's elapsed since the state was last updated.  If the state
@@ -173,6 +380,16 @@ type CloudStackMachine struct {
 	Status CloudStackMachineStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the conditions of the CloudStackMachine.
+func (c *CloudStackMachine) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions sets the conditions of the CloudStackMachine.
+func (c *CloudStackMachine) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
 //+kubebuilder:object:root=true
 
 // CloudStackMachineList contains a list of CloudStackMachine
@@ -18,6 +18,7 @@ package v1beta3
 
 import (
 	"fmt"
+	"net"
 	"reflect"
 
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -63,6 +64,12 @@ func (r *CloudStackMachine) ValidateCreate() error {
 	if len(r.Spec.DiskOffering.ID) > 0 || len(r.Spec.DiskOffering.Name) > 0 {
 		errorList = webhookutil.EnsureIntFieldsAreNotNegative(r.Spec.DiskOffering.CustomSize, "customSizeInGB", errorList)
 	}
+	errorList = validateAdditionalNetworks(r.Spec.AdditionalNetworks, field.NewPath("spec", "additionalNetworks"), errorList)
+	errorList = validateRootDisk(r.Spec.RootDisk, field.NewPath("spec", "rootDisk"), errorList)
+	errorList = validateFailureDomainOverrides(
+		r.Spec.FailureDomainOverrides, field.NewPath("spec", "failureDomainOverrides"), errorList)
+	errorList = validateDataDisks(r.Spec.DataDisks, field.NewPath("spec", "dataDisks"), errorList)
+	errorList = validateIPAddress(r.Spec, field.NewPath("spec"), errorList)
 
 	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, errorList)
 }
@@ -97,6 +104,22 @@ func (r *CloudStackMachine) ValidateUpdate(old runtime.Object) error {
 	if !reflect.DeepEqual(r.Spec.AffinityGroupIDs, oldSpec.AffinityGroupIDs) { // Equivalent to other Ensure funcs.
 		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "AffinityGroupIDs"), "AffinityGroupIDs"))
 	}
+	if !reflect.DeepEqual(r.Spec.AdditionalNetworks, oldSpec.AdditionalNetworks) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "additionalNetworks"), "additionalNetworks"))
+	}
+	if !reflect.DeepEqual(r.Spec.FailureDomainOverrides, oldSpec.FailureDomainOverrides) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "failureDomainOverrides"), "failureDomainOverrides"))
+	}
+	if !reflect.DeepEqual(r.Spec.RootDisk, oldSpec.RootDisk) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "rootDisk"), "rootDisk"))
+	}
+	if !reflect.DeepEqual(r.Spec.DataDisks, oldSpec.DataDisks) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "dataDisks"), "dataDisks"))
+	}
+	errorList = webhookutil.EnsureEqualStrings(r.Spec.IPAddress, oldSpec.IPAddress, "ipAddress", errorList)
+	if !reflect.DeepEqual(r.Spec.IPAddressPools, oldSpec.IPAddressPools) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "ipAddressPools"), "ipAddressPools"))
+	}
 
 	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, errorList)
 }
@@ -107,3 +130,88 @@ func (r *CloudStackMachine) ValidateDelete() error {
 	// No deletion validations.  Deletion webhook not enabled.
 	return nil
 }
+
+// validateAdditionalNetworks checks that each additional network has an ID or a name, and that static IPs are IP
+// addresses.
+func validateAdditionalNetworks(networks []CloudStackMachineNetwork, path *field.Path, errorList field.ErrorList) field.ErrorList {
+	for i, network := range networks {
+		if network.ID == "" && network.Name == "" {
+			errorList = append(errorList, field.Required(path.Index(i), "an ID or a name is required"))
+		}
+		if network.IP != "" && net.ParseIP(network.IP) == nil {
+			errorList = append(errorList, field.Invalid(path.Index(i).Child("ip"), network.IP, "must be an IP address"))
+		}
+	}
+	return errorList
+}
+
+// validateFailureDomainOverrides checks that the disk offering overriding that of the spec in a failure domain has an ID
+// or a name and a size that isn't negative.
+func validateFailureDomainOverrides(
+	overrides map[string]CloudStackMachineOverrides, path *field.Path, errorList field.ErrorList,
+) field.ErrorList {
+	for name, o := range overrides {
+		if o.DiskOffering == nil {
+			continue
+		}
+		diskOfferingPath := path.Key(name).Child("diskOffering")
+		if o.DiskOffering.ID == "" && o.DiskOffering.Name == "" {
+			errorList = append(errorList, field.Required(diskOfferingPath, "an ID or a name is required"))
+		}
+		if o.DiskOffering.CustomSize < 0 {
+			errorList = append(errorList, field.Invalid(diskOfferingPath.Child("customSizeInGB"), o.DiskOffering.CustomSize,
+				"must not be negative"))
+		}
+	}
+	return errorList
+}
+
+// validateRootDisk checks that the root disk size isn't negative, and that the root disk is placed by either a disk
+// offering or a storage tag. Whether the size fits the template is only known to CloudStack, and is checked when the VM
+// is deployed.
+func validateRootDisk(disk CloudStackMachineRootDisk, path *field.Path, errorList field.ErrorList) field.ErrorList {
+	if disk.SizeInGB < 0 {
+		errorList = append(errorList, field.Invalid(path.Child("sizeInGB"), disk.SizeInGB, "must not be negative"))
+	}
+	if (disk.Offering.ID != "" || disk.Offering.Name != "") && disk.StorageTag != "" {
+		errorList = append(errorList, field.Forbidden(path.Child("storageTag"),
+			"storageTag cannot be specified when offering is specified"))
+	}
+	return errorList
+}
+
+// validateDataDisks checks that each data disk has a disk offering, and that its size and IOPS are valid.
+func validateDataDisks(disks []CloudStackMachineDataDisk, path *field.Path, errorList field.ErrorList) field.ErrorList {
+	for i, disk := range disks {
+		if disk.Offering.ID == "" && disk.Offering.Name == "" {
+			errorList = append(errorList, field.Required(path.Index(i).Child("offering"), "an ID or a name is required"))
+		}
+		if disk.CustomSize < 0 {
+			errorList = append(errorList, field.Invalid(path.Index(i).Child("customSizeInGB"), disk.CustomSize, "must not be negative"))
+		}
+		if disk.MinIOPS < 0 {
+			errorList = append(errorList, field.Invalid(path.Index(i).Child("minIOPS"), disk.MinIOPS, "must not be negative"))
+		}
+		if disk.MaxIOPS < 0 || (disk.MaxIOPS > 0 && disk.MaxIOPS < disk.MinIOPS) {
+			errorList = append(errorList, field.Invalid(path.Index(i).Child("maxIOPS"), disk.MaxIOPS,
+				"must not be negative or less than minIOPS"))
+		}
+	}
+	return errorList
+}
+
+// validateIPAddress checks that the static IP of a machine is an IP address, and that it isn't combined with IP
+// address pools.
+func validateIPAddress(spec CloudStackMachineSpec, path *field.Path, errorList field.ErrorList) field.ErrorList {
+	if spec.IPAddress == "" {
+		return errorList
+	}
+	if net.ParseIP(spec.IPAddress) == nil {
+		errorList = append(errorList, field.Invalid(path.Child("ipAddress"), spec.IPAddress, "must be an IP address"))
+	}
+	if len(spec.IPAddressPools) > 0 {
+		errorList = append(errorList, field.Forbidden(path.Child("ipAddressPools"),
+			"ipAddressPools cannot be specified when ipAddress is specified"))
+	}
+	return errorList
+}
@@ -30,6 +30,7 @@ var _ = Describe("CloudStackMachine webhook", func() {
 	var ctx context.Context
 	forbiddenRegex := "admission webhook.*denied the request.*Forbidden\\: %s"
 	requiredRegex := "admission webhook.*denied the request.*Required value\\: %s"
+	invalidRegex := "admission webhook.*denied the request.*Invalid value.*%s"
 
 	BeforeEach(func() { // Reset test vars to initial state.
 		dummies.SetDummyVars()
@@ -70,6 +71,74 @@ var _ = Describe("CloudStackMachine webhook", func() {
 			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
 				Should(MatchError(MatchRegexp(requiredRegex, "Template")))
 		})
+
+		It("should accept a CloudStackMachine with additional networks", func() {
+			dummies.CSMachine1.Spec.AdditionalNetworks = []infrav1.CloudStackMachineNetwork{
+				{Name: "storage", IP: "10.2.0.10"}, {ID: "28b907b8-75a7-4214-bd3d-6c61961fc2af"},
+			}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).Should(Succeed())
+		})
+
+		It("should reject a CloudStackMachine with an additional network without ID or name", func() {
+			dummies.CSMachine1.Spec.AdditionalNetworks = []infrav1.CloudStackMachineNetwork{{IP: "10.2.0.10"}}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(requiredRegex, "an ID or a name is required")))
+		})
+
+		It("should reject a CloudStackMachine with an invalid static IP on an additional network", func() {
+			dummies.CSMachine1.Spec.AdditionalNetworks = []infrav1.CloudStackMachineNetwork{{Name: "storage", IP: "10.2.0"}}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(invalidRegex, "must be an IP address")))
+		})
+
+		It("should reject a CloudStackMachine with an invalid static IP", func() {
+			dummies.CSMachine1.Spec.IPAddress = "10.1.0"
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(invalidRegex, "must be an IP address")))
+		})
+
+		It("should reject a CloudStackMachine with both a static IP and IP address pools", func() {
+			dummies.CSMachine1.Spec.IPAddress = "10.1.0.10"
+			dummies.CSMachine1.Spec.IPAddressPools = []string{"control-plane"}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "ipAddressPools cannot be specified")))
+		})
+
+		It("should reject a CloudStackMachine with a negative root disk size", func() {
+			dummies.CSMachine1.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{SizeInGB: -1}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(invalidRegex, "must not be negative")))
+		})
+
+		It("should reject a CloudStackMachine with both a root disk offering and storage tag", func() {
+			dummies.CSMachine1.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{
+				Offering: infrav1.CloudStackResourceIdentifier{Name: "Fast"}, StorageTag: "ssd",
+			}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "storageTag cannot be specified")))
+		})
+
+		It("should reject a CloudStackMachine overriding the disk offering of a failure domain without a disk offering", func() {
+			dummies.CSMachine1.Spec.FailureDomainOverrides = map[string]infrav1.CloudStackMachineOverrides{
+				"fd2": {DiskOffering: &infrav1.CloudStackResourceDiskOffering{CustomSize: 10}},
+			}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(requiredRegex, "an ID or a name is required")))
+		})
+
+		It("should reject a CloudStackMachine with a data disk without a disk offering", func() {
+			dummies.CSMachine1.Spec.DataDisks = []infrav1.CloudStackMachineDataDisk{{CustomSize: 10}}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(requiredRegex, "an ID or a name is required")))
+		})
+
+		It("should reject a CloudStackMachine with a data disk whose maximum IOPS are less than its minimum IOPS", func() {
+			dummies.CSMachine1.Spec.DataDisks = []infrav1.CloudStackMachineDataDisk{{
+				Offering: infrav1.CloudStackResourceIdentifier{Name: "Fast"}, MinIOPS: 1000, MaxIOPS: 500,
+			}}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(invalidRegex, "less than minIOPS")))
+		})
 	})
 
 	Context("When updating a CloudStackMachine", func() {
@@ -106,5 +175,45 @@ var _ = Describe("CloudStackMachine webhook", func() {
 			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
 				Should(MatchError(MatchRegexp(forbiddenRegex, "AffinityGroupIDs")))
 		})
+
+		It("should reject updates to the additional networks of the CloudStackMachine", func() {
+			dummies.CSMachine1.Spec.AdditionalNetworks = []infrav1.CloudStackMachineNetwork{{Name: "storage"}}
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "additionalNetworks")))
+		})
+
+		It("should reject updates to the static IP of the CloudStackMachine", func() {
+			dummies.CSMachine1.Spec.IPAddress = "10.1.0.10"
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "ipAddress")))
+		})
+
+		It("should reject updates to the IP address pools of the CloudStackMachine", func() {
+			dummies.CSMachine1.Spec.IPAddressPools = []string{"control-plane"}
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "ipAddressPools")))
+		})
+
+		It("should reject updates to the failure domain overrides of the CloudStackMachine", func() {
+			dummies.CSMachine1.Spec.FailureDomainOverrides = map[string]infrav1.CloudStackMachineOverrides{
+				"fd2": {Template: infrav1.CloudStackResourceIdentifier{Name: "ubuntu-zone2"}},
+			}
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "failureDomainOverrides")))
+		})
+
+		It("should reject updates to the root disk of the CloudStackMachine", func() {
+			dummies.CSMachine1.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{SizeInGB: 50}
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "rootDisk")))
+		})
+
+		It("should reject updates to the data disks of the CloudStackMachine", func() {
+			dummies.CSMachine1.Spec.DataDisks = []infrav1.CloudStackMachineDataDisk{{
+				Offering: infrav1.CloudStackResourceIdentifier{Name: "Fast"},
+			}}
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "dataDisks")))
+		})
 	})
 })
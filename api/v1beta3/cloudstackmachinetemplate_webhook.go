@@ -74,6 +74,16 @@ func (r *CloudStackMachineTemplate) ValidateCreate() error {
 
 	errorList = webhookutil.EnsureAtLeastOneFieldExists(spec.Offering.ID, spec.Offering.Name, "Offering", errorList)
 	errorList = webhookutil.EnsureAtLeastOneFieldExists(spec.Template.ID, spec.Template.Name, "Template", errorList)
+	errorList = validateAdditionalNetworks(
+		spec.AdditionalNetworks, field.NewPath("spec", "template", "spec", "additionalNetworks"), errorList)
+	errorList = validateRootDisk(spec.RootDisk, field.NewPath("spec", "template", "spec", "rootDisk"), errorList)
+	errorList = validateFailureDomainOverrides(
+		spec.FailureDomainOverrides, field.NewPath("spec", "template", "spec", "failureDomainOverrides"), errorList)
+	errorList = validateDataDisks(spec.DataDisks, field.NewPath("spec", "template", "spec", "dataDisks"), errorList)
+	if spec.IPAddress != "" { // Every machine of the template would get the same address.
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "template", "spec", "ipAddress"),
+			"ipAddress cannot be specified in a template, use ipAddressPools instead"))
+	}
 
 	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, errorList)
 }
@@ -105,6 +115,24 @@ func (r *CloudStackMachineTemplate) ValidateUpdate(old runtime.Object) error {
 	if !reflect.DeepEqual(spec.AffinityGroupIDs, oldSpec.AffinityGroupIDs) { // Equivalent to other Ensure funcs.
 		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "AffinityGroupIDs"), "AffinityGroupIDs"))
 	}
+	if !reflect.DeepEqual(spec.AdditionalNetworks, oldSpec.AdditionalNetworks) {
+		errorList = append(errorList, field.Forbidden(
+			field.NewPath("spec", "template", "spec", "additionalNetworks"), "additionalNetworks"))
+	}
+	if !reflect.DeepEqual(spec.FailureDomainOverrides, oldSpec.FailureDomainOverrides) {
+		errorList = append(errorList, field.Forbidden(
+			field.NewPath("spec", "template", "spec", "failureDomainOverrides"), "failureDomainOverrides"))
+	}
+	if !reflect.DeepEqual(spec.RootDisk, oldSpec.RootDisk) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "template", "spec", "rootDisk"), "rootDisk"))
+	}
+	if !reflect.DeepEqual(spec.DataDisks, oldSpec.DataDisks) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "template", "spec", "dataDisks"), "dataDisks"))
+	}
+	if !reflect.DeepEqual(spec.IPAddressPools, oldSpec.IPAddressPools) {
+		errorList = append(errorList, field.Forbidden(
+			field.NewPath("spec", "template", "spec", "ipAddressPools"), "ipAddressPools"))
+	}
 
 	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, errorList)
 }
@@ -60,6 +60,26 @@ var _ = Describe("CloudStackMachineTemplate webhook", func() {
 			Expect(k8sClient.Create(ctx, dummies.CSMachineTemplate1)).
 				Should(MatchError(MatchRegexp(requiredRegex, "Template")))
 		})
+
+		It("Should reject a CloudStackMachineTemplate with a static IP", func() {
+			dummies.CSMachineTemplate1.Spec.Template.Spec.IPAddress = "10.1.0.10"
+			Expect(k8sClient.Create(ctx, dummies.CSMachineTemplate1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "ipAddress cannot be specified in a template")))
+		})
+
+		It("should reject a CloudStackMachineTemplate with both a root disk offering and storage tag", func() {
+			dummies.CSMachineTemplate1.Spec.Template.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{
+				Offering: infrav1.CloudStackResourceIdentifier{Name: "Fast"}, StorageTag: "ssd",
+			}
+			Expect(k8sClient.Create(ctx, dummies.CSMachineTemplate1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "storageTag cannot be specified")))
+		})
+
+		It("should reject a CloudStackMachineTemplate with a data disk without a disk offering", func() {
+			dummies.CSMachineTemplate1.Spec.Template.Spec.DataDisks = []infrav1.CloudStackMachineDataDisk{{CustomSize: 10}}
+			Expect(k8sClient.Create(ctx, dummies.CSMachineTemplate1)).
+				Should(MatchError(MatchRegexp(requiredRegex, "an ID or a name is required")))
+		})
 	})
 
 	Context("When updating a CloudStackMachineTemplate", func() {
@@ -96,5 +116,33 @@ var _ = Describe("CloudStackMachineTemplate webhook", func() {
 			dummies.CSMachineTemplate1.Spec.Template.Spec.AffinityGroupIDs = []string{"28b907b8-75a7-4214-bd3d-6c61961fc2ag"}
 			Ω(k8sClient.Update(ctx, dummies.CSMachineTemplate1)).ShouldNot(Succeed())
 		})
+
+		It("should reject updates to the IP address pools of the CloudStackMachineTemplate", func() {
+			dummies.CSMachineTemplate1.Spec.Template.Spec.IPAddressPools = []string{"workers"}
+			Ω(k8sClient.Update(ctx, dummies.CSMachineTemplate1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "ipAddressPools")))
+		})
+
+		It("should reject updates to the failure domain overrides of the CloudStackMachineTemplate", func() {
+			dummies.CSMachineTemplate1.Spec.Template.Spec.FailureDomainOverrides = map[string]infrav1.CloudStackMachineOverrides{
+				"fd2": {Template: infrav1.CloudStackResourceIdentifier{Name: "ubuntu-zone2"}},
+			}
+			Ω(k8sClient.Update(ctx, dummies.CSMachineTemplate1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "failureDomainOverrides")))
+		})
+
+		It("should reject updates to the root disk of the CloudStackMachineTemplate", func() {
+			dummies.CSMachineTemplate1.Spec.Template.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{SizeInGB: 50}
+			Ω(k8sClient.Update(ctx, dummies.CSMachineTemplate1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "rootDisk")))
+		})
+
+		It("should reject updates to the data disks of the CloudStackMachineTemplate", func() {
+			dummies.CSMachineTemplate1.Spec.Template.Spec.DataDisks = []infrav1.CloudStackMachineDataDisk{{
+				Offering: infrav1.CloudStackResourceIdentifier{Name: "Fast"},
+			}}
+			Ω(k8sClient.Update(ctx, dummies.CSMachineTemplate1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "dataDisks")))
+		})
 	})
 })
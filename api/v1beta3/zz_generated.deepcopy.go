@@ -25,6 +25,7 @@ import (
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/errors"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -33,7 +34,7 @@ func (in *CloudStackAffinityGroup) DeepCopyInto(out *CloudStackAffinityGroup) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackAffinityGroup.
@@ -104,6 +105,13 @@ func (in *CloudStackAffinityGroupSpec) DeepCopy() *CloudStackAffinityGroupSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudStackAffinityGroupStatus) DeepCopyInto(out *CloudStackAffinityGroupStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackAffinityGroupStatus.
@@ -211,6 +219,17 @@ func (in *CloudStackClusterStatus) DeepCopyInto(out *CloudStackClusterStatus) {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.QuotasCollectedAt != nil {
+		in, out := &in.QuotasCollectedAt, &out.QuotasCollectedAt
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackClusterStatus.
@@ -229,7 +248,7 @@ func (in *CloudStackFailureDomain) DeepCopyInto(out *CloudStackFailureDomain) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackFailureDomain.
@@ -302,6 +321,13 @@ func (in *CloudStackFailureDomainSpec) DeepCopy() *CloudStackFailureDomainSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudStackFailureDomainStatus) DeepCopyInto(out *CloudStackFailureDomainStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackFailureDomainStatus.
@@ -314,13 +340,127 @@ func (in *CloudStackFailureDomainStatus) DeepCopy() *CloudStackFailureDomainStat
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackIPAddressAllocation) DeepCopyInto(out *CloudStackIPAddressAllocation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIPAddressAllocation.
+func (in *CloudStackIPAddressAllocation) DeepCopy() *CloudStackIPAddressAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackIPAddressAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackIPAddressPool) DeepCopyInto(out *CloudStackIPAddressPool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIPAddressPool.
+func (in *CloudStackIPAddressPool) DeepCopy() *CloudStackIPAddressPool {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackIPAddressPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudStackIPAddressPool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackIPAddressPoolList) DeepCopyInto(out *CloudStackIPAddressPoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CloudStackIPAddressPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIPAddressPoolList.
+func (in *CloudStackIPAddressPoolList) DeepCopy() *CloudStackIPAddressPoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackIPAddressPoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudStackIPAddressPoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackIPAddressPoolSpec) DeepCopyInto(out *CloudStackIPAddressPoolSpec) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIPAddressPoolSpec.
+func (in *CloudStackIPAddressPoolSpec) DeepCopy() *CloudStackIPAddressPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackIPAddressPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackIPAddressPoolStatus) DeepCopyInto(out *CloudStackIPAddressPoolStatus) {
+	*out = *in
+	if in.Allocations != nil {
+		in, out := &in.Allocations, &out.Allocations
+		*out = make([]CloudStackIPAddressAllocation, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIPAddressPoolStatus.
+func (in *CloudStackIPAddressPoolStatus) DeepCopy() *CloudStackIPAddressPoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackIPAddressPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudStackIsolatedNetwork) DeepCopyInto(out *CloudStackIsolatedNetwork) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIsolatedNetwork.
@@ -392,6 +532,13 @@ func (in *CloudStackIsolatedNetworkSpec) DeepCopy() *CloudStackIsolatedNetworkSp
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudStackIsolatedNetworkStatus) DeepCopyInto(out *CloudStackIsolatedNetworkStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIsolatedNetworkStatus.
@@ -431,6 +578,22 @@ func (in *CloudStackMachine) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineDataDisk) DeepCopyInto(out *CloudStackMachineDataDisk) {
+	*out = *in
+	out.Offering = in.Offering
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineDataDisk.
+func (in *CloudStackMachineDataDisk) DeepCopy() *CloudStackMachineDataDisk {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineDataDisk)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudStackMachineList) DeepCopyInto(out *CloudStackMachineList) {
 	*out = *in
@@ -463,6 +626,66 @@ func (in *CloudStackMachineList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineNetwork) DeepCopyInto(out *CloudStackMachineNetwork) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineNetwork.
+func (in *CloudStackMachineNetwork) DeepCopy() *CloudStackMachineNetwork {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineNetwork)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineOverrides) DeepCopyInto(out *CloudStackMachineOverrides) {
+	*out = *in
+	out.Offering = in.Offering
+	out.Template = in.Template
+	if in.DiskOffering != nil {
+		in, out := &in.DiskOffering, &out.DiskOffering
+		*out = new(CloudStackResourceDiskOffering)
+		**out = **in
+	}
+	if in.Details != nil {
+		in, out := &in.Details, &out.Details
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineOverrides.
+func (in *CloudStackMachineOverrides) DeepCopy() *CloudStackMachineOverrides {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineOverrides)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineRootDisk) DeepCopyInto(out *CloudStackMachineRootDisk) {
+	*out = *in
+	out.Offering = in.Offering
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineRootDisk.
+func (in *CloudStackMachineRootDisk) DeepCopy() *CloudStackMachineRootDisk {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineRootDisk)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudStackMachineSpec) DeepCopyInto(out *CloudStackMachineSpec) {
 	*out = *in
@@ -474,6 +697,12 @@ func (in *CloudStackMachineSpec) DeepCopyInto(out *CloudStackMachineSpec) {
 	out.Offering = in.Offering
 	out.Template = in.Template
 	out.DiskOffering = in.DiskOffering
+	out.RootDisk = in.RootDisk
+	if in.DataDisks != nil {
+		in, out := &in.DataDisks, &out.DataDisks
+		*out = make([]CloudStackMachineDataDisk, len(*in))
+		copy(*out, *in)
+	}
 	if in.Details != nil {
 		in, out := &in.Details, &out.Details
 		*out = make(map[string]string, len(*in))
@@ -481,6 +710,13 @@ func (in *CloudStackMachineSpec) DeepCopyInto(out *CloudStackMachineSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.FailureDomainOverrides != nil {
+		in, out := &in.FailureDomainOverrides, &out.FailureDomainOverrides
+		*out = make(map[string]CloudStackMachineOverrides, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 	if in.AffinityGroupIDs != nil {
 		in, out := &in.AffinityGroupIDs, &out.AffinityGroupIDs
 		*out = make([]string, len(*in))
@@ -501,6 +737,16 @@ func (in *CloudStackMachineSpec) DeepCopyInto(out *CloudStackMachineSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.AdditionalNetworks != nil {
+		in, out := &in.AdditionalNetworks, &out.AdditionalNetworks
+		*out = make([]CloudStackMachineNetwork, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPAddressPools != nil {
+		in, out := &in.IPAddressPools, &out.IPAddressPools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineSpec.
@@ -611,6 +857,11 @@ func (in *CloudStackMachineStatus) DeepCopyInto(out *CloudStackMachineStatus) {
 		copy(*out, *in)
 	}
 	in.InstanceStateLastUpdated.DeepCopyInto(&out.InstanceStateLastUpdated)
+	if in.DeployJobID != nil {
+		in, out := &in.DeployJobID, &out.DeployJobID
+		*out = new(string)
+		**out = **in
+	}
 	if in.Status != nil {
 		in, out := &in.Status, &out.Status
 		*out = new(string)
@@ -621,6 +872,23 @@ func (in *CloudStackMachineStatus) DeepCopyInto(out *CloudStackMachineStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.FailureReason != nil {
+		in, out := &in.FailureReason, &out.FailureReason
+		*out = new(errors.MachineStatusError)
+		**out = **in
+	}
+	if in.FailureMessage != nil {
+		in, out := &in.FailureMessage, &out.FailureMessage
+		*out = new(string)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineStatus.
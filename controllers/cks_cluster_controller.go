@@ -19,13 +19,14 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"strings"
 
+	"github.com/pkg/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
 	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 )
 
 const CksClusterFinalizer = "ckscluster.infrastructure.cluster.x-k8s.io"
@@ -99,7 +100,7 @@ func (r *CksClusterReconciliationRunner) ReconcileDelete() (ctrl.Result, error)
 			return res, err
 		}
 		err = r.CSUser.DeleteCksCluster(r.ReconciliationSubject)
-		if err != nil && !strings.Contains(err.Error(), " not found") {
+		if err != nil && !errors.Is(err, cloud.ErrNotFound) {
 			return r.RequeueWithMessage(fmt.Sprintf("Deleting cks cluster on CloudStack failed. error: %s", err.Error()))
 		}
 	}
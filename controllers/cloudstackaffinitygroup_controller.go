@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -53,6 +54,7 @@ func NewCSAGReconciliationRunner() *CloudStackAGReconciliationRunner {
 	r.FailureDomain = &infrav1.CloudStackFailureDomain{}
 	// Setup the base runner. Initializes pointers and links reconciliation methods.
 	r.ReconciliationRunner = csCtrlrUtils.NewRunner(r, r.ReconciliationSubject, "CloudStackAffinityGroup")
+	r.ReadyConditions = []clusterv1.ConditionType{infrav1.AffinityGroupReadyCondition}
 	return r
 }
 
@@ -68,7 +70,10 @@ func (reconciler *CloudStackAffinityGroupReconciler) Reconcile(ctx context.Conte
 func (r *CloudStackAGReconciliationRunner) Reconcile() (ctrl.Result, error) {
 	controllerutil.AddFinalizer(r.ReconciliationSubject, infrav1.AffinityGroupFinalizer)
 	affinityGroup := &cloud.AffinityGroup{Name: r.ReconciliationSubject.Spec.Name, Type: r.ReconciliationSubject.Spec.Type}
-	if err := r.CSUser.GetOrCreateAffinityGroup(affinityGroup); err != nil {
+	err := r.CSUser.GetOrCreateAffinityGroup(affinityGroup)
+	csCtrlrUtils.MarkConditionFromError(r.ReconciliationSubject, infrav1.AffinityGroupReadyCondition,
+		infrav1.AffinityGroupProvisioningFailedReason, err)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
 	r.ReconciliationSubject.Spec.ID = affinityGroup.ID
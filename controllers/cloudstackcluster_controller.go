@@ -21,12 +21,15 @@ import (
 	"fmt"
 	"reflect"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/pkg/errors"
@@ -34,6 +37,7 @@ import (
 	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/predicates"
 )
 
@@ -55,12 +59,14 @@ type CloudStackClusterReconciliationRunner struct {
 	*csCtrlrUtils.ReconciliationRunner
 	FailureDomains        *infrav1.CloudStackFailureDomainList
 	ReconciliationSubject *infrav1.CloudStackCluster
+	QuotaCollection       csCtrlrUtils.QuotaCollectionOptions
 }
 
 // CloudStackClusterReconciler is the k8s controller manager's interface to reconcile a CloudStackCluster.
 // This is primarily to adapt to k8s.
 type CloudStackClusterReconciler struct {
 	csCtrlrUtils.ReconcilerBase
+	QuotaCollection csCtrlrUtils.QuotaCollectionOptions
 }
 
 // Initialize a new CloudStackCluster reconciliation runner with concrete types and initialized member fields.
@@ -70,6 +76,8 @@ func NewCSClusterReconciliationRunner() *CloudStackClusterReconciliationRunner {
 	r.FailureDomains = &infrav1.CloudStackFailureDomainList{}
 	// Setup the base runner. Initializes pointers and links reconciliation methods.
 	r.ReconciliationRunner = csCtrlrUtils.NewRunner(r, r.ReconciliationSubject, "CloudStackCluster")
+	r.ReadyConditions = []clusterv1.ConditionType{infrav1.FailureDomainsReadyCondition}
+	r.OwnedConditions = []clusterv1.ConditionType{infrav1.QuotaHeadroomCondition, infrav1.QuotaSufficientCondition}
 	// For the CloudStackCluster, the ReconciliationSubject is the CSCluster
 	// Have to do after or the setup method will overwrite the link.
 	r.CSCluster = r.ReconciliationSubject
@@ -79,7 +87,9 @@ func NewCSClusterReconciliationRunner() *CloudStackClusterReconciliationRunner {
 
 // Reconcile is the method k8s will call upon a reconciliation request.
 func (reconciler *CloudStackClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (retRes ctrl.Result, retErr error) {
-	return NewCSClusterReconciliationRunner().
+	r := NewCSClusterReconciliationRunner()
+	r.QuotaCollection = reconciler.QuotaCollection
+	return r.
 		UsingBaseReconciler(reconciler.ReconcilerBase).
 		ForRequest(req).
 		WithRequestCtx(ctx).
@@ -94,7 +104,8 @@ func (r *CloudStackClusterReconciliationRunner) Reconcile() (res ctrl.Result, re
 		r.GetFailureDomains(r.FailureDomains),
 		r.RemoveExtraneousFailureDomains(r.FailureDomains),
 		r.VerifyFailureDomainCRDs,
-		r.SetReady)
+		r.SetReady,
+		r.CollectResourceQuotas(r.FailureDomains, r.QuotaCollection))
 }
 
 // SetReady adds a finalizer and sets the cluster status to ready.
@@ -113,15 +124,20 @@ func (r *CloudStackClusterReconciliationRunner) VerifyFailureDomainCRDs() (ctrl.
 			if requiredFdSpec.Name == fd.Spec.Name {
 				found = true
 				if !fd.Status.Ready {
+					conditions.MarkFalse(r.ReconciliationSubject, infrav1.FailureDomainsReadyCondition,
+						infrav1.WaitingForFailureDomainsReason, clusterv1.ConditionSeverityInfo, "Failure domain %s is not ready", fd.Spec.Name)
 					return r.RequeueWithMessage(fmt.Sprintf("Required FailureDomain %s not ready, requeueing.", fd.Spec.Name))
 				}
 				break
 			}
 		}
 		if !found {
+			conditions.MarkFalse(r.ReconciliationSubject, infrav1.FailureDomainsReadyCondition,
+				infrav1.WaitingForFailureDomainsReason, clusterv1.ConditionSeverityInfo, "Failure domain %s does not exist yet", requiredFdSpec.Name)
 			return r.RequeueWithMessage(fmt.Sprintf("Required FailureDomain %s not found, requeueing.", requiredFdSpec.Name))
 		}
 	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.FailureDomainsReadyCondition)
 	return ctrl.Result{}, nil
 }
 
@@ -151,6 +167,9 @@ func (r *CloudStackClusterReconciliationRunner) ReconcileDelete() (ctrl.Result,
 		}
 		return r.RequeueWithMessage("Child FailureDomains still present, requeueing.")
 	}
+	clusterName := r.ReconciliationSubject.GetLabels()[clusterv1.ClusterNameLabel]
+	csCtrlrUtils.QuotaMetrics.DeleteResourceQuotas(r.ReconciliationSubject.Namespace, clusterName, "")
+	csCtrlrUtils.MachineMetrics.DeleteMachinesByInstanceState(r.ReconciliationSubject.Namespace, clusterName)
 	controllerutil.RemoveFinalizer(r.ReconciliationSubject, infrav1.ClusterFinalizer)
 	return ctrl.Result{}, nil
 }
@@ -198,5 +217,37 @@ func (reconciler *CloudStackClusterReconciler) SetupWithManager(ctx context.Cont
 		return errors.Wrap(err, "building CloudStackCluster controller")
 	}
 
+	// Add a watch on new CloudStackMachines to check the quotas of their cluster before they're deployed.
+	if reconciler.QuotaCollection.Interval > 0 {
+		if err = controller.Watch(
+			&source.Kind{Type: &infrav1.CloudStackMachine{}},
+			handler.EnqueueRequestsFromMapFunc(reconciler.machineToCloudStackCluster(ctx)),
+			predicate.Funcs{
+				UpdateFunc:  func(event.UpdateEvent) bool { return false },
+				DeleteFunc:  func(event.DeleteEvent) bool { return false },
+				GenericFunc: func(event.GenericEvent) bool { return false },
+			},
+		); err != nil {
+			return errors.Wrap(err, "building CloudStackCluster controller")
+		}
+	}
+
 	return nil
 }
+
+// machineToCloudStackCluster maps a CloudStackMachine to a reconciliation request for the CloudStackCluster of its
+// CAPI cluster.
+func (reconciler *CloudStackClusterReconciler) machineToCloudStackCluster(ctx context.Context) handler.MapFunc {
+	return func(o client.Object) []reconcile.Request {
+		capiCluster, err := util.GetClusterFromMetadata(ctx, reconciler.K8sClient, metav1.ObjectMeta{
+			Namespace: o.GetNamespace(), Labels: o.GetLabels(),
+		})
+		if err != nil || capiCluster.Spec.InfrastructureRef == nil {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: client.ObjectKey{
+			Namespace: capiCluster.Namespace,
+			Name:      capiCluster.Spec.InfrastructureRef.Name,
+		}}}
+	}
+}
@@ -17,12 +17,19 @@ limitations under the License.
 package controllers_test
 
 import (
+	"strings"
+	"time"
+
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers"
+	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 )
@@ -49,6 +56,46 @@ var _ = Describe("CloudStackClusterReconciler", func() {
 		})
 	})
 
+	Context("With a fake ctrlRuntimeClient and no test Env at all.", func() {
+		BeforeEach(func() {
+			setupFakeTestClient()
+			ClusterReconciler.QuotaCollection = csCtrlrUtils.QuotaCollectionOptions{Interval: time.Minute, HeadroomThreshold: 20}
+		})
+
+		It("Should persist the QuotaHeadroom condition and raise the LowQuotaHeadroom event once.", func() {
+			for _, fd := range []*infrav1.CloudStackFailureDomain{dummies.CSFailureDomain1, dummies.CSFailureDomain2} {
+				fd.Status.Ready = true
+				Ω(fakeCtrlClient.Create(ctx, fd)).Should(Succeed())
+			}
+			// Read once per failure domain, the second reconciliation coming before the interval is up.
+			mockCloudClient.EXPECT().GetResourceQuotas().Return([]cloud.ResourceQuota{
+				{Scope: "account", Name: "admin", Resource: "cpu", Limit: 10, Used: 9, Available: 1},
+			}, nil).Times(len(dummies.CSCluster.Spec.FailureDomains))
+
+			request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(dummies.CSCluster)}
+			res, err := ClusterReconciler.Reconcile(ctx, request)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).Should(Equal(time.Minute))
+			res, err = ClusterReconciler.Reconcile(ctx, request)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).Should(And(BeNumerically(">", 0), BeNumerically("<", time.Minute)))
+
+			csCluster := &infrav1.CloudStackCluster{}
+			Ω(fakeCtrlClient.Get(ctx, request.NamespacedName, csCluster)).Should(Succeed())
+			Ω(conditions.IsFalse(csCluster, infrav1.QuotaHeadroomCondition)).Should(BeTrue())
+			Ω(conditions.GetReason(csCluster, infrav1.QuotaHeadroomCondition)).Should(Equal(infrav1.LowQuotaHeadroomReason))
+			Ω(conditions.IsTrue(csCluster, infrav1.QuotaSufficientCondition)).Should(BeTrue())
+
+			lowHeadroomEvents := 0
+			for len(fakeRecorder.Events) > 0 {
+				if strings.Contains(<-fakeRecorder.Events, infrav1.LowQuotaHeadroomReason) {
+					lowHeadroomEvents++
+				}
+			}
+			Ω(lowHeadroomEvents).Should(Equal(1))
+		})
+	})
+
 	Context("Without a k8s test environment.", func() {
 		It("Should create a reconciliation runner with a Cloudstack Cluster as the reconciliation subject.", func() {
 			reconRunenr := controllers.NewCSClusterReconciliationRunner()
@@ -18,19 +18,30 @@ package controllers
 
 import (
 	"context"
+	"reflect"
 	"sort"
 
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
 	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 )
 
 const (
@@ -38,6 +49,9 @@ const (
 	conditionStatusFalse = "False"
 )
 
+// acsEndpointIndex indexes failure domains by the namespace/name of their ACS endpoint secret.
+const acsEndpointIndex = "spec.acsEndpoint"
+
 // CloudStackFailureDomainReconciler is the k8s controller manager's interface to reconcile a CloudStackFailureDomain.
 // This is primarily to adapt to k8s.
 type CloudStackFailureDomainReconciler struct {
@@ -47,6 +61,7 @@ type CloudStackFailureDomainReconciler struct {
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackfailuredomains,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackfailuredomains/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackfailuredomains/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinesets,verbs=get;list;watch
 //+kubebuilder:rbac:groups=etcdcluster.cluster.x-k8s.io,resources=etcdadmclusters,verbs=get;list;watch
 //+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=kubeadmcontrolplanes,verbs=get;list;watch
@@ -70,6 +85,8 @@ func NewCSFailureDomainReconciliationRunner() *CloudStackFailureDomainReconcilia
 	r.IsoNet = &infrav1.CloudStackIsolatedNetwork{}
 	// Setup the base runner. Initializes pointers and links reconciliation methods.
 	r.ReconciliationRunner = csCtrlrUtils.NewRunner(r, r.ReconciliationSubject, "CloudStackFailureDomain")
+	r.ReadyConditions = []clusterv1.ConditionType{
+		infrav1.CredentialsValidCondition, infrav1.ZoneResolvedCondition, infrav1.NetworkReadyCondition}
 
 	return r
 }
@@ -86,6 +103,7 @@ func (reconciler *CloudStackFailureDomainReconciler) Reconcile(ctx context.Conte
 // Reconcile on the ReconciliationRunner actually attempts to modify or create the reconciliation subject.
 func (r *CloudStackFailureDomainReconciliationRunner) Reconcile() (retRes ctrl.Result, retErr error) {
 	res, err := r.AsFailureDomainUser(&r.ReconciliationSubject.Spec)()
+	r.SetCredentialsValidCondition(err)
 	if r.ShouldReturn(res, err) {
 		return res, err
 	}
@@ -93,11 +111,19 @@ func (r *CloudStackFailureDomainReconciliationRunner) Reconcile() (retRes ctrl.R
 	controllerutil.AddFinalizer(r.ReconciliationSubject, infrav1.FailureDomainFinalizer)
 
 	// Start by purely data fetching information about the zone and specified network.
-	if err := r.CSUser.ResolveZone(&r.ReconciliationSubject.Spec.Zone); err != nil {
+	err = r.CSUser.ResolveZone(&r.ReconciliationSubject.Spec.Zone)
+	// Report the management server in use whether it could resolve the zone or not.
+	r.ReconciliationSubject.Status.ActiveAPIURL = r.CSUser.ActiveAPIURL()
+	csCtrlrUtils.MarkConditionFromError(r.ReconciliationSubject, infrav1.ZoneResolvedCondition, infrav1.ZoneResolutionFailedReason, err)
+	if err != nil {
+		if errors.Is(err, cloud.ErrPermissionDenied) { // The client may have been cached before the keys were revoked.
+			r.SetCredentialsValidCondition(err)
+		}
 		return ctrl.Result{}, errors.Wrap(err, "resolving CloudStack zone information")
 	}
 	if err := r.CSUser.ResolveNetworkForZone(&r.ReconciliationSubject.Spec.Zone); err != nil &&
-		!csCtrlrUtils.ContainsNoMatchSubstring(err) {
+		!errors.Is(err, cloud.ErrNotFound) {
+		csCtrlrUtils.MarkConditionFromError(r.ReconciliationSubject, infrav1.NetworkReadyCondition, infrav1.NetworkResolutionFailedReason, err)
 		return ctrl.Result{}, errors.Wrap(err, "resolving Cloudstack network information")
 	}
 
@@ -113,16 +139,43 @@ func (r *CloudStackFailureDomainReconciliationRunner) Reconcile() (retRes ctrl.R
 			return res, err
 		}
 		if r.IsoNet.Name == "" {
+			conditions.MarkFalse(r.ReconciliationSubject, infrav1.NetworkReadyCondition, infrav1.WaitingForIsolatedNetworkReason,
+				clusterv1.ConditionSeverityInfo, "Isolated network %s does not exist yet", netName)
 			return r.RequeueWithMessage("Couldn't find isolated network.")
 		}
 		if !r.IsoNet.Status.Ready {
+			conditions.MarkFalse(r.ReconciliationSubject, infrav1.NetworkReadyCondition, infrav1.WaitingForIsolatedNetworkReason,
+				clusterv1.ConditionSeverityInfo, "Isolated network %s is not ready", netName)
 			return r.RequeueWithMessage("Isolated network dependency not ready.")
 		}
 	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.NetworkReadyCondition)
 	r.ReconciliationSubject.Status.Ready = true
 	return ctrl.Result{}, nil
 }
 
+// SetCredentialsValidCondition sets the CredentialsValid condition from the outcome of setting up the failure domain's
+// CloudStack client.
+func (r *CloudStackFailureDomainReconciliationRunner) SetCredentialsValidCondition(err error) {
+	switch {
+	case err == nil:
+		conditions.MarkTrue(r.ReconciliationSubject, infrav1.CredentialsValidCondition)
+	case errors.Is(err, cloud.ErrPermissionDenied):
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.CredentialsValidCondition,
+			infrav1.InvalidCredentialsReason, clusterv1.ConditionSeverityError, "%s", err.Error())
+	case apierrors.IsNotFound(err):
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.CredentialsValidCondition,
+			infrav1.EndpointSecretUnavailableReason, clusterv1.ConditionSeverityError, "%s", err.Error())
+	case errors.Is(err, cloud.ErrTransient) || errors.Is(err, cloud.ErrThrottled):
+		// Says nothing about the credentials.
+		conditions.MarkUnknown(r.ReconciliationSubject, infrav1.CredentialsValidCondition,
+			infrav1.CredentialsCheckFailedReason, "%s", err.Error())
+	default:
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.CredentialsValidCondition,
+			infrav1.CredentialsCheckFailedReason, clusterv1.ConditionSeverityWarning, "%s", err.Error())
+	}
+}
+
 // ReconcileDelete on the ReconciliationRunner attempts to delete the reconciliation subject.
 func (r *CloudStackFailureDomainReconciliationRunner) ReconcileDelete() (ctrl.Result, error) {
 	r.Log.Info("Deleting CloudStackFailureDomain")
@@ -266,8 +319,45 @@ func (r *CloudStackFailureDomainReconciliationRunner) RemoveFinalizer() (ctrl.Re
 
 // SetupWithManager sets up the controller with the Manager.
 func (reconciler *CloudStackFailureDomainReconciler) SetupWithManager(mgr ctrl.Manager, opts controller.Options) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &infrav1.CloudStackFailureDomain{}, acsEndpointIndex,
+		func(o client.Object) []string {
+			endpoint := o.(*infrav1.CloudStackFailureDomain).Spec.ACSEndpoint
+			return []string{endpoint.Namespace + "/" + endpoint.Name}
+		}); err != nil {
+		return errors.Wrap(err, "indexing CloudStackFailureDomains by ACS endpoint")
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		WithOptions(opts).
 		For(&infrav1.CloudStackFailureDomain{}).
+		// Reconcile failure domains when their ACS endpoint secret changes, e.g. to pick up rotated credentials.
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(reconciler.secretToFailureDomains),
+			builder.WithPredicates(secretDataChanged())).
 		Complete(reconciler)
 }
+
+// secretDataChanged filters out updates of secrets that leave their data as it is, e.g. label or annotation changes.
+func secretDataChanged() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldSecret, okOld := e.ObjectOld.(*corev1.Secret)
+			newSecret, okNew := e.ObjectNew.(*corev1.Secret)
+			return !okOld || !okNew || !reflect.DeepEqual(oldSecret.Data, newSecret.Data)
+		},
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+}
+
+// secretToFailureDomains maps an ACS endpoint secret to reconcile requests for the failure domains referring to it.
+func (reconciler *CloudStackFailureDomainReconciler) secretToFailureDomains(o client.Object) []reconcile.Request {
+	fds := &infrav1.CloudStackFailureDomainList{}
+	if err := reconciler.K8sClient.List(context.TODO(), fds,
+		client.MatchingFields{acsEndpointIndex: o.GetNamespace() + "/" + o.GetName()}); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(fds.Items))
+	for _, fd := range fds.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Namespace: fd.Namespace, Name: fd.Name}})
+	}
+	return requests
+}
@@ -26,6 +26,7 @@ import (
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -69,6 +70,30 @@ var _ = Describe("CloudStackFailureDomainReconciler", func() {
 			}, timeout).WithPolling(pollInterval).Should(BeTrue())
 		})
 
+		It("Should report the failure domain's credentials as valid.", func() {
+			Eventually(func() bool {
+				tempfd := &infrav1.CloudStackFailureDomain{}
+				key := client.ObjectKeyFromObject(dummies.CSFailureDomain1)
+				if err := k8sClient.Get(ctx, key, tempfd); err != nil {
+					return false
+				}
+				return conditions.IsTrue(tempfd, infrav1.CredentialsValidCondition)
+			}, timeout).WithPolling(pollInterval).Should(BeTrue())
+		})
+
+		It("Should summarize the failure domain's conditions in its Ready condition.", func() {
+			Eventually(func() bool {
+				tempfd := &infrav1.CloudStackFailureDomain{}
+				key := client.ObjectKeyFromObject(dummies.CSFailureDomain1)
+				if err := k8sClient.Get(ctx, key, tempfd); err != nil {
+					return false
+				}
+				return conditions.IsTrue(tempfd, infrav1.ZoneResolvedCondition) &&
+					conditions.IsTrue(tempfd, infrav1.NetworkReadyCondition) &&
+					conditions.IsTrue(tempfd, clusterv1.ReadyCondition)
+			}, timeout).WithPolling(pollInterval).Should(BeTrue())
+		})
+
 		DescribeTable("Should function in different replicas conditions",
 			func(shouldDeleteVM bool, specReplicas, statusReplicas, statusReadyReplicas *int32, statusReady *bool, controlPlaneReady bool) {
 				Eventually(func() bool {
@@ -18,8 +18,9 @@ package controllers
 
 import (
 	"context"
-	"strings"
 
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -53,6 +54,7 @@ func NewCSIsoNetReconciliationRunner() *CloudStackIsoNetReconciliationRunner {
 	r.FailureDomain = &infrav1.CloudStackFailureDomain{}
 	// Setup the base runner. Initializes pointers and links reconciliation methods.
 	r.ReconciliationRunner = csCtrlrUtils.NewRunner(r, r.ReconciliationSubject, "CloudStackIsolatedNetwork")
+	r.ReadyConditions = []clusterv1.ConditionType{infrav1.NetworkReadyCondition}
 	return r
 }
 
@@ -76,15 +78,21 @@ func (r *CloudStackIsoNetReconciliationRunner) Reconcile() (retRes ctrl.Result,
 		return r.ReturnWrappedError(retErr, "setting up CloudStackCluster patcher")
 	}
 	if r.FailureDomain.Spec.Zone.ID == "" {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.NetworkReadyCondition, infrav1.WaitingForZoneReason,
+			clusterv1.ConditionSeverityInfo, "Zone of failure domain %s is not resolved yet", r.FailureDomain.Spec.Name)
 		return r.RequeueWithMessage("Zone ID not resolved yet.")
 	}
 	if err := r.CSUser.GetOrCreateIsolatedNetwork(r.FailureDomain, r.ReconciliationSubject, r.CSCluster); err != nil {
+		csCtrlrUtils.MarkConditionFromError(r.ReconciliationSubject, infrav1.NetworkReadyCondition, infrav1.NetworkProvisioningFailedReason, err)
 		return ctrl.Result{}, err
 	}
 	// Tag the created network.
 	if err := r.CSUser.AddClusterTag(cloud.ResourceTypeNetwork, r.ReconciliationSubject.Spec.ID, r.CSCluster); err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "tagging network with id %s", r.ReconciliationSubject.Spec.ID)
+		err = errors.Wrapf(err, "tagging network with id %s", r.ReconciliationSubject.Spec.ID)
+		csCtrlrUtils.MarkConditionFromError(r.ReconciliationSubject, infrav1.NetworkReadyCondition, infrav1.NetworkProvisioningFailedReason, err)
+		return ctrl.Result{}, err
 	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.NetworkReadyCondition)
 	if err := csClusterPatcher.Patch(r.RequestCtx, r.CSCluster); err != nil {
 		return r.ReturnWrappedError(err, "patching endpoint update to CloudStackCluster")
 	}
@@ -96,7 +104,7 @@ func (r *CloudStackIsoNetReconciliationRunner) Reconcile() (retRes ctrl.Result,
 func (r *CloudStackIsoNetReconciliationRunner) ReconcileDelete() (retRes ctrl.Result, retErr error) {
 	r.Log.Info("Deleting IsolatedNetwork.")
 	if err := r.CSUser.DisposeIsoNetResources(r.FailureDomain, r.ReconciliationSubject, r.CSCluster); err != nil {
-		if !strings.Contains(strings.ToLower(err.Error()), "no match found") {
+		if !errors.Is(err, cloud.ErrNotFound) {
 			return ctrl.Result{}, err
 		}
 	}
@@ -28,9 +28,11 @@ import (
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -45,6 +47,7 @@ import (
 	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
 var (
@@ -59,6 +62,8 @@ const (
 	MachineInstanceRunning                     = "Machine instance is Running..."
 	MachineInErrorMessage                      = "CloudStackMachine VM in error state. Deleting associated Machine"
 	MachineNotReadyMessage                     = "Instance not ready, is %s"
+	MachineFailedMessage                       = "CloudStackMachine failed terminally, waiting for it to be replaced"
+	DeployJobPendingMessage                    = "CloudStack instance deployment still running."
 	CSMachineStateCheckerCreationFailed        = "error encountered when creating CloudStackMachineStateChecker"
 	CSMachineStateCheckerCreationSuccess       = "CloudStackMachineStateChecker created"
 	CSMachineDeletionMessage                   = "Deleting CloudStack Machine %s"
@@ -68,6 +73,8 @@ const (
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachines,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachines/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachines/finalizers,verbs=update
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackipaddresspools,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackipaddresspools/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinesets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=kubeadmcontrolplanes,verbs=get;list;watch
@@ -100,6 +107,8 @@ func NewCSMachineReconciliationRunner() *CloudStackMachineReconciliationRunner {
 	r.FailureDomain = &infrav1.CloudStackFailureDomain{}
 	// Setup the base runner. Initializes pointers and links reconciliation methods.
 	r.ReconciliationRunner = utils.NewRunner(r, r.ReconciliationSubject, "CloudStackMachine")
+	r.ReadyConditions = []clusterv1.ConditionType{
+		infrav1.InstanceProvisionedCondition, infrav1.AffinityGroupReadyCondition, infrav1.LoadBalancerAttachedCondition}
 	return r
 }
 
@@ -114,17 +123,21 @@ func (reconciler *CloudStackMachineReconciler) Reconcile(ctx context.Context, re
 		r.SetFailureDomainOnCSMachine,
 		r.GetFailureDomainByName(func() string { return r.ReconciliationSubject.Spec.FailureDomainName }, r.FailureDomain),
 		r.AsFailureDomainUser(&r.FailureDomain.Spec))
-	return r.RunBaseReconciliationStages()
+	res, retErr = r.RunBaseReconciliationStages()
+	r.RecordMachineInstanceStates(r.ReconciliationSubject)
+	return res, retErr
 }
 
 func (r *CloudStackMachineReconciliationRunner) Reconcile() (retRes ctrl.Result, reterr error) {
 	return r.RunReconciliationStages(
+		r.StopIfFailed,
 		r.DeleteMachineIfFailuredomainNotExist,
 		r.GetObjectByName("placeholder", r.IsoNet,
 			func() string { return r.IsoNetMetaName(r.FailureDomain.Spec.Zone.Network.Name) }),
 		r.RunIf(func() bool { return r.FailureDomain.Spec.Zone.Network.Type == cloud.NetworkTypeIsolated },
 			r.CheckPresent(map[string]client.Object{"CloudStackIsolatedNetwork": r.IsoNet})),
 		r.ConsiderAffinity,
+		r.ClaimIPAddress(r.ReconciliationSubject),
 		r.GetOrCreateVMInstance,
 		r.RequeueIfInstanceNotRunning,
 		r.AddToLBIfNeeded,
@@ -166,12 +179,26 @@ func (r *CloudStackMachineReconciliationRunner) ConsiderAffinity() (ctrl.Result,
 		Namespace: r.AffinityGroup.Namespace,
 	}
 	if !r.AffinityGroup.Status.Ready {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.AffinityGroupReadyCondition, infrav1.WaitingForAffinityGroupReason,
+			clusterv1.ConditionSeverityInfo, "Affinity group %s is not ready", r.AffinityGroup.Name)
 		return r.RequeueWithMessage("Required affinity group not ready.")
 	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.AffinityGroupReadyCondition)
 
 	return ctrl.Result{}, nil
 }
 
+// StopIfFailed stops the reconciliation of a machine that failed terminally. CAPI remediates such machines by replacing
+// them.
+func (r *CloudStackMachineReconciliationRunner) StopIfFailed() (ctrl.Result, error) {
+	if r.ReconciliationSubject.Status.FailureReason != nil {
+		r.Log.Info(MachineFailedMessage, "reason", *r.ReconciliationSubject.Status.FailureReason,
+			"message", pointer.StringDeref(r.ReconciliationSubject.Status.FailureMessage, ""))
+		r.SetReturnEarly()
+	}
+	return ctrl.Result{}, nil
+}
+
 // SetFailureDomainOnCSMachine sets the failure domain the machine should launch in.
 func (r *CloudStackMachineReconciliationRunner) SetFailureDomainOnCSMachine() (retRes ctrl.Result, reterr error) {
 	if r.ReconciliationSubject.Spec.FailureDomainName == "" {
@@ -222,6 +249,8 @@ func (r *CloudStackMachineReconciliationRunner) DeleteMachineIfFailuredomainNotE
 func (r *CloudStackMachineReconciliationRunner) GetOrCreateVMInstance() (retRes ctrl.Result, reterr error) {
 	if r.CAPIMachine.Spec.Bootstrap.DataSecretName == nil {
 		r.Recorder.Event(r.ReconciliationSubject, "Normal", "Creating", BootstrapDataNotReady)
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition, infrav1.WaitingForBootstrapDataReason,
+			clusterv1.ConditionSeverityInfo, BootstrapDataNotReady)
 		return r.RequeueWithMessage(BootstrapDataNotReady + ".")
 	}
 	r.Log.Info("Got Bootstrap DataSecretName.")
@@ -238,15 +267,44 @@ func (r *CloudStackMachineReconciliationRunner) GetOrCreateVMInstance() (retRes
 	}
 
 	userData := processCustomMetadata(data, r)
+	hadInstance := r.ReconciliationSubject.Spec.InstanceID != nil
+	wasDeploying := r.ReconciliationSubject.Status.DeployJobID != nil
+	wasRunning := r.ReconciliationSubject.Status.InstanceState == "Running"
 	err := r.CSUser.GetOrCreateVMInstance(r.ReconciliationSubject, r.CAPIMachine, r.CSCluster, r.FailureDomain, r.AffinityGroup, userData)
-	if err != nil {
+	if r.ReconciliationSubject.Spec.InstanceID != nil || r.ReconciliationSubject.Status.DeployJobID != nil {
+		// Adding a finalizer will make reconcile-delete wait for the VM's deployment and destroy the VM through its
+		// instanceID. Machines without a VM or a deployment don't get one, so that reconcile-delete isn't stuck looking
+		// for a VM that was never created.
+		controllerutil.AddFinalizer(r.ReconciliationSubject, infrav1.MachineFinalizer)
+	}
+	if errors.Is(err, cloud.ErrJobPending) {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition, infrav1.WaitingForDeployJobReason,
+			clusterv1.ConditionSeverityInfo, "Deploy job %s is running", pointer.StringDeref(r.ReconciliationSubject.Status.DeployJobID, ""))
+		return r.RequeueWithMessage(DeployJobPendingMessage, "jobID", pointer.StringDeref(r.ReconciliationSubject.Status.DeployJobID, ""))
+	} else if err != nil {
 		r.Recorder.Eventf(r.ReconciliationSubject, "Warning", "Creating", CSMachineCreationFailed, err.Error())
+		reason := infrav1.InstanceProvisioningFailedReason
+		if errors.Is(err, cloud.ErrLimitExceeded) {
+			reason = infrav1.InsufficientQuotaReason
+		}
+		utils.MarkConditionFromError(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition, reason, err)
+		if errors.Is(err, cloud.ErrInvalidSpec) && r.ReconciliationSubject.Spec.InstanceID == nil {
+			// Retrying won't help, so the failure is reported to CAPI for the machine to be remediated. Machines whose
+			// VM was created anyway keep being reconciled so that the VM gets cleaned up.
+			r.ReconciliationSubject.Status.FailureReason = capierrors.MachineStatusErrorPtr(capierrors.InvalidConfigurationMachineError)
+			r.ReconciliationSubject.Status.FailureMessage = pointer.String(err.Error())
+			r.Log.Error(err, MachineFailedMessage)
+			r.SetReturnEarly()
+			return ctrl.Result{}, nil
+		}
 	}
-	if err == nil && !controllerutil.ContainsFinalizer(r.ReconciliationSubject, infrav1.MachineFinalizer) { // Fetched or Created?
-		// Adding a finalizer will make reconcile-delete try to destroy the associated VM through instanceID.
-		// If err is not nil, it means CAPC could not get an associated VM through instanceID or name, so we should not add a finalizer to this CloudStackMachine,
-		// Otherwise, reconcile-delete will be stuck trying to wait for instanceID to be available.
-		controllerutil.AddFinalizer(r.ReconciliationSubject, infrav1.MachineFinalizer)
+	if !hadInstance && r.ReconciliationSubject.Spec.InstanceID != nil {
+		utils.ObserveMachineProvisioning(r.ReconciliationSubject, utils.InstanceCreated)
+	}
+	if !wasRunning && r.ReconciliationSubject.Status.InstanceState == "Running" && !r.ReconciliationSubject.Status.Ready {
+		utils.ObserveMachineProvisioning(r.ReconciliationSubject, utils.InstanceRunning)
+	}
+	if err == nil && (!hadInstance || wasDeploying) { // Fetched or Created?
 		r.Recorder.Eventf(r.ReconciliationSubject, "Normal", "Created", CSMachineCreationSuccess)
 		r.Log.Info(CSMachineCreationSuccess, "instanceStatus", r.ReconciliationSubject.Status)
 	}
@@ -267,9 +325,15 @@ func (r *CloudStackMachineReconciliationRunner) RequeueIfInstanceNotRunning() (r
 	if r.ReconciliationSubject.Status.InstanceState == "Running" {
 		r.Recorder.Event(r.ReconciliationSubject, "Normal", "Running", MachineInstanceRunning)
 		r.Log.Info(MachineInstanceRunning)
+		if !r.ReconciliationSubject.Status.Ready {
+			utils.ObserveMachineProvisioning(r.ReconciliationSubject, utils.MachineReady)
+		}
 		r.ReconciliationSubject.Status.Ready = true
+		conditions.MarkTrue(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition)
 	} else if r.ReconciliationSubject.Status.InstanceState == "Error" {
 		r.Recorder.Event(r.ReconciliationSubject, "Warning", "Error", MachineInErrorMessage)
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition, infrav1.InstanceErrorReason,
+			clusterv1.ConditionSeverityError, "Instance is in state Error")
 		r.Log.Info(MachineInErrorMessage, "csMachine", r.ReconciliationSubject.GetName())
 		if err := r.K8sClient.Delete(r.RequestCtx, r.CAPIMachine); err != nil {
 			return ctrl.Result{}, err
@@ -278,6 +342,8 @@ func (r *CloudStackMachineReconciliationRunner) RequeueIfInstanceNotRunning() (r
 	} else {
 		r.Recorder.Eventf(r.ReconciliationSubject, "Warning", r.ReconciliationSubject.Status.InstanceState, MachineNotReadyMessage, r.ReconciliationSubject.Status.InstanceState)
 		r.Log.Info(fmt.Sprintf(MachineNotReadyMessage, r.ReconciliationSubject.Status.InstanceState))
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition, infrav1.InstanceNotRunningReason,
+			clusterv1.ConditionSeverityInfo, MachineNotReadyMessage, r.ReconciliationSubject.Status.InstanceState)
 		return ctrl.Result{RequeueAfter: utils.RequeueTimeout}, nil
 	}
 	return ctrl.Result{}, nil
@@ -291,6 +357,8 @@ func (r *CloudStackMachineReconciliationRunner) AddToLBIfNeeded() (retRes ctrl.R
 			return r.RequeueWithMessage("Could not get required Isolated Network for VM, requeueing.")
 		}
 		err := r.CSUser.AssignVMToLoadBalancerRule(r.IsoNet, *r.ReconciliationSubject.Spec.InstanceID)
+		utils.MarkConditionFromError(r.ReconciliationSubject, infrav1.LoadBalancerAttachedCondition,
+			infrav1.LoadBalancerAttachFailedReason, err)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
@@ -307,7 +375,7 @@ func (r *CloudStackMachineReconciliationRunner) GetOrCreateMachineStateChecker()
 		Status:     infrav1.CloudStackMachineStateCheckerStatus{Ready: false},
 	}
 
-	if err := r.K8sClient.Create(r.RequestCtx, csMachineStateChecker); err != nil && !utils.ContainsAlreadyExistsSubstring(err) {
+	if err := r.K8sClient.Create(r.RequestCtx, csMachineStateChecker); err != nil && !apierrors.IsAlreadyExists(err) {
 		r.Recorder.Eventf(r.ReconciliationSubject, "Warning", "Machine State Checker", CSMachineStateCheckerCreationFailed)
 		return r.ReturnWrappedError(err, CSMachineStateCheckerCreationFailed)
 	}
@@ -316,11 +384,13 @@ func (r *CloudStackMachineReconciliationRunner) GetOrCreateMachineStateChecker()
 }
 
 func (r *CloudStackMachineReconciliationRunner) ReconcileDelete() (retRes ctrl.Result, reterr error) {
-	if r.ReconciliationSubject.Spec.InstanceID == nil {
-		// InstanceID is not set until deploying VM finishes which can take minutes, and CloudStack Machine can be deleted before VM deployment complete.
-		// ResolveVMInstanceDetails can get InstanceID by CS machine name
+	if r.ReconciliationSubject.Spec.InstanceID == nil || r.ReconciliationSubject.Status.DeployJobID != nil {
+		// Deploying a VM can take minutes, and CloudStack Machine can be deleted before VM deployment complete.
+		// ResolveVMInstanceDetails waits for the deployment, and can get InstanceID by the machine's ownership tag.
 		err := r.CSClient.ResolveVMInstanceDetails(r.ReconciliationSubject)
-		if err != nil {
+		if errors.Is(err, cloud.ErrJobPending) { // The VM can only be destroyed once its deployment is done.
+			return r.RequeueWithMessage(DeployJobPendingMessage)
+		} else if err != nil {
 			r.ReconciliationSubject.Status.Status = pointer.String(metav1.StatusFailure)
 			r.ReconciliationSubject.Status.Reason = pointer.String(err.Error() +
 				fmt.Sprintf(" If this VM has already been deleted, please remove the finalizer named %s from object %s",
@@ -343,6 +413,9 @@ func (r *CloudStackMachineReconciliationRunner) ReconcileDelete() (retRes ctrl.R
 		}
 		return ctrl.Result{}, err
 	}
+	if err := r.ReleaseIPAddresses(r.ReconciliationSubject); err != nil {
+		return ctrl.Result{}, err
+	}
 
 	controllerutil.RemoveFinalizer(r.ReconciliationSubject, infrav1.MachineFinalizer)
 	r.Log.Info("VM Deleted", "instanceID", r.ReconciliationSubject.Spec.InstanceID)
@@ -28,13 +28,17 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/pointer"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	crtlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 var _ = Describe("CloudStackMachineReconciler", func() {
@@ -81,6 +85,78 @@ var _ = Describe("CloudStackMachineReconciler", func() {
 			}, timeout).WithPolling(pollInterval).Should(BeTrue())
 		})
 
+		It("Should set the failure reason and message when the machine's spec can't be deployed", func() {
+			mockCloudClient.EXPECT().GetOrCreateVMInstance(
+				gomock.Any(), gomock.Any(), gomock.Any(),
+				gomock.Any(), gomock.Any(), gomock.Any()).Return(
+				fmt.Errorf("could not get Template ID from missing-template: %w", cloud.ErrInvalidSpec)).AnyTimes()
+
+			setupMachineCRDs()
+
+			Eventually(func() bool {
+				tempMachine := &infrav1.CloudStackMachine{}
+				key := client.ObjectKey{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+				if err := k8sClient.Get(ctx, key, tempMachine); err == nil {
+					return tempMachine.Status.FailureReason != nil &&
+						*tempMachine.Status.FailureReason == capierrors.InvalidConfigurationMachineError &&
+						strings.Contains(pointer.StringDeref(tempMachine.Status.FailureMessage, ""), "missing-template")
+				}
+				return false
+			}, timeout).WithPolling(pollInterval).Should(BeTrue())
+		})
+
+		It("Should wait for the deploy job of the machine's instance", func() {
+			mockCloudClient.EXPECT().GetOrCreateVMInstance(
+				gomock.Any(), gomock.Any(), gomock.Any(),
+				gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(arg1, _, _, _, _, _ interface{}) error {
+					arg1.(*infrav1.CloudStackMachine).Status.DeployJobID = pointer.String("job-id")
+					return fmt.Errorf("deploy job job-id: %w", cloud.ErrJobPending)
+				}).AnyTimes()
+
+			setupMachineCRDs()
+
+			Eventually(func() bool {
+				tempMachine := &infrav1.CloudStackMachine{}
+				key := client.ObjectKey{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+				if err := k8sClient.Get(ctx, key, tempMachine); err == nil {
+					return pointer.StringDeref(tempMachine.Status.DeployJobID, "") == "job-id" &&
+						conditions.GetReason(tempMachine, infrav1.InstanceProvisionedCondition) == infrav1.WaitingForDeployJobReason
+				}
+				return false
+			}, timeout).WithPolling(pollInterval).Should(BeTrue())
+		})
+
+		It("Should record the time the machine took to be ready", func() {
+			mockCloudClient.EXPECT().GetOrCreateVMInstance(
+				gomock.Any(), gomock.Any(), gomock.Any(),
+				gomock.Any(), gomock.Any(), gomock.Any()).Do(
+				func(arg1, _, _, _, _, _ interface{}) {
+					arg1.(*infrav1.CloudStackMachine).Status.InstanceState = "Running"
+				}).AnyTimes()
+
+			setupMachineCRDs()
+
+			// Eventually the machine ready histogram should have a sample for the machine's failure domain.
+			Eventually(func() uint64 {
+				families, err := crtlmetrics.Registry.Gather()
+				Ω(err).ShouldNot(HaveOccurred())
+				for _, family := range families {
+					if family.GetName() != "acs_machine_ready_seconds" {
+						continue
+					}
+					for _, metric := range family.GetMetric() {
+						for _, label := range metric.GetLabel() {
+							if label.GetName() == "failure_domain" && label.GetValue() == dummies.CSFailureDomain1.Spec.Name {
+								return metric.GetHistogram().GetSampleCount()
+							}
+						}
+					}
+				}
+				return 0
+			}, timeout).WithPolling(pollInterval).Should(BeNumerically(">", 0))
+		})
+
 		It("Should call DestroyVMInstance when CS machine deleted", func() {
 			// Mock a call to GetOrCreateVMInstance and set the machine to running.
 			mockCloudClient.EXPECT().GetOrCreateVMInstance(
@@ -120,6 +196,54 @@ var _ = Describe("CloudStackMachineReconciler", func() {
 
 		})
 
+		It("Should deploy the machine with an address claimed from its IP address pool and release it on deletion", func() {
+			pool := &infrav1.CloudStackIPAddressPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "control-plane", Namespace: dummies.ClusterNameSpace},
+				Spec: infrav1.CloudStackIPAddressPoolSpec{
+					FailureDomainName: dummies.CSMachine1.Spec.FailureDomainName,
+					Addresses:         []string{"10.0.0.10-10.0.0.11"},
+				},
+			}
+			Ω(k8sClient.Create(ctx, pool)).Should(Succeed())
+			pool.Status.Allocations = []infrav1.CloudStackIPAddressAllocation{{Address: "10.0.0.10", Machine: "other-machine"}}
+			Ω(k8sClient.Status().Update(ctx, pool)).Should(Succeed())
+			Ω(k8sClient.Create(ctx, &infrav1.CloudStackMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "other-machine", Namespace: dummies.ClusterNameSpace},
+				Spec:       dummies.CSMachine1.Spec,
+			})).Should(Succeed())
+
+			deployedIPs := make(chan string, 10)
+			mockCloudClient.EXPECT().GetOrCreateVMInstance(
+				gomock.Any(), gomock.Any(), gomock.Any(),
+				gomock.Any(), gomock.Any(), gomock.Any()).Do(
+				func(arg1, _, _, _, _, _ interface{}) {
+					deployedIPs <- arg1.(*infrav1.CloudStackMachine).Status.IPAddress
+					arg1.(*infrav1.CloudStackMachine).Status.InstanceState = "Running"
+					controllerutil.AddFinalizer(arg1.(*infrav1.CloudStackMachine), infrav1.MachineFinalizer)
+				}).AnyTimes()
+			mockCloudClient.EXPECT().DestroyVMInstance(gomock.Any()).Times(1).Return(nil)
+
+			dummies.CSMachine1.Spec.IPAddressPools = []string{pool.Name}
+			setupMachineCRDs()
+
+			Eventually(deployedIPs, timeout).Should(Receive(Equal("10.0.0.11")))
+			poolKey := client.ObjectKeyFromObject(pool)
+			Ω(k8sClient.Get(ctx, poolKey, pool)).Should(Succeed())
+			Ω(pool.Status.Allocations).Should(ConsistOf(
+				infrav1.CloudStackIPAddressAllocation{Address: "10.0.0.10", Machine: "other-machine"},
+				infrav1.CloudStackIPAddressAllocation{Address: "10.0.0.11", Machine: dummies.CSMachine1.Name},
+			))
+
+			Ω(k8sClient.Delete(ctx, dummies.CSMachine1)).Should(Succeed())
+
+			Eventually(func() []infrav1.CloudStackIPAddressAllocation {
+				Ω(k8sClient.Get(ctx, poolKey, pool)).Should(Succeed())
+				return pool.Status.Allocations
+			}, timeout).WithPolling(pollInterval).Should(ConsistOf(
+				infrav1.CloudStackIPAddressAllocation{Address: "10.0.0.10", Machine: "other-machine"},
+			))
+		})
+
 		It("Should call ResolveVMInstanceDetails when CS machine without instanceID deleted", func() {
 			instanceID := pointer.String("instance-id-123")
 			// Mock a call to GetOrCreateVMInstance and set the machine to running.
@@ -264,5 +388,107 @@ var _ = Describe("CloudStackMachineReconciler", func() {
 				return false
 			}, timeout).Should(BeTrue())
 		})
+
+		It("Should keep a machine whose deploy job is running until the job is done", func() {
+			key := client.ObjectKeyFromObject(dummies.CSCluster)
+			dummies.CAPIMachine.Name = "someMachine"
+			dummies.CAPIMachine.Spec.Bootstrap.DataSecretName = &dummies.BootstrapSecret.Name
+			dummies.CSMachine1.OwnerReferences = append(dummies.CSMachine1.OwnerReferences, metav1.OwnerReference{
+				Kind:       "Machine",
+				APIVersion: clusterv1.GroupVersion.String(),
+				Name:       dummies.CAPIMachine.Name,
+				UID:        "uniqueness",
+			})
+			dummies.CSMachine1.Spec.InstanceID = nil
+			mockCloudClient.EXPECT().GetOrCreateVMInstance(
+				gomock.Any(), gomock.Any(), gomock.Any(),
+				gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(arg1, _, _, _, _, _ interface{}) error {
+					arg1.(*infrav1.CloudStackMachine).Spec.InstanceID = pointer.String("instance-id")
+					arg1.(*infrav1.CloudStackMachine).Status.DeployJobID = pointer.String("job-id")
+					return fmt.Errorf("deploy job job-id: %w", cloud.ErrJobPending)
+				})
+			Ω(fakeCtrlClient.Get(ctx, key, dummies.CSCluster)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.CAPIMachine)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSMachine1)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSFailureDomain1)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.ACSEndpointSecret1)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.BootstrapSecret)).Should(Succeed())
+
+			setClusterReady(fakeCtrlClient)
+
+			request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(dummies.CSMachine1)}
+			res, err := MachineReconciler.Reconcile(ctx, request)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).ShouldNot(BeZero())
+
+			csMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, request.NamespacedName, csMachine)).Should(Succeed())
+			Ω(csMachine.Finalizers).Should(ContainElement(infrav1.MachineFinalizer))
+
+			// The VM is only destroyed once its deployment is done.
+			mockCloudClient.EXPECT().ResolveVMInstanceDetails(gomock.Any()).
+				Return(fmt.Errorf("deploy job job-id: %w", cloud.ErrJobPending))
+			Ω(fakeCtrlClient.Delete(ctx, csMachine)).Should(Succeed())
+			res, err = MachineReconciler.Reconcile(ctx, request)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).ShouldNot(BeZero())
+			Ω(fakeCtrlClient.Get(ctx, request.NamespacedName, csMachine)).Should(Succeed())
+			Ω(csMachine.Finalizers).Should(ContainElement(infrav1.MachineFinalizer))
+		})
+	})
+
+	Context("With a fake ctrlRuntimeClient and a fake CloudStack API.", func() {
+		BeforeEach(func() {
+			setupFakeTestClient()
+			setupFakeCloudStack()
+			dummies.CSCluster.Spec.FailureDomains = dummies.CSCluster.Spec.FailureDomains[:1]
+			dummies.CSCluster.Spec.FailureDomains[0].Name = dummies.CSFailureDomain1.Spec.Name
+		})
+
+		It("Should deploy the machine's VM and destroy it when the machine is deleted", func() {
+			key := client.ObjectKeyFromObject(dummies.CSCluster)
+			dummies.CAPIMachine.Name = "someMachine"
+			dummies.CAPIMachine.Spec.Bootstrap.DataSecretName = &dummies.BootstrapSecret.Name
+			dummies.CSMachine1.OwnerReferences = append(dummies.CSMachine1.OwnerReferences, metav1.OwnerReference{
+				Kind:       "Machine",
+				APIVersion: clusterv1.GroupVersion.String(),
+				Name:       dummies.CAPIMachine.Name,
+				UID:        "uniqueness",
+			})
+			Ω(fakeCtrlClient.Get(ctx, key, dummies.CSCluster)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.CAPIMachine)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSMachine1)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSFailureDomain1)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.ACSEndpointSecret1)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.BootstrapSecret)).Should(Succeed())
+
+			setClusterReady(fakeCtrlClient)
+
+			request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(dummies.CSMachine1)}
+			_, err := MachineReconciler.Reconcile(ctx, request)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			csMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, request.NamespacedName, csMachine)).Should(Succeed())
+			Ω(csMachine.Spec.InstanceID).ShouldNot(BeNil())
+			Ω(csMachine.Status.InstanceState).Should(Equal("Running"))
+			Ω(csMachine.Status.Ready).Should(BeTrue())
+			Ω(csMachine.Finalizers).Should(ContainElement(infrav1.MachineFinalizer))
+			vm := fakeCSServer.VirtualMachine(*csMachine.Spec.InstanceID)
+			Ω(vm).ShouldNot(BeNil())
+			Ω(vm.State).Should(Equal("Running"))
+
+			Ω(fakeCtrlClient.Delete(ctx, csMachine)).Should(Succeed())
+			_, err = MachineReconciler.Reconcile(ctx, request)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeCSServer.VirtualMachines()).Should(BeEmpty())
+			err = fakeCtrlClient.Get(ctx, request.NamespacedName, csMachine)
+			if err == nil {
+				Ω(csMachine.Finalizers).ShouldNot(ContainElement(infrav1.MachineFinalizer))
+			} else {
+				Ω(errors.IsNotFound(err)).Should(BeTrue())
+			}
+		})
 	})
 })
@@ -18,14 +18,15 @@ package controllers
 
 import (
 	"context"
-	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
 	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
@@ -78,10 +79,11 @@ func (r *CloudStackMachineStateCheckerReconciliationRunner) Reconcile() (ctrl.Re
 		r.AsFailureDomainUser(&r.FailureDomain.Spec),
 		func() (ctrl.Result, error) {
 			if err := r.CSClient.ResolveVMInstanceDetails(r.CSMachine); err != nil {
-				if !strings.Contains(strings.ToLower(err.Error()), "no match found") {
+				if !errors.Is(err, cloud.ErrNotFound) {
 					return r.ReturnWrappedError(err, "failed to resolve VM instance details")
 				}
 			}
+			r.RecordMachineInstanceStates(r.CSMachine)
 
 			// capiTimeout indicates that a new VM is running, but it isn't reachable.
 			// The cluster may not recover if the machine isn't replaced.
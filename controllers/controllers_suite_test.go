@@ -53,7 +53,9 @@ import (
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
 	csReconcilers "sigs.k8s.io/cluster-api-provider-cloudstack/controllers"
 	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/mocks"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
 
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -124,6 +126,10 @@ var (
 	mockCloudClient *mocks.MockClient
 	mockCSAPIClient *cloudstack.CloudStackClient
 
+	// Fake CloudStack vars.
+	fakeCSServer    *csserver.Server
+	fakeCloudClient cloud.Client
+
 	// Reconcilers
 	MachineReconciler       *csReconcilers.CloudStackMachineReconciler
 	ClusterReconciler       *csReconcilers.CloudStackClusterReconciler
@@ -182,6 +188,26 @@ func (m *MockCtrlrCloudClientImplementation) RegisterExtension(r *csCtrlrUtils.R
 	return &MockCtrlrCloudClientImplementation{ReconciliationRunner: r}
 }
 
+// A CloudClient implementation used in controller utils that uses a client of the fake CloudStack API server.
+type FakeServerCtrlrCloudClientImplementation struct {
+	*csCtrlrUtils.ReconciliationRunner
+	csCtrlrUtils.CloudClientExtension
+}
+
+// AsFailureDomainUser sets the CSUser to the client of the fake CloudStack API server.
+func (m *FakeServerCtrlrCloudClientImplementation) AsFailureDomainUser(
+	*infrav1.CloudStackFailureDomainSpec) csCtrlrUtils.CloudStackReconcilerMethod {
+	return func() (ctrl.Result, error) {
+		m.CSUser = fakeCloudClient
+
+		return ctrl.Result{}, nil
+	}
+}
+
+func (m *FakeServerCtrlrCloudClientImplementation) RegisterExtension(r *csCtrlrUtils.ReconciliationRunner) csCtrlrUtils.CloudClientExtension {
+	return &FakeServerCtrlrCloudClientImplementation{ReconciliationRunner: r}
+}
+
 func SetupTestEnvironment() {
 	repoRoot := os.Getenv("REPO_ROOT")
 	crdPaths := []string{filepath.Join(repoRoot, "config", "crd", "bases"), filepath.Join(repoRoot, "test", "fakes")}
@@ -216,6 +242,7 @@ func SetupTestEnvironment() {
 	// Base reconciler shared across reconcilers.
 	base := csCtrlrUtils.ReconcilerBase{
 		K8sClient:            k8sManager.GetClient(),
+		APIReader:            k8sManager.GetAPIReader(),
 		Scheme:               k8sManager.GetScheme(),
 		CSClient:             mockCloudClient,
 		BaseLogger:           logger,
@@ -237,6 +264,7 @@ func SetupTestEnvironment() {
 	// Setup mock clients.
 	mockCSAPIClient = cloudstack.NewMockClient(mockCtrl)
 	mockCloudClient = mocks.NewMockClient(mockCtrl)
+	mockCloudClient.EXPECT().ActiveAPIURL().Return("").AnyTimes()
 
 	// Set on reconcilers. The mock client wasn't available at suite startup, so set it now.
 	ClusterReconciler.CSClient = mockCloudClient
@@ -272,10 +300,12 @@ func setupFakeTestClient() {
 	// Setup mock clients.
 	mockCSAPIClient = cloudstack.NewMockClient(mockCtrl)
 	mockCloudClient = mocks.NewMockClient(mockCtrl)
+	mockCloudClient.EXPECT().ActiveAPIURL().Return("").AnyTimes()
 
 	// Base reconciler shared across reconcilers.
 	base := csCtrlrUtils.ReconcilerBase{
 		K8sClient:            fakeCtrlClient,
+		APIReader:            fakeCtrlClient,
 		Scheme:               scheme.Scheme,
 		CSClient:             mockCloudClient,
 		BaseLogger:           logger,
@@ -304,6 +334,42 @@ func setupFakeTestClient() {
 	})
 }
 
+// Starts a fake CloudStack API server with the zone, network, offerings and template of the dummies, and sets the
+// reconcilers up to use a client of it rather than the mock client. Call after setupFakeTestClient.
+func setupFakeCloudStack() {
+	fakeCSServer = csserver.NewServer()
+	DeferCleanup(fakeCSServer.Close)
+	zone := fakeCSServer.AddZone(&cloudstack.Zone{Name: dummies.Zone1.Name})
+	network := fakeCSServer.AddNetwork(&cloudstack.Network{Name: dummies.Zone1.Network.Name, Zoneid: zone.Id, Type: "Shared"})
+	fakeCSServer.AddServiceOffering(&cloudstack.ServiceOffering{Name: dummies.CSMachine1.Spec.Offering.Name, Cpunumber: 2, Memory: 2048})
+	fakeCSServer.AddDiskOffering(&cloudstack.DiskOffering{Name: dummies.CSMachine1.Spec.DiskOffering.Name, Disksize: 10})
+	fakeCSServer.AddTemplate(&cloudstack.Template{Name: dummies.CSMachine1.Spec.Template.Name})
+
+	dummies.CSFailureDomain1.Spec.Zone.ID = zone.Id
+	dummies.CSFailureDomain1.Spec.Zone.Network.ID = network.Id
+	dummies.CSMachine1.Spec.InstanceID = nil
+	dummies.CSMachine1.Spec.Affinity = "no"
+
+	var err error
+	fakeCloudClient, err = cloud.NewClientFromConf(cloud.Config{
+		APIUrl:    fakeCSServer.URL(),
+		APIKey:    csserver.AdminAPIKey,
+		SecretKey: csserver.AdminSecretKey,
+	}, nil, "")
+	Ω(err).ShouldNot(HaveOccurred())
+
+	for _, base := range []*csCtrlrUtils.ReconcilerBase{
+		&ClusterReconciler.ReconcilerBase,
+		&MachineReconciler.ReconcilerBase,
+		&FailureDomainReconciler.ReconcilerBase,
+		&IsoNetReconciler.ReconcilerBase,
+		&AffinityGReconciler.ReconcilerBase,
+	} {
+		base.CSClient = fakeCloudClient
+		base.CloudClientExtension = &FakeServerCtrlrCloudClientImplementation{}
+	}
+}
+
 // Setup and teardown on a per test basis.
 var _ = BeforeEach(func() {
 	dummies.SetDummyVars()
@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+)
+
+// RBAC permissions for the MachineDeployment quota check.
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachinetemplates,verbs=get;list;watch
+
+// The webhooks are called for all MachineDeployments and their scale subresource, and ignored when they can't be
+// reached so that scaling doesn't depend on the controller manager being up, or on the check being enabled.
+// +kubebuilder:webhook:path=/validate-cluster-x-k8s-io-v1beta1-machinedeployment,mutating=false,failurePolicy=ignore,sideEffects=None,groups=cluster.x-k8s.io,resources=machinedeployments,verbs=update,versions=v1beta1,name=vcloudstackmachinedeployment.kb.io,admissionReviewVersions=v1;v1beta1
+// +kubebuilder:webhook:path=/validate-cluster-x-k8s-io-v1beta1-machinedeployment-scale,mutating=false,failurePolicy=ignore,sideEffects=None,groups=cluster.x-k8s.io,resources=machinedeployments/scale,verbs=update,versions=v1beta1,name=vcloudstackmachinedeploymentscale.kb.io,admissionReviewVersions=v1;v1beta1
+
+// MachineDeploymentScalePath is the path the scale subresource of MachineDeployments is validated at.
+const MachineDeploymentScalePath = "/validate-cluster-x-k8s-io-v1beta1-machinedeployment-scale"
+
+// MachineDeploymentQuotaValidator rejects replica increases of MachineDeployments of CloudStackMachineTemplates when
+// the failure domains of their cluster don't have the CPU, memory or VMs available to deploy the additional machines
+// along with the cluster's pending machines. Replicas are increased either through the MachineDeployment itself or
+// through its scale subresource, as kubectl scale and the cluster autoscaler do.
+type MachineDeploymentQuotaValidator struct {
+	csCtrlrUtils.ReconcilerBase
+}
+
+// SetupWebhookWithManager registers the validator with the manager's webhook server.
+func (v *MachineDeploymentQuotaValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(MachineDeploymentScalePath, &webhook.Admission{Handler: v.ScaleHandler()})
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&clusterv1.MachineDeployment{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate admits all new MachineDeployments; their machines are checked by the CloudStackCluster's
+// QuotaSufficient condition.
+func (v *MachineDeploymentQuotaValidator) ValidateCreate(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+// ValidateUpdate rejects replica increases the quotas of the cluster's failure domains can't fulfil.
+func (v *MachineDeploymentQuotaValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	oldMD, ok := oldObj.(*clusterv1.MachineDeployment)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a MachineDeployment but got a %T", oldObj))
+	}
+	newMD, ok := newObj.(*clusterv1.MachineDeployment)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a MachineDeployment but got a %T", newObj))
+	}
+	added := pointer.Int32Deref(newMD.Spec.Replicas, 1) - pointer.Int32Deref(oldMD.Spec.Replicas, 1)
+	return v.validateScaleOut(ctx, newMD, added)
+}
+
+// ScaleHandler returns the admission handler that rejects replica increases through the scale subresource of
+// MachineDeployments that the quotas of the cluster's failure domains can't fulfil.
+func (v *MachineDeploymentQuotaValidator) ScaleHandler() admission.Handler {
+	return admission.HandlerFunc(func(ctx context.Context, req admission.Request) admission.Response {
+		oldScale, newScale := &autoscalingv1.Scale{}, &autoscalingv1.Scale{}
+		if err := json.Unmarshal(req.OldObject.Raw, oldScale); err != nil {
+			return admission.Errored(http.StatusBadRequest, errors.Wrap(err, "decoding the old Scale"))
+		}
+		if err := json.Unmarshal(req.Object.Raw, newScale); err != nil {
+			return admission.Errored(http.StatusBadRequest, errors.Wrap(err, "decoding the new Scale"))
+		}
+		added := newScale.Spec.Replicas - oldScale.Spec.Replicas
+		if added <= 0 {
+			return admission.Allowed("")
+		}
+
+		md := &clusterv1.MachineDeployment{}
+		if err := v.K8sClient.Get(ctx, client.ObjectKey{Namespace: req.Namespace, Name: req.Name}, md); err != nil {
+			v.BaseLogger.Error(err, "failed to get MachineDeployment for scaling out, admitting it",
+				"machineDeployment", req.Name, "namespace", req.Namespace)
+			return admission.Allowed("")
+		}
+		if err := v.validateScaleOut(ctx, md, added); err != nil {
+			return admission.Denied(err.Error())
+		}
+		return admission.Allowed("")
+	})
+}
+
+// validateScaleOut rejects adding machines to the MachineDeployment when the quotas of the cluster's failure domains
+// can't fulfil them.
+func (v *MachineDeploymentQuotaValidator) validateScaleOut(ctx context.Context, md *clusterv1.MachineDeployment, added int32) error {
+	infraRef := md.Spec.Template.Spec.InfrastructureRef
+	if added <= 0 || infraRef.Kind != "CloudStackMachineTemplate" {
+		return nil
+	}
+
+	shortfalls, err := v.scaleOutShortfalls(ctx, md, int(added))
+	if err != nil {
+		// Scaling shouldn't depend on the quotas being readable, so the increase is admitted.
+		v.BaseLogger.Error(err, "failed to check resource limits for scaling out, admitting it",
+			"machineDeployment", md.Name, "namespace", md.Namespace)
+		return nil
+	} else if len(shortfalls) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(clusterv1.GroupVersion.WithKind("MachineDeployment").GroupKind(), md.Name, field.ErrorList{
+		field.Forbidden(field.NewPath("spec", "replicas"), fmt.Sprintf(
+			"scaling out by %d machines needs more than is available of %s", added, strings.Join(shortfalls, ", "))),
+	})
+}
+
+// scaleOutShortfalls describes each resource the failure domains of the MachineDeployment's cluster lack to deploy
+// the added machines from its CloudStackMachineTemplate.
+func (v *MachineDeploymentQuotaValidator) scaleOutShortfalls(
+	ctx context.Context, md *clusterv1.MachineDeployment, added int,
+) ([]string, error) {
+	infraRef := md.Spec.Template.Spec.InfrastructureRef
+	template := &infrav1.CloudStackMachineTemplate{}
+	if err := v.K8sClient.Get(ctx, client.ObjectKey{Namespace: md.Namespace, Name: infraRef.Name}, template); err != nil {
+		return nil, errors.Wrapf(err, "getting CloudStackMachineTemplate %s", infraRef.Name)
+	}
+	capiCluster := &clusterv1.Cluster{}
+	if err := v.K8sClient.Get(ctx, client.ObjectKey{Namespace: md.Namespace, Name: md.Spec.ClusterName}, capiCluster); err != nil {
+		return nil, errors.Wrapf(err, "getting Cluster %s", md.Spec.ClusterName)
+	}
+
+	csMachine := &infrav1.CloudStackMachine{Spec: template.Spec.Template.Spec}
+	csMachine.Name = template.Name
+	return csCtrlrUtils.CheckScaleOutQuotas(ctx, v.ReconcilerBase, capiCluster, csMachine,
+		pointer.StringDeref(md.Spec.Template.Spec.FailureDomain, ""), added)
+}
+
+// ValidateDelete admits all deletions.
+func (v *MachineDeploymentQuotaValidator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
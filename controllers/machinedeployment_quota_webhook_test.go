@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers_test
+
+import (
+	"encoding/json"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+)
+
+var _ = Describe("MachineDeploymentQuotaValidator", func() {
+	var (
+		validator *controllers.MachineDeploymentQuotaValidator
+		md        *clusterv1.MachineDeployment
+	)
+
+	withReplicas := func(replicas int32) *clusterv1.MachineDeployment {
+		scaled := md.DeepCopy()
+		scaled.Spec.Replicas = pointer.Int32(replicas)
+		return scaled
+	}
+	scaleRequest := func(from, to int32) admission.Request {
+		raw := func(replicas int32) runtime.RawExtension {
+			scale, err := json.Marshal(&autoscalingv1.Scale{Spec: autoscalingv1.ScaleSpec{Replicas: replicas}})
+			Ω(err).ShouldNot(HaveOccurred())
+			return runtime.RawExtension{Raw: scale}
+		}
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Name:      md.Name,
+			Namespace: md.Namespace,
+			Operation: admissionv1.Update,
+			Object:    raw(to),
+			OldObject: raw(from),
+		}}
+	}
+	// Each machine needs 2 CPUs, of which the account has 3 available.
+	expectQuotas := func() {
+		mockCloudClient.EXPECT().GetResourceQuotas().Return([]cloud.ResourceQuota{
+			{Scope: cloud.QuotaScopeAccount, Name: "admin", Resource: cloud.QuotaResourceCPU, Limit: 10, Used: 7, Available: 3},
+		}, nil).AnyTimes()
+		mockCloudClient.EXPECT().GetResourceDemand(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ interface{}, _ string, count int64) (cloud.ResourceDemand, error) {
+				return cloud.ResourceDemand{cloud.QuotaResourceCPU: 2 * count}, nil
+			}).AnyTimes()
+	}
+
+	BeforeEach(func() {
+		setupFakeTestClient()
+		validator = &controllers.MachineDeploymentQuotaValidator{ReconcilerBase: ClusterReconciler.ReconcilerBase}
+
+		dummies.CAPICluster.Spec.InfrastructureRef.Name = dummies.CSCluster.Name
+		Ω(fakeCtrlClient.Update(ctx, dummies.CAPICluster)).Should(Succeed())
+		Ω(fakeCtrlClient.Create(ctx, dummies.CSFailureDomain1)).Should(Succeed())
+		Ω(fakeCtrlClient.Create(ctx, dummies.CSFailureDomain2)).Should(Succeed())
+		Ω(fakeCtrlClient.Create(ctx, dummies.CSMachineTemplate1)).Should(Succeed())
+		md = &clusterv1.MachineDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "md", Namespace: dummies.ClusterNameSpace},
+			Spec: clusterv1.MachineDeploymentSpec{
+				ClusterName: dummies.ClusterName,
+				Replicas:    pointer.Int32(1),
+				Template: clusterv1.MachineTemplateSpec{Spec: clusterv1.MachineSpec{
+					ClusterName: dummies.ClusterName,
+					InfrastructureRef: corev1.ObjectReference{
+						Kind: "CloudStackMachineTemplate",
+						Name: dummies.CSMachineTemplate1.Name,
+					},
+				}},
+			},
+		}
+		Ω(fakeCtrlClient.Create(ctx, md)).Should(Succeed())
+	})
+
+	It("Should reject replica increases the failure domains lack the resources for.", func() {
+		expectQuotas()
+		err := validator.ValidateUpdate(ctx, md, withReplicas(5))
+		Ω(apierrors.IsInvalid(err)).Should(BeTrue())
+		Ω(err.Error()).Should(ContainSubstring("scaling out by 4 machines"))
+	})
+
+	It("Should admit replica increases the failure domains have the resources for.", func() {
+		expectQuotas()
+		Ω(validator.ValidateUpdate(ctx, md, withReplicas(2))).Should(Succeed())
+	})
+
+	It("Should admit replica decreases without reading quotas.", func() {
+		Ω(validator.ValidateUpdate(ctx, withReplicas(5), md)).Should(Succeed())
+	})
+
+	It("Should admit replica increases when the resource limits can't be read.", func() {
+		mockCloudClient.EXPECT().GetResourceQuotas().Return(nil, cloud.ErrTransient).AnyTimes()
+		Ω(validator.ValidateUpdate(ctx, md, withReplicas(5))).Should(Succeed())
+	})
+
+	It("Should check replica increases through the scale subresource.", func() {
+		expectQuotas()
+		handler := validator.ScaleHandler()
+		Ω(handler.Handle(ctx, scaleRequest(1, 5)).Allowed).Should(BeFalse())
+		Ω(handler.Handle(ctx, scaleRequest(1, 2)).Allowed).Should(BeTrue())
+		Ω(handler.Handle(ctx, scaleRequest(5, 1)).Allowed).Should(BeTrue())
+	})
+})
@@ -24,6 +24,7 @@ import (
 	"golang.org/x/text/language"
 
 	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -81,7 +82,7 @@ func (r *ReconciliationRunner) GetOrCreateAffinityGroup(
 				UID:        fd.UID,
 			})
 
-		if err := r.K8sClient.Create(r.RequestCtx, ag); err != nil && !ContainsAlreadyExistsSubstring(err) {
+		if err := r.K8sClient.Create(r.RequestCtx, ag); err != nil && !apierrors.IsAlreadyExists(err) {
 			return r.ReturnWrappedError(err, "creating affinity group CRD")
 		}
 		return ctrl.Result{}, nil
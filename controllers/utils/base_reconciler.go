@@ -19,6 +19,9 @@ package utils
 import (
 	"context"
 	"fmt"
+	"reflect"
+	goruntime "runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,12 +30,16 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/tracing"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/patch"
@@ -47,6 +54,7 @@ type ReconcilerBase struct {
 	BaseLogger logr.Logger
 	Scheme     *runtime.Scheme
 	K8sClient  client.Client
+	APIReader  client.Reader // Reads from the API server rather than the cache.
 	CSClient   cloud.Client
 	Recorder   record.EventRecorder
 	CloudClientExtension
@@ -76,6 +84,8 @@ type ReconciliationRunner struct {
 	Reconcile              CloudStackReconcilerMethod
 	CSUser                 cloud.Client
 	ControllerKind         string
+	ReadyConditions        []clusterv1.ConditionType // Conditions summarized into the subject's Ready condition.
+	OwnedConditions        []clusterv1.ConditionType // Other conditions of the subject set by the reconciler.
 }
 
 type ConcreteRunner interface {
@@ -144,7 +154,7 @@ func (r *ReconciliationRunner) RunIf(conditional func() bool, fn CloudStackRecon
 			r.ConditionalResult = placeholder
 		}()
 		if placeholder {
-			return fn()
+			return r.runStage(fn)
 		}
 		return ctrl.Result{}, nil
 	}
@@ -154,7 +164,7 @@ func (r *ReconciliationRunner) RunIf(conditional func() bool, fn CloudStackRecon
 func (r *ReconciliationRunner) Else(fn CloudStackReconcilerMethod) CloudStackReconcilerMethod {
 	return func() (ctrl.Result, error) {
 		if !r.ConditionalResult {
-			return fn()
+			return r.runStage(fn)
 		}
 		return ctrl.Result{}, nil
 	}
@@ -370,10 +380,14 @@ func (r *ReconciliationRunner) ShouldReturn(rslt ctrl.Result, err error) bool {
 }
 
 // RunReconciliationStages runs CloudStackReconcilerMethods in order and exits if an error or requeue condition is set.
-// On exit patches changes back to API.
+// On exit patches changes back to API. Stages failing because CloudStack throttled API calls are requeued after a delay
+// rather than failed.
 func (r *ReconciliationRunner) RunReconciliationStages(fns ...CloudStackReconcilerMethod) (ctrl.Result, error) {
 	for _, fn := range fns {
-		if rslt, err := fn(); err != nil {
+		if rslt, err := r.runStage(fn); errors.Is(err, cloud.ErrThrottled) {
+			r.Log.Info("CloudStack API calls are throttled. Requeuing.", "error", err.Error())
+			return ctrl.Result{RequeueAfter: ThrottledRequeueInterval}, nil
+		} else if err != nil {
 			return rslt, err
 		} else if rslt.Requeue || rslt.RequeueAfter != time.Duration(0) || r.returnEarly {
 			return rslt, nil
@@ -385,9 +399,15 @@ func (r *ReconciliationRunner) RunReconciliationStages(fns ...CloudStackReconcil
 // RunBaseReconciliationStages runs the base reconciliation stages which are to setup the logger, get the reconciliation
 // subject, get CAPI and CloudStackClusters, and call either r.Reconcile or r.ReconcileDelete.
 func (r *ReconciliationRunner) RunBaseReconciliationStages() (res ctrl.Result, retErr error) {
+	ctx, span := tracing.Tracer().Start(r.RequestCtx, r.ControllerKind+" reconcile", trace.WithAttributes(
+		attribute.String("k8s.namespace.name", r.Request.Namespace), attribute.String("k8s.object.name", r.Request.Name)))
+	r.RequestCtx = ctx
+	defer func() {
+		endSpan(span, retErr)
+	}()
 	defer func() {
 		if r.Patcher != nil {
-			if err := r.Patcher.Patch(r.RequestCtx, r.ReconciliationSubject); err != nil {
+			if err := r.Patcher.Patch(r.RequestCtx, r.ReconciliationSubject, r.summarizeConditions()...); err != nil {
 				if !strings.Contains(err.Error(), "is invalid: status.ready") {
 					err = errors.Wrapf(err, "error patching reconciliation subject")
 					retErr = multierror.Append(retErr, err)
@@ -413,6 +433,48 @@ func (r *ReconciliationRunner) RunBaseReconciliationStages() (res ctrl.Result, r
 	return r.RunReconciliationStages(baseStages...)
 }
 
+// runStage runs a reconciliation stage in a span named after the stage's function. Stages run by RunIf and Else are
+// traced as the stage they run rather than as RunIf or Else.
+func (r *ReconciliationRunner) runStage(fn CloudStackReconcilerMethod) (ctrl.Result, error) {
+	name := stageName(fn)
+	if name == "RunIf" || name == "Else" {
+		return fn()
+	}
+	parentCtx := r.RequestCtx
+	ctx, span := tracing.Tracer().Start(parentCtx, name)
+	r.RequestCtx = ctx
+	rslt, err := fn()
+	r.RequestCtx = parentCtx
+	endSpan(span, err)
+	return rslt, err
+}
+
+// stageName returns the name of the function of a reconciliation stage, e.g. GetCAPICluster for r.GetCAPICluster, or
+// CreateFailureDomains for the stage r.CreateFailureDomains(...) returns.
+func stageName(fn CloudStackReconcilerMethod) string {
+	name := goruntime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	name = strings.TrimSuffix(name[strings.LastIndex(name, "/")+1:], "-fm")
+	parts := strings.Split(name, ".")
+	// Closures are named after the function they're declared in, followed by func1, func1.1 and so on.
+	for len(parts) > 1 {
+		last := parts[len(parts)-1]
+		if _, err := strconv.Atoi(strings.TrimPrefix(last, "func")); err != nil {
+			break
+		}
+		parts = parts[:len(parts)-1]
+	}
+	return parts[len(parts)-1]
+}
+
+// endSpan ends the span of a reconciliation or stage, recording the error it failed with.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
 // CheckIfPaused returns with requeue later set if paused.
 func (r *ReconciliationRunner) CheckIfPaused() (ctrl.Result, error) {
 	r.Log.V(1).Info("Checking if paused.")
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils_test
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+)
+
+// noopRunner is a concrete runner whose own stages do nothing.
+type noopRunner struct{}
+
+func (noopRunner) Reconcile() (ctrl.Result, error)       { return ctrl.Result{}, nil }
+func (noopRunner) ReconcileDelete() (ctrl.Result, error) { return ctrl.Result{}, nil }
+
+// failingStage is a reconciliation stage that fails.
+func failingStage() (ctrl.Result, error) {
+	return ctrl.Result{}, errors.New("stage failed")
+}
+
+var _ = Describe("ReconciliationRunner", func() {
+	Context("tracing", func() {
+		var exporter *tracetest.InMemoryExporter
+
+		BeforeEach(func() {
+			exporter = tracetest.NewInMemoryExporter()
+			origProvider := otel.GetTracerProvider()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+			DeferCleanup(func() { otel.SetTracerProvider(origProvider) })
+		})
+
+		It("traces each stage in a span named after its function, up to the stage that fails", func() {
+			ctx, reconcile := otel.Tracer("test").Start(context.Background(), "reconcile")
+			defer reconcile.End()
+			r := utils.NewRunner(noopRunner{}, &infrav1.CloudStackCluster{}, "Test").
+				UsingBaseReconciler(utils.ReconcilerBase{BaseLogger: logr.Discard()}).
+				WithRequestCtx(ctx)
+			_, err := r.RunReconciliationStages(
+				r.SetupLogger,
+				r.RunIf(func() bool { return true }, r.LogReconciliationSubject),
+				r.CreateFailureDomains(nil),
+				failingStage,
+				r.SetupLogger)
+			Ω(err).Should(MatchError("stage failed"))
+
+			var names []string
+			for _, span := range exporter.GetSpans() {
+				names = append(names, span.Name)
+				Ω(span.Parent.SpanID()).Should(Equal(reconcile.SpanContext().SpanID()))
+				if span.Name == "failingStage" {
+					Ω(span.Status.Code).Should(Equal(codes.Error))
+				} else {
+					Ω(span.Status.Code).Should(Equal(codes.Unset))
+				}
+			}
+			Ω(names).Should(Equal([]string{"SetupLogger", "LogReconciliationSubject", "CreateFailureDomains", "failingStage"}))
+		})
+	})
+})
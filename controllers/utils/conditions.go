@@ -0,0 +1,62 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+)
+
+// ConditionSeverity returns the severity of a condition that's false because of an error. Transient and throttled
+// CloudStack errors are expected to clear up on a later reconciliation, so they're warnings.
+func ConditionSeverity(err error) clusterv1.ConditionSeverity {
+	if errors.Is(err, cloud.ErrTransient) || errors.Is(err, cloud.ErrThrottled) {
+		return clusterv1.ConditionSeverityWarning
+	}
+	return clusterv1.ConditionSeverityError
+}
+
+// MarkConditionFromError marks a condition true when err is nil, and false with the reason and the error as message
+// otherwise.
+func MarkConditionFromError(to conditions.Setter, t clusterv1.ConditionType, reason string, err error) {
+	if err == nil {
+		conditions.MarkTrue(to, t)
+		return
+	}
+	conditions.MarkFalse(to, t, reason, ConditionSeverity(err), "%s", err.Error())
+}
+
+// summarizeConditions sets the Ready condition of a reconciliation subject with conditions to a summary of its
+// ReadyConditions, and returns the patch options that give the reconciler ownership of them and of its
+// OwnedConditions.
+func (r *ReconciliationRunner) summarizeConditions() []patch.Option {
+	subject, ok := r.ReconciliationSubject.(conditions.Setter)
+	if !ok || len(r.ReadyConditions)+len(r.OwnedConditions) == 0 {
+		return nil
+	}
+	owned := append([]clusterv1.ConditionType{}, r.OwnedConditions...)
+	if len(r.ReadyConditions) > 0 {
+		conditions.SetSummary(subject, conditions.WithConditions(r.ReadyConditions...))
+		owned = append(owned, clusterv1.ReadyCondition)
+		owned = append(owned, r.ReadyConditions...)
+	}
+	return []patch.Option{patch.WithOwnedConditions{Conditions: owned}}
+}
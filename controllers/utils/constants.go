@@ -20,3 +20,4 @@ import "time"
 
 const RequeueTimeout = 5 * time.Second
 const DestoryVMRequeueInterval = 10 * time.Second
+const ThrottledRequeueInterval = 30 * time.Second
@@ -17,10 +17,13 @@ limitations under the License.
 package utils
 
 import (
+	"context"
 	"fmt"
-	"strings"
+	"sync"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 
@@ -28,6 +31,7 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -46,7 +50,7 @@ func (r *ReconciliationRunner) CreateFailureDomains(fdSpecs []infrav1.CloudStack
 	return func() (ctrl.Result, error) {
 		for _, fdSpec := range fdSpecs {
 			if err := r.CreateFailureDomain(fdSpec); err != nil {
-				if !strings.Contains(strings.ToLower(err.Error()), "already exists") {
+				if !apierrors.IsAlreadyExists(err) {
 					return reconcile.Result{}, errors.Wrap(err, "creating CloudStackFailureDomains")
 				}
 			}
@@ -152,7 +156,7 @@ func (c *CloudClientImplementation) AsFailureDomainUser(fdSpec *infrav1.CloudSta
 		}
 
 		if fdSpec.Account != "" { // Set r.CSUser CloudStack Client per Account and Domain.
-			client, err := c.CSClient.NewClientInDomainAndAccount(fdSpec.Domain, fdSpec.Account, fdSpec.Project)
+			client, err := c.accountClient(fdSpec)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
@@ -161,6 +165,95 @@ func (c *CloudClientImplementation) AsFailureDomainUser(fdSpec *infrav1.CloudSta
 			c.CSUser = c.CSClient
 		}
 
+		// Trace API calls as part of the reconciliation stage they're made in.
+		spanCtx := func() context.Context { return c.RequestCtx }
+		c.CSClient, c.CSUser = c.CSClient.WithTracing(spanCtx), c.CSUser.WithTracing(spanCtx)
+
 		return ctrl.Result{}, nil
 	}
 }
+
+// accountCredentialsLocks holds a mutex per domain and account. Provisioning replaces the API keys of the account's
+// service user, so it's done by one reconciliation of the account at a time to keep concurrent reconciliations from
+// invalidating each other's keys, while those of other accounts go ahead.
+var accountCredentialsLocks sync.Map
+
+// lockAccountCredentials locks the provisioning of the credentials of an account and returns the func unlocking it.
+func lockAccountCredentials(domain, account string) func() {
+	m, _ := accountCredentialsLocks.LoadOrStore(domain+"/"+account, &sync.Mutex{})
+	mutex := m.(*sync.Mutex)
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+// accountClient returns a client for the account of the failure domain. Failure domains that provision account
+// credentials use the API keys of their account credentials secret. The keys are generated for the account's service
+// user if the account has no user with keys, or if the keys of the secret are missing or no longer valid.
+func (c *CloudClientImplementation) accountClient(fdSpec *infrav1.CloudStackFailureDomainSpec) (cloud.Client, error) {
+	if !fdSpec.ProvisionAccountCredentials {
+		return c.CSClient.NewClientInDomainAndAccount(fdSpec.Domain, fdSpec.Account, fdSpec.Project)
+	}
+
+	unlock := lockAccountCredentials(fdSpec.Domain, fdSpec.Account)
+	defer unlock()
+
+	fdName := infrav1.FailureDomainHashedMetaName(fdSpec.Name, c.CAPICluster.Name)
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: c.Request.Namespace, Name: infrav1.AccountCredentialsSecretName(fdName)}
+	if err := c.K8sClient.Get(c.RequestCtx, key, secret); err == nil {
+		apiKey, secretKey := string(secret.Data["api-key"]), string(secret.Data["secret-key"])
+		if apiKey != "" && secretKey != "" {
+			csClient, err := c.CSClient.NewClientWithAPIKeys(apiKey, secretKey, fdSpec.Project)
+			if err == nil || !errors.Is(err, cloud.ErrPermissionDenied) {
+				return csClient, err
+			}
+			c.Log.Info("API keys of the account credentials secret were rejected, provisioning new ones.", "secret", key)
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "getting account credentials secret %s", key)
+	} else {
+		// Only provision keys when the account has none.
+		csClient, err := c.CSClient.NewClientInDomainAndAccount(fdSpec.Domain, fdSpec.Account, fdSpec.Project)
+		if err == nil || !errors.Is(err, cloud.ErrNotFound) {
+			return csClient, err
+		}
+
+		// The secret is created before the keys are generated, so keys are never generated without a secret to keep
+		// them in. A secret left without keys gets them on the next reconciliation.
+		fd := &infrav1.CloudStackFailureDomain{}
+		if err := c.K8sClient.Get(c.RequestCtx, client.ObjectKey{Namespace: c.Request.Namespace, Name: fdName}, fd); err != nil {
+			return nil, errors.Wrapf(err, "getting failure domain %s", fdSpec.Name)
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels:    map[string]string{infrav1.FailureDomainLabelName: fdName},
+			},
+			Type: corev1.SecretTypeOpaque,
+		}
+		if err := controllerutil.SetControllerReference(fd, secret, c.K8sClient.Scheme()); err != nil {
+			return nil, errors.Wrapf(err, "setting owner of account credentials secret %s", key)
+		}
+		if err := c.K8sClient.Create(c.RequestCtx, secret); err != nil {
+			return nil, errors.Wrapf(err, "creating account credentials secret %s", key)
+		}
+	}
+
+	user := &cloud.User{}
+	user.Account.Domain.Path = fdSpec.Domain
+	user.Account.Name = fdSpec.Account
+	if err := c.CSClient.ProvisionUserKeys(user); err != nil {
+		return nil, errors.Wrapf(err, "provisioning API keys in domain/account %s/%s", fdSpec.Domain, fdSpec.Account)
+	}
+	secret.Data = map[string][]byte{
+		"api-key":    []byte(user.APIKey),
+		"secret-key": []byte(user.SecretKey),
+	}
+	if err := c.K8sClient.Update(c.RequestCtx, secret); err != nil {
+		return nil, errors.Wrapf(err, "storing API keys in account credentials secret %s", key)
+	}
+	c.Log.Info("Provisioned API keys.", "domain", fdSpec.Domain, "account", fdSpec.Account, "user", user.Name, "secret", key)
+
+	return c.CSClient.NewClientWithAPIKeys(user.APIKey, user.SecretKey, fdSpec.Project)
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ClaimIPAddress claims an address for the machine from the IP address pool of its failure domain, and sets it in the
+// machine's status for the VM to be deployed with. Claims are recorded in the pool by machine UID, so a machine that
+// claimed an address before gets the same one back, and a machine recreated with the same name doesn't. A machine none
+// of whose pools is in its failure domain fails, since waiting won't give it one.
+func (r *ReconciliationRunner) ClaimIPAddress(csMachine *infrav1.CloudStackMachine) CloudStackReconcilerMethod {
+	return func() (ctrl.Result, error) {
+		if len(csMachine.Spec.IPAddressPools) == 0 || csMachine.Status.IPAddress != "" || csMachine.Spec.InstanceID != nil {
+			return ctrl.Result{}, nil
+		}
+
+		pool, missing, err := r.ipAddressPoolOf(csMachine)
+		if err != nil {
+			return ctrl.Result{}, err
+		} else if pool == nil && len(missing) > 0 {
+			conditions.MarkFalse(csMachine, infrav1.InstanceProvisionedCondition, infrav1.WaitingForIPAddressReason,
+				clusterv1.ConditionSeverityWarning, "IP address pools %v don't exist", missing)
+			return r.RequeueWithMessage("IP address pools of the machine don't exist.", "pools", missing)
+		} else if pool == nil {
+			message := fmt.Sprintf("None of the IP address pools %v is in failure domain %s",
+				csMachine.Spec.IPAddressPools, csMachine.Spec.FailureDomainName)
+			conditions.MarkFalse(csMachine, infrav1.InstanceProvisionedCondition, infrav1.InstanceProvisioningFailedReason,
+				clusterv1.ConditionSeverityError, "%s", message)
+			csMachine.Status.FailureReason = capierrors.MachineStatusErrorPtr(capierrors.InvalidConfigurationMachineError)
+			csMachine.Status.FailureMessage = pointer.String(message)
+			r.Log.Info(message)
+			r.SetReturnEarly()
+			return ctrl.Result{}, nil
+		}
+
+		address := allocatedAddress(pool, csMachine.UID)
+		if address == "" {
+			if err := r.releaseStaleAllocations(pool); err != nil {
+				return ctrl.Result{}, err
+			}
+			if address, err = freeAddress(pool); err != nil {
+				return ctrl.Result{}, errors.Wrapf(err, "claiming an address from IP address pool %s", pool.Name)
+			} else if address == "" {
+				conditions.MarkFalse(csMachine, infrav1.InstanceProvisionedCondition, infrav1.WaitingForIPAddressReason,
+					clusterv1.ConditionSeverityWarning, "IP address pool %s is exhausted", pool.Name)
+				return r.RequeueWithMessage("IP address pool is exhausted.", "pool", pool.Name)
+			}
+			pool.Status.Allocations = append(pool.Status.Allocations,
+				infrav1.CloudStackIPAddressAllocation{Address: address, Machine: csMachine.Name, MachineUID: csMachine.UID})
+			if err := r.K8sClient.Status().Update(r.RequestCtx, pool); apierrors.IsConflict(err) {
+				return r.RequeueWithMessage("IP address pool changed while claiming an address.", "pool", pool.Name)
+			} else if err != nil {
+				return r.ReturnWrappedError(err, "claiming an address from IP address pool "+pool.Name)
+			}
+			r.Log.Info("Claimed IP address.", "pool", pool.Name, "address", address)
+		}
+		csMachine.Status.IPAddress = address
+		return ctrl.Result{}, nil
+	}
+}
+
+// ReleaseIPAddresses releases the addresses the machine claimed from its IP address pools.
+func (r *ReconciliationRunner) ReleaseIPAddresses(csMachine *infrav1.CloudStackMachine) error {
+	for _, name := range csMachine.Spec.IPAddressPools {
+		pool := &infrav1.CloudStackIPAddressPool{}
+		key := client.ObjectKey{Namespace: csMachine.Namespace, Name: name}
+		if err := r.K8sClient.Get(r.RequestCtx, key, pool); apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "getting IP address pool %s", name)
+		}
+		if allocatedAddress(pool, csMachine.UID) == "" {
+			continue
+		}
+		pool.Status.Allocations = removeAllocations(pool.Status.Allocations, func(a infrav1.CloudStackIPAddressAllocation) bool {
+			return a.MachineUID == csMachine.UID
+		})
+		if err := r.K8sClient.Status().Update(r.RequestCtx, pool); err != nil {
+			return errors.Wrapf(err, "releasing the address claimed from IP address pool %s", name)
+		}
+		r.Log.Info("Released IP address.", "pool", name)
+	}
+	csMachine.Status.IPAddress = ""
+	return nil
+}
+
+// ipAddressPoolOf returns the first of the machine's IP address pools that's in its failure domain, or nil if there's
+// none, along with the names of the pools that don't exist.
+func (r *ReconciliationRunner) ipAddressPoolOf(csMachine *infrav1.CloudStackMachine) (*infrav1.CloudStackIPAddressPool, []string, error) {
+	var missing []string
+	for _, name := range csMachine.Spec.IPAddressPools {
+		pool := &infrav1.CloudStackIPAddressPool{}
+		key := client.ObjectKey{Namespace: csMachine.Namespace, Name: name}
+		if err := r.K8sClient.Get(r.RequestCtx, key, pool); apierrors.IsNotFound(err) {
+			missing = append(missing, name)
+			continue
+		} else if err != nil {
+			return nil, nil, errors.Wrapf(err, "getting IP address pool %s", name)
+		}
+		if pool.Spec.FailureDomainName == csMachine.Spec.FailureDomainName {
+			return pool, nil, nil
+		}
+	}
+	return nil, missing, nil
+}
+
+// releaseStaleAllocations drops the allocations of machines that no longer exist from the pool, such as those of
+// machines deleted before their VM was deployed, or replaced by a machine of the same name. The pool is updated along
+// with the next claim. Machines are read from the API server, since the cache may not have caught up with machines that
+// were just created.
+func (r *ReconciliationRunner) releaseStaleAllocations(pool *infrav1.CloudStackIPAddressPool) error {
+	stale := map[types.UID]bool{}
+	for _, allocation := range pool.Status.Allocations {
+		key := client.ObjectKey{Namespace: pool.Namespace, Name: allocation.Machine}
+		machine := &infrav1.CloudStackMachine{}
+		if err := r.APIReader.Get(r.RequestCtx, key, machine); apierrors.IsNotFound(err) {
+			stale[allocation.MachineUID] = true
+		} else if err != nil {
+			return errors.Wrapf(err, "getting CloudStackMachine %s", allocation.Machine)
+		} else if machine.UID != allocation.MachineUID {
+			stale[allocation.MachineUID] = true
+		}
+	}
+	pool.Status.Allocations = removeAllocations(pool.Status.Allocations, func(a infrav1.CloudStackIPAddressAllocation) bool {
+		return stale[a.MachineUID]
+	})
+	return nil
+}
+
+// allocatedAddress returns the address allocated to the machine of the UID in the pool, or an empty string.
+func allocatedAddress(pool *infrav1.CloudStackIPAddressPool, machineUID types.UID) string {
+	for _, allocation := range pool.Status.Allocations {
+		if allocation.MachineUID == machineUID {
+			return allocation.Address
+		}
+	}
+	return ""
+}
+
+// freeAddress returns the first address of the pool that isn't allocated, or an empty string if all are.
+func freeAddress(pool *infrav1.CloudStackIPAddressPool) (string, error) {
+	allocated := map[netip.Addr]bool{}
+	for _, allocation := range pool.Status.Allocations {
+		if addr, err := netip.ParseAddr(allocation.Address); err == nil {
+			allocated[addr] = true
+		}
+	}
+	for _, entry := range pool.Spec.Addresses {
+		first, last, err := infrav1.ParseIPAddressRange(entry)
+		if err != nil {
+			return "", err
+		}
+		for addr := first; addr.IsValid() && addr.Compare(last) <= 0; addr = addr.Next() {
+			if !allocated[addr] {
+				return addr.String(), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// removeAllocations returns the allocations except for those matching the predicate.
+func removeAllocations(
+	allocations []infrav1.CloudStackIPAddressAllocation,
+	matches func(infrav1.CloudStackIPAddressAllocation) bool,
+) []infrav1.CloudStackIPAddressAllocation {
+	kept := []infrav1.CloudStackIPAddressAllocation{}
+	for _, allocation := range allocations {
+		if !matches(allocation) {
+			kept = append(kept, allocation)
+		}
+	}
+	return kept
+}
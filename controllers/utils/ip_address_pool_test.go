@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils_test
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+)
+
+var _ = Describe("ClaimIPAddress", func() {
+	const namespace = "default"
+
+	var (
+		scheme *runtime.Scheme
+		pool   *infrav1.CloudStackIPAddressPool
+	)
+
+	machine := func(name string) *infrav1.CloudStackMachine {
+		return &infrav1.CloudStackMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: types.UID(name + "-uid")},
+			Spec:       infrav1.CloudStackMachineSpec{FailureDomainName: "fd1", IPAddressPools: []string{pool.Name}},
+		}
+	}
+
+	BeforeEach(func() {
+		scheme = runtime.NewScheme()
+		Ω(infrav1.AddToScheme(scheme)).Should(Succeed())
+		pool = &infrav1.CloudStackIPAddressPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "pool", Namespace: namespace},
+			Spec:       infrav1.CloudStackIPAddressPoolSpec{FailureDomainName: "fd1", Addresses: []string{"10.0.0.10-10.0.0.11"}},
+		}
+	})
+
+	claim := func(csMachine *infrav1.CloudStackMachine, cached, apiReader client.Client) error {
+		r := utils.NewRunner(noopRunner{}, csMachine, "Test").
+			UsingBaseReconciler(utils.ReconcilerBase{K8sClient: cached, APIReader: apiReader, BaseLogger: logr.Discard()}).
+			WithRequestCtx(context.Background())
+		_, err := r.RunReconciliationStages(r.SetupLogger, r.ClaimIPAddress(csMachine))
+		return err
+	}
+
+	It("releases the addresses of deleted machines only, reading the machines from the API server", func() {
+		pool.Status.Allocations = []infrav1.CloudStackIPAddressAllocation{
+			{Address: "10.0.0.10", Machine: "just-created", MachineUID: "just-created-uid"},
+			{Address: "10.0.0.11", Machine: "deleted", MachineUID: "deleted-uid"},
+		}
+		csMachine := machine("new")
+		// The cache hasn't caught up with the machine that was just created.
+		cached := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pool, csMachine).Build()
+		apiReader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(csMachine, machine("just-created")).Build()
+
+		Ω(claim(csMachine, cached, apiReader)).Should(Succeed())
+
+		Ω(csMachine.Status.IPAddress).Should(Equal("10.0.0.11"))
+		Ω(cached.Get(context.Background(), client.ObjectKeyFromObject(pool), pool)).Should(Succeed())
+		Ω(pool.Status.Allocations).Should(ConsistOf(
+			infrav1.CloudStackIPAddressAllocation{Address: "10.0.0.10", Machine: "just-created", MachineUID: "just-created-uid"},
+			infrav1.CloudStackIPAddressAllocation{Address: "10.0.0.11", Machine: "new", MachineUID: "new-uid"},
+		))
+	})
+
+	It("doesn't give the address of a deleted machine to a new machine of the same name", func() {
+		pool.Spec.Addresses = []string{"10.0.0.10"}
+		pool.Status.Allocations = []infrav1.CloudStackIPAddressAllocation{
+			{Address: "10.0.0.10", Machine: "recreated", MachineUID: "previous-uid"},
+		}
+		csMachine := machine("recreated")
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pool, csMachine).Build()
+
+		Ω(claim(csMachine, k8sClient, k8sClient)).Should(Succeed())
+
+		Ω(csMachine.Status.IPAddress).Should(Equal("10.0.0.10"))
+		Ω(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(pool), pool)).Should(Succeed())
+		Ω(pool.Status.Allocations).Should(ConsistOf(
+			infrav1.CloudStackIPAddressAllocation{Address: "10.0.0.10", Machine: "recreated", MachineUID: "recreated-uid"},
+		))
+	})
+
+	It("fails machines none of whose pools is in their failure domain", func() {
+		csMachine := machine("elsewhere")
+		csMachine.Spec.FailureDomainName = "fd2"
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pool, csMachine).Build()
+
+		Ω(claim(csMachine, k8sClient, k8sClient)).Should(Succeed())
+		Ω(csMachine.Status.FailureReason).Should(Equal(capierrors.MachineStatusErrorPtr(capierrors.InvalidConfigurationMachineError)))
+		Ω(conditions.GetReason(csMachine, infrav1.InstanceProvisionedCondition)).Should(Equal(infrav1.InstanceProvisioningFailedReason))
+	})
+
+	It("waits for pools that don't exist yet", func() {
+		csMachine := machine("early")
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(csMachine).Build()
+
+		r := utils.NewRunner(noopRunner{}, csMachine, "Test").
+			UsingBaseReconciler(utils.ReconcilerBase{K8sClient: k8sClient, APIReader: k8sClient, BaseLogger: logr.Discard()}).
+			WithRequestCtx(context.Background())
+		res, err := r.RunReconciliationStages(r.SetupLogger, r.ClaimIPAddress(csMachine))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(res.RequeueAfter).ShouldNot(BeZero())
+		Ω(csMachine.Status.FailureReason).Should(BeNil())
+		Ω(conditions.GetReason(csMachine, infrav1.InstanceProvisionedCondition)).Should(Equal(infrav1.WaitingForIPAddressReason))
+	})
+})
@@ -21,6 +21,7 @@ import (
 	"regexp"
 	"strings"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
@@ -44,7 +45,7 @@ func (r *ReconciliationRunner) GenerateIsolatedNetwork(name string, fdNameFunc f
 		csIsoNet.Spec.ControlPlaneEndpoint.Host = r.CSCluster.Spec.ControlPlaneEndpoint.Host
 		csIsoNet.Spec.ControlPlaneEndpoint.Port = r.CSCluster.Spec.ControlPlaneEndpoint.Port
 
-		if err := r.K8sClient.Create(r.RequestCtx, csIsoNet); err != nil && !ContainsAlreadyExistsSubstring(err) {
+		if err := r.K8sClient.Create(r.RequestCtx, csIsoNet); err != nil && !apierrors.IsAlreadyExists(err) {
 			return r.ReturnWrappedError(err, "creating isolated network CRD")
 		}
 		return ctrl.Result{}, nil
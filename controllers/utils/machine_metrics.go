@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"time"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/metrics"
+)
+
+// NoInstanceState is the instance state machines without an instance are counted in.
+const NoInstanceState = "None"
+
+// MachineMetrics are the CloudStack machine lifecycle metrics, shared by the controllers reconciling machines.
+var MachineMetrics = metrics.NewMachineMetrics()
+
+// MachineProvisioningStage is a stage of provisioning a CloudStackMachine that's recorded in the lifecycle metrics.
+type MachineProvisioningStage int
+
+const (
+	InstanceCreated MachineProvisioningStage = iota
+	InstanceRunning
+	MachineReady
+)
+
+// ObserveMachineProvisioning records the time the machine took from its creation to reach the provisioning stage. The
+// instance is taken to be running from the time its state last changed, rather than from when the change was noticed.
+func ObserveMachineProvisioning(csMachine *infrav1.CloudStackMachine, stage MachineProvisioningStage) {
+	spec := csMachine.Spec.InFailureDomain(csMachine.Spec.FailureDomainName)
+	offering := spec.Offering.Name
+	if offering == "" {
+		offering = spec.Offering.ID
+	}
+	template := spec.Template.Name
+	if template == "" {
+		template = spec.Template.ID
+	}
+	elapsed := time.Since(csMachine.CreationTimestamp.Time)
+
+	switch stage {
+	case InstanceCreated:
+		MachineMetrics.ObserveInstanceCreated(csMachine.Spec.FailureDomainName, offering, template, elapsed)
+	case InstanceRunning:
+		if !csMachine.Status.InstanceStateLastUpdated.IsZero() {
+			elapsed = csMachine.Status.InstanceStateLastUpdated.Sub(csMachine.CreationTimestamp.Time)
+		}
+		MachineMetrics.ObserveInstanceRunning(csMachine.Spec.FailureDomainName, offering, template, elapsed)
+	case MachineReady:
+		MachineMetrics.ObserveMachineReady(csMachine.Spec.FailureDomainName, offering, template, elapsed)
+	}
+}
+
+// RecordMachineInstanceStates counts the CloudStackMachines of the cluster the passed machine belongs to per instance
+// state, taking the state of the passed machine from it rather than from the API, as it may not be patched yet.
+func (r *ReconciliationRunner) RecordMachineInstanceStates(csMachine *infrav1.CloudStackMachine) {
+	clusterName := csMachine.GetLabels()[clusterv1.ClusterNameLabel]
+	if clusterName == "" {
+		return
+	}
+	machines := &infrav1.CloudStackMachineList{}
+	if err := r.K8sClient.List(r.RequestCtx, machines,
+		client.InNamespace(csMachine.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: clusterName},
+	); err != nil {
+		r.Log.Error(err, "failed to list machines for the instance state metrics")
+		return
+	}
+
+	counts := map[string]int{}
+	for _, machine := range machines.Items {
+		state := machine.Status.InstanceState
+		if machine.UID == csMachine.UID {
+			state = csMachine.Status.InstanceState
+		}
+		if state == "" {
+			state = NoInstanceState
+		}
+		counts[state]++
+	}
+	MachineMetrics.SetMachinesByInstanceState(csMachine.Namespace, clusterName, counts)
+}
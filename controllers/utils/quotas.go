@@ -0,0 +1,330 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/metrics"
+)
+
+// QuotaMetrics are the CloudStack resource limit metrics of the failure domains of all clusters.
+var QuotaMetrics = metrics.NewQuotaMetrics()
+
+// QuotaCollectionOptions configures the periodic collection of the resource limits of a cluster's failure domains.
+type QuotaCollectionOptions struct {
+	// Interval is how often the limits are collected. Collection is disabled when zero.
+	Interval time.Duration
+	// HeadroomThreshold is the percentage of a limit under which the remaining headroom is reported as low.
+	HeadroomThreshold int
+}
+
+// CollectResourceQuotas reads the resource limits and usage of the accounts, domains and projects of the failure
+// domains, exports them as metrics, and sets the QuotaHeadroom condition of the reconciliation subject, a
+// CloudStackCluster. A Warning Event is raised when the headroom of a resource drops under the threshold. The
+// QuotaSufficient condition reports whether the resources available are enough to deploy the cluster's pending
+// machines. Collection is repeated every interval, and skipped when the last one is more recent.
+func (r *ReconciliationRunner) CollectResourceQuotas(
+	fds *infrav1.CloudStackFailureDomainList, opts QuotaCollectionOptions,
+) CloudStackReconcilerMethod {
+	return func() (ctrl.Result, error) {
+		if opts.Interval <= 0 {
+			return ctrl.Result{}, nil
+		}
+		// The conditions go to the reconciliation subject, which is patched back, rather than to r.CSCluster, which
+		// GetCSCluster replaces with a copy of its own.
+		subject, ok := r.ReconciliationSubject.(*infrav1.CloudStackCluster)
+		if !ok {
+			return ctrl.Result{}, errors.Errorf("%s has no conditions to report resource limits in", r.ControllerKind)
+		}
+		// Every change to the cluster triggers a reconciliation, so the limits are only read once per interval.
+		if last := subject.Status.QuotasCollectedAt; last != nil {
+			if wait := opts.Interval - time.Since(last.Time); wait > 0 {
+				return ctrl.Result{RequeueAfter: wait}, nil
+			}
+		}
+		clusterName := r.CSCluster.GetLabels()[clusterv1.ClusterNameLabel]
+		requested := map[string]bool{}
+		var requestedNames []string
+		for _, fdSpec := range r.CSCluster.Spec.FailureDomains {
+			requested[fdSpec.Name] = true
+			requestedNames = append(requestedNames, fdSpec.Name)
+		}
+
+		pending, err := r.pendingMachines()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Drop the metrics of failure domains that were removed.
+		QuotaMetrics.RetainResourceQuotas(r.CSCluster.Namespace, clusterName, requestedNames)
+		var lowHeadroom, insufficient, failures, demandFailures []string
+		for idx := range fds.Items {
+			fd := &fds.Items[idx]
+			if !requested[fd.Spec.Name] {
+				continue
+			}
+			user, err := r.failureDomainUser(&fd.Spec)
+			if err != nil {
+				r.Log.Error(err, "failed to collect resource limits", "failureDomain", fd.Spec.Name)
+				failures = append(failures, fmt.Sprintf("%s: %s", fd.Spec.Name, err.Error()))
+				continue
+			}
+			quotas, err := resourceQuotas(user)
+			if err != nil {
+				r.Log.Error(err, "failed to collect resource limits", "failureDomain", fd.Spec.Name)
+				failures = append(failures, fmt.Sprintf("%s: %s", fd.Spec.Name, err.Error()))
+				continue
+			}
+			for _, q := range quotas {
+				QuotaMetrics.SetResourceQuota(r.CSCluster.Namespace, clusterName, fd.Spec.Name, q.Scope, q.Resource, q.Limit, q.Used, q.Available)
+				if !q.Unlimited() && q.Headroom()*100 < float64(opts.HeadroomThreshold) {
+					lowHeadroom = append(lowHeadroom, fmt.Sprintf("%s of %s %s in failure domain %s (%d of %d available)",
+						q.Resource, q.Scope, q.Name, fd.Spec.Name, q.Available, q.Limit))
+				}
+			}
+			shortfalls, err := quotaShortfalls(user, fd, quotas, pending[fd.Spec.Name])
+			if err != nil {
+				r.Log.Error(err, "failed to resolve resources of pending machines", "failureDomain", fd.Spec.Name)
+				demandFailures = append(demandFailures, fmt.Sprintf("%s: %s", fd.Spec.Name, err.Error()))
+				continue
+			}
+			insufficient = append(insufficient, shortfalls...)
+		}
+		r.setQuotaSufficientCondition(subject, insufficient, append(failures, demandFailures...))
+
+		switch {
+		case len(lowHeadroom) > 0:
+			message := fmt.Sprintf("Less than %d%% of the limit left for %s", opts.HeadroomThreshold, strings.Join(lowHeadroom, ", "))
+			// Raise the Event when the headroom drops, rather than at every collection while it stays low.
+			if conditions.GetReason(subject, infrav1.QuotaHeadroomCondition) != infrav1.LowQuotaHeadroomReason {
+				r.Recorder.Event(subject, "Warning", infrav1.LowQuotaHeadroomReason, message)
+			}
+			conditions.MarkFalse(subject, infrav1.QuotaHeadroomCondition,
+				infrav1.LowQuotaHeadroomReason, clusterv1.ConditionSeverityWarning, "%s", message)
+		case len(failures) > 0:
+			conditions.MarkUnknown(subject, infrav1.QuotaHeadroomCondition,
+				infrav1.QuotaCollectionFailedReason, "Failed to collect resource limits of failure domains: %s", strings.Join(failures, "; "))
+		default:
+			conditions.MarkTrue(subject, infrav1.QuotaHeadroomCondition)
+		}
+		now := metav1.Now()
+		subject.Status.QuotasCollectedAt = &now
+		return ctrl.Result{RequeueAfter: opts.Interval}, nil
+	}
+}
+
+// setQuotaSufficientCondition sets the QuotaSufficient condition of the CloudStackCluster from the resources its
+// pending machines lack and the failure domains whose quotas couldn't be checked. A Warning Event is raised when the
+// resources become insufficient.
+func (r *ReconciliationRunner) setQuotaSufficientCondition(csCluster conditions.Setter, insufficient, failures []string) {
+	switch {
+	case len(insufficient) > 0:
+		message := fmt.Sprintf("Pending machines need more than is available of %s", strings.Join(insufficient, ", "))
+		if conditions.GetReason(csCluster, infrav1.QuotaSufficientCondition) != infrav1.InsufficientQuotaReason {
+			r.Recorder.Event(csCluster, "Warning", infrav1.InsufficientQuotaReason, message)
+		}
+		conditions.MarkFalse(csCluster, infrav1.QuotaSufficientCondition,
+			infrav1.InsufficientQuotaReason, clusterv1.ConditionSeverityWarning, "%s", message)
+	case len(failures) > 0:
+		conditions.MarkUnknown(csCluster, infrav1.QuotaSufficientCondition,
+			infrav1.QuotaCollectionFailedReason, "Failed to check resource limits of failure domains: %s", strings.Join(failures, "; "))
+	default:
+		conditions.MarkTrue(csCluster, infrav1.QuotaSufficientCondition)
+	}
+}
+
+// pendingMachines returns the machines of the cluster that aren't deployed yet by the name of the failure domain they
+// are to be deployed in. Machines whose failure domain isn't chosen yet are spread evenly over all failure domains.
+func (r *ReconciliationRunner) pendingMachines() (map[string][]*infrav1.CloudStackMachine, error) {
+	machines := &infrav1.CloudStackMachineList{}
+	if err := r.K8sClient.List(r.RequestCtx, machines, client.InNamespace(r.CSCluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: r.CSCluster.GetLabels()[clusterv1.ClusterNameLabel]}); err != nil {
+		return nil, errors.Wrap(err, "listing machines of the cluster")
+	}
+
+	pending := map[string][]*infrav1.CloudStackMachine{}
+	var unassigned []*infrav1.CloudStackMachine
+	for idx := range machines.Items {
+		machine := &machines.Items[idx]
+		if !machine.DeletionTimestamp.IsZero() || pointer.StringDeref(machine.Spec.InstanceID, "") != "" {
+			continue
+		}
+		if machine.Spec.FailureDomainName == "" {
+			unassigned = append(unassigned, machine)
+			continue
+		}
+		pending[machine.Spec.FailureDomainName] = append(pending[machine.Spec.FailureDomainName], machine)
+	}
+	r.spreadMachines(pending, unassigned)
+	return pending, nil
+}
+
+// spreadMachines adds machines to the failure domains of the cluster in turn.
+func (r *ReconciliationRunner) spreadMachines(byFailureDomain map[string][]*infrav1.CloudStackMachine, machines []*infrav1.CloudStackMachine) {
+	fdSpecs := r.CSCluster.Spec.FailureDomains
+	if len(fdSpecs) == 0 {
+		return
+	}
+	for idx, machine := range machines {
+		name := fdSpecs[idx%len(fdSpecs)].Name
+		byFailureDomain[name] = append(byFailureDomain[name], machine)
+	}
+}
+
+// quotaShortfalls describes each resource the account, domain or project of the failure domain doesn't have enough of
+// to deploy the machines, using the client of the failure domain's user.
+func quotaShortfalls(
+	user cloud.Client, fd *infrav1.CloudStackFailureDomain, quotas []cloud.ResourceQuota, machines []*infrav1.CloudStackMachine,
+) ([]string, error) {
+	if len(machines) == 0 {
+		return nil, nil
+	}
+
+	// Machines mostly come from a handful of templates, so the offering of each distinct sizing is resolved once.
+	type sizing struct {
+		offering    infrav1.CloudStackResourceIdentifier
+		cpu, memory string
+	}
+	counts := map[sizing]int64{}
+	examples := map[sizing]*infrav1.CloudStackMachine{}
+	for _, machine := range machines {
+		// Machines not assigned to a failure domain yet are sized with the overrides of the one they're spread to.
+		example := machine.DeepCopy()
+		example.Spec.FailureDomainName = fd.Spec.Name
+		spec := example.Spec.InFailureDomain(fd.Spec.Name)
+		key := sizing{spec.Offering, spec.Details["cpuNumber"], spec.Details["memory"]}
+		counts[key]++
+		examples[key] = example
+	}
+	demand := cloud.ResourceDemand{}
+	for key, count := range counts {
+		machineDemand, err := user.GetResourceDemand(examples[key], fd.Spec.Zone.ID, count)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolving resources of machine %s", examples[key].Name)
+		}
+		demand.Add(machineDemand)
+	}
+
+	var shortfalls []string
+	for _, q := range cloud.InsufficientQuotas(quotas, demand) {
+		shortfalls = append(shortfalls, fmt.Sprintf("%s of %s %s in failure domain %s (%d needed, %d available)",
+			q.Resource, q.Scope, q.Name, fd.Spec.Name, demand[q.Resource], q.Available))
+	}
+	return shortfalls, nil
+}
+
+// CheckScaleOutQuotas describes each resource the failure domains of a cluster lack to deploy replicas more machines
+// like csMachine on top of the cluster's pending machines. The machines are deployed in the named failure domain, or
+// spread over all failure domains if the name is empty. Clusters that aren't CloudStackClusters have no shortfalls.
+func CheckScaleOutQuotas(
+	ctx context.Context,
+	base ReconcilerBase,
+	capiCluster *clusterv1.Cluster,
+	csMachine *infrav1.CloudStackMachine,
+	failureDomain string,
+	replicas int,
+) ([]string, error) {
+	ref := capiCluster.Spec.InfrastructureRef
+	if ref == nil || ref.Kind != "CloudStackCluster" {
+		return nil, nil
+	}
+	r := (&ReconciliationRunner{ReconcilerBase: &ReconcilerBase{}}).
+		UsingBaseReconciler(base).
+		ForRequest(ctrl.Request{NamespacedName: client.ObjectKey{Namespace: capiCluster.Namespace, Name: ref.Name}}).
+		WithRequestCtx(ctx)
+	r.Log = base.BaseLogger.WithName("QuotaCheck").WithValues("cluster", capiCluster.Name, "namespace", capiCluster.Namespace)
+	r.CAPICluster = capiCluster
+	r.CSCluster = &infrav1.CloudStackCluster{}
+	if err := r.K8sClient.Get(ctx, r.Request.NamespacedName, r.CSCluster); err != nil {
+		return nil, errors.Wrapf(err, "getting CloudStackCluster %s", ref.Name)
+	}
+
+	pending, err := r.pendingMachines()
+	if err != nil {
+		return nil, err
+	}
+	additional := make([]*infrav1.CloudStackMachine, replicas)
+	for idx := range additional {
+		additional[idx] = csMachine
+	}
+	if failureDomain != "" {
+		pending[failureDomain] = append(pending[failureDomain], additional...)
+	} else {
+		r.spreadMachines(pending, additional)
+	}
+
+	fds := &infrav1.CloudStackFailureDomainList{}
+	if _, err := r.GetFailureDomains(fds)(); err != nil {
+		return nil, err
+	}
+	var shortfalls []string
+	for idx := range fds.Items {
+		fd := &fds.Items[idx]
+		if len(pending[fd.Spec.Name]) == 0 {
+			continue
+		}
+		// The check runs in an admission webhook, which mustn't have side effects, so it only uses the API keys the
+		// account already has rather than provisioning them.
+		fdSpec := fd.Spec
+		fdSpec.ProvisionAccountCredentials = false
+		user, err := r.failureDomainUser(&fdSpec)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading resource limits of failure domain %s", fd.Spec.Name)
+		}
+		quotas, err := resourceQuotas(user)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading resource limits of failure domain %s", fd.Spec.Name)
+		}
+		fdShortfalls, err := quotaShortfalls(user, fd, quotas, pending[fd.Spec.Name])
+		if err != nil {
+			return nil, err
+		}
+		shortfalls = append(shortfalls, fdShortfalls...)
+	}
+	return shortfalls, nil
+}
+
+// failureDomainUser returns a client acting as the user of the failure domain, leaving the clients of the runner as
+// they were.
+func (r *ReconciliationRunner) failureDomainUser(fdSpec *infrav1.CloudStackFailureDomainSpec) (cloud.Client, error) {
+	csClient, csUser := r.CSClient, r.CSUser
+	defer func() { r.CSClient, r.CSUser = csClient, csUser }()
+	if _, err := r.AsFailureDomainUser(fdSpec)(); err != nil {
+		return nil, err
+	}
+	return r.CSUser, nil
+}
+
+// resourceQuotas returns the resource limits and usage of the account, domain and project of the user of a client.
+func resourceQuotas(user cloud.Client) ([]cloud.ResourceQuota, error) {
+	quotas, err := user.GetResourceQuotas()
+	return quotas, errors.Wrap(err, "reading resource limits")
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils_test
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/mocks"
+)
+
+// mockCloudClientExtension acts as the users of failure domains with a mock client, recording the failure domain
+// specs it's asked to act as.
+type mockCloudClientExtension struct {
+	*utils.ReconciliationRunner
+	csUser  cloud.Client
+	fdSpecs *[]infrav1.CloudStackFailureDomainSpec
+}
+
+func (m *mockCloudClientExtension) RegisterExtension(r *utils.ReconciliationRunner) utils.CloudClientExtension {
+	return &mockCloudClientExtension{ReconciliationRunner: r, csUser: m.csUser, fdSpecs: m.fdSpecs}
+}
+
+func (m *mockCloudClientExtension) AsFailureDomainUser(fdSpec *infrav1.CloudStackFailureDomainSpec) utils.CloudStackReconcilerMethod {
+	return func() (ctrl.Result, error) {
+		*m.fdSpecs = append(*m.fdSpecs, *fdSpec)
+		m.CSUser = m.csUser
+		return ctrl.Result{}, nil
+	}
+}
+
+var _ = Describe("CheckScaleOutQuotas", func() {
+	const namespace = "default"
+
+	var (
+		mockClient  *mocks.MockClient
+		base        utils.ReconcilerBase
+		capiCluster *clusterv1.Cluster
+		fdSpecs     []infrav1.CloudStackFailureDomainSpec
+		objects     []runtime.Object
+	)
+
+	clusterLabels := map[string]string{clusterv1.ClusterNameLabel: "cluster"}
+	machine := func(name, fdName string, mutate func(*infrav1.CloudStackMachine)) *infrav1.CloudStackMachine {
+		m := &infrav1.CloudStackMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: clusterLabels},
+			Spec: infrav1.CloudStackMachineSpec{
+				FailureDomainName: fdName,
+				Offering:          infrav1.CloudStackResourceIdentifier{Name: "small"},
+			},
+		}
+		if mutate != nil {
+			mutate(m)
+		}
+		return m
+	}
+	demand := func(machines int64) cloud.ResourceDemand {
+		return cloud.ResourceDemand{cloud.QuotaResourceCPU: 2 * machines}
+	}
+	cpuQuota := []cloud.ResourceQuota{
+		{Scope: cloud.QuotaScopeAccount, Name: "admin", Resource: cloud.QuotaResourceCPU, Limit: 10, Used: 6, Available: 4},
+	}
+
+	BeforeEach(func() {
+		mockClient = mocks.NewMockClient(gomock.NewController(GinkgoT()))
+		fdSpecs = nil
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: namespace},
+			Spec: clusterv1.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{Kind: "CloudStackCluster", Name: "cluster"},
+			},
+		}
+		csCluster := &infrav1.CloudStackCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: namespace, Labels: clusterLabels},
+		}
+		objects = []runtime.Object{csCluster}
+		for _, name := range []string{"fd1", "fd2"} {
+			spec := infrav1.CloudStackFailureDomainSpec{
+				Name:                        name,
+				Zone:                        infrav1.CloudStackZoneSpec{ID: name + "-zone"},
+				ProvisionAccountCredentials: true,
+			}
+			csCluster.Spec.FailureDomains = append(csCluster.Spec.FailureDomains, spec)
+			objects = append(objects, &infrav1.CloudStackFailureDomain{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      infrav1.FailureDomainHashedMetaName(name, "cluster"),
+					Namespace: namespace,
+					Labels:    clusterLabels,
+				},
+				Spec: spec,
+			})
+		}
+	})
+
+	check := func(csMachine *infrav1.CloudStackMachine, failureDomain string, replicas int) ([]string, error) {
+		scheme := runtime.NewScheme()
+		Ω(infrav1.AddToScheme(scheme)).Should(Succeed())
+		Ω(clusterv1.AddToScheme(scheme)).Should(Succeed())
+		base = utils.ReconcilerBase{
+			K8sClient:            fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build(),
+			Scheme:               scheme,
+			BaseLogger:           logr.Discard(),
+			CloudClientExtension: &mockCloudClientExtension{csUser: mockClient, fdSpecs: &fdSpecs},
+		}
+		return utils.CheckScaleOutQuotas(context.Background(), base, capiCluster, csMachine, failureDomain, replicas)
+	}
+
+	It("counts pending machines in their failure domain and spreads the others", func() {
+		objects = append(objects,
+			machine("pending", "fd1", nil),
+			machine("unassigned", "", nil),
+			machine("deployed", "fd2", func(m *infrav1.CloudStackMachine) { m.Spec.InstanceID = pointer.String("vm") }),
+			machine("deleting", "fd2", func(m *infrav1.CloudStackMachine) {
+				m.Finalizers = []string{infrav1.MachineFinalizer}
+				m.DeletionTimestamp = &metav1.Time{Time: metav1.Now().Time}
+			}),
+		)
+		mockClient.EXPECT().GetResourceQuotas().Return(cpuQuota, nil).Times(2)
+		// fd1 gets the pending machine, the unassigned one and the first added one, fd2 the second added one.
+		mockClient.EXPECT().GetResourceDemand(gomock.Any(), "fd1-zone", int64(3)).Return(demand(3), nil)
+		mockClient.EXPECT().GetResourceDemand(gomock.Any(), "fd2-zone", int64(1)).Return(demand(1), nil)
+
+		shortfalls, err := check(machine("template", "", nil), "", 2)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(shortfalls).Should(Equal([]string{"cpu of account admin in failure domain fd1 (6 needed, 4 available)"}))
+	})
+
+	It("adds the machines to the named failure domain only", func() {
+		mockClient.EXPECT().GetResourceQuotas().Return(cpuQuota, nil)
+		mockClient.EXPECT().GetResourceDemand(gomock.Any(), "fd2-zone", int64(2)).Return(demand(2), nil)
+
+		shortfalls, err := check(machine("template", "", nil), "fd2", 2)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(shortfalls).Should(BeEmpty())
+	})
+
+	It("resolves the resources of each distinct sizing once", func() {
+		objects = append(objects,
+			machine("small", "fd1", nil),
+			machine("large", "fd1", func(m *infrav1.CloudStackMachine) { m.Spec.Offering.Name = "large" }),
+		)
+		mockClient.EXPECT().GetResourceQuotas().Return(cpuQuota, nil)
+		offerings := map[string]int64{}
+		mockClient.EXPECT().GetResourceDemand(gomock.Any(), "fd1-zone", gomock.Any()).Times(2).DoAndReturn(
+			func(csMachine *infrav1.CloudStackMachine, _ string, count int64) (cloud.ResourceDemand, error) {
+				offerings[csMachine.Spec.Offering.Name] = count
+				return demand(count), nil
+			})
+
+		shortfalls, err := check(machine("template", "", nil), "fd1", 1)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(offerings).Should(Equal(map[string]int64{"small": 2, "large": 1}))
+		Ω(shortfalls).Should(Equal([]string{"cpu of account admin in failure domain fd1 (6 needed, 4 available)"}))
+	})
+
+	It("only uses the API keys accounts already have", func() {
+		mockClient.EXPECT().GetResourceQuotas().Return(cpuQuota, nil)
+		mockClient.EXPECT().GetResourceDemand(gomock.Any(), "fd1-zone", int64(1)).Return(demand(1), nil)
+
+		_, err := check(machine("template", "", nil), "fd1", 1)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(fdSpecs).Should(HaveLen(1))
+		Ω(fdSpecs[0].ProvisionAccountCredentials).Should(BeFalse())
+	})
+
+	It("has no shortfalls for clusters that aren't CloudStackClusters", func() {
+		capiCluster.Spec.InfrastructureRef.Kind = "OtherCluster"
+		shortfalls, err := check(machine("template", "", nil), "", 1)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(shortfalls).Should(BeEmpty())
+	})
+})
@@ -159,14 +159,6 @@ func GetOwnerOfKind(ctx context.Context, c clientPkg.Client, owned clientPkg.Obj
 	return errors.Errorf("couldn't find owner of kind %s in namespace %s", gvk.Kind, owned.GetNamespace())
 }
 
-func ContainsNoMatchSubstring(err error) bool {
-	return strings.Contains(strings.ToLower(err.Error()), "no match")
-}
-
-func ContainsAlreadyExistsSubstring(err error) bool {
-	return strings.Contains(strings.ToLower(err.Error()), "already exists")
-}
-
 // WithClusterSuffix appends a hyphen and the cluster name to a name if not already present.
 func WithClusterSuffix(name string, clusterName string) string {
 	newName := name
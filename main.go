@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
@@ -50,6 +51,7 @@ import (
 	infrav1b3 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -87,6 +89,11 @@ type managerOpts struct {
 	CloudStackAffinityGroupConcurrency int
 	CloudStackFailureDomainConcurrency int
 	EnableCloudStackCksSync            bool
+	QuotaCollectionInterval            time.Duration
+	QuotaHeadroomThreshold             int
+	EnableQuotaAdmission               bool
+	EnableTracing                      bool
+	Tracing                            tracing.Options
 }
 
 func setFlags() *managerOpts {
@@ -161,6 +168,48 @@ func setFlags() *managerOpts {
 		false,
 		"Enable syncing of CloudStack clusters and machines with CKS clusters and machines",
 	)
+	flag.DurationVar(
+		&opts.QuotaCollectionInterval,
+		"quota-collection-interval",
+		5*time.Minute,
+		"How often the CloudStack resource limits of each cluster's failure domains are collected. Set to 0 to disable collection.",
+	)
+	flag.IntVar(
+		&opts.QuotaHeadroomThreshold,
+		"quota-headroom-threshold",
+		10,
+		"Percentage of a CloudStack resource limit under which the remaining headroom is reported on the CloudStackCluster",
+	)
+	flag.BoolVar(
+		&opts.EnableQuotaAdmission,
+		"enable-quota-admission",
+		false,
+		"Reject replica increases of MachineDeployments whose additional machines exceed the CloudStack resource limits of their cluster's failure domains",
+	)
+	flag.BoolVar(
+		&opts.EnableTracing,
+		"enable-tracing",
+		false,
+		"Enable OpenTelemetry tracing of reconciliations and CloudStack API calls, exported over OTLP gRPC",
+	)
+	flag.StringVar(
+		&opts.Tracing.Endpoint,
+		"tracing-endpoint",
+		"localhost:4317",
+		"The host and port of the OTLP gRPC collector traces are exported to.",
+	)
+	flag.BoolVar(
+		&opts.Tracing.Insecure,
+		"tracing-insecure",
+		false,
+		"Connect to the OTLP collector without TLS.",
+	)
+	flag.Float64Var(
+		&opts.Tracing.SamplingRatio,
+		"tracing-sampling-ratio",
+		1,
+		"Share of reconciliations that are traced, from 0 to 1.",
+	)
 
 	return opts
 }
@@ -206,6 +255,7 @@ func main() {
 	// Register reconcilers with the controller manager.
 	base := utils.ReconcilerBase{
 		K8sClient:  mgr.GetClient(),
+		APIReader:  mgr.GetAPIReader(),
 		BaseLogger: ctrl.Log.WithName("controllers"),
 		Recorder:   mgr.GetEventRecorderFor("capc-controller-manager"),
 		Scheme:     mgr.GetScheme(),
@@ -240,6 +290,32 @@ func main() {
 		setupLog.Error(err, "unable to create webhook", "webhook", "CloudStackMachineTemplate")
 		os.Exit(1)
 	}
+	if err = (&infrav1b3.CloudStackIPAddressPool{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "CloudStackIPAddressPool")
+		os.Exit(1)
+	}
+	if opts.EnableQuotaAdmission {
+		if err = (&controllers.MachineDeploymentQuotaValidator{ReconcilerBase: base}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MachineDeployment")
+			os.Exit(1)
+		}
+	}
+
+	if opts.EnableTracing {
+		shutdownTracing, err := tracing.Setup(ctx, opts.Tracing)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+		defer func() {
+			// Flush the spans of the last reconciliations.
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(shutdownCtx); err != nil {
+				setupLog.Error(err, "unable to flush traces")
+			}
+		}()
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctx); err != nil {
@@ -249,7 +325,8 @@ func main() {
 }
 
 func setupReconcilers(ctx context.Context, base utils.ReconcilerBase, opts managerOpts, mgr manager.Manager) {
-	if err := (&controllers.CloudStackClusterReconciler{ReconcilerBase: base}).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: opts.CloudStackClusterConcurrency}); err != nil {
+	quotaCollection := utils.QuotaCollectionOptions{Interval: opts.QuotaCollectionInterval, HeadroomThreshold: opts.QuotaHeadroomThreshold}
+	if err := (&controllers.CloudStackClusterReconciler{ReconcilerBase: base, QuotaCollection: quotaCollection}).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: opts.CloudStackClusterConcurrency}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CloudStackCluster")
 		os.Exit(1)
 	}
@@ -47,7 +47,7 @@ func (c *client) FetchAffinityGroup(group *AffinityGroup) (reterr error) {
 		if err != nil {
 			// handle via multierr
 			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-			return err
+			return classifyError(err)
 		} else if count > 1 {
 			// handle via creating a new error.
 			return errors.New("count bad")
@@ -62,7 +62,7 @@ func (c *client) FetchAffinityGroup(group *AffinityGroup) (reterr error) {
 		if err != nil {
 			// handle via multierr
 			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-			return err
+			return classifyError(err)
 		} else if count > 1 {
 			// handle via creating a new error.
 			return errors.New("count bad")
@@ -83,7 +83,7 @@ func (c *client) GetOrCreateAffinityGroup(group *AffinityGroup) (retErr error) {
 		resp, err := c.cs.AffinityGroup.CreateAffinityGroup(p)
 		if err != nil {
 			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-			return err
+			return classifyError(err)
 		}
 		group.ID = resp.Id
 	}
@@ -97,7 +97,7 @@ func (c *client) DeleteAffinityGroup(group *AffinityGroup) (retErr error) {
 	setIfNotEmpty(c.user.Project.ID, p.SetProjectid)
 	_, retErr = c.cs.AffinityGroup.DeleteAffinityGroup(p)
 	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
-	return retErr
+	return classifyError(retErr)
 }
 
 type affinityGroups []AffinityGroup
@@ -106,7 +106,7 @@ func (c *client) getCurrentAffinityGroups(csMachine *infrav1.CloudStackMachine)
 	// Start by fetching VM details which includes an array of currently associated affinity groups.
 	if virtM, count, err := c.cs.VirtualMachine.GetVirtualMachineByID(*csMachine.Spec.InstanceID, cloudstack.WithProject(c.user.Project.ID)); err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return nil, err
+		return nil, classifyError(err)
 	} else if count > 1 {
 		return nil, errors.Errorf("found more than one VM for ID: %s", *csMachine.Spec.InstanceID)
 	} else {
@@ -158,18 +158,18 @@ func (c *client) stopAndModifyAffinityGroups(csMachine *infrav1.CloudStackMachin
 	p1 := c.cs.VirtualMachine.NewStopVirtualMachineParams(string(*csMachine.Spec.InstanceID))
 	if _, err := c.cs.VirtualMachine.StopVirtualMachine(p1); err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return err
+		return classifyError(err)
 	}
 
 	if _, err := c.cs.AffinityGroup.UpdateVMAffinityGroup(agp); err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return err
+		return classifyError(err)
 	}
 
 	p2 := c.cs.VirtualMachine.NewStartVirtualMachineParams(string(*csMachine.Spec.InstanceID))
 	_, err := c.cs.VirtualMachine.StartVirtualMachine(p2)
 	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-	return err
+	return classifyError(err)
 }
 
 func (c *client) AssociateAffinityGroup(csMachine *infrav1.CloudStackMachine, group AffinityGroup) (retErr error) {
@@ -18,7 +18,6 @@ package cloud
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/apache/cloudstack-go/v2/cloudstack"
 	"github.com/pkg/errors"
@@ -53,7 +52,7 @@ func (c *client) GetOrCreateCksCluster(cluster *clusterv1.Cluster, csCluster *in
 	if csCluster.Status.CloudStackClusterID != "" {
 		externalManagedCluster, count, err := c.cs.Kubernetes.GetKubernetesClusterByID(csCluster.Status.CloudStackClusterID, withExternalManaged(), cloudstack.WithProject(c.user.Project.ID))
 		if err != nil {
-			return err
+			return classifyError(err)
 		} else if count > 0 {
 			csCluster.Status.CloudStackClusterID = externalManagedCluster.Id
 			return nil
@@ -63,18 +62,22 @@ func (c *client) GetOrCreateCksCluster(cluster *clusterv1.Cluster, csCluster *in
 	// Check if a cluster exists with the same name
 	clusterName := fmt.Sprintf("%s - %s - %s", cluster.GetName(), csCluster.GetName(), csCluster.GetUID())
 	externalManagedCluster, count, err := c.cs.Kubernetes.GetKubernetesClusterByName(clusterName, withExternalManaged(), cloudstack.WithProject(c.user.Project.ID))
-	if err != nil && !strings.Contains(err.Error(), "No match found for ") {
+	if err = classifyError(err); err != nil && !errors.Is(err, ErrNotFound) {
 		return err
 	}
 	if count > 0 {
 		csCluster.Status.CloudStackClusterID = externalManagedCluster.Id
-	} else if err == nil || (err != nil && strings.Contains(err.Error(), "No match found for ")) {
+	} else {
 		// Create cluster
 		accountName := csCluster.Spec.FailureDomains[0].Account
+		if accountName == "" && c.config.usesSession() {
+			// Clients logged in with a username and password have no API key to look the user up by.
+			accountName = c.user.Account.Name
+		}
 		if accountName == "" {
 			userParams := c.cs.User.NewGetUserParams(c.config.APIKey)
 			user, err := c.cs.User.GetUser(userParams)
-			if err != nil && !strings.Contains(err.Error(), "does not exist or is not available for the account") {
+			if err = classifyError(err); err != nil && !errors.Is(err, ErrPermissionDenied) {
 				return err
 			} else if err == nil {
 				accountName = user.Account
@@ -94,7 +97,7 @@ func (c *client) GetOrCreateCksCluster(cluster *clusterv1.Cluster, csCluster *in
 
 		cloudStackCKSCluster, err := c.cs.Kubernetes.CreateKubernetesCluster(params)
 		if err != nil {
-			return err
+			return classifyError(err)
 		}
 		csCluster.Status.CloudStackClusterID = cloudStackCKSCluster.Id
 	}
@@ -105,14 +108,14 @@ func (c *client) DeleteCksCluster(csCluster *infrav1.CloudStackCluster) error {
 	if csCluster.Status.CloudStackClusterID != "" {
 		csCksCluster, count, err := c.cs.Kubernetes.GetKubernetesClusterByID(
 			csCluster.Status.CloudStackClusterID, withExternalManaged(), cloudstack.WithProject(c.user.Project.ID))
-		if err != nil && strings.Contains(err.Error(), " not found") {
+		if err = classifyError(err); errors.Is(err, ErrNotFound) {
 			return nil
 		}
 		if count != 0 {
 			params := c.cs.Kubernetes.NewDeleteKubernetesClusterParams(csCksCluster.Id)
 			_, err = c.cs.Kubernetes.DeleteKubernetesCluster(params)
 			if err != nil {
-				return err
+				return classifyError(err)
 			}
 		}
 		csCluster.Status.CloudStackClusterID = ""
@@ -129,7 +132,7 @@ func (c *client) AddVMToCksCluster(csCluster *infrav1.CloudStackCluster, csMachi
 		}
 
 		_, err := c.cs.Kubernetes.AddVirtualMachinesToKubernetesCluster(params)
-		return err
+		return classifyError(err)
 	}
 	return nil
 }
@@ -138,7 +141,7 @@ func (c *client) RemoveVMFromCksCluster(csCluster *infrav1.CloudStackCluster, cs
 	if csCluster.Status.CloudStackClusterID != "" {
 		params := c.cs.Kubernetes.NewRemoveVirtualMachinesFromKubernetesClusterParams(csCluster.Status.CloudStackClusterID, []string{*csMachine.Spec.InstanceID})
 		_, err := c.cs.Kubernetes.RemoveVirtualMachinesFromKubernetesCluster(params)
-		return err
+		return classifyError(err)
 	}
 	return nil
 }
@@ -18,8 +18,15 @@ package cloud
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"math"
+	"net/http"
+	"net/url"
 	"os"
 	"sync"
 	"time"
@@ -32,6 +39,7 @@ import (
 	"github.com/apache/cloudstack-go/v2/cloudstack"
 	"github.com/jellydator/ttlcache/v3"
 	"github.com/pkg/errors"
+	"golang.org/x/net/http/httpproxy"
 )
 
 //go:generate ../../hack/tools/bin/mockgen -destination=../mocks/mock_client.go -package=mocks sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud Client
@@ -45,7 +53,11 @@ type Client interface {
 	ZoneIFace
 	IsoNetworkIface
 	UserCredIFace
+	QuotaIface
 	NewClientInDomainAndAccount(string, string, string) (Client, error)
+	NewClientWithAPIKeys(string, string, string) (Client, error)
+	WithTracing(func() context.Context) Client
+	ActiveAPIURL() string
 }
 
 // cloud-config ini structure.
@@ -54,14 +66,55 @@ type Config struct {
 	APIKey    string `yaml:"api-key"`
 	SecretKey string `yaml:"secret-key"`
 	VerifySSL string `yaml:"verify-ssl"`
+
+	// Username and password to log in with instead of API keys, and the path of the user's domain, e.g. /sub, empty
+	// for ROOT.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Domain   string `yaml:"domain"`
+
+	// Further API URLs of the endpoint's management servers to fail over to in order.
+	APIUrls APIURLList `yaml:"api-urls"`
+
+	// PEM encoded certificates of the CAs to verify the endpoint against, instead of the system ones.
+	CABundle string `yaml:"ca-bundle"`
+	// PEM encoded certificate and key to authenticate to the endpoint with.
+	ClientCert string `yaml:"client-cert"`
+	ClientKey  string `yaml:"client-key"`
+
+	// Proxy to reach the endpoint through instead of the one from the environment, and hosts to reach directly.
+	HTTPProxy string `yaml:"http-proxy"`
+	NoProxy   string `yaml:"no-proxy"`
+	// Timeouts of API calls and of waiting for async jobs to finish, as durations such as "90s".
+	Timeout         string `yaml:"timeout"`
+	AsyncJobTimeout string `yaml:"async-job-timeout"`
+
+	APIRateLimit      string `yaml:"api-rate-limit"`
+	APIRateLimitBurst string `yaml:"api-rate-limit-burst"`
+	APIMaxInFlight    string `yaml:"api-max-in-flight"`
 }
 
 type client struct {
 	cs            *cloudstack.CloudStackClient
 	csAsync       *cloudstack.CloudStackClient
 	config        Config
+	clientConfig  *corev1.ConfigMap
 	user          *User
 	customMetrics metrics.ACSCustomMetrics
+	retryPolicy   RetryPolicy
+	secret        secretVersion
+	failover      *endpointFailover
+	session       *sessionAuth
+	// httpClient and asyncJobTimeout are kept to create traced copies of the CloudStack API clients.
+	httpClient      *http.Client
+	asyncJobTimeout int64
+	networkDomains  *ttlcache.Cache[string, string] // Network domains by network ID.
+}
+
+// secretVersion identifies the version of the endpoint secret a client was created from.
+type secretVersion struct {
+	name            string
+	resourceVersion string
 }
 
 type SecretConfig struct {
@@ -82,6 +135,8 @@ const ClientConfigMapName = "capc-client-config"
 const ClientConfigMapNamespace = "capc-system"
 const ClientCacheTTLKey = "client-cache-ttl"
 const DefaultClientCacheTTL = time.Duration(1 * time.Hour)
+const DefaultAPITimeout = time.Duration(60 * time.Second)
+const DefaultAsyncJobTimeout = time.Duration(300 * time.Second)
 
 // UnmarshalAllSecretConfigs parses a yaml document for each secret.
 func UnmarshalAllSecretConfigs(in []byte, out *[]SecretConfig) error {
@@ -101,7 +156,8 @@ func UnmarshalAllSecretConfigs(in []byte, out *[]SecretConfig) error {
 	return nil
 }
 
-// NewClientFromK8sSecret returns a client from a k8s secret
+// NewClientFromK8sSecret returns a client from a k8s secret. Cached clients created from an older version of the
+// secret are dropped, so that rotated credentials are used right away.
 func NewClientFromK8sSecret(endpointSecret *corev1.Secret, clientConfig *corev1.ConfigMap, project string) (Client, error) {
 	endpointSecretStrings := map[string]string{}
 	for k, v := range endpointSecret.Data {
@@ -111,7 +167,22 @@ func NewClientFromK8sSecret(endpointSecret *corev1.Secret, clientConfig *corev1.
 	if err != nil {
 		return nil, err
 	}
-	return NewClientFromBytesConfig(bytes, clientConfig, project)
+	var config Config
+	if err := yaml.Unmarshal(bytes, &config); err != nil {
+		return nil, err
+	}
+	// Report broken TLS material as a problem of the secret rather than of the connection to the endpoint.
+	if _, err := newTLSConfig(config, true); err != nil {
+		return nil, &classifiedError{class: ErrInvalidSpec, err: errors.Wrapf(err, "endpoint secret %s/%s",
+			endpointSecret.Namespace, endpointSecret.Name)}
+	}
+
+	secret := secretVersion{
+		name:            endpointSecret.Namespace + "/" + endpointSecret.Name,
+		resourceVersion: endpointSecret.ResourceVersion,
+	}
+	invalidateClientsOfSecret(secret)
+	return newClientFromConf(config, clientConfig, project, secret)
 }
 
 // NewClientFromBytesConfig returns a client from a bytes array that unmarshals to a yaml config.
@@ -143,7 +214,7 @@ func NewClientFromYamlPath(confPath string, secretName string) (Client, error) {
 			break
 		}
 	}
-	if conf.APIKey == "" {
+	if conf.APIKey == "" && conf.Username == "" {
 		return nil, errors.Errorf("config with secret name %s not found", secretName)
 	}
 
@@ -152,6 +223,11 @@ func NewClientFromYamlPath(confPath string, secretName string) (Client, error) {
 
 // NewClientFromConf creates a new Cloud Client form a map of strings to strings.
 func NewClientFromConf(conf Config, clientConfig *corev1.ConfigMap, project string) (Client, error) {
+	return newClientFromConf(conf, clientConfig, project, secretVersion{})
+}
+
+// newClientFromConf creates a new Cloud Client, or returns a cached one, for a config read from the passed secret.
+func newClientFromConf(conf Config, clientConfig *corev1.ConfigMap, project string, secret secretVersion) (Client, error) {
 	cacheMutex.Lock()
 	defer cacheMutex.Unlock()
 
@@ -159,7 +235,7 @@ func NewClientFromConf(conf Config, clientConfig *corev1.ConfigMap, project stri
 		clientCache = newClientCache(clientConfig)
 	}
 
-	clientCacheKey := generateClientCacheKey(conf)
+	clientCacheKey := generateClientCacheKey(conf, secret)
 	if item := clientCache.Get(clientCacheKey); item != nil {
 		return item.Value(), nil
 	}
@@ -172,15 +248,47 @@ func NewClientFromConf(conf Config, clientConfig *corev1.ConfigMap, project stri
 	// The client returned from NewAsyncClient works in a synchronous way. On the other hand,
 	// a client returned from NewClient works in an asynchronous way. Dive into the constructor definition
 	// comments for more details
-	c := &client{config: conf}
-	c.cs = NewClient(conf.APIUrl, conf.APIKey, conf.SecretKey, verifySSL)
-	c.csAsync = NewAsyncClient(conf.APIUrl, conf.APIKey, conf.SecretKey, verifySSL)
+	c := &client{config: conf, clientConfig: clientConfig, secret: secret, networkDomains: newNetworkDomainCache()}
 	c.customMetrics = metrics.NewCustomMetrics()
+	c.retryPolicy = GetRetryPolicy(clientConfig)
+	apiURLs, err := conf.apiURLs()
+	if err != nil {
+		return nil, err
+	}
+	c.failover = getEndpointFailover(apiURLs)
+	transport, err := newTransport(conf, verifySSL)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := parseTimeout(conf.Timeout, DefaultAPITimeout, "timeout")
+	if err != nil {
+		return nil, err
+	}
+	asyncJobTimeout, err := parseTimeout(conf.AsyncJobTimeout, DefaultAsyncJobTimeout, "async-job-timeout")
+	if err != nil {
+		return nil, err
+	}
+	asyncJobTimeoutSeconds := int64(math.Ceil(asyncJobTimeout.Seconds()))
+	if conf.usesSession() {
+		if c.session, err = newSessionAuth(conf, apiURLs[0].String(), c.newEndpointTransport(transport)); err != nil {
+			return nil, err
+		}
+	}
+	c.httpClient, c.asyncJobTimeout = c.newHTTPClient(transport, timeout), asyncJobTimeoutSeconds
+	c.cs, c.csAsync = c.newCSClients(c.httpClient)
+
+	if c.session != nil {
+		if err := c.resolveSessionUser(project, timeout); err != nil {
+			return nil, err
+		}
+		clientCache.Set(clientCacheKey, c, ttlcache.DefaultTTL)
+		return c, nil
+	}
 
 	p := c.cs.User.NewListUsersParams()
 	userResponse, err := c.cs.User.ListUsers(p)
 	if err != nil {
-		return c, err
+		return c, classifyError(err)
 	}
 	user := &User{
 		ID: userResponse.Users[0].Id,
@@ -206,23 +314,75 @@ func NewClientFromConf(conf Config, clientConfig *corev1.ConfigMap, project stri
 	return c, nil
 }
 
-// NewClientInDomainAndAccount returns a new client in the specified domain and account.
+// resolveSessionUser logs in with the username and password of the client's config, and resolves the account and
+// project of the logged in user.
+func (c *client) resolveSessionUser(project string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	session, err := c.session.current(ctx)
+	if err != nil {
+		return err
+	}
+	user := &User{
+		ID:   session.user.Userid,
+		Name: session.user.Username,
+		Account: Account{
+			Name: session.user.Account,
+			Domain: Domain{
+				ID: session.user.Domainid,
+			},
+		},
+		Project: Project{
+			Name: project,
+		},
+	}
+	if err := c.ResolveAccount(&user.Account); err != nil {
+		return errors.Wrapf(err, "resolving account %s details", user.Account.Name)
+	}
+	if err := c.ResolveProject(user); err != nil {
+		return errors.Wrapf(err, "resolving project %s details", user.Project.Name)
+	}
+	c.user = user
+	return nil
+}
+
+// NewClientInDomainAndAccount returns a new client in the specified domain and account. Clients logged in with a
+// username and password keep their session and name the account in their calls instead.
 func (c *client) NewClientInDomainAndAccount(domain string, account string, project string) (Client, error) {
 	user := &User{}
 	user.Account.Domain.Path = domain
 	user.Account.Name = account
 	user.Project.Name = project
+	if c.session != nil {
+		return c.newClientInAccountScope(user)
+	}
 	if found, err := c.GetUserWithKeys(user); err != nil {
 		return nil, err
 	} else if !found {
-		return nil, errors.Errorf(
+		return nil, classifiedErrorf(ErrNotFound,
 			"could not find sufficient user (with API keys) in domain/account %s/%s", domain, account)
 	}
 	c.config.APIKey = user.APIKey
 	c.config.SecretKey = user.SecretKey
 	c.user = user
 
-	return NewClientFromConf(c.config, nil, project)
+	return newClientFromConf(c.config, c.clientConfig, project, c.secret)
+}
+
+// NewClientWithAPIKeys returns a new client of the same endpoint that authenticates with the specified API keys.
+func (c *client) NewClientWithAPIKeys(apiKey string, secretKey string, project string) (Client, error) {
+	conf := c.config
+	conf.APIKey = apiKey
+	conf.SecretKey = secretKey
+	return newClientFromConf(conf, c.clientConfig, project, c.secret)
+}
+
+// ActiveAPIURL returns the API URL of the management server the client currently sends calls to.
+func (c *client) ActiveAPIURL() string {
+	if c.failover == nil {
+		return c.config.APIUrl
+	}
+	return c.failover.activeURL()
 }
 
 // NewClientFromCSAPIClient creates a client from a CloudStack-Go API client. Used only for testing.
@@ -231,28 +391,150 @@ func NewClientFromCSAPIClient(cs *cloudstack.CloudStackClient, user *User) Clien
 		user = &User{
 			Account: Account{
 				Domain: Domain{
-					CPUAvailable:    "Unlimited",
-					MemoryAvailable: "Unlimited",
-					VMAvailable:     "Unlimited",
+					CPUAvailable:            "Unlimited",
+					MemoryAvailable:         "Unlimited",
+					VMAvailable:             "Unlimited",
+					VolumeAvailable:         "Unlimited",
+					PrimaryStorageAvailable: "Unlimited",
 				},
-				CPUAvailable:    "Unlimited",
-				MemoryAvailable: "Unlimited",
-				VMAvailable:     "Unlimited",
+				CPUAvailable:            "Unlimited",
+				MemoryAvailable:         "Unlimited",
+				VMAvailable:             "Unlimited",
+				VolumeAvailable:         "Unlimited",
+				PrimaryStorageAvailable: "Unlimited",
 			},
 		}
 	}
 	c := &client{
-		cs:            cs,
-		csAsync:       cs,
-		customMetrics: metrics.NewCustomMetrics(),
-		user:          user,
+		cs:             cs,
+		csAsync:        cs,
+		customMetrics:  metrics.NewCustomMetrics(),
+		retryPolicy:    GetRetryPolicy(nil),
+		user:           user,
+		networkDomains: newNetworkDomainCache(),
 	}
 	return c
 }
 
-// generateClientCacheKey generates a cache key from a Config
-func generateClientCacheKey(conf Config) string {
-	return fmt.Sprintf("%+v", conf)
+// newHTTPClient returns an HTTP client for the CloudStack API that retries read-only calls failing with a transient
+// error, authenticates calls with the login session of the client if it has one, fails over to another management
+// server of the endpoint when the active one fails, and holds calls back according to the rate limits of the endpoint.
+func (c *client) newHTTPClient(transport http.RoundTripper, timeout time.Duration) *http.Client {
+	endpoint := c.newEndpointTransport(transport)
+	if c.session != nil {
+		endpoint = &sessionTransport{next: endpoint, auth: c.session}
+	}
+	return &http.Client{
+		Transport: &retryTransport{next: endpoint, policy: c.retryPolicy, customMetrics: c.customMetrics},
+		Timeout:   timeout,
+	}
+}
+
+// newCSClients returns the CloudStack API clients of the client's endpoint config, sending calls with the HTTP client.
+func (c *client) newCSClients(httpClient *http.Client) (cs, csAsync *cloudstack.CloudStackClient) {
+	// The failover transport sends calls to the active API URL, whatever URL the clients are created with.
+	apiURL, verifySSL := c.failover.urls[0].String(), c.config.VerifySSL != "false"
+	cs = NewClient(apiURL, c.config.APIKey, c.config.SecretKey, verifySSL,
+		cloudstack.WithHTTPClient(httpClient), cloudstack.WithAsyncTimeout(c.asyncJobTimeout))
+	csAsync = NewAsyncClient(apiURL, c.config.APIKey, c.config.SecretKey, verifySSL,
+		cloudstack.WithHTTPClient(httpClient), cloudstack.WithAsyncTimeout(c.asyncJobTimeout))
+	return cs, csAsync
+}
+
+// newEndpointTransport returns an http.RoundTripper that sends calls to the active management server of the endpoint,
+// within the rate limits of the endpoint, and records metrics of the calls.
+func (c *client) newEndpointTransport(transport http.RoundTripper) http.RoundTripper {
+	limited := &rateLimitTransport{
+		next:          &instrumentedTransport{next: transport, customMetrics: c.customMetrics},
+		limiter:       getEndpointLimiter(c.failover.urls[0].String(), GetRateLimits(c.config)),
+		customMetrics: c.customMetrics,
+	}
+	return &failoverTransport{next: limited, failover: c.failover}
+}
+
+// newTransport returns the HTTP transport for connecting to the endpoint, with the TLS and proxy settings of the
+// endpoint config.
+func newTransport(conf Config, verifySSL bool) (*http.Transport, error) {
+	tlsConfig, err := newTLSConfig(conf, verifySSL)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	if conf.HTTPProxy != "" || conf.NoProxy != "" {
+		proxyConfig := httpproxy.FromEnvironment()
+		if conf.HTTPProxy != "" {
+			if proxyURL, err := url.Parse(conf.HTTPProxy); err != nil || proxyURL.Host == "" {
+				return nil, errors.Errorf("invalid http-proxy %q: expected a URL such as http://proxy:3128", conf.HTTPProxy)
+			}
+			proxyConfig.HTTPProxy = conf.HTTPProxy
+			proxyConfig.HTTPSProxy = conf.HTTPProxy
+		}
+		if conf.NoProxy != "" {
+			proxyConfig.NoProxy = conf.NoProxy
+		}
+		proxyFunc := proxyConfig.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) { return proxyFunc(req.URL) }
+	}
+	return transport, nil
+}
+
+// parseTimeout parses a timeout of the endpoint config, returning the default one if it isn't set.
+func parseTimeout(timeout string, defaultTimeout time.Duration, key string) (time.Duration, error) {
+	if timeout == "" {
+		return defaultTimeout, nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil || d <= 0 {
+		return 0, errors.Errorf("invalid %s %q: expected a positive duration such as 90s", key, timeout)
+	}
+	return d, nil
+}
+
+// newTLSConfig returns the TLS config for connecting to the endpoint, from the CA bundle and client certificate of the
+// endpoint config.
+func newTLSConfig(conf Config, verifySSL bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: !verifySSL} // #nosec G402 -- Opted out of via verify-ssl.
+	if conf.CABundle != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(conf.CABundle)) {
+			return nil, errors.New("invalid ca-bundle: no PEM encoded certificate found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if conf.ClientCert != "" || conf.ClientKey != "" {
+		if conf.ClientCert == "" || conf.ClientKey == "" {
+			return nil, errors.New("invalid client certificate: client-cert and client-key must be set together")
+		}
+		cert, err := tls.X509KeyPair([]byte(conf.ClientCert), []byte(conf.ClientKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// generateClientCacheKey generates a cache key from a Config and the secret it was read from, so that the clients of a
+// secret can be dropped without affecting others. The key is hashed to keep credentials out of it.
+func generateClientCacheKey(conf Config, secret secretVersion) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("%s %+v", secret.name, conf))))
+}
+
+// invalidateClientsOfSecret removes the cached clients created from another version of the passed secret.
+func invalidateClientsOfSecret(secret secretVersion) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	if clientCache == nil {
+		return
+	}
+	for key, item := range clientCache.Items() {
+		if cached := item.Value().secret; cached.name == secret.name && cached.resourceVersion != secret.resourceVersion {
+			clientCache.Delete(key)
+		}
+	}
 }
 
 // newClientCache returns a new instance of client cache
@@ -267,6 +549,15 @@ func newClientCache(clientConfig *corev1.ConfigMap) *ttlcache.Cache[string, *cli
 	return cache
 }
 
+// newNetworkDomainCache returns a cache of network domains. The domain of a network rarely changes, so entries are kept
+// for as long as clients are cached by default.
+func newNetworkDomainCache() *ttlcache.Cache[string, string] {
+	return ttlcache.New[string, string](
+		ttlcache.WithTTL[string, string](DefaultClientCacheTTL),
+		ttlcache.WithDisableTouchOnHit[string, string](),
+	)
+}
+
 // GetClientCacheTTL returns a client cache TTL duration from the passed config map
 func GetClientCacheTTL(clientConfig *corev1.ConfigMap) time.Duration {
 	var cacheTTL time.Duration
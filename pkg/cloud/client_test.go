@@ -17,17 +17,31 @@ limitations under the License.
 package cloud_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
 	"os"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/apache/cloudstack-go/v2/cloudstack"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/test/helpers"
 )
 
@@ -112,6 +126,69 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Context("GetRetryPolicy", func() {
+		defaultPolicy := cloud.RetryPolicy{
+			MaxAttempts:    cloud.DefaultRetryMaxAttempts,
+			InitialBackoff: cloud.DefaultRetryInitialBackoff,
+			MaxBackoff:     cloud.DefaultRetryMaxBackoff,
+		}
+
+		It("Returns the default policy when a nil is passed", func() {
+			Ω(cloud.GetRetryPolicy(nil)).Should(Equal(defaultPolicy))
+		})
+
+		It("Returns the default policy when the values are invalid", func() {
+			clientConfig := &corev1.ConfigMap{Data: map[string]string{
+				cloud.RetryMaxAttemptsKey:    "0",
+				cloud.RetryInitialBackoffKey: "1sXXX",
+				cloud.RetryMaxBackoffKey:     "-1s",
+			}}
+			Ω(cloud.GetRetryPolicy(clientConfig)).Should(Equal(defaultPolicy))
+		})
+
+		It("Returns the policy from the input clientConfig map", func() {
+			clientConfig := &corev1.ConfigMap{Data: map[string]string{
+				cloud.RetryMaxAttemptsKey:    "5",
+				cloud.RetryInitialBackoffKey: "2s",
+				cloud.RetryMaxBackoffKey:     "1s",
+			}}
+			// The maximum backoff is raised to the initial one.
+			Ω(cloud.GetRetryPolicy(clientConfig)).Should(Equal(cloud.RetryPolicy{
+				MaxAttempts:    5,
+				InitialBackoff: 2 * time.Second,
+				MaxBackoff:     2 * time.Second,
+			}))
+		})
+	})
+
+	Context("GetRateLimits", func() {
+		defaultLimits := cloud.RateLimits{
+			QPS:         cloud.DefaultAPIRateLimit,
+			Burst:       cloud.DefaultAPIRateLimitBurst,
+			MaxInFlight: cloud.DefaultAPIMaxInFlight,
+		}
+
+		It("Returns the default limits when none are configured", func() {
+			Ω(cloud.GetRateLimits(cloud.Config{})).Should(Equal(defaultLimits))
+		})
+
+		It("Returns the default limits when the values are invalid", func() {
+			Ω(cloud.GetRateLimits(cloud.Config{
+				APIRateLimit:      "fast",
+				APIRateLimitBurst: "0",
+				APIMaxInFlight:    "-1",
+			})).Should(Equal(defaultLimits))
+		})
+
+		It("Returns the limits from the endpoint config", func() {
+			Ω(cloud.GetRateLimits(cloud.Config{
+				APIRateLimit:      "2.5",
+				APIRateLimitBurst: "5",
+				APIMaxInFlight:    "3",
+			})).Should(Equal(cloud.RateLimits{QPS: 2.5, Burst: 5, MaxInFlight: 3}))
+		})
+	})
+
 	Context("NewClientFromConf", func() {
 		clientConfig := &corev1.ConfigMap{}
 		cloud.NewAsyncClient = func(apiurl, apikey, secret string, verifyssl bool, options ...cloudstack.ClientOption) *cloudstack.CloudStackClient {
@@ -192,3 +269,202 @@ var _ = Describe("Client", func() {
 		})
 	})
 })
+
+var _ = Describe("Client against the fake CloudStack API", func() {
+	var server *csserver.Server
+
+	BeforeEach(func() {
+		server, _ = NewFakeServerClient()
+	})
+
+	Context("credential rotation", func() {
+		endpointSecret := func(resourceVersion, secretKey string) *corev1.Secret {
+			return &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "acs-endpoint", ResourceVersion: resourceVersion},
+				Data: map[string][]byte{
+					"api-url":    []byte(server.URL()),
+					"api-key":    []byte(csserver.AdminAPIKey),
+					"secret-key": []byte(secretKey),
+				},
+			}
+		}
+
+		It("reuses the cached client while the secret is unchanged", func() {
+			first, err := cloud.NewClientFromK8sSecret(endpointSecret("1", csserver.AdminSecretKey), nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			second, err := cloud.NewClientFromK8sSecret(endpointSecret("1", csserver.AdminSecretKey), nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(second).Should(BeIdenticalTo(first))
+		})
+
+		It("drops the cached client when the secret changes", func() {
+			first, err := cloud.NewClientFromK8sSecret(endpointSecret("1", csserver.AdminSecretKey), nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			second, err := cloud.NewClientFromK8sSecret(endpointSecret("2", csserver.AdminSecretKey), nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(second == first).Should(BeFalse())
+		})
+
+		It("reports rejected credentials as permission denied", func() {
+			_, err := cloud.NewClientFromK8sSecret(endpointSecret("3", "wrong"), nil, "")
+			Ω(errors.Is(err, cloud.ErrPermissionDenied)).Should(BeTrue())
+		})
+	})
+
+	Context("TLS", func() {
+		var tlsServer *csserver.Server
+
+		AfterEach(func() {
+			tlsServer.Close()
+		})
+
+		tlsConfig := func(modify func(*cloud.Config)) cloud.Config {
+			conf := cloud.Config{
+				APIUrl:    tlsServer.URL(),
+				APIKey:    csserver.AdminAPIKey,
+				SecretKey: csserver.AdminSecretKey,
+				CABundle:  string(tlsServer.CACertificate()),
+			}
+			modify(&conf)
+			return conf
+		}
+
+		It("verifies the endpoint against the CA bundle", func() {
+			tlsServer = csserver.NewTLSServer(nil)
+			_, err := cloud.NewClientFromConf(tlsConfig(func(*cloud.Config) {}), nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = cloud.NewClientFromConf(tlsConfig(func(conf *cloud.Config) { conf.CABundle = "" }), nil, "")
+			Ω(err).Should(MatchError(ContainSubstring("certificate")))
+		})
+
+		It("rejects a malformed CA bundle", func() {
+			tlsServer = csserver.NewTLSServer(nil)
+			_, err := cloud.NewClientFromConf(tlsConfig(func(conf *cloud.Config) { conf.CABundle = "not a certificate" }), nil, "")
+			Ω(err).Should(MatchError(ContainSubstring("invalid ca-bundle")))
+		})
+
+		It("rejects a malformed CA bundle when the endpoint secret is read", func() {
+			tlsServer = csserver.NewTLSServer(nil)
+			_, err := cloud.NewClientFromK8sSecret(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "acs-endpoint"},
+				Data: map[string][]byte{
+					"api-url":    []byte(tlsServer.URL()),
+					"api-key":    []byte(csserver.AdminAPIKey),
+					"secret-key": []byte(csserver.AdminSecretKey),
+					"ca-bundle":  []byte("not a certificate"),
+				},
+			}, nil, "")
+			Ω(errors.Is(err, cloud.ErrInvalidSpec)).Should(BeTrue())
+			Ω(err).Should(MatchError(ContainSubstring("endpoint secret default/acs-endpoint")))
+			Ω(tlsServer.Calls()).Should(BeEmpty())
+		})
+
+		It("authenticates with the client certificate", func() {
+			clientCAs, certPEM, keyPEM := newClientCertificate()
+			tlsServer = csserver.NewTLSServer(clientCAs)
+			_, err := cloud.NewClientFromConf(tlsConfig(func(conf *cloud.Config) {
+				conf.ClientCert, conf.ClientKey = string(certPEM), string(keyPEM)
+			}), nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = cloud.NewClientFromConf(tlsConfig(func(*cloud.Config) {}), nil, "")
+			Ω(err).Should(HaveOccurred())
+			Ω(errors.Is(err, cloud.ErrTransient)).Should(BeFalse()) // Not retried.
+
+			_, err = cloud.NewClientFromConf(tlsConfig(func(conf *cloud.Config) { conf.ClientCert = string(certPEM) }), nil, "")
+			Ω(err).Should(MatchError(ContainSubstring("client-cert and client-key must be set together")))
+		})
+	})
+
+	Context("proxy and timeouts", func() {
+		conf := func() cloud.Config {
+			return cloud.Config{APIUrl: server.URL(), APIKey: csserver.AdminAPIKey, SecretKey: csserver.AdminSecretKey}
+		}
+
+		It("reaches the endpoint through the proxy", func() {
+			target, err := url.Parse(server.URL())
+			Ω(err).ShouldNot(HaveOccurred())
+			proxied := 0
+			proxy := httptest.NewServer(&httputil.ReverseProxy{Director: func(req *http.Request) {
+				proxied++
+				req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+			}})
+			defer proxy.Close()
+
+			proxyConf := conf()
+			proxyConf.APIUrl = "http://cloudstack.invalid/client/api"
+			proxyConf.HTTPProxy = proxy.URL
+			_, err = cloud.NewClientFromConf(proxyConf, nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(proxied).Should(BeNumerically(">", 0))
+		})
+
+		It("times out hung API calls", func() {
+			hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			}))
+			defer hung.Close()
+
+			hungConf := conf()
+			hungConf.APIUrl = hung.URL + "/client/api"
+			hungConf.Timeout = "100ms"
+			start := time.Now()
+			_, err := cloud.NewClientFromConf(hungConf, nil, "")
+			Ω(errors.Is(err, cloud.ErrTransient)).Should(BeTrue())
+			Ω(time.Since(start)).Should(BeNumerically("<", 5*time.Second))
+		})
+
+		DescribeTable("rejects invalid settings",
+			func(modify func(*cloud.Config), message string) {
+				invalidConf := conf()
+				modify(&invalidConf)
+				_, err := cloud.NewClientFromConf(invalidConf, nil, "")
+				Ω(err).Should(MatchError(ContainSubstring(message)))
+			},
+			Entry("proxy", func(c *cloud.Config) { c.HTTPProxy = "proxy:3128" }, "invalid http-proxy"),
+			Entry("timeout", func(c *cloud.Config) { c.Timeout = "soon" }, "invalid timeout"),
+			Entry("async job timeout", func(c *cloud.Config) { c.AsyncJobTimeout = "-1s" }, "invalid async-job-timeout"),
+		)
+	})
+})
+
+// newClientCertificate returns a pool holding a new CA, and a PEM encoded client certificate and key signed by it.
+func newClientCertificate() (*x509.CertPool, []byte, []byte) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Ω(err).ShouldNot(HaveOccurred())
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	Ω(err).ShouldNot(HaveOccurred())
+	ca, err := x509.ParseCertificate(caDER)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Ω(err).ShouldNot(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "capc"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	Ω(err).ShouldNot(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return pool,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
@@ -24,9 +24,11 @@ import (
 	"github.com/apache/cloudstack-go/v2/cloudstack"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/test/helpers"
 )
 
@@ -95,3 +97,40 @@ func FetchIntegTestResources() {
 	Ω(realCloudClient.GetOrCreateIsolatedNetwork(
 		dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
 }
+
+// NewFakeServerClient starts a fake CloudStack API server with the zone, network, offerings and template of the dummies
+// and returns a client of it. The server is closed and the client constructors restored when the spec ends.
+func NewFakeServerClient() (*csserver.Server, cloud.Client) {
+	// Other specs swap the constructors for mocks; use the real ones here.
+	origNewClient, origNewAsyncClient := cloud.NewClient, cloud.NewAsyncClient
+	cloud.NewClient, cloud.NewAsyncClient = cloudstack.NewClient, cloudstack.NewAsyncClient
+	DeferCleanup(func() {
+		cloud.NewClient, cloud.NewAsyncClient = origNewClient, origNewAsyncClient
+	})
+
+	dummies.SetDummyVars()
+	server := csserver.NewServer()
+	DeferCleanup(server.Close)
+	zone := server.AddZone(&cloudstack.Zone{Name: dummies.Zone1.Name})
+	network := server.AddNetwork(&cloudstack.Network{Name: dummies.Zone1.Network.Name, Zoneid: zone.Id, Type: "Shared"})
+	server.AddServiceOffering(&cloudstack.ServiceOffering{Name: dummies.CSMachine1.Spec.Offering.Name, Cpunumber: 2, Memory: 2048})
+	server.AddDiskOffering(&cloudstack.DiskOffering{Name: dummies.CSMachine1.Spec.DiskOffering.Name, Disksize: 10})
+	server.AddTemplate(&cloudstack.Template{Name: dummies.CSMachine1.Spec.Template.Name})
+
+	dummies.CSFailureDomain1.Spec.Zone.ID = zone.Id
+	dummies.CSFailureDomain1.Spec.Zone.Network.ID = network.Id
+	dummies.CSMachine1.Spec.InstanceID = nil
+	dummies.CSMachine1.Spec.Affinity = "no"
+
+	client, err := cloud.NewClientFromConf(cloud.Config{
+		APIUrl:    server.URL(),
+		APIKey:    csserver.AdminAPIKey,
+		SecretKey: csserver.AdminSecretKey,
+	}, &corev1.ConfigMap{Data: map[string]string{
+		cloud.RetryInitialBackoffKey: "1ms",
+		cloud.RetryMaxBackoffKey:     "5ms",
+	}}, "")
+	Ω(err).ShouldNot(HaveOccurred())
+
+	return server, client
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+	"github.com/pkg/errors"
+)
+
+// Error classes returned by client methods. Use errors.Is to branch on them, and errors.As with *APIError to get at
+// the underlying CloudStack error codes.
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrAlreadyExists    = errors.New("already exists")
+	ErrLimitExceeded    = errors.New("limit exceeded")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrTransient        = errors.New("transient error")
+	ErrThrottled        = errors.New("throttled")
+	// ErrInvalidSpec is returned when a spec can't be fulfilled as it is, e.g. because it names a template that doesn't
+	// exist or sets a disk size on an offering that isn't customized. Retrying won't help until the spec changes.
+	ErrInvalidSpec = errors.New("invalid spec")
+	// ErrJobPending is returned while an async job a client method started or checks on is still running. The caller
+	// should check back later rather than start the job again.
+	ErrJobPending = errors.New("async job pending")
+)
+
+// CloudStack ApiErrorCode values, as returned in the errorcode field of an API error response.
+const (
+	apiErrorUnauthorized         = 401
+	apiErrorLimitExceeded        = 429
+	apiErrorUnsupportedAction    = 432
+	apiErrorInternal             = 530
+	apiErrorAccount              = 531
+	apiErrorAccountResourceLimit = 532
+	apiErrorInsufficientCapacity = 533
+	apiErrorResourceUnavailable  = 534
+	apiErrorResourceAllocation   = 535
+	apiErrorNetworkRuleConflict  = 537
+)
+
+// CloudStack CSExceptionErrorCode values that identify an error class regardless of the ApiErrorCode they come with.
+const (
+	csErrorAccountLimit                = 4280
+	csErrorAuthentication              = 4290
+	csErrorConcurrentOperation         = 4300
+	csErrorInsufficientAddressCapacity = 4320
+	csErrorInsufficientCapacity        = 4325
+	csErrorInsufficientNetworkCapacity = 4330
+	csErrorInsufficientServerCapacity  = 4335
+	csErrorInsufficientStorageCapacity = 4340
+	csErrorInvalidParameter            = 4350
+	csErrorNetworkRuleConflict         = 4360
+	csErrorPermissionDenied            = 4365
+	csErrorResourceAllocation          = 4370
+	csErrorResourceUnavailable         = 4380
+	csErrorStorageUnavailable          = 4385
+	csErrorRequestLimit                = 4545
+)
+
+// APIError is an error response from the CloudStack API.
+type APIError struct {
+	// ErrorCode is the CloudStack ApiErrorCode, e.g. 431 for a parameter error.
+	ErrorCode int
+	// CSErrorCode is the CSExceptionErrorCode identifying the exception raised by the management server.
+	CSErrorCode int
+	// Message is the error text returned by the API.
+	Message string
+
+	class error
+	err   error
+}
+
+func (e *APIError) Error() string { return e.err.Error() }
+
+func (e *APIError) Unwrap() error { return e.err }
+
+// Is reports whether the error belongs to the given error class, e.g. ErrNotFound.
+func (e *APIError) Is(target error) bool { return e.class != nil && e.class == target }
+
+// Throttled reports whether the API call was rejected by CloudStack's API throttling.
+func (e *APIError) Throttled() bool { return e.class == ErrThrottled }
+
+// classifiedError attaches an error class to an error that didn't come from an API error response, e.g. a lookup
+// helper finding no match or a connection failure.
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string { return e.err.Error() }
+
+func (e *classifiedError) Unwrap() error { return e.err }
+
+func (e *classifiedError) Is(target error) bool { return e.class == target }
+
+// Throttled reports whether the error belongs to ErrThrottled, e.g. because the client's own rate limits were hit.
+func (e *classifiedError) Throttled() bool { return e.class == ErrThrottled }
+
+// classifiedErrorf formats an error that belongs to the given error class.
+func classifiedErrorf(class error, format string, args ...interface{}) error {
+	return &classifiedError{class: class, err: errors.Errorf(format, args...)}
+}
+
+// Errors from the cloudstack-go client look like "CloudStack API error 431 (CSExceptionErrorCode: 9999): ...".
+var apiErrorRegexp = regexp.MustCompile(`CloudStack API error ([0-9]+) \(CSExceptionErrorCode: ([0-9]+)\): (?s)(.*)`)
+
+// The start of the messages of the errors the cloudstack-go lookup helpers raise, which carry no error code, when no
+// resource or more than one resource matches. API errors are only classified by their codes.
+var lookupNotFoundMessages = []string{
+	"no match found",
+	"could not find an exact match",
+}
+
+// asyncJobError returns the error of a failed async job, classified by the error codes of its result.
+func asyncJobError(job *cloudstack.QueryAsyncJobResultResponse) error {
+	var result struct {
+		ErrorCode   int    `json:"errorcode"`
+		CSErrorCode int    `json:"cserrorcode"`
+		ErrorText   string `json:"errortext"`
+	}
+	if err := json.Unmarshal(job.Jobresult, &result); err != nil || result.ErrorText == "" {
+		return errors.Errorf("async job %s failed: %s", job.JobID, string(job.Jobresult))
+	}
+	return &APIError{
+		ErrorCode:   result.ErrorCode,
+		CSErrorCode: result.CSErrorCode,
+		Message:     result.ErrorText,
+		class:       classifyAPIError(result.ErrorCode, result.CSErrorCode),
+		err: errors.Errorf("async job %s failed with CloudStack API error %d (CSExceptionErrorCode: %d): %s",
+			job.JobID, result.ErrorCode, result.CSErrorCode, result.ErrorText),
+	}
+}
+
+// invalidSpecIfNotFound classifies an error looking up a resource a spec refers to as ErrInvalidSpec when the lookup
+// helper listed the resources but none matched. The error still belongs to ErrNotFound as well. Failed API requests,
+// which the helpers report with a count of -1, keep their own class.
+func invalidSpecIfNotFound(err error, count int) error {
+	err = classifyError(err)
+	if count >= 0 && errors.Is(err, ErrNotFound) {
+		return &classifiedError{class: ErrInvalidSpec, err: err}
+	}
+	return err
+}
+
+// notFoundIfInvalidID classifies the error of a request that only takes the ID of the resource it acts on as
+// ErrNotFound when CloudStack rejected the ID as an invalid parameter value, which it does for IDs that don't resolve
+// to a resource.
+func notFoundIfInvalidID(err error) error {
+	err = classifyError(err)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.CSErrorCode == csErrorInvalidParameter {
+		return &classifiedError{class: ErrNotFound, err: err}
+	}
+	return err
+}
+
+// classifyError attaches an error class to an error returned by the CloudStack API. Errors that can't be classified
+// are returned unchanged.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var apiErr *APIError
+	var clsErr *classifiedError
+	if errors.As(err, &apiErr) || errors.As(err, &clsErr) { // Already classified.
+		return err
+	}
+
+	if matches := apiErrorRegexp.FindStringSubmatch(err.Error()); matches != nil {
+		errorCode, _ := strconv.Atoi(matches[1])
+		csErrorCode, _ := strconv.Atoi(matches[2])
+		return &APIError{
+			ErrorCode:   errorCode,
+			CSErrorCode: csErrorCode,
+			Message:     matches[3],
+			class:       classifyAPIError(errorCode, csErrorCode),
+			err:         err,
+		}
+	}
+
+	if isLookupNotFound(err) {
+		return &classifiedError{class: ErrNotFound, err: err}
+	}
+	var netErr net.Error
+	if errors.Is(err, cloudstack.AsyncTimeoutErr) || errors.As(err, &netErr) && !isTLSError(err) {
+		return &classifiedError{class: ErrTransient, err: err}
+	}
+	return err
+}
+
+// isTLSError reports whether err comes from a failed TLS handshake, e.g. an untrusted server certificate. Such errors
+// need a config change and aren't worth retrying.
+func isTLSError(err error) bool {
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var opErr *net.OpError
+	return errors.As(err, &certErr) || errors.As(err, &recordErr) ||
+		errors.As(err, &opErr) && opErr.Op == "remote error" // A TLS alert sent by the server, e.g. for a missing client certificate.
+}
+
+// classifyAPIError maps the CSExceptionErrorCode of a CloudStack API error to an error class, falling back to its
+// ApiErrorCode for exceptions that don't identify a class.
+func classifyAPIError(errorCode, csErrorCode int) error {
+	switch csErrorCode {
+	case csErrorPermissionDenied, csErrorAuthentication:
+		return ErrPermissionDenied
+	case csErrorAccountLimit, csErrorResourceAllocation, csErrorInsufficientAddressCapacity, csErrorInsufficientCapacity,
+		csErrorInsufficientNetworkCapacity, csErrorInsufficientServerCapacity, csErrorInsufficientStorageCapacity:
+		return ErrLimitExceeded
+	case csErrorConcurrentOperation, csErrorResourceUnavailable, csErrorStorageUnavailable:
+		return ErrTransient
+	case csErrorRequestLimit:
+		return ErrThrottled
+	case csErrorNetworkRuleConflict:
+		return ErrAlreadyExists
+	case csErrorInvalidParameter:
+		return ErrInvalidSpec
+	}
+	switch errorCode {
+	case apiErrorUnauthorized, apiErrorAccount:
+		return ErrPermissionDenied
+	case apiErrorAccountResourceLimit, apiErrorInsufficientCapacity:
+		return ErrLimitExceeded
+	case apiErrorLimitExceeded:
+		return ErrThrottled
+	case apiErrorResourceUnavailable, apiErrorResourceAllocation:
+		return ErrTransient
+	case apiErrorNetworkRuleConflict:
+		return ErrAlreadyExists
+	case apiErrorUnsupportedAction: // Commands that don't exist or that the user isn't allowed to call.
+		return ErrPermissionDenied
+	case apiErrorInternal:
+		return ErrTransient
+	}
+	return nil
+}
+
+// isLookupNotFound reports whether err is the error a cloudstack-go lookup helper raises when no single resource
+// matches.
+func isLookupNotFound(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, m := range lookupNotFoundMessages {
+		if strings.HasPrefix(message, m) {
+			return true
+		}
+	}
+	return false
+}
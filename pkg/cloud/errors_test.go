@@ -0,0 +1,156 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
+)
+
+var _ = Describe("Errors against the fake CloudStack API", func() {
+	var (
+		server *csserver.Server
+		client cloud.Client
+	)
+
+	BeforeEach(func() {
+		server, client = NewFakeServerClient()
+	})
+
+	Context("error classification", func() {
+		It("classifies a missing resource as not found", func() {
+			err := client.FetchAffinityGroup(&cloud.AffinityGroup{Name: "missing-group"})
+			Ω(errors.Is(err, cloud.ErrNotFound)).Should(BeTrue())
+
+			dummies.CSMachine1.Name = "missing-machine"
+			err = client.ResolveVMInstanceDetails(dummies.CSMachine1)
+			Ω(errors.Is(err, cloud.ErrNotFound)).Should(BeTrue())
+		})
+
+		DescribeTable("classifies API errors by their error codes",
+			func(errorCode, csErrorCode int, errorText string, class error) {
+				server.InjectFault("listZones", csserver.Fault{
+					APIError: csserver.APIError{ErrorCode: errorCode, CSErrorCode: csErrorCode, ErrorText: errorText},
+				})
+				err := client.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})
+				Ω(errors.Is(err, class)).Should(BeTrue())
+
+				var apiErr *cloud.APIError
+				Ω(errors.As(err, &apiErr)).Should(BeTrue())
+				Ω(apiErr.ErrorCode).Should(Equal(errorCode))
+				Ω(apiErr.CSErrorCode).Should(Equal(csErrorCode))
+				Ω(apiErr.Message).Should(Equal(errorText))
+			},
+			Entry("unauthorized", 401, 9999, "unable to verify user credentials and/or request signature", cloud.ErrPermissionDenied),
+			Entry("permission denied", 531, 4365, "Account does not have permission to operate within domain", cloud.ErrPermissionDenied),
+			Entry("resource limit", 532, 4370, "Maximum number of resources of type 'user_vm' for account has been exceeded.", cloud.ErrLimitExceeded),
+			Entry("insufficient capacity", 533, 4310, "Insufficient capacity", cloud.ErrLimitExceeded),
+			Entry("resource unavailable", 534, 4380, "Resource [Host:1] is unreachable", cloud.ErrTransient),
+			Entry("concurrent operation", 530, 4300, "Unable to acquire lock", cloud.ErrTransient),
+			Entry("internal error", 530, 4250, "boom", cloud.ErrTransient),
+			Entry("throttled", 429, 4545, "There are too many API calls. Wait and retry.", cloud.ErrThrottled),
+			Entry("rule conflict", 537, 4360, "The range specified conflicts with rule 5", cloud.ErrAlreadyExists),
+			Entry("invalid parameter", 431, 4350, "A network with name net1 already exists", cloud.ErrInvalidSpec),
+			Entry("unavailable command", 432, 9999, "The given command does not exist or it is not available for user", cloud.ErrPermissionDenied),
+			Entry("insufficient server capacity", 533, 4335, "Unable to create a deployment for VM", cloud.ErrLimitExceeded),
+		)
+
+		It("leaves unrecognized API errors unclassified", func() {
+			server.InjectFault("listZones", csserver.Fault{
+				APIError: csserver.APIError{ErrorCode: 430, CSErrorCode: 9999, ErrorText: "boom"},
+			})
+			err := client.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})
+			for _, class := range []error{
+				cloud.ErrNotFound, cloud.ErrAlreadyExists, cloud.ErrLimitExceeded, cloud.ErrPermissionDenied, cloud.ErrTransient,
+				cloud.ErrThrottled, cloud.ErrInvalidSpec,
+			} {
+				Ω(errors.Is(err, class)).Should(BeFalse())
+			}
+			var apiErr *cloud.APIError
+			Ω(errors.As(err, &apiErr)).Should(BeTrue())
+			Ω(apiErr.ErrorCode).Should(Equal(430))
+		})
+
+		It("classifies machine specs referring to missing resources as invalid", func() {
+			dummies.CSMachine1.Spec.Template = infrav1.CloudStackResourceIdentifier{Name: "missing-template"}
+			err := client.GetOrCreateVMInstance(
+				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")
+			Ω(errors.Is(err, cloud.ErrInvalidSpec)).Should(BeTrue())
+			Ω(errors.Is(err, cloud.ErrNotFound)).Should(BeTrue())
+			Ω(server.VirtualMachines()).Should(BeEmpty())
+		})
+
+		It("classifies rejected deployment parameters as an invalid spec", func() {
+			server.InjectFault("deployVirtualMachine", csserver.Fault{
+				APIError: csserver.APIError{ErrorCode: 431, CSErrorCode: 4350, ErrorText: "Unsupported root disk size"},
+			})
+			err := client.GetOrCreateVMInstance(
+				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")
+			Ω(errors.Is(err, cloud.ErrInvalidSpec)).Should(BeTrue())
+			Ω(server.CallCount("deployVirtualMachine")).Should(Equal(1))
+		})
+
+		It("keeps parameter errors without an invalid parameter code retryable", func() {
+			server.InjectFault("deployVirtualMachine", csserver.Fault{
+				APIError: csserver.APIError{ErrorCode: 431, CSErrorCode: 9999, ErrorText: "Unable to execute API command"},
+			})
+			err := client.GetOrCreateVMInstance(
+				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")
+			Ω(err).Should(HaveOccurred())
+			Ω(errors.Is(err, cloud.ErrInvalidSpec)).Should(BeFalse())
+		})
+
+		It("doesn't classify failed lookups of spec resources as invalid", func() {
+			dummies.CSMachine1.Spec.Template = infrav1.CloudStackResourceIdentifier{Name: dummies.CSMachine1.Spec.Template.Name}
+			server.InjectFault("listTemplates", csserver.Fault{
+				APIError: csserver.APIError{ErrorCode: 530, CSErrorCode: 4250, ErrorText: "No match found, entity does not exist"},
+			})
+			err := client.GetOrCreateVMInstance(
+				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")
+			Ω(errors.Is(err, cloud.ErrTransient)).Should(BeTrue())
+			Ω(errors.Is(err, cloud.ErrNotFound)).Should(BeFalse())
+			Ω(errors.Is(err, cloud.ErrInvalidSpec)).Should(BeFalse())
+		})
+
+		It("classifies API errors by their codes rather than their text", func() {
+			tags := map[string]string{cloud.CreatedByCAPCTagName: "1"}
+			server.InjectFault("createTags", csserver.Fault{
+				APIError: csserver.APIError{ErrorCode: 530, CSErrorCode: 4250, ErrorText: "Resource tag already exists"},
+			})
+			err := client.AddTags(cloud.ResourceTypeNetwork, dummies.CSFailureDomain1.Spec.Zone.Network.ID, tags)
+			Ω(errors.Is(err, cloud.ErrTransient)).Should(BeTrue())
+			Ω(errors.Is(err, cloud.ErrAlreadyExists)).Should(BeFalse())
+		})
+
+		It("classifies connection failures as transient", func() {
+			server.Close()
+			err := client.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})
+			Ω(errors.Is(err, cloud.ErrTransient)).Should(BeTrue())
+		})
+
+		It("ignores already present tags", func() {
+			tags := map[string]string{cloud.CreatedByCAPCTagName: "1"}
+			Ω(client.AddTags(cloud.ResourceTypeNetwork, dummies.CSFailureDomain1.Spec.Zone.Network.ID, tags)).Should(Succeed())
+			Ω(client.AddTags(cloud.ResourceTypeNetwork, dummies.CSFailureDomain1.Spec.Zone.Network.ID, tags)).Should(Succeed())
+		})
+	})
+})
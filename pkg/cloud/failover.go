@@ -0,0 +1,201 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// APIURLList is a list of API URLs. As the values of an endpoint secret are strings, it's written there as a YAML list
+// in a string, e.g. "[https://ms1:8080/client/api, https://ms2:8080/client/api]".
+type APIURLList []string
+
+// UnmarshalYAML decodes a list of URLs, or a string holding one.
+func (l *APIURLList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		if strings.TrimSpace(node.Value) == "" {
+			*l = nil
+			return nil
+		}
+		var inner yaml.Node
+		if err := yaml.Unmarshal([]byte(node.Value), &inner); err != nil || len(inner.Content) != 1 ||
+			inner.Content[0].Kind != yaml.SequenceNode {
+			return errors.Errorf("invalid api-urls %q: expected a list such as [https://ms1:8080/client/api, https://ms2:8080/client/api]",
+				node.Value)
+		}
+		node = inner.Content[0]
+	}
+	var urls []string
+	if err := node.Decode(&urls); err != nil {
+		return errors.Wrap(err, "invalid api-urls")
+	}
+	*l = urls
+	return nil
+}
+
+// apiURLs returns the API URLs of the endpoint config in order of preference: api-url first, followed by api-urls.
+func (conf Config) apiURLs() ([]*url.URL, error) {
+	var raw []string
+	for _, u := range append([]string{conf.APIUrl}, conf.APIUrls...) {
+		if u = strings.TrimSpace(u); u != "" {
+			raw = append(raw, u)
+		}
+	}
+	seen := map[string]bool{}
+	var urls []*url.URL
+	for _, u := range raw {
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		parsed, err := url.Parse(u)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || strings.ContainsAny(u, ", \t\n") {
+			return nil, errors.Errorf("invalid API URL %q: expected a URL such as https://cloudstack:8080/client/api", u)
+		}
+		urls = append(urls, parsed)
+	}
+	if len(urls) == 0 {
+		return nil, errors.New("no API URL set: api-url or api-urls is required")
+	}
+	return urls, nil
+}
+
+// endpointFailover tracks which of the API URLs of an endpoint calls are sent to.
+type endpointFailover struct {
+	urls []*url.URL
+
+	mu     sync.Mutex
+	active int
+}
+
+// Failovers are shared by all clients of the same API URLs, so that they all move on from a failed management server
+// together.
+var endpointFailovers = map[string]*endpointFailover{}
+var endpointFailoversMutex sync.Mutex
+
+// getEndpointFailover returns the failover of the passed API URLs, creating it if needed.
+func getEndpointFailover(urls []*url.URL) *endpointFailover {
+	endpointFailoversMutex.Lock()
+	defer endpointFailoversMutex.Unlock()
+
+	keys := make([]string, len(urls))
+	for i, u := range urls {
+		keys[i] = u.String()
+	}
+	key := strings.Join(keys, ",")
+	f, ok := endpointFailovers[key]
+	if !ok {
+		f = &endpointFailover{urls: urls}
+		endpointFailovers[key] = f
+	}
+	return f
+}
+
+// activeURL returns the API URL calls are currently sent to.
+func (f *endpointFailover) activeURL() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.urls[f.active].String()
+}
+
+func (f *endpointFailover) activeIndex() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
+
+func (f *endpointFailover) setActive(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active = i
+}
+
+// failoverTransport is an http.RoundTripper that sends API calls to the active API URL of an endpoint, and moves on to
+// the next URL when a management server is unreachable or fails. Calls that change state only fail over when the
+// failed server can't have acted on them, e.g. when the connection couldn't be established.
+type failoverTransport struct {
+	next     http.RoundTripper
+	failover *endpointFailover
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	readOnly := isReadOnlyCommand(apiCommand(req))
+	start := t.failover.activeIndex()
+	count := len(t.failover.urls)
+
+	var resp *http.Response
+	var err error
+	for i := 0; i < count; i++ {
+		current := (start + i) % count
+		attempt := req
+		if i > 0 {
+			if attempt, err = rewindRequest(req); err != nil {
+				return nil, err
+			}
+		}
+		attempt = attempt.Clone(attempt.Context())
+		target := *t.failover.urls[current]
+		target.RawQuery = req.URL.RawQuery
+		attempt.URL, attempt.Host = &target, ""
+
+		resp, err = t.next.RoundTrip(attempt)
+		if !shouldFailover(resp, err, readOnly) {
+			if err == nil {
+				t.failover.setActive(current)
+			}
+			return resp, err
+		}
+		if i == count-1 {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+	return resp, err
+}
+
+// shouldFailover reports whether a call that got the passed response or error should be sent to another management
+// server.
+func shouldFailover(resp *http.Response, err error, readOnly bool) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		var opErr *net.OpError
+		return readOnly || errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+	// CloudStack uses its error codes as status codes: a management server that isn't healthy answers with 534 for an
+	// unavailable resource, or 530 for an internal error that may just as well come from the call itself.
+	switch resp.StatusCode {
+	case http.StatusServiceUnavailable, apiErrorResourceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout, apiErrorInternal:
+		return readOnly
+	}
+	return false
+}
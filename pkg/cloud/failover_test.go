@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
+)
+
+var _ = Describe("Failover against the fake CloudStack API", func() {
+	var server *csserver.Server
+
+	BeforeEach(func() {
+		server, _ = NewFakeServerClient()
+	})
+
+	Context("failover", func() {
+		It("fails over to the next API URL when a management server is unreachable", func() {
+			down := httptest.NewServer(http.NotFoundHandler())
+			down.Close()
+
+			failoverClient, err := cloud.NewClientFromConf(cloud.Config{
+				APIUrl:    down.URL + "/client/api",
+				APIUrls:   cloud.APIURLList{server.URL()},
+				APIKey:    csserver.AdminAPIKey,
+				SecretKey: csserver.AdminSecretKey,
+			}, nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(failoverClient.ActiveAPIURL()).Should(Equal(server.URL()))
+		})
+
+		It("fails over on server errors and sticks to the healthy API URL", func() {
+			failing := 0
+			unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				failing++
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer unavailable.Close()
+
+			failoverClient, err := cloud.NewClientFromConf(cloud.Config{
+				APIUrls:   cloud.APIURLList{unavailable.URL + "/client/api", server.URL()},
+				APIKey:    csserver.AdminAPIKey,
+				SecretKey: csserver.AdminSecretKey,
+			}, nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(failoverClient.ActiveAPIURL()).Should(Equal(server.URL()))
+			Ω(failing).Should(Equal(1))
+
+			Ω(failoverClient.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})).Should(Succeed())
+			Ω(failing).Should(Equal(1))
+		})
+
+		DescribeTable("fails over on the CloudStack errors of an unhealthy management server",
+			func(statusCode int) {
+				unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(statusCode)
+				}))
+				defer unhealthy.Close()
+
+				failoverClient, err := cloud.NewClientFromConf(cloud.Config{
+					APIUrls:   cloud.APIURLList{unhealthy.URL + "/client/api", server.URL()},
+					APIKey:    csserver.AdminAPIKey,
+					SecretKey: csserver.AdminSecretKey,
+				}, nil, "")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(failoverClient.ActiveAPIURL()).Should(Equal(server.URL()))
+			},
+			Entry("internal error", 530),
+			Entry("resource unavailable", 534),
+		)
+
+		It("reads the API URLs of an endpoint secret as a list", func() {
+			down := httptest.NewServer(http.NotFoundHandler())
+			down.Close()
+
+			endpointSecret := func(apiURLs string) *corev1.Secret {
+				return &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "acs-endpoint-failover"},
+					Data: map[string][]byte{
+						"api-urls":   []byte(apiURLs),
+						"api-key":    []byte(csserver.AdminAPIKey),
+						"secret-key": []byte(csserver.AdminSecretKey),
+					},
+				}
+			}
+			failoverClient, err := cloud.NewClientFromK8sSecret(
+				endpointSecret("["+down.URL+"/client/api, "+server.URL()+"]"), nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(failoverClient.ActiveAPIURL()).Should(Equal(server.URL()))
+
+			_, err = cloud.NewClientFromK8sSecret(endpointSecret(down.URL+"/client/api, "+server.URL()), nil, "")
+			Ω(err).Should(MatchError(ContainSubstring("invalid api-urls")))
+		})
+
+		It("rejects invalid API URLs", func() {
+			_, err := cloud.NewClientFromConf(cloud.Config{
+				APIUrl:    server.URL(),
+				APIUrls:   cloud.APIURLList{"cloudstack:8080"},
+				APIKey:    csserver.AdminAPIKey,
+				SecretKey: csserver.AdminSecretKey,
+			}, nil, "")
+			Ω(err).Should(MatchError(ContainSubstring("invalid API URL")))
+		})
+	})
+})
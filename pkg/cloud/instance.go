@@ -19,6 +19,7 @@ package cloud
 import (
 	"encoding/base64"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -28,6 +29,7 @@ import (
 
 	"github.com/apache/cloudstack-go/v2/cloudstack"
 	"github.com/hashicorp/go-multierror"
+	"github.com/jellydator/ttlcache/v3"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/utils/pointer"
@@ -41,11 +43,11 @@ type VMIface interface {
 }
 
 // Set infrastructure spec and status from the CloudStack API's virtual machine metrics type.
-func setMachineDataFromVMMetrics(vmResponse *cloudstack.VirtualMachinesMetric, csMachine *infrav1.CloudStackMachine) {
+func setMachineDataFromVMMetrics(vmResponse *cloudstack.VirtualMachinesMetric, csMachine *infrav1.CloudStackMachine, networkDomain string) {
 	csMachine.Spec.ProviderID = pointer.String(fmt.Sprintf("cloudstack:///%s", vmResponse.Id))
 	// InstanceID is later used as required parameter to destroy VM.
 	csMachine.Spec.InstanceID = pointer.String(vmResponse.Id)
-	csMachine.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: vmResponse.Ipaddress}}
+	csMachine.Status.Addresses = vmAddresses(vmResponse, networkDomain)
 	newInstanceState := vmResponse.State
 	if newInstanceState != csMachine.Status.InstanceState || (newInstanceState != "" && csMachine.Status.InstanceStateLastUpdated.IsZero()) {
 		csMachine.Status.InstanceState = newInstanceState
@@ -53,65 +55,221 @@ func setMachineDataFromVMMetrics(vmResponse *cloudstack.VirtualMachinesMetric, c
 	}
 }
 
-// ResolveVMInstanceDetails Retrieves VM instance details by csMachine.Spec.InstanceID or csMachine.Name, and
-// sets infrastructure machine spec and status if VM instance is found.
+// vmAddresses returns the addresses of the VM: the IPv4 and IPv6 addresses of its NICs, the default NIC's first, the
+// public IPs statically NATed to it, and its hostname, which is also qualified with the network domain of its default
+// network when there's one.
+func vmAddresses(vmResponse *cloudstack.VirtualMachinesMetric, networkDomain string) []corev1.NodeAddress {
+	addresses := []corev1.NodeAddress{}
+	add := func(addressType corev1.NodeAddressType, address string) {
+		if address == "" {
+			return
+		}
+		for _, a := range addresses {
+			if a.Type == addressType && a.Address == address {
+				return
+			}
+		}
+		addresses = append(addresses, corev1.NodeAddress{Type: addressType, Address: address})
+	}
+
+	nics := append([]cloudstack.Nic{}, vmResponse.Nic...)
+	sort.SliceStable(nics, func(i, j int) bool { return nics[i].Isdefault && !nics[j].Isdefault })
+	add(corev1.NodeInternalIP, vmResponse.Ipaddress)
+	for _, nic := range nics {
+		add(corev1.NodeInternalIP, nic.Ipaddress)
+		add(corev1.NodeInternalIP, nic.Ip6address)
+	}
+	add(corev1.NodeExternalIP, vmResponse.Publicip)
+	for _, nic := range nics {
+		add(corev1.NodeExternalIP, nic.Publicip)
+	}
+	add(corev1.NodeHostName, vmResponse.Name)
+	if vmResponse.Name != "" && networkDomain != "" {
+		add(corev1.NodeInternalDNS, vmResponse.Name+"."+networkDomain)
+	}
+	return addresses
+}
+
+// defaultNetworkDomain returns the network domain of the VM's default network, which is used to report the VM's DNS
+// name. An empty string is returned when the network isn't found. Domains are cached by network ID, since they're
+// looked up each time a VM is resolved.
+func (c *client) defaultNetworkDomain(vmResponse *cloudstack.VirtualMachinesMetric) (string, error) {
+	for _, nic := range vmResponse.Nic {
+		if !nic.Isdefault {
+			continue
+		}
+		if item := c.networkDomains.Get(nic.Networkid); item != nil {
+			return item.Value(), nil
+		}
+		network, count, err := c.cs.Network.GetNetworkByID(nic.Networkid, cloudstack.WithProject(c.user.Project.ID))
+		if err = classifyError(err); err != nil && !errors.Is(err, ErrNotFound) {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return "", errors.Wrapf(err, "getting the network domain of network %s", nic.Networkid)
+		} else if err != nil || count != 1 {
+			return "", nil
+		}
+		c.networkDomains.Set(nic.Networkid, network.Networkdomain, ttlcache.DefaultTTL)
+		return network.Networkdomain, nil
+	}
+	return "", nil
+}
+
+// ResolveVMInstanceDetails Retrieves VM instance details by csMachine.Spec.InstanceID or the machine's ownership tag,
+// and sets infrastructure machine spec and status if VM instance is found. A machine whose VM is still being deployed
+// adopts the VM of its deploy job instead of looking it up by tag, and gets ErrJobPending until the job completes.
 func (c *client) ResolveVMInstanceDetails(csMachine *infrav1.CloudStackMachine) error {
+	_, err := c.resolveVMInstance(csMachine)
+	return err
+}
+
+// resolveVMInstance is ResolveVMInstanceDetails, returning the VM instance found.
+func (c *client) resolveVMInstance(csMachine *infrav1.CloudStackMachine) (*cloudstack.VirtualMachinesMetric, error) {
+	if csMachine.Status.DeployJobID != nil {
+		if err := c.resolveDeployJob(csMachine); err != nil {
+			return nil, err
+		}
+	}
+
 	// Attempt to fetch by ID.
 	if csMachine.Spec.InstanceID != nil {
 		vmResp, count, err := c.cs.VirtualMachine.GetVirtualMachinesMetricByID(*csMachine.Spec.InstanceID, cloudstack.WithProject(c.user.Project.ID))
-		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "no match found") {
+		if err = classifyError(err); err != nil && !errors.Is(err, ErrNotFound) {
 			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-			return err
+			return nil, err
 		} else if count > 1 {
-			return fmt.Errorf("found more than one VM Instance with ID %s", *csMachine.Spec.InstanceID)
+			return nil, fmt.Errorf("found more than one VM Instance with ID %s", *csMachine.Spec.InstanceID)
 		} else if err == nil {
-			setMachineDataFromVMMetrics(vmResp, csMachine)
-			return nil
+			networkDomain, err := c.defaultNetworkDomain(vmResp)
+			if err != nil {
+				return nil, err
+			}
+			setMachineDataFromVMMetrics(vmResp, csMachine, networkDomain)
+			return vmResp, nil
 		}
 	}
 
-	// Attempt fetch by name.
-	if csMachine.Name != "" {
-		vmResp, count, err := c.cs.VirtualMachine.GetVirtualMachinesMetricByName(csMachine.Name, cloudstack.WithProject(c.user.Project.ID))
-		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "no match") {
+	// Attempt fetch by ownership tag. Unlike its name, the tag can't match VMs of other clusters or VMs created by hand.
+	if csMachine.UID != "" {
+		p := c.cs.VirtualMachine.NewListVirtualMachinesMetricsParams()
+		p.SetTags(map[string]string{MachineUIDTagName: string(csMachine.UID)})
+		p.SetListall(true)
+		setIfNotEmpty(c.user.Project.ID, p.SetProjectid)
+		resp, err := c.cs.VirtualMachine.ListVirtualMachinesMetrics(p)
+		if err != nil {
 			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-			return err
-		} else if count > 1 {
-			return fmt.Errorf("found more than one VM Instance with name %s", csMachine.Name)
-		} else if err == nil {
-			setMachineDataFromVMMetrics(vmResp, csMachine)
-			return nil
+			return nil, classifyError(err)
+		} else if resp.Count > 1 {
+			return nil, fmt.Errorf("found more than one VM Instance owned by machine %s", csMachine.Name)
+		} else if resp.Count == 1 {
+			vm := resp.VirtualMachinesMetrics[0]
+			networkDomain, err := c.defaultNetworkDomain(vm)
+			if err != nil {
+				return nil, err
+			}
+			setMachineDataFromVMMetrics(vm, csMachine, networkDomain)
+			return vm, nil
 		}
 	}
-	return errors.New("no match found")
+	return nil, classifiedErrorf(ErrNotFound, "no match found")
+}
+
+// vmOwnershipTags returns the tags of a VM owned by the machine of the cluster. Only VMs the machine deployed are tagged
+// as created by CAPC, so that pre-existing VMs adopted by their ID aren't mistaken for ones CAPC may clean up.
+func vmOwnershipTags(csMachine *infrav1.CloudStackMachine, csCluster *infrav1.CloudStackCluster) map[string]string {
+	tags := map[string]string{
+		generateClusterTagName(csCluster): "1",
+		MachineUIDTagName:                 string(csMachine.UID),
+	}
+	if csMachine.Annotations[infrav1.InstanceCreatedByCAPCAnnotation] == "true" {
+		tags[CreatedByCAPCTagName] = "1"
+	}
+	return tags
+}
+
+// markCreatedByCAPC records in the machine's annotations that CAPC deployed its VM.
+func markCreatedByCAPC(csMachine *infrav1.CloudStackMachine) {
+	if csMachine.Annotations == nil {
+		csMachine.Annotations = map[string]string{}
+	}
+	csMachine.Annotations[infrav1.InstanceCreatedByCAPCAnnotation] = "true"
+}
+
+// vmOwnerUID returns the UID of the machine the VM is tagged as owned by, if any.
+func vmOwnerUID(tags []cloudstack.Tags) (string, bool) {
+	for _, t := range tags {
+		if t.Key == MachineUIDTagName {
+			return t.Value, true
+		}
+	}
+	return "", false
+}
+
+// tagVMInstance tags the machine's VM as owned by the machine and its cluster, since CloudStack can't tag VMs as it
+// deploys them. A VM tagged as owned by another machine UID is only tagged anew if that's the UID the machine had before
+// it was moved to another management cluster, and is an invalid spec otherwise. The UID is recorded in the machine's
+// annotations for it to be able to delete the VM after such a move. See DestroyVMInstance.
+func (c *client) tagVMInstance(
+	vm *cloudstack.VirtualMachinesMetric,
+	csMachine *infrav1.CloudStackMachine,
+	csCluster *infrav1.CloudStackCluster,
+) error {
+	present := make(map[string]string, len(vm.Tags))
+	for _, t := range vm.Tags {
+		present[t.Key] = t.Value
+	}
+	missing := map[string]string{}
+	for k, v := range vmOwnershipTags(csMachine, csCluster) {
+		if value, found := present[k]; !found || value != v {
+			missing[k] = v
+		}
+	}
+	if len(missing) > 0 {
+		if owner, found := vmOwnerUID(vm.Tags); found && owner != string(csMachine.UID) {
+			if owner != csMachine.Annotations[infrav1.InstanceOwnerUIDAnnotation] {
+				return classifiedErrorf(ErrInvalidSpec, "VM %s is tagged as owned by another machine with UID %s", vm.Id, owner)
+			}
+			if err := c.DeleteTags(ResourceTypeUserVM, vm.Id, map[string]string{MachineUIDTagName: owner}); err != nil {
+				return errors.Wrapf(err, "removing the previous owner tag of VM %s", vm.Id)
+			}
+		}
+		if err := c.AddTags(ResourceTypeUserVM, vm.Id, missing); err != nil {
+			return errors.Wrapf(err, "tagging VM %s", vm.Id)
+		}
+	}
+	if csMachine.Annotations == nil {
+		csMachine.Annotations = map[string]string{}
+	}
+	csMachine.Annotations[infrav1.InstanceOwnerUIDAnnotation] = string(csMachine.UID)
+	return nil
 }
 
 func (c *client) ResolveServiceOffering(csMachine *infrav1.CloudStackMachine, zoneID string) (offering cloudstack.ServiceOffering, retErr error) {
-	if len(csMachine.Spec.Offering.ID) > 0 {
-		csOffering, count, err := c.cs.ServiceOffering.GetServiceOfferingByID(csMachine.Spec.Offering.ID, cloudstack.WithProject(c.user.Project.ID))
+	spec := csMachine.Spec.InFailureDomain(csMachine.Spec.FailureDomainName)
+	if len(spec.Offering.ID) > 0 {
+		csOffering, count, err := c.cs.ServiceOffering.GetServiceOfferingByID(spec.Offering.ID, cloudstack.WithProject(c.user.Project.ID))
 		if err != nil {
 			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
 			return cloudstack.ServiceOffering{}, multierror.Append(retErr, errors.Wrapf(
-				err, "could not get Service Offering by ID %s", csMachine.Spec.Offering.ID))
+				invalidSpecIfNotFound(err, count), "could not get Service Offering by ID %s", spec.Offering.ID))
 		} else if count != 1 {
-			return *csOffering, multierror.Append(retErr, errors.Errorf(
-				"expected 1 Service Offering with UUID %s, but got %d", csMachine.Spec.Offering.ID, count))
+			return *csOffering, multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
+				"expected 1 Service Offering with UUID %s, but got %d", spec.Offering.ID, count))
 		}
 
-		if len(csMachine.Spec.Offering.Name) > 0 && csMachine.Spec.Offering.Name != csOffering.Name {
-			return *csOffering, multierror.Append(retErr, errors.Errorf(
-				"offering name %s does not match name %s returned using UUID %s", csMachine.Spec.Offering.Name, csOffering.Name, csMachine.Spec.Offering.ID))
+		if len(spec.Offering.Name) > 0 && spec.Offering.Name != csOffering.Name {
+			return *csOffering, multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
+				"offering name %s does not match name %s returned using UUID %s", spec.Offering.Name, csOffering.Name, spec.Offering.ID))
 		}
 		return *csOffering, nil
 	}
-	csOffering, count, err := c.cs.ServiceOffering.GetServiceOfferingByName(csMachine.Spec.Offering.Name, cloudstack.WithZone(zoneID), cloudstack.WithProject(c.user.Project.ID))
+	csOffering, count, err := c.cs.ServiceOffering.GetServiceOfferingByName(spec.Offering.Name, cloudstack.WithZone(zoneID), cloudstack.WithProject(c.user.Project.ID))
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
 		return cloudstack.ServiceOffering{}, multierror.Append(retErr, errors.Wrapf(
-			err, "could not get Service Offering ID from %s in zone %s", csMachine.Spec.Offering.Name, zoneID))
+			invalidSpecIfNotFound(err, count), "could not get Service Offering ID from %s in zone %s", spec.Offering.Name, zoneID))
 	} else if count != 1 {
-		return *csOffering, multierror.Append(retErr, errors.Errorf(
-			"expected 1 Service Offering with name %s in zone %s, but got %d", csMachine.Spec.Offering.Name, zoneID, count))
+		return *csOffering, multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
+			"expected 1 Service Offering with name %s in zone %s, but got %d", spec.Offering.Name, zoneID, count))
 	}
 	return *csOffering, nil
 }
@@ -121,57 +279,86 @@ func (c *client) ResolveTemplate(
 	csMachine *infrav1.CloudStackMachine,
 	zoneID string,
 ) (templateID string, retErr error) {
-	if len(csMachine.Spec.Template.ID) > 0 {
-		csTemplate, count, err := c.cs.Template.GetTemplateByID(csMachine.Spec.Template.ID, "executable", cloudstack.WithProject(c.user.Project.ID))
+	spec := csMachine.Spec.InFailureDomain(csMachine.Spec.FailureDomainName)
+	if len(spec.Template.ID) > 0 {
+		csTemplate, count, err := c.cs.Template.GetTemplateByID(spec.Template.ID, "executable", cloudstack.WithProject(c.user.Project.ID))
 		if err != nil {
 			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
 			return "", multierror.Append(retErr, errors.Wrapf(
-				err, "could not get Template by ID %s", csMachine.Spec.Template.ID))
+				invalidSpecIfNotFound(err, count), "could not get Template by ID %s", spec.Template.ID))
 		} else if count != 1 {
-			return "", multierror.Append(retErr, errors.Errorf(
-				"expected 1 Template with UUID %s, but got %d", csMachine.Spec.Template.ID, count))
+			return "", multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
+				"expected 1 Template with UUID %s, but got %d", spec.Template.ID, count))
 		}
 
-		if len(csMachine.Spec.Template.Name) > 0 && csMachine.Spec.Template.Name != csTemplate.Name {
-			return "", multierror.Append(retErr, errors.Errorf(
-				"template name %s does not match name %s returned using UUID %s", csMachine.Spec.Template.Name, csTemplate.Name, csMachine.Spec.Template.ID))
+		if len(spec.Template.Name) > 0 && spec.Template.Name != csTemplate.Name {
+			return "", multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
+				"template name %s does not match name %s returned using UUID %s", spec.Template.Name, csTemplate.Name, spec.Template.ID))
 		}
-		return csMachine.Spec.Template.ID, nil
+		return spec.Template.ID, nil
 	}
-	templateID, count, err := c.cs.Template.GetTemplateID(csMachine.Spec.Template.Name, "executable", zoneID, cloudstack.WithProject(c.user.Project.ID))
+	templateID, count, err := c.cs.Template.GetTemplateID(spec.Template.Name, "executable", zoneID, cloudstack.WithProject(c.user.Project.ID))
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
 		return "", multierror.Append(retErr, errors.Wrapf(
-			err, "could not get Template ID from %s", csMachine.Spec.Template.Name))
+			invalidSpecIfNotFound(err, count), "could not get Template ID from %s", spec.Template.Name))
 	} else if count != 1 {
-		return "", multierror.Append(retErr, errors.Errorf(
-			"expected 1 Template with name %s, but got %d", csMachine.Spec.Template.Name, count))
+		return "", multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
+			"expected 1 Template with name %s, but got %d", spec.Template.Name, count))
 	}
 	return templateID, nil
 }
 
+// dataDisks returns the machine's data disks in order: the disk of its disk offering if it has one, then its data
+// disks.
+func dataDisks(csMachine *infrav1.CloudStackMachine) []infrav1.CloudStackMachineDataDisk {
+	var disks []infrav1.CloudStackMachineDataDisk
+	if offering := csMachine.Spec.InFailureDomain(csMachine.Spec.FailureDomainName).DiskOffering; len(offering.ID) > 0 || len(offering.Name) > 0 {
+		disks = append(disks, infrav1.CloudStackMachineDataDisk{
+			Offering:   offering.CloudStackResourceIdentifier,
+			CustomSize: offering.CustomSize,
+			MountPath:  offering.MountPath,
+			Device:     offering.Device,
+			Filesystem: offering.Filesystem,
+			Label:      offering.Label,
+		})
+	}
+	return append(disks, csMachine.Spec.DataDisks...)
+}
+
 // ResolveDiskOffering Retrieves diskOffering by using disk offering ID if ID is provided and confirm returned
 // disk offering name matches name provided in spec.
 // If disk offering ID is not provided, the disk offering name is used to retrieve disk offering ID.
+// The disk offering is that of the machine's first data disk, which is deployed with the VM.
 func (c *client) ResolveDiskOffering(csMachine *infrav1.CloudStackMachine, zoneID string) (diskOfferingID string, retErr error) {
-	diskOfferingID = csMachine.Spec.DiskOffering.ID
-	if len(csMachine.Spec.DiskOffering.Name) > 0 {
-		diskID, count, err := c.cs.DiskOffering.GetDiskOfferingID(csMachine.Spec.DiskOffering.Name, cloudstack.WithZone(zoneID), cloudstack.WithProject(c.user.Project.ID))
+	disks := dataDisks(csMachine)
+	if len(disks) == 0 {
+		return "", nil
+	}
+	return c.resolveDataDiskOffering(disks[0], zoneID)
+}
+
+// resolveDataDiskOffering resolves the disk offering of a data disk in the zone, and verifies the disk's size and IOPS
+// against it.
+func (c *client) resolveDataDiskOffering(disk infrav1.CloudStackMachineDataDisk, zoneID string) (diskOfferingID string, retErr error) {
+	diskOfferingID = disk.Offering.ID
+	if len(disk.Offering.Name) > 0 {
+		diskID, count, err := c.cs.DiskOffering.GetDiskOfferingID(disk.Offering.Name, cloudstack.WithZone(zoneID), cloudstack.WithProject(c.user.Project.ID))
 		if err != nil {
 			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
 			return "", multierror.Append(retErr, errors.Wrapf(
-				err, "could not get DiskOffering ID from %s", csMachine.Spec.DiskOffering.Name))
+				invalidSpecIfNotFound(err, count), "could not get DiskOffering ID from %s", disk.Offering.Name))
 		} else if count != 1 {
-			return "", multierror.Append(retErr, errors.Errorf(
-				"expected 1 DiskOffering with name %s in zone %s, but got %d", csMachine.Spec.DiskOffering.Name, zoneID, count))
-		} else if len(csMachine.Spec.DiskOffering.ID) > 0 && diskID != csMachine.Spec.DiskOffering.ID {
-			return "", multierror.Append(retErr, errors.Errorf(
+			return "", multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
+				"expected 1 DiskOffering with name %s in zone %s, but got %d", disk.Offering.Name, zoneID, count))
+		} else if len(disk.Offering.ID) > 0 && diskID != disk.Offering.ID {
+			return "", multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
 				"diskOffering ID %s does not match ID %s returned using name %s in zone %s",
-				csMachine.Spec.DiskOffering.ID, diskID, csMachine.Spec.DiskOffering.Name, zoneID))
+				disk.Offering.ID, diskID, disk.Offering.Name, zoneID))
 		} else if len(diskID) == 0 {
-			return "", multierror.Append(retErr, errors.Errorf(
+			return "", multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
 				"empty diskOffering ID %s returned using name %s in zone %s",
-				diskID, csMachine.Spec.DiskOffering.Name, zoneID))
+				diskID, disk.Offering.Name, zoneID))
 		}
 		diskOfferingID = diskID
 	}
@@ -179,86 +366,218 @@ func (c *client) ResolveDiskOffering(csMachine *infrav1.CloudStackMachine, zoneI
 		return "", nil
 	}
 
-	return verifyDiskoffering(csMachine, c, diskOfferingID, retErr)
+	return verifyDiskoffering(disk, c, diskOfferingID, retErr)
 }
 
-func verifyDiskoffering(csMachine *infrav1.CloudStackMachine, c *client, diskOfferingID string, retErr error) (string, error) {
+func verifyDiskoffering(disk infrav1.CloudStackMachineDataDisk, c *client, diskOfferingID string, retErr error) (string, error) {
 	csDiskOffering, count, err := c.cs.DiskOffering.GetDiskOfferingByID(diskOfferingID, cloudstack.WithProject(c.user.Project.ID))
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
 		return "", multierror.Append(retErr, errors.Wrapf(
-			err, "could not get DiskOffering by ID %s", diskOfferingID))
+			invalidSpecIfNotFound(err, count), "could not get DiskOffering by ID %s", diskOfferingID))
 	} else if count != 1 {
-		return "", multierror.Append(retErr, errors.Errorf(
+		return "", multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
 			"expected 1 DiskOffering with UUID %s, but got %d", diskOfferingID, count))
 	}
 
-	if csDiskOffering.Iscustomized && csMachine.Spec.DiskOffering.CustomSize == 0 {
-		return "", multierror.Append(retErr, errors.Errorf(
+	if csDiskOffering.Iscustomized && disk.CustomSize == 0 {
+		return "", multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
 			"diskOffering with UUID %s is customized, disk size can not be 0 GB",
 			diskOfferingID))
 	}
 
-	if !csDiskOffering.Iscustomized && csMachine.Spec.DiskOffering.CustomSize > 0 {
-		return "", multierror.Append(retErr, errors.Errorf(
+	if !csDiskOffering.Iscustomized && disk.CustomSize > 0 {
+		return "", multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
 			"diskOffering with UUID %s is not customized, disk size can not be specified",
 			diskOfferingID))
 	}
+
+	if !csDiskOffering.Iscustomizediops && (disk.MinIOPS > 0 || disk.MaxIOPS > 0) {
+		return "", multierror.Append(retErr, classifiedErrorf(ErrInvalidSpec,
+			"diskOffering with UUID %s does not have customized IOPS, disk IOPS can not be specified",
+			diskOfferingID))
+	}
 	return diskOfferingID, nil
 }
 
-// CheckAccountLimits Checks the account's limit of VM, CPU & Memory
-func (c *client) CheckAccountLimits(fd *infrav1.CloudStackFailureDomain, offering *cloudstack.ServiceOffering) error {
+// resolveRootDiskOffering resolves the disk offering overriding that of the service offering for the machine's root
+// disk: the root disk's offering, or a disk offering with its storage tag. Returns an empty ID when neither is set. The
+// size of a fixed size offering must fit the template.
+func (c *client) resolveRootDiskOffering(csMachine *infrav1.CloudStackMachine, zoneID, templateID string) (string, error) {
+	rootDisk := csMachine.Spec.RootDisk
+	if len(rootDisk.Offering.ID) > 0 || len(rootDisk.Offering.Name) > 0 {
+		offeringID, err := c.resolveDataDiskOffering(
+			infrav1.CloudStackMachineDataDisk{Offering: rootDisk.Offering, CustomSize: rootDisk.SizeInGB}, zoneID)
+		if err != nil || rootDisk.SizeInGB > 0 {
+			return offeringID, err
+		}
+		// Without a size, the offering is a fixed size one, whose size is that of the root disk.
+		offering, count, err := c.cs.DiskOffering.GetDiskOfferingByID(offeringID, cloudstack.WithProject(c.user.Project.ID))
+		if err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return "", errors.Wrapf(invalidSpecIfNotFound(err, count), "could not get DiskOffering by ID %s", offeringID)
+		} else if count != 1 {
+			return "", classifiedErrorf(ErrInvalidSpec, "expected 1 DiskOffering with UUID %s, but got %d", offeringID, count)
+		}
+		if err := c.verifyRootDiskSize(offering.Disksize, templateID); err != nil {
+			return "", errors.Wrapf(err, "disk offering %s", offeringID)
+		}
+		return offeringID, nil
+	} else if rootDisk.StorageTag == "" {
+		return "", nil
+	}
+
+	p := c.cs.DiskOffering.NewListDiskOfferingsParams()
+	p.SetZoneid(zoneID)
+	setIfNotEmpty(c.user.Project.ID, p.SetProjectid)
+	resp, err := c.cs.DiskOffering.ListDiskOfferings(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return "", errors.Wrapf(classifyError(err), "listing disk offerings in zone %s", zoneID)
+	}
+	// A root disk of a given size needs an offering with a custom disk size, otherwise one of the offering's size.
+	customized := rootDisk.SizeInGB > 0
+	for _, offering := range resp.DiskOfferings {
+		if offering.Iscustomized != customized {
+			continue
+		}
+		for _, tag := range strings.Split(offering.Tags, ",") {
+			if strings.TrimSpace(tag) != rootDisk.StorageTag {
+				continue
+			} else if !customized {
+				if err := c.verifyRootDiskSize(offering.Disksize, templateID); err != nil {
+					return "", errors.Wrapf(err, "disk offering %s with storage tag %s", offering.Id, rootDisk.StorageTag)
+				}
+			}
+			return offering.Id, nil
+		}
+	}
+	kind := "fixed size"
+	if customized {
+		kind = "customized"
+	}
+	return "", classifiedErrorf(ErrInvalidSpec,
+		"no %s disk offering with storage tag %s in zone %s", kind, rootDisk.StorageTag, zoneID)
+}
+
+// verifyRootDiskSize checks that a root disk of the given size is at least as big as the template. A size of 0 leaves
+// the size to the template.
+func (c *client) verifyRootDiskSize(sizeInGB int64, templateID string) error {
+	if sizeInGB == 0 {
+		return nil
+	}
+	template, count, err := c.cs.Template.GetTemplateByID(templateID, "executable", cloudstack.WithProject(c.user.Project.ID))
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(invalidSpecIfNotFound(err, count), "could not get Template by ID %s", templateID)
+	} else if count != 1 {
+		return classifiedErrorf(ErrInvalidSpec, "expected 1 Template with UUID %s, but got %d", templateID, count)
+	}
+	if sizeInGB<<30 < template.Size {
+		return classifiedErrorf(ErrInvalidSpec, "root disk size %d GB is smaller than the %d GB of template %s",
+			sizeInGB, (template.Size+1<<30-1)>>30, templateID)
+	}
+	return nil
+}
+
+// ResolveAdditionalNetworks resolves the machine's additional networks in the zone, and returns them with their static
+// IPs as the iptonetworklist parameter of a VM deployment.
+func (c *client) ResolveAdditionalNetworks(csMachine *infrav1.CloudStackMachine, zoneID string) ([]map[string]string, error) {
+	ipToNetworkList := make([]map[string]string, 0, len(csMachine.Spec.AdditionalNetworks))
+	for _, network := range csMachine.Spec.AdditionalNetworks {
+		networkID, err := c.resolveAdditionalNetwork(network, zoneID)
+		if err != nil {
+			return nil, err
+		}
+		entry := map[string]string{"networkid": networkID}
+		setIfNotEmpty(network.IP, func(ip string) { entry["ip"] = ip })
+		ipToNetworkList = append(ipToNetworkList, entry)
+	}
+	return ipToNetworkList, nil
+}
+
+// resolveAdditionalNetwork returns the ID of an additional network, which must be in the zone.
+func (c *client) resolveAdditionalNetwork(network infrav1.CloudStackMachineNetwork, zoneID string) (string, error) {
+	if network.ID == "" {
+		netDetails, count, err := c.cs.Network.GetNetworkByName(
+			network.Name, cloudstack.WithZone(zoneID), cloudstack.WithProject(c.user.Project.ID))
+		if err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return "", errors.Wrapf(invalidSpecIfNotFound(err, count), "could not get Network ID from %s", network.Name)
+		} else if count != 1 {
+			return "", classifiedErrorf(ErrInvalidSpec,
+				"expected 1 Network with name %s in zone %s, but got %d", network.Name, zoneID, count)
+		}
+		return netDetails.Id, nil
+	}
+
+	netDetails, count, err := c.cs.Network.GetNetworkByID(network.ID, cloudstack.WithProject(c.user.Project.ID))
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return "", errors.Wrapf(invalidSpecIfNotFound(err, count), "could not get Network by ID %s", network.ID)
+	} else if count != 1 {
+		return "", classifiedErrorf(ErrInvalidSpec, "expected 1 Network with UUID %s, but got %d", network.ID, count)
+	} else if network.Name != "" && netDetails.Name != network.Name {
+		return "", classifiedErrorf(ErrInvalidSpec,
+			"network name %s does not match name %s returned using UUID %s", network.Name, netDetails.Name, network.ID)
+	} else if netDetails.Zoneid != zoneID {
+		return "", classifiedErrorf(ErrInvalidSpec, "network with UUID %s is not in zone %s", network.ID, zoneID)
+	}
+	return netDetails.Id, nil
+}
+
+// CheckAccountLimits Checks the account's limit of VM, CPU, Memory, volumes & primary storage
+func (c *client) CheckAccountLimits(fd *infrav1.CloudStackFailureDomain, offering *cloudstack.ServiceOffering, disks diskDemand) error {
 	if c.user.Account.CPUAvailable != "Unlimited" {
 		cpuAvailable, err := strconv.ParseInt(c.user.Account.CPUAvailable, 10, 0)
 		if err == nil && int64(offering.Cpunumber) > cpuAvailable {
-			return fmt.Errorf("CPU available (%d) in account can't fulfil the requirement: %d", cpuAvailable, offering.Cpunumber)
+			return classifiedErrorf(ErrLimitExceeded, "CPU available (%d) in account can't fulfil the requirement: %d", cpuAvailable, offering.Cpunumber)
 		}
 	}
 
 	if c.user.Account.MemoryAvailable != "Unlimited" {
 		memoryAvailable, err := strconv.ParseInt(c.user.Account.MemoryAvailable, 10, 0)
 		if err == nil && int64(offering.Memory) > memoryAvailable {
-			return fmt.Errorf("memory available (%d) in account can't fulfil the requirement: %d", memoryAvailable, offering.Memory)
+			return classifiedErrorf(ErrLimitExceeded, "memory available (%d) in account can't fulfil the requirement: %d", memoryAvailable, offering.Memory)
 		}
 	}
 
 	if c.user.Account.VMAvailable != "Unlimited" {
 		vmAvailable, err := strconv.ParseInt(c.user.Account.VMAvailable, 10, 0)
 		if err == nil && vmAvailable < 1 {
-			return fmt.Errorf("VM Limit in account has reached it's maximum value")
+			return classifiedErrorf(ErrLimitExceeded, "VM Limit in account has reached it's maximum value")
 		}
 	}
-	return nil
+	return checkDiskLimits("account", c.user.Account.VolumeAvailable, c.user.Account.PrimaryStorageAvailable, disks)
 }
 
-// CheckDomainLimits Checks the domain's limit of VM, CPU & Memory
-func (c *client) CheckDomainLimits(fd *infrav1.CloudStackFailureDomain, offering *cloudstack.ServiceOffering) error {
+// CheckDomainLimits Checks the domain's limit of VM, CPU, Memory, volumes & primary storage
+func (c *client) CheckDomainLimits(fd *infrav1.CloudStackFailureDomain, offering *cloudstack.ServiceOffering, disks diskDemand) error {
 	if c.user.Account.Domain.CPUAvailable != "Unlimited" {
 		cpuAvailable, err := strconv.ParseInt(c.user.Account.Domain.CPUAvailable, 10, 0)
 		if err == nil && int64(offering.Cpunumber) > cpuAvailable {
-			return fmt.Errorf("CPU available (%d) in domain can't fulfil the requirement: %d", cpuAvailable, offering.Cpunumber)
+			return classifiedErrorf(ErrLimitExceeded, "CPU available (%d) in domain can't fulfil the requirement: %d", cpuAvailable, offering.Cpunumber)
 		}
 	}
 
 	if c.user.Account.Domain.MemoryAvailable != "Unlimited" {
 		memoryAvailable, err := strconv.ParseInt(c.user.Account.Domain.MemoryAvailable, 10, 0)
 		if err == nil && int64(offering.Memory) > memoryAvailable {
-			return fmt.Errorf("memory available (%d) in domain can't fulfil the requirement: %d", memoryAvailable, offering.Memory)
+			return classifiedErrorf(ErrLimitExceeded, "memory available (%d) in domain can't fulfil the requirement: %d", memoryAvailable, offering.Memory)
 		}
 	}
 
 	if c.user.Account.Domain.VMAvailable != "Unlimited" {
 		vmAvailable, err := strconv.ParseInt(c.user.Account.Domain.VMAvailable, 10, 0)
 		if err == nil && vmAvailable < 1 {
-			return fmt.Errorf("VM Limit in domain has reached it's maximum value")
+			return classifiedErrorf(ErrLimitExceeded, "VM Limit in domain has reached it's maximum value")
 		}
 	}
-	return nil
+	return checkDiskLimits("domain", c.user.Account.Domain.VolumeAvailable, c.user.Account.Domain.PrimaryStorageAvailable, disks)
 }
 
-// CheckProjectLimits Checks the project's limit of VM, CPU & Memory
-func (c *client) CheckProjectLimits(fd *infrav1.CloudStackFailureDomain, offering *cloudstack.ServiceOffering) error {
+// CheckProjectLimits Checks the project's limit of VM, CPU, Memory, volumes & primary storage
+func (c *client) CheckProjectLimits(fd *infrav1.CloudStackFailureDomain, offering *cloudstack.ServiceOffering, disks diskDemand) error {
 	if c.user.Project.ID == "" {
 		return nil
 	}
@@ -266,42 +585,79 @@ func (c *client) CheckProjectLimits(fd *infrav1.CloudStackFailureDomain, offerin
 	if c.user.Project.CPUAvailable != "Unlimited" {
 		cpuAvailable, err := strconv.ParseInt(c.user.Project.CPUAvailable, 10, 0)
 		if err == nil && int64(offering.Cpunumber) > cpuAvailable {
-			return fmt.Errorf("CPU available (%d) in project can't fulfil the requirement: %d", cpuAvailable, offering.Cpunumber)
+			return classifiedErrorf(ErrLimitExceeded, "CPU available (%d) in project can't fulfil the requirement: %d", cpuAvailable, offering.Cpunumber)
 		}
 	}
 
 	if c.user.Project.MemoryAvailable != "Unlimited" {
 		memoryAvailable, err := strconv.ParseInt(c.user.Project.MemoryAvailable, 10, 0)
 		if err == nil && int64(offering.Memory) > memoryAvailable {
-			return fmt.Errorf("memory available (%d) in project can't fulfil the requirement: %d", memoryAvailable, offering.Memory)
+			return classifiedErrorf(ErrLimitExceeded, "memory available (%d) in project can't fulfil the requirement: %d", memoryAvailable, offering.Memory)
 		}
 	}
 
 	if c.user.Project.VMAvailable != "Unlimited" {
 		vmAvailable, err := strconv.ParseInt(c.user.Project.VMAvailable, 10, 0)
 		if err == nil && vmAvailable < 1 {
-			return fmt.Errorf("VM Limit in project has reached it's maximum value")
+			return classifiedErrorf(ErrLimitExceeded, "VM Limit in project has reached it's maximum value")
+		}
+	}
+	return checkDiskLimits("project", c.user.Project.VolumeAvailable, c.user.Project.PrimaryStorageAvailable, disks)
+}
+
+// diskDemand is the number of volumes and the primary storage, in GB, the disks of a machine take.
+type diskDemand struct {
+	volumes   int64
+	storageGB int64
+}
+
+// machineDiskDemand returns what the disks of a machine take: its root disk and each of its data disks. Only sizes set
+// in the machine's spec are counted, as those of templates and fixed size disk offerings aren't known before deploying.
+func machineDiskDemand(csMachine *infrav1.CloudStackMachine) diskDemand {
+	demand := diskDemand{volumes: 1, storageGB: csMachine.Spec.RootDisk.SizeInGB}
+	for _, disk := range dataDisks(csMachine) {
+		demand.volumes++
+		demand.storageGB += disk.CustomSize
+	}
+	return demand
+}
+
+// checkDiskLimits checks the volumes & primary storage available in the scope against what the disks of a machine take.
+func checkDiskLimits(scope, volumeAvailable, primaryStorageAvailable string, disks diskDemand) error {
+	if volumeAvailable != "Unlimited" {
+		available, err := strconv.ParseInt(volumeAvailable, 10, 0)
+		if err == nil && disks.volumes > available {
+			return classifiedErrorf(ErrLimitExceeded, "volumes available (%d) in %s can't fulfil the requirement: %d", available, scope, disks.volumes)
+		}
+	}
+
+	if primaryStorageAvailable != "Unlimited" {
+		available, err := strconv.ParseInt(primaryStorageAvailable, 10, 0)
+		if err == nil && disks.storageGB > available {
+			return classifiedErrorf(ErrLimitExceeded, "primary storage available (%d GB) in %s can't fulfil the requirement: %d GB", available, scope, disks.storageGB)
 		}
 	}
 	return nil
 }
 
-// CheckLimits will check the account & domain limits
+// CheckLimits will check the account, domain & project limits
 func (c *client) CheckLimits(
 	fd *infrav1.CloudStackFailureDomain,
 	offering *cloudstack.ServiceOffering,
+	csMachine *infrav1.CloudStackMachine,
 ) error {
-	err := c.CheckAccountLimits(fd, offering)
+	disks := machineDiskDemand(csMachine)
+	err := c.CheckAccountLimits(fd, offering, disks)
 	if err != nil {
 		return err
 	}
 
-	err = c.CheckDomainLimits(fd, offering)
+	err = c.CheckDomainLimits(fd, offering, disks)
 	if err != nil {
 		return err
 	}
 
-	err = c.CheckProjectLimits(fd, offering)
+	err = c.CheckProjectLimits(fd, offering, disks)
 	if err != nil {
 		return err
 	}
@@ -309,8 +665,18 @@ func (c *client) CheckLimits(
 	return nil
 }
 
+// machineIPAddress returns the address of the machine on the network of its failure domain: its static IP, or the
+// address it claimed from an IP address pool. CloudStack allocates one when it's empty.
+func machineIPAddress(csMachine *infrav1.CloudStackMachine) string {
+	if csMachine.Spec.IPAddress != "" {
+		return csMachine.Spec.IPAddress
+	}
+	return csMachine.Status.IPAddress
+}
+
 // DeployVM will create a VM instance,
-// and sets the infrastructure machine spec and status accordingly.
+// and sets the infrastructure machine spec and status accordingly. The VM is deployed by an async job whose ID is set
+// in the machine's status along with the VM's instance ID, unless a retried deployment found the VM already.
 func (c *client) DeployVM(
 	csMachine *infrav1.CloudStackMachine,
 	capiMachine *clusterv1.Machine,
@@ -324,18 +690,66 @@ func (c *client) DeployVM(
 	if err != nil {
 		return err
 	}
+	if err := c.verifyRootDiskSize(csMachine.Spec.RootDisk.SizeInGB, templateID); err != nil {
+		return err
+	}
+	rootDiskOfferingID, err := c.resolveRootDiskOffering(csMachine, fd.Spec.Zone.ID, templateID)
+	if err != nil {
+		return err
+	}
 	diskOfferingID, err := c.ResolveDiskOffering(csMachine, fd.Spec.Zone.ID)
 	if err != nil {
 		return err
 	}
+	// The other data disks are attached before the VM is started, their offerings are checked before it's deployed.
+	disks := dataDisks(csMachine)
+	for i := 1; i < len(disks); i++ {
+		if _, err := c.resolveDataDiskOffering(disks[i], fd.Spec.Zone.ID); err != nil {
+			return err
+		}
+	}
+	additionalNetworks, err := c.ResolveAdditionalNetworks(csMachine, fd.Spec.Zone.ID)
+	if err != nil {
+		return err
+	}
 
 	p := c.cs.VirtualMachine.NewDeployVirtualMachineParams(offering.Id, templateID, fd.Spec.Zone.ID)
-	p.SetNetworkids([]string{fd.Spec.Zone.Network.ID})
+	ipAddress := machineIPAddress(csMachine)
+	if len(additionalNetworks) > 0 {
+		// The failure domain's network comes first to be the VM's default network.
+		defaultNetwork := map[string]string{"networkid": fd.Spec.Zone.Network.ID}
+		if ipAddress != "" {
+			defaultNetwork["ip"] = ipAddress
+		}
+		p.SetIptonetworklist(append([]map[string]string{defaultNetwork}, additionalNetworks...))
+	} else {
+		p.SetNetworkids([]string{fd.Spec.Zone.Network.ID})
+		setIfNotEmpty(ipAddress, p.SetIpaddress)
+	}
 	setIfNotEmpty(csMachine.Name, p.SetName)
 	setIfNotEmpty(capiMachine.Name, p.SetDisplayname)
+	setIntIfPositive(csMachine.Spec.RootDisk.SizeInGB, p.SetRootdisksize)
+	setIfNotEmpty(rootDiskOfferingID, p.SetOverridediskofferingid)
 	setIfNotEmpty(diskOfferingID, p.SetDiskofferingid)
 	setIfNotEmpty(c.user.Project.ID, p.SetProjectid)
-	setIntIfPositive(csMachine.Spec.DiskOffering.CustomSize, p.SetSize)
+	details := map[string]string{}
+	for k, v := range csMachine.Spec.InFailureDomain(csMachine.Spec.FailureDomainName).Details {
+		details[k] = v
+	}
+	if len(disks) > 0 {
+		setIntIfPositive(disks[0].CustomSize, p.SetSize)
+		// CloudStack takes the IOPS of the disk deployed with the VM as details.
+		if disks[0].MinIOPS > 0 {
+			details["minIopsDo"] = strconv.FormatInt(disks[0].MinIOPS, 10)
+		}
+		if disks[0].MaxIOPS > 0 {
+			details["maxIopsDo"] = strconv.FormatInt(disks[0].MaxIOPS, 10)
+		}
+	}
+	if len(disks) > 1 {
+		p.SetStartvm(false)
+		csMachine.Status.DataDisksPending = true
+	}
 
 	setIfNotEmpty(csMachine.Spec.SSHKey, p.SetKeypair)
 
@@ -354,21 +768,33 @@ func (c *client) DeployVM(
 		p.SetAffinitygroupids([]string{affinity.Spec.ID})
 	}
 
-	if csMachine.Spec.Details != nil {
-		p.SetDetails(csMachine.Spec.Details)
+	if len(details) > 0 {
+		p.SetDetails(details)
 	}
 
-	deployVMResp, err := c.cs.VirtualMachine.DeployVirtualMachine(p)
+	// Before retrying a failed deployment, check whether it created the VM anyway.
+	var deployVMResp *cloudstack.DeployVirtualMachineResponse
+	var deployedVM *cloudstack.VirtualMachine
+	err = c.retry("deployVirtualMachine", func() error {
+		resp, err := c.cs.VirtualMachine.DeployVirtualMachine(p)
+		if err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return err
+		}
+		deployVMResp = resp
+		return nil
+	}, func() bool {
+		deployedVM, _ = findVirtualMachine(c.cs.VirtualMachine, templateID, fd, csMachine, capiMachine, c.user.Project.ID)
+		return deployedVM != nil
+	})
 	if err != nil {
-		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-
 		// CloudStack may have created the VM even though it reported an error. We attempt to
 		// retrieve the VM so we can populate the CloudStackMachine for the user to manually
 		// clean up.
-		vm, findErr := findVirtualMachine(c.cs.VirtualMachine, templateID, fd, csMachine, c.user.Project.ID)
+		vm, findErr := findVirtualMachine(c.cs.VirtualMachine, templateID, fd, csMachine, capiMachine, c.user.Project.ID)
 		if findErr != nil {
 			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(findErr)
-			return fmt.Errorf("%v; find virtual machine: %v", err, findErr)
+			return fmt.Errorf("%w; find virtual machine: %v", err, findErr)
 		}
 
 		// We didn't find a VM so return the original error.
@@ -378,16 +804,83 @@ func (c *client) DeployVM(
 
 		csMachine.Spec.InstanceID = pointer.String(vm.Id)
 		csMachine.Status.InstanceState = vm.State
+		markCreatedByCAPC(csMachine)
 
 		return fmt.Errorf("incomplete vm deployment (vm_id=%v): %w", vm.Id, err)
 	}
 
+	markCreatedByCAPC(csMachine)
+	if deployVMResp == nil { // A retry found the VM the failed call deployed.
+		csMachine.Spec.InstanceID = pointer.String(deployedVM.Id)
+		csMachine.Status.Status = pointer.String(metav1.StatusSuccess)
+		return nil
+	}
 	csMachine.Spec.InstanceID = pointer.String(deployVMResp.Id)
-	csMachine.Status.Status = pointer.String(metav1.StatusSuccess)
-
+	if deployVMResp.JobID == "" { // Deployed by a client waiting for the job.
+		csMachine.Status.Status = pointer.String(metav1.StatusSuccess)
+		return nil
+	}
+	// The job ID is kept in the machine's status along with the ID of the VM it deploys, so that the machine adopts the VM
+	// even if the controller restarts before the job completes. See ResolveVMInstanceDetails. The VM is tagged right away
+	// for it to be found by its ownership tag should the job be purged.
+	csMachine.Status.DeployJobID = pointer.String(deployVMResp.JobID)
+	if err := c.AddTags(ResourceTypeUserVM, deployVMResp.Id, vmOwnershipTags(csMachine, csCluster)); err != nil {
+		return errors.Wrapf(err, "tagging VM %s", deployVMResp.Id)
+	}
 	return nil
 }
 
+// Statuses of CloudStack async jobs.
+const (
+	asyncJobPending   = 0
+	asyncJobSucceeded = 1
+	asyncJobFailed    = 2
+)
+
+// resolveDeployJob checks once on the async job deploying the machine's VM. When the job has succeeded, the VM it
+// deployed becomes the machine's instance. Returns ErrJobPending while the job is running, and the job's error if it
+// failed.
+func (c *client) resolveDeployJob(csMachine *infrav1.CloudStackMachine) error {
+	jobID := *csMachine.Status.DeployJobID
+	job, err := c.cs.Asyncjob.QueryAsyncJobResult(c.cs.Asyncjob.NewQueryAsyncJobResultParams(jobID))
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		if err = notFoundIfInvalidID(err); errors.Is(err, ErrNotFound) {
+			// The job has been purged. The VM is looked up by the instance ID its deployment returned, or by its ownership
+			// tag, and only deployed again once neither finds it.
+			csMachine.Status.DeployJobID = nil
+			return nil
+		}
+		return errors.Wrapf(err, "querying deploy job %s", jobID)
+	}
+
+	switch job.Jobstatus {
+	case asyncJobPending:
+		return errors.Wrapf(ErrJobPending, "deploy job %s", jobID)
+	case asyncJobSucceeded:
+		if job.Jobinstanceid == "" {
+			return errors.Errorf("deploy job %s succeeded without reporting the VM it deployed", jobID)
+		}
+		csMachine.Spec.InstanceID = pointer.String(job.Jobinstanceid)
+		csMachine.Status.DeployJobID = nil
+		csMachine.Status.Status = pointer.String(metav1.StatusSuccess)
+		return nil
+	case asyncJobFailed:
+		csMachine.Status.DeployJobID = nil
+		err := asyncJobError(job)
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		if job.Jobinstanceid != "" {
+			// CloudStack may have created the VM even though the job failed. It's adopted for it to be cleaned up along
+			// with the machine.
+			csMachine.Spec.InstanceID = pointer.String(job.Jobinstanceid)
+			return fmt.Errorf("incomplete vm deployment (vm_id=%v): %w", job.Jobinstanceid, err)
+		}
+		csMachine.Spec.InstanceID = nil
+		return err
+	}
+	return errors.Errorf("deploy job %s has unknown status %d", jobID, job.Jobstatus)
+}
+
 // GetOrCreateVMInstance CreateVMInstance will fetch or create a VM instance, and
 // sets the infrastructure machine spec and status accordingly.
 func (c *client) GetOrCreateVMInstance(
@@ -398,9 +891,15 @@ func (c *client) GetOrCreateVMInstance(
 	affinity *infrav1.CloudStackAffinityGroup,
 	userData string,
 ) error {
-	// Check if VM instance already exists.
-	if err := c.ResolveVMInstanceDetails(csMachine); err == nil ||
-		!strings.Contains(strings.ToLower(err.Error()), "no match") {
+	// Check if VM instance already exists. A VM that isn't found after a deployment was started is only deployed again
+	// on the next reconciliation, once that deployment is known to be gone.
+	deploying := csMachine.Status.DeployJobID != nil
+	if vm, err := c.resolveVMInstance(csMachine); err == nil {
+		if err := c.tagVMInstance(vm, csMachine, csCluster); err != nil {
+			return err
+		}
+		return c.attachDataDisksAndStart(vm, csMachine, fd)
+	} else if !errors.Is(err, ErrNotFound) || deploying {
 		return err
 	}
 
@@ -409,7 +908,7 @@ func (c *client) GetOrCreateVMInstance(
 		return err
 	}
 
-	err = c.CheckLimits(fd, &offering)
+	err = c.CheckLimits(fd, &offering, csMachine)
 	if err != nil {
 		return err
 	}
@@ -420,16 +919,148 @@ func (c *client) GetOrCreateVMInstance(
 
 	// Resolve uses a VM metrics request response to fill cloudstack machine status.
 	// The deployment response is insufficient.
+	vm, err := c.resolveVMInstance(csMachine)
+	if err != nil {
+		return err
+	}
+	if err := c.tagVMInstance(vm, csMachine, csCluster); err != nil {
+		return err
+	}
+	return c.attachDataDisksAndStart(vm, csMachine, fd)
+}
+
+// attachDataDisksAndStart creates the machine's data disks beyond the first, attaches them to its VM, and starts the VM,
+// which is deployed stopped when the machine has such disks. The disks are named after the machine and tagged as owned
+// by it, so disks created by an interrupted attempt are reused. Nothing's done unless the VM is stopped while its
+// deployment marked its data disks as pending or some of them aren't attached to it, so a VM stopped after it was
+// started with all its disks stays stopped.
+func (c *client) attachDataDisksAndStart(
+	vm *cloudstack.VirtualMachinesMetric,
+	csMachine *infrav1.CloudStackMachine,
+	fd *infrav1.CloudStackFailureDomain,
+) error {
+	if vm.State == "Running" {
+		// The VM was started, by an earlier reconciliation whose status update was lost or by someone else.
+		csMachine.Status.DataDisksPending = false
+		return nil
+	} else if vm.State != "Stopped" {
+		return nil
+	}
+
+	disks := dataDisks(csMachine)
+	volumes := make([]*cloudstack.Volume, len(disks))
+	unattached := false
+	for i := 1; i < len(disks); i++ {
+		volume, err := c.findDataDiskVolume(csMachine, fmt.Sprintf("%s-data-%d", csMachine.Name, i))
+		if err != nil {
+			return err
+		}
+		volumes[i] = volume
+		unattached = unattached || volume == nil || volume.Virtualmachineid != vm.Id
+	}
+	if !unattached && !csMachine.Status.DataDisksPending {
+		return nil
+	}
+
+	for i := 1; i < len(disks); i++ {
+		volume := volumes[i]
+		if volume == nil {
+			var err error
+			if volume, err = c.createDataDiskVolume(csMachine, fd, disks[i], fmt.Sprintf("%s-data-%d", csMachine.Name, i)); err != nil {
+				return err
+			}
+		}
+		if volume.Virtualmachineid == vm.Id {
+			continue
+		} else if volume.Virtualmachineid != "" {
+			return errors.Errorf("data disk volume %s is attached to another VM %s", volume.Id, volume.Virtualmachineid)
+		}
+		if _, err := c.csAsync.Volume.AttachVolume(c.csAsync.Volume.NewAttachVolumeParams(volume.Id, vm.Id)); err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrapf(classifyError(err), "attaching data disk volume %s to VM %s", volume.Id, vm.Id)
+		}
+	}
+
+	if _, err := c.csAsync.VirtualMachine.StartVirtualMachine(c.csAsync.VirtualMachine.NewStartVirtualMachineParams(vm.Id)); err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(classifyError(err), "starting VM %s", vm.Id)
+	}
+	csMachine.Status.DataDisksPending = false
 	return c.ResolveVMInstanceDetails(csMachine)
 }
 
-// findVirtualMachine retrieves a virtual machine by matching its expected name, template, failure
-// domain zone and failure domain network. If no virtual machine is found it returns nil, nil.
+// findDataDiskVolume returns the data disk volume of the machine with the given name, or nil if there's none. Volumes
+// tagged as owned by another machine don't match, and an untagged one is tagged as owned by the machine.
+func (c *client) findDataDiskVolume(csMachine *infrav1.CloudStackMachine, name string) (*cloudstack.Volume, error) {
+	p := c.cs.Volume.NewListVolumesParams()
+	p.SetName(name)
+	p.SetType("DATADISK")
+	setIfNotEmpty(c.user.Project.ID, p.SetProjectid)
+	resp, err := c.cs.Volume.ListVolumes(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return nil, errors.Wrapf(classifyError(err), "listing data disk volume %s", name)
+	}
+	for _, volume := range resp.Volumes {
+		if volume.Name != name {
+			continue
+		}
+		if owner, found := vmOwnerUID(volume.Tags); !found {
+			if csMachine.UID == "" {
+				return volume, nil
+			} else if err := c.AddTags(ResourceTypeVolume, volume.Id, map[string]string{MachineUIDTagName: string(csMachine.UID)}); err != nil {
+				return nil, errors.Wrapf(err, "tagging data disk volume %s", volume.Id)
+			}
+			return volume, nil
+		} else if owner == string(csMachine.UID) {
+			return volume, nil
+		}
+	}
+	return nil, nil
+}
+
+// createDataDiskVolume creates a data disk volume of the machine and tags it as owned by the machine.
+func (c *client) createDataDiskVolume(
+	csMachine *infrav1.CloudStackMachine,
+	fd *infrav1.CloudStackFailureDomain,
+	disk infrav1.CloudStackMachineDataDisk,
+	name string,
+) (*cloudstack.Volume, error) {
+	diskOfferingID, err := c.resolveDataDiskOffering(disk, fd.Spec.Zone.ID)
+	if err != nil {
+		return nil, err
+	}
+	p := c.csAsync.Volume.NewCreateVolumeParams()
+	p.SetName(name)
+	p.SetZoneid(fd.Spec.Zone.ID)
+	p.SetDiskofferingid(diskOfferingID)
+	setIfNotEmpty(c.user.Project.ID, p.SetProjectid)
+	setIntIfPositive(disk.CustomSize, p.SetSize)
+	setIntIfPositive(disk.MinIOPS, p.SetMiniops)
+	setIntIfPositive(disk.MaxIOPS, p.SetMaxiops)
+	resp, err := c.csAsync.Volume.CreateVolume(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return nil, errors.Wrapf(classifyError(err), "creating data disk volume %s", name)
+	}
+	tags := map[string]string{MachineUIDTagName: string(csMachine.UID), CreatedByCAPCTagName: "1"}
+	if err := c.AddTags(ResourceTypeVolume, resp.Id, tags); err != nil {
+		return nil, errors.Wrapf(err, "tagging data disk volume %s", resp.Id)
+	}
+	return &cloudstack.Volume{Id: resp.Id, Name: name}, nil
+}
+
+// findVirtualMachine retrieves a virtual machine of the machine by matching its expected name, template, failure
+// domain zone and failure domain network. A VM deployed by a failed call isn't tagged yet, so besides VMs tagged as
+// owned by the machine, untagged VMs with the display name of the CAPI machine match. If no virtual machine is found
+// it returns nil, nil.
 func findVirtualMachine(
 	client cloudstack.VirtualMachineServiceIface,
 	templateID string,
 	failureDomain *infrav1.CloudStackFailureDomain,
-	machine *infrav1.CloudStackMachine, projectID string,
+	machine *infrav1.CloudStackMachine,
+	capiMachine *clusterv1.Machine,
+	projectID string,
 ) (*cloudstack.VirtualMachine, error) {
 	params := client.NewListVirtualMachinesParams()
 	params.SetTemplateid(templateID)
@@ -443,15 +1074,28 @@ func findVirtualMachine(
 		return nil, err
 	}
 
-	if response.Count == 0 {
-		return nil, nil
+	for _, vm := range response.VirtualMachines {
+		if owner, found := vmOwnerUID(vm.Tags); found && owner == string(machine.UID) {
+			return vm, nil
+		} else if !found && vm.Displayname == capiMachine.Name {
+			return vm, nil
+		}
 	}
-
-	return response.VirtualMachines[0], nil
+	return nil, nil
 }
 
-// DestroyVMInstance Destroys a VM instance. Assumes machine has been fetched prior and has an instance ID.
+// DestroyVMInstance Destroys a VM instance. Assumes machine has been fetched prior and has an instance ID. VMs tagged
+// as owned by another machine aren't destroyed, unless the machine tagged the VM with the UID it had before it was moved
+// to another management cluster.
 func (c *client) DestroyVMInstance(csMachine *infrav1.CloudStackMachine) error {
+	tags, err := c.GetTags(ResourceTypeUserVM, *csMachine.Spec.InstanceID)
+	if err != nil {
+		return errors.Wrapf(err, "checking the owner of VM %s", *csMachine.Spec.InstanceID)
+	} else if owner, found := tags[MachineUIDTagName]; found && owner != string(csMachine.UID) &&
+		owner != csMachine.Annotations[infrav1.InstanceOwnerUIDAnnotation] {
+		return errors.Errorf("VM %s is tagged as owned by another machine with UID %s", *csMachine.Spec.InstanceID, owner)
+	}
+
 	p := c.cs.Configuration.NewListCapabilitiesParams()
 	capabilities, err := c.cs.Configuration.ListCapabilities(p)
 	expunge := true
@@ -459,6 +1103,10 @@ func (c *client) DestroyVMInstance(csMachine *infrav1.CloudStackMachine) error {
 		expunge = capabilities.Capabilities.Allowuserexpungerecovervm
 	}
 
+	if err := c.deleteDetachedDataDiskVolumes(csMachine); err != nil {
+		return err
+	}
+
 	// Attempt deletion regardless of machine state.
 	p2 := c.csAsync.VirtualMachine.NewDestroyVirtualMachineParams(*csMachine.Spec.InstanceID)
 	volIDs, err := c.listVMInstanceDatadiskVolumeIDs(*csMachine.Spec.InstanceID)
@@ -467,13 +1115,15 @@ func (c *client) DestroyVMInstance(csMachine *infrav1.CloudStackMachine) error {
 	}
 	p2.SetExpunge(expunge)
 	setArrayIfNotEmpty(volIDs, p2.SetVolumeids)
-	if _, err := c.csAsync.VirtualMachine.DestroyVirtualMachine(p2); err != nil &&
-		strings.Contains(strings.ToLower(err.Error()), "unable to find uuid for id") {
-		// VM doesn't exist. Success...
-		return nil
-	} else if err != nil {
+	if _, err := c.csAsync.VirtualMachine.DestroyVirtualMachine(p2); err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return err
+		// CloudStack rejects the ID of a VM that doesn't exist as invalid, like any other invalid parameter.
+		if _, count, getErr := c.cs.VirtualMachine.GetVirtualMachinesMetricByID(
+			*csMachine.Spec.InstanceID, cloudstack.WithProject(c.user.Project.ID)); count == 0 && errors.Is(classifyError(getErr), ErrNotFound) {
+			// VM doesn't exist. Success...
+			return nil
+		}
+		return classifyError(err)
 	}
 
 	if err := c.ResolveVMInstanceDetails(csMachine); err == nil && (csMachine.Status.InstanceState == "Expunging" ||
@@ -481,7 +1131,7 @@ func (c *client) DestroyVMInstance(csMachine *infrav1.CloudStackMachine) error {
 		// VM is stopped and getting expunged.  So the desired state is getting satisfied.  Let's move on.
 		return nil
 	} else if err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "no match found") {
+		if errors.Is(err, ErrNotFound) {
 			// VM doesn't exist.  So the desired state is in effect.  Our work is done here.
 			return nil
 		}
@@ -491,6 +1141,35 @@ func (c *client) DestroyVMInstance(csMachine *infrav1.CloudStackMachine) error {
 	return errors.New("VM deletion in progress")
 }
 
+// deleteDetachedDataDiskVolumes deletes the data disk volumes of the machine that aren't attached to its VM, such as
+// those created before the machine was deleted while its VM was being provisioned. Attached volumes are destroyed
+// along with the VM.
+func (c *client) deleteDetachedDataDiskVolumes(csMachine *infrav1.CloudStackMachine) error {
+	if len(dataDisks(csMachine)) < 2 || csMachine.UID == "" {
+		return nil
+	}
+	p := c.cs.Volume.NewListVolumesParams()
+	p.SetTags(map[string]string{MachineUIDTagName: string(csMachine.UID)})
+	p.SetType("DATADISK")
+	setIfNotEmpty(c.user.Project.ID, p.SetProjectid)
+	resp, err := c.cs.Volume.ListVolumes(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrap(classifyError(err), "listing data disk volumes")
+	}
+	for _, volume := range resp.Volumes {
+		if volume.Virtualmachineid != "" {
+			continue
+		}
+		if _, err := c.cs.Volume.DeleteVolume(c.cs.Volume.NewDeleteVolumeParams(volume.Id)); err != nil &&
+			!errors.Is(notFoundIfInvalidID(err), ErrNotFound) {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrapf(classifyError(err), "deleting data disk volume %s", volume.Id)
+		}
+	}
+	return nil
+}
+
 func (c *client) listVMInstanceDatadiskVolumeIDs(instanceID string) ([]string, error) {
 	p := c.cs.Volume.NewListVolumesParams()
 	p.SetVirtualmachineid(instanceID)
@@ -501,7 +1180,7 @@ func (c *client) listVMInstanceDatadiskVolumeIDs(instanceID string) ([]string, e
 	listVOLResp, err := c.csAsync.Volume.ListVolumes(p)
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	var ret []string
@@ -27,9 +27,11 @@ import (
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
 
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/utils/pointer"
 )
 
@@ -54,6 +56,7 @@ var _ = Describe("Instance", func() {
 		dos           *cloudstack.MockDiskOfferingServiceIface
 		ts            *cloudstack.MockTemplateServiceIface
 		vs            *cloudstack.MockVolumeServiceIface
+		rtlp          *cloudstack.MockResourcetagsServiceIface
 		client        cloud.Client
 	)
 
@@ -66,6 +69,7 @@ var _ = Describe("Instance", func() {
 		dos = mockClient.DiskOffering.(*cloudstack.MockDiskOfferingServiceIface)
 		ts = mockClient.Template.(*cloudstack.MockTemplateServiceIface)
 		vs = mockClient.Volume.(*cloudstack.MockVolumeServiceIface)
+		rtlp = mockClient.Resourcetags.(*cloudstack.MockResourcetagsServiceIface)
 		client = cloud.NewClientFromCSAPIClient(mockClient, nil)
 
 		dummies.SetDummyVars()
@@ -75,6 +79,30 @@ var _ = Describe("Instance", func() {
 		mockCtrl.Finish()
 	})
 
+	expectVMsOwnedByMachine := func(resp *cloudstack.ListVirtualMachinesMetricsResponse, err error) {
+		vms.EXPECT().NewListVirtualMachinesMetricsParams().Return(&cloudstack.ListVirtualMachinesMetricsParams{})
+		vms.EXPECT().ListVirtualMachinesMetrics(gomock.Any()).Return(resp, err)
+	}
+
+	// The dummy machine has an instance ID, so its VM is adopted rather than deployed by it.
+	expectVMTagged := func() {
+		rtlp.EXPECT().NewCreateTagsParams(gomock.Any(), string(cloud.ResourceTypeUserVM), map[string]string{
+			dummies.CSClusterTagKey: "1",
+			cloud.MachineUIDTagName: string(dummies.CSMachine1.UID),
+		}).Return(&cloudstack.CreateTagsParams{})
+		rtlp.EXPECT().CreateTags(gomock.Any()).Return(&cloudstack.CreateTagsResponse{}, nil)
+	}
+
+	// A VM the dummy machine deployed is tagged as created by CAPC as well.
+	expectDeployedVMTagged := func() {
+		rtlp.EXPECT().NewCreateTagsParams(gomock.Any(), string(cloud.ResourceTypeUserVM), map[string]string{
+			dummies.CSClusterTagKey:    "1",
+			cloud.MachineUIDTagName:    string(dummies.CSMachine1.UID),
+			cloud.CreatedByCAPCTagName: "1",
+		}).Return(&cloudstack.CreateTagsParams{})
+		rtlp.EXPECT().CreateTags(gomock.Any()).Return(&cloudstack.CreateTagsResponse{}, nil)
+	}
+
 	Context("when fetching a VM instance", func() {
 		It("Handles an unknown error when fetching by ID", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).Return(nil, -1, unknownError)
@@ -95,31 +123,39 @@ var _ = Describe("Instance", func() {
 			Ω(dummies.CSMachine1.Spec.InstanceID).Should(Equal(pointer.String(vmsResp.Id)))
 		})
 
-		It("handles an unknown error when fetching by name", func() {
+		It("handles an unknown error when fetching by ownership tag", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).Return(nil, -1, notFoundError)
-			vms.EXPECT().GetVirtualMachinesMetricByName(dummies.CSMachine1.Name, gomock.Any()).Return(nil, -1, unknownError)
+			expectVMsOwnedByMachine(nil, unknownError)
 
 			Ω(client.ResolveVMInstanceDetails(dummies.CSMachine1)).Should(MatchError(unknownErrorMessage))
 		})
 
-		It("handles finding more than one VM instance by Name", func() {
+		It("handles finding more than one VM instance by ownership tag", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).Return(nil, -1, notFoundError)
-			vms.EXPECT().GetVirtualMachinesMetricByName(dummies.CSMachine1.Name, gomock.Any()).Return(nil, 2, nil)
+			expectVMsOwnedByMachine(&cloudstack.ListVirtualMachinesMetricsResponse{Count: 2}, nil)
 
 			Ω(client.ResolveVMInstanceDetails(dummies.CSMachine1)).Should(
-				MatchError("found more than one VM Instance with name " + dummies.CSMachine1.Name))
+				MatchError("found more than one VM Instance owned by machine " + dummies.CSMachine1.Name))
 		})
 
-		It("sets dummies.CSMachine1 spec and status values when VM instance found by Name", func() {
+		It("sets dummies.CSMachine1 spec and status values when VM instance found by ownership tag", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).Return(nil, -1, notFoundError)
-			vms.EXPECT().GetVirtualMachinesMetricByName(dummies.CSMachine1.Name, gomock.Any()).
-				Return(&cloudstack.VirtualMachinesMetric{Id: *dummies.CSMachine1.Spec.InstanceID}, -1, nil)
+			expectVMsOwnedByMachine(&cloudstack.ListVirtualMachinesMetricsResponse{
+				Count:                  1,
+				VirtualMachinesMetrics: []*cloudstack.VirtualMachinesMetric{{Id: *dummies.CSMachine1.Spec.InstanceID}},
+			}, nil)
 
 			Ω(client.ResolveVMInstanceDetails(dummies.CSMachine1)).Should(Succeed())
 			Ω(dummies.CSMachine1.Spec.ProviderID).Should(Equal(
 				pointer.String(fmt.Sprintf("cloudstack:///%s", *dummies.CSMachine1.Spec.InstanceID))))
 			Ω(dummies.CSMachine1.Spec.InstanceID).Should(Equal(pointer.String(*dummies.CSMachine1.Spec.InstanceID)))
 		})
+
+		It("doesn't look VM instances up by ownership tag for machines without a UID", func() {
+			dummies.CSMachine1.UID = ""
+			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).Return(nil, -1, notFoundError)
+			Ω(errors.Is(client.ResolveVMInstanceDetails(dummies.CSMachine1), cloud.ErrNotFound)).Should(BeTrue())
+		})
 	})
 
 	Context("when creating a VM instance", func() {
@@ -127,16 +163,61 @@ var _ = Describe("Instance", func() {
 
 		expectVMNotFound := func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).Return(nil, -1, notFoundError)
-			vms.EXPECT().GetVirtualMachinesMetricByName(dummies.CSMachine1.Name, gomock.Any()).Return(nil, -1, notFoundError)
+			expectVMsOwnedByMachine(&cloudstack.ListVirtualMachinesMetricsResponse{}, nil)
 		}
 
 		It("doesn't re-create if one already exists.", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).Return(vmMetricResp, -1, nil)
+			expectVMTagged()
+			Ω(client.GetOrCreateVMInstance(
+				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
+				Should(Succeed())
+		})
+
+		It("doesn't tag a VM that's already tagged as owned by the machine again.", func() {
+			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).
+				Return(&cloudstack.VirtualMachinesMetric{Tags: []cloudstack.Tags{
+					{Key: cloud.CreatedByCAPCTagName, Value: "1"},
+					{Key: dummies.CSClusterTagKey, Value: "1"},
+					{Key: cloud.MachineUIDTagName, Value: string(dummies.CSMachine1.UID)},
+				}}, 1, nil)
+			Ω(client.GetOrCreateVMInstance(
+				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
+				Should(Succeed())
+		})
+
+		It("tags a VM found by ID that's tagged with the UID the machine had before it was moved with its UID.", func() {
+			dummies.CSMachine1.Annotations = map[string]string{infrav1.InstanceOwnerUIDAnnotation: "previous-uid"}
+			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).
+				Return(&cloudstack.VirtualMachinesMetric{Id: *dummies.CSMachine1.Spec.InstanceID, Tags: []cloudstack.Tags{
+					{Key: cloud.CreatedByCAPCTagName, Value: "1"},
+					{Key: dummies.CSClusterTagKey, Value: "1"},
+					{Key: cloud.MachineUIDTagName, Value: "previous-uid"},
+				}}, 1, nil)
+			rtlp.EXPECT().NewDeleteTagsParams([]string{*dummies.CSMachine1.Spec.InstanceID}, string(cloud.ResourceTypeUserVM)).
+				Return(&cloudstack.DeleteTagsParams{})
+			rtlp.EXPECT().DeleteTags(gomock.Any()).Return(&cloudstack.DeleteTagsResponse{}, nil)
+			rtlp.EXPECT().NewCreateTagsParams([]string{*dummies.CSMachine1.Spec.InstanceID}, string(cloud.ResourceTypeUserVM),
+				map[string]string{cloud.MachineUIDTagName: string(dummies.CSMachine1.UID)}).Return(&cloudstack.CreateTagsParams{})
+			rtlp.EXPECT().CreateTags(gomock.Any()).Return(&cloudstack.CreateTagsResponse{}, nil)
 			Ω(client.GetOrCreateVMInstance(
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 				Should(Succeed())
 		})
 
+		It("refuses to adopt a VM found by ID that's tagged as owned by another machine", func() {
+			dummies.CSMachine1.Annotations = map[string]string{infrav1.InstanceOwnerUIDAnnotation: "previous-uid"}
+			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).
+				Return(&cloudstack.VirtualMachinesMetric{Id: *dummies.CSMachine1.Spec.InstanceID, Tags: []cloudstack.Tags{
+					{Key: dummies.CSClusterTagKey, Value: "1"},
+					{Key: cloud.MachineUIDTagName, Value: "other-uid"},
+				}}, 1, nil)
+			err := client.GetOrCreateVMInstance(
+				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")
+			Ω(errors.Is(err, cloud.ErrInvalidSpec)).Should(BeTrue())
+			Ω(err).Should(MatchError(ContainSubstring("owned by another machine with UID other-uid")))
+		})
+
 		It("returns unknown error while fetching VM instance", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).Return(nil, -1, unknownError)
 			Ω(client.GetOrCreateVMInstance(
@@ -562,6 +643,75 @@ var _ = Describe("Instance", func() {
 					dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 					Should(MatchError("VM Limit in project has reached it's maximum value"))
 			})
+
+			It("returns errors when there are not enough available volumes in account for the data disks", func() {
+				expectVMNotFound()
+				dummies.CSMachine1.Spec.DiskOffering.CustomSize = 0
+				sos.EXPECT().GetServiceOfferingByName(dummies.CSMachine1.Spec.Offering.Name, gomock.Any()).
+					Return(&cloudstack.ServiceOffering{
+						Id:        dummies.CSMachine1.Spec.Offering.ID,
+						Name:      dummies.CSMachine1.Spec.Offering.Name,
+						Cpunumber: 2,
+						Memory:    1024,
+					}, 1, nil)
+				user := &cloud.User{
+					Account: cloud.Account{
+						Domain: cloud.Domain{
+							CPUAvailable:    "20",
+							MemoryAvailable: "2048",
+							VMAvailable:     "10",
+							VolumeAvailable: "20",
+						},
+						CPUAvailable:    "20",
+						MemoryAvailable: "2048",
+						VMAvailable:     "10",
+						VolumeAvailable: "1",
+					},
+				}
+				c := cloud.NewClientFromCSAPIClient(mockClient, user)
+				err := c.GetOrCreateVMInstance(
+					dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")
+				Ω(err).Should(MatchError("volumes available (1) in account can't fulfil the requirement: 2"))
+				Ω(errors.Is(err, cloud.ErrLimitExceeded)).Should(BeTrue())
+			})
+
+			It("returns errors when there is not enough available primary storage in project for the data disks", func() {
+				expectVMNotFound()
+				dummies.CSMachine1.Spec.DiskOffering.CustomSize = 0
+				dummies.CSMachine1.Spec.DataDisks = []infrav1.CloudStackMachineDataDisk{
+					{Offering: infrav1.CloudStackResourceIdentifier{Name: dummies.CSMachine1.Spec.DiskOffering.Name}, CustomSize: 100},
+				}
+				sos.EXPECT().GetServiceOfferingByName(dummies.CSMachine1.Spec.Offering.Name, gomock.Any()).
+					Return(&cloudstack.ServiceOffering{
+						Id:        dummies.CSMachine1.Spec.Offering.ID,
+						Name:      dummies.CSMachine1.Spec.Offering.Name,
+						Cpunumber: 2,
+						Memory:    1024,
+					}, 1, nil)
+				user := &cloud.User{
+					Account: cloud.Account{
+						Domain: cloud.Domain{
+							CPUAvailable:    "20",
+							MemoryAvailable: "2048",
+							VMAvailable:     "10",
+						},
+						CPUAvailable:    "20",
+						MemoryAvailable: "2048",
+						VMAvailable:     "10",
+					},
+					Project: cloud.Project{
+						ID:                      "123",
+						CPUAvailable:            "20",
+						MemoryAvailable:         "2048",
+						VMAvailable:             "10",
+						PrimaryStorageAvailable: "50",
+					},
+				}
+				c := cloud.NewClientFromCSAPIClient(mockClient, user)
+				Ω(c.GetOrCreateVMInstance(
+					dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
+					Should(MatchError("primary storage available (50 GB) in project can't fulfil the requirement: 100 GB"))
+			})
 		})
 
 		It("handles deployment errors", func() {
@@ -597,7 +747,7 @@ var _ = Describe("Instance", func() {
 					vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).
 						Return(&cloudstack.VirtualMachinesMetric{}, 1, nil))
 
-				vms.EXPECT().GetVirtualMachinesMetricByName(dummies.CSMachine1.Name, gomock.Any()).Return(nil, -1, notFoundError)
+				expectVMsOwnedByMachine(&cloudstack.ListVirtualMachinesMetricsResponse{}, nil)
 			})
 
 			ActionAndAssert := func() {
@@ -624,6 +774,7 @@ var _ = Describe("Instance", func() {
 
 						Ω(string(decompressedUserData)).To(Equal(expectUserData))
 					}).Return(deploymentResp, nil)
+				expectDeployedVMTagged()
 
 				Ω(client.GetOrCreateVMInstance(
 					dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, expectUserData)).
@@ -756,7 +907,7 @@ var _ = Describe("Instance", func() {
 			BeforeEach(func() {
 				vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).
 					Return(nil, -1, notFoundError)
-				vms.EXPECT().GetVirtualMachinesMetricByName(dummies.CSMachine1.Name, gomock.Any()).Return(nil, -1, notFoundError)
+				expectVMsOwnedByMachine(&cloudstack.ListVirtualMachinesMetricsResponse{}, nil)
 			})
 
 			It("works with Id and name both provided, offering name mismatch", func() {
@@ -818,9 +969,7 @@ var _ = Describe("Instance", func() {
 			vms.EXPECT().
 				GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).
 				Return(&cloudstack.VirtualMachinesMetric{}, 1, nil)
-			vms.EXPECT().
-				GetVirtualMachinesMetricByName(dummies.CSMachine1.Name, gomock.Any()).
-				Return(nil, -1, notFoundError)
+			expectVMsOwnedByMachine(&cloudstack.ListVirtualMachinesMetricsResponse{}, nil)
 
 			sos.EXPECT().
 				GetServiceOfferingByName(dummies.CSMachine1.Spec.Offering.Name, gomock.Any()).
@@ -860,6 +1009,7 @@ var _ = Describe("Instance", func() {
 					Ω(err).ToNot(HaveOccurred())
 					Ω(string(userData)).To(Equal(expectUserData))
 				}).Return(deploymentResp, nil)
+			expectDeployedVMTagged()
 
 			err := client.GetOrCreateVMInstance(
 				dummies.CSMachine1,
@@ -893,6 +1043,8 @@ var _ = Describe("Instance", func() {
 		}
 
 		BeforeEach(func() {
+			rtlp.EXPECT().NewListTagsParams().Return(&cloudstack.ListTagsParams{})
+			rtlp.EXPECT().ListTags(gomock.Any()).Return(&cloudstack.ListTagsResponse{}, nil)
 			configuration.EXPECT().NewListCapabilitiesParams().Return(listCapabilitiesParams)
 			configuration.EXPECT().ListCapabilities(listCapabilitiesParams).Return(listCapabilitiesResponse, nil)
 		})
@@ -902,9 +1054,11 @@ var _ = Describe("Instance", func() {
 			listVolumesParams.SetType("DATADISK")
 			vms.EXPECT().NewDestroyVirtualMachineParams(*dummies.CSMachine1.Spec.InstanceID).
 				Return(expungeDestroyParams)
-			vms.EXPECT().DestroyVirtualMachine(expungeDestroyParams).Return(nil, fmt.Errorf("unable to find uuid for id"))
+			vms.EXPECT().DestroyVirtualMachine(expungeDestroyParams).Return(nil, fmt.Errorf(
+				"CloudStack API error 431 (CSExceptionErrorCode: 4350): Unable to execute API command destroyvirtualmachine due to invalid value"))
 			vs.EXPECT().NewListVolumesParams().Return(listVolumesParams)
 			vs.EXPECT().ListVolumes(listVolumesParams).Return(listVolumesResponse, nil)
+			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).Return(nil, 0, notFoundError)
 			Ω(client.DestroyVMInstance(dummies.CSMachine1)).
 				Should(Succeed())
 		})
@@ -917,6 +1071,8 @@ var _ = Describe("Instance", func() {
 			vms.EXPECT().DestroyVirtualMachine(expungeDestroyParams).Return(nil, fmt.Errorf("new error"))
 			vs.EXPECT().NewListVolumesParams().Return(listVolumesParams)
 			vs.EXPECT().ListVolumes(listVolumesParams).Return(listVolumesResponse, nil)
+			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).
+				Return(&cloudstack.VirtualMachinesMetric{State: "Running"}, 1, nil)
 			Ω(client.DestroyVMInstance(dummies.CSMachine1)).Should(MatchError("new error"))
 		})
 
@@ -929,7 +1085,7 @@ var _ = Describe("Instance", func() {
 			vs.EXPECT().NewListVolumesParams().Return(listVolumesParams)
 			vs.EXPECT().ListVolumes(listVolumesParams).Return(listVolumesResponse, nil)
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID, gomock.Any()).Return(nil, -1, notFoundError)
-			vms.EXPECT().GetVirtualMachinesMetricByName(dummies.CSMachine1.Name, gomock.Any()).Return(nil, -1, notFoundError)
+			expectVMsOwnedByMachine(&cloudstack.ListVirtualMachinesMetricsResponse{}, nil)
 			Ω(client.DestroyVMInstance(dummies.CSMachine1)).
 				Should(Succeed())
 		})
@@ -981,4 +1137,559 @@ var _ = Describe("Instance", func() {
 			Ω(client.DestroyVMInstance(dummies.CSMachine1)).Should(MatchError("VM deletion in progress"))
 		})
 	})
+
+	Context("when destroying a VM instance owned by another machine", func() {
+		It("refuses to destroy it", func() {
+			rtlp.EXPECT().NewListTagsParams().Return(&cloudstack.ListTagsParams{})
+			rtlp.EXPECT().ListTags(gomock.Any()).Return(&cloudstack.ListTagsResponse{
+				Count: 1,
+				Tags:  []*cloudstack.Tag{{Key: cloud.MachineUIDTagName, Value: "another-uid"}},
+			}, nil)
+			Ω(client.DestroyVMInstance(dummies.CSMachine1)).Should(MatchError(ContainSubstring("owned by another machine")))
+		})
+	})
+})
+
+var _ = Describe("Instance against the fake CloudStack API", func() {
+	var (
+		server *csserver.Server
+		client cloud.Client
+	)
+
+	BeforeEach(func() {
+		server, client = NewFakeServerClient()
+	})
+
+	// deploy gets or creates the VM instance of the first dummy machine in the first dummy failure domain.
+	deploy := func() error {
+		return client.GetOrCreateVMInstance(
+			dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")
+	}
+
+	It("creates, resolves and destroys a VM instance", func() {
+		Ω(deploy()).Should(Succeed())
+		Ω(dummies.CSMachine1.Spec.InstanceID).ShouldNot(BeNil())
+		Ω(dummies.CSMachine1.Status.InstanceState).Should(Equal("Running"))
+		Ω(dummies.CSMachine1.Status.Addresses).Should(ConsistOf(
+			HaveField("Type", corev1.NodeInternalIP),
+			corev1.NodeAddress{Type: corev1.NodeHostName, Address: dummies.CSMachine1.Name},
+		))
+
+		vm := server.VirtualMachine(*dummies.CSMachine1.Spec.InstanceID)
+		Ω(vm).ShouldNot(BeNil())
+		Ω(vm.Details).Should(Equal(dummies.CSMachine1.Spec.Details))
+
+		// A second call finds the existing instance instead of deploying another.
+		Ω(deploy()).Should(Succeed())
+		Ω(server.CallCount("deployVirtualMachine")).Should(Equal(1))
+
+		Ω(client.DestroyVMInstance(dummies.CSMachine1)).Should(Succeed())
+		Ω(server.VirtualMachines()).Should(BeEmpty())
+	})
+
+	Context("async deployment", func() {
+		It("keeps the deploy job ID until the job completes and adopts the VM it deployed", func() {
+			server.HoldJobs("deployVirtualMachine")
+			err := deploy()
+			Ω(errors.Is(err, cloud.ErrJobPending)).Should(BeTrue())
+			Ω(dummies.CSMachine1.Status.DeployJobID).ShouldNot(BeNil())
+			Ω(dummies.CSMachine1.Spec.InstanceID).Should(Equal(pointer.String(server.VirtualMachines()[0].Id)))
+			Ω(server.Tags(*dummies.CSMachine1.Spec.InstanceID)).Should(
+				HaveKeyWithValue(cloud.MachineUIDTagName, string(dummies.CSMachine1.UID)))
+
+			// A renamed machine still adopts the VM of its job rather than deploying another one.
+			dummies.CSMachine1.Name = "renamed-machine"
+			Ω(errors.Is(deploy(), cloud.ErrJobPending)).Should(BeTrue())
+			server.CompleteJobs()
+			Ω(deploy()).Should(Succeed())
+
+			Ω(server.CallCount("deployVirtualMachine")).Should(Equal(1))
+			Ω(server.VirtualMachines()).Should(HaveLen(1))
+			Ω(dummies.CSMachine1.Spec.InstanceID).Should(Equal(pointer.String(server.VirtualMachines()[0].Id)))
+			Ω(dummies.CSMachine1.Status.DeployJobID).Should(BeNil())
+			Ω(dummies.CSMachine1.Status.InstanceState).Should(Equal("Running"))
+		})
+
+		It("looks the VM up by its ownership tag when the deploy job is gone", func() {
+			Ω(deploy()).Should(Succeed())
+			deployedID := *dummies.CSMachine1.Spec.InstanceID
+
+			dummies.CSMachine1.Spec.InstanceID = nil
+			dummies.CSMachine1.Status.DeployJobID = pointer.String("purged-job")
+			Ω(deploy()).Should(Succeed())
+			Ω(*dummies.CSMachine1.Spec.InstanceID).Should(Equal(deployedID))
+			Ω(dummies.CSMachine1.Status.DeployJobID).Should(BeNil())
+			Ω(server.CallCount("deployVirtualMachine")).Should(Equal(1))
+		})
+
+		It("looks the VM up by the instance ID of its deployment when the deploy job is gone", func() {
+			server.HoldJobs("deployVirtualMachine")
+			Ω(errors.Is(deploy(), cloud.ErrJobPending)).Should(BeTrue())
+			deployedID := *dummies.CSMachine1.Spec.InstanceID
+
+			server.CompleteJobs()
+			dummies.CSMachine1.Status.DeployJobID = pointer.String("purged-job")
+			Ω(deploy()).Should(Succeed())
+			Ω(*dummies.CSMachine1.Spec.InstanceID).Should(Equal(deployedID))
+			Ω(dummies.CSMachine1.Status.DeployJobID).Should(BeNil())
+			Ω(server.CallCount("deployVirtualMachine")).Should(Equal(1))
+		})
+	})
+
+	Context("VM ownership", func() {
+		var otherMachine *infrav1.CloudStackMachine
+		var otherFD *infrav1.CloudStackFailureDomain
+
+		BeforeEach(func() {
+			// A machine of the same name in another namespace and network.
+			otherMachine = dummies.CSMachine1.DeepCopy()
+			otherMachine.Namespace = "other"
+			otherMachine.UID = "other-machine-uid"
+			otherFD = dummies.CSFailureDomain1.DeepCopy()
+			otherFD.Spec.Zone.Network.ID = server.AddNetwork(&cloudstack.Network{
+				Name: "other-network", Zoneid: dummies.CSFailureDomain1.Spec.Zone.ID, Type: "Shared",
+			}).Id
+			Ω(client.GetOrCreateVMInstance(
+				otherMachine, dummies.CAPIMachine, dummies.CSCluster, otherFD, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+		})
+
+		It("tags deployed VMs as owned by the machine and its cluster", func() {
+			Ω(server.Tags(*otherMachine.Spec.InstanceID)).Should(Equal(map[string]string{
+				cloud.CreatedByCAPCTagName: "1",
+				dummies.CSClusterTagKey:    "1",
+				cloud.MachineUIDTagName:    "other-machine-uid",
+			}))
+		})
+
+		It("doesn't adopt a VM of the same name owned by another machine", func() {
+			Ω(deploy()).Should(Succeed())
+			Ω(*dummies.CSMachine1.Spec.InstanceID).ShouldNot(Equal(*otherMachine.Spec.InstanceID))
+			Ω(server.VirtualMachines()).Should(HaveLen(2))
+			Ω(server.Tags(*dummies.CSMachine1.Spec.InstanceID)).Should(
+				HaveKeyWithValue(cloud.MachineUIDTagName, string(dummies.CSMachine1.UID)))
+		})
+
+		It("doesn't tag a pre-existing VM it adopts by ID as created by CAPC", func() {
+			dummies.CSMachine1.Spec.InstanceID = pointer.String(server.AddVirtualMachine(&cloudstack.VirtualMachinesMetric{
+				Name: "pre-existing", Zoneid: dummies.CSFailureDomain1.Spec.Zone.ID,
+			}).Id)
+			Ω(deploy()).Should(Succeed())
+			Ω(server.Tags(*dummies.CSMachine1.Spec.InstanceID)).Should(Equal(map[string]string{
+				dummies.CSClusterTagKey: "1",
+				cloud.MachineUIDTagName: string(dummies.CSMachine1.UID),
+			}))
+		})
+
+		It("destroys its VM after being moved to another management cluster", func() {
+			otherMachine.UID = "moved-machine-uid"
+			Ω(otherMachine.Annotations).Should(HaveKeyWithValue(infrav1.InstanceOwnerUIDAnnotation, "other-machine-uid"))
+			Ω(client.DestroyVMInstance(otherMachine)).Should(Succeed())
+			Ω(server.VirtualMachines()).Should(BeEmpty())
+		})
+
+		It("refuses to destroy a VM owned by another machine", func() {
+			dummies.CSMachine1.Spec.InstanceID = otherMachine.Spec.InstanceID
+			Ω(client.DestroyVMInstance(dummies.CSMachine1)).Should(MatchError(ContainSubstring("owned by another machine")))
+			Ω(server.VirtualMachines()).Should(HaveLen(1))
+
+			Ω(client.DestroyVMInstance(otherMachine)).Should(Succeed())
+			Ω(server.VirtualMachines()).Should(BeEmpty())
+		})
+	})
+
+	Context("additional networks", func() {
+		var storage, management *cloudstack.Network
+
+		BeforeEach(func() {
+			zoneID := dummies.CSFailureDomain1.Spec.Zone.ID
+			storage = server.AddNetwork(&cloudstack.Network{Name: "storage", Zoneid: zoneID, Type: "Shared", Cidr: "10.2.0.0/24"})
+			management = server.AddNetwork(&cloudstack.Network{Name: "management", Zoneid: zoneID, Type: "Shared", Cidr: "10.3.0.0/24"})
+		})
+
+		It("deploys VMs with a NIC on each additional network and reports their addresses", func() {
+			dummies.CSMachine1.Spec.AdditionalNetworks = []infrav1.CloudStackMachineNetwork{
+				{Name: storage.Name, IP: "10.2.0.50"}, {ID: management.Id},
+			}
+			Ω(deploy()).Should(Succeed())
+
+			nics := server.VirtualMachine(*dummies.CSMachine1.Spec.InstanceID).Nic
+			Ω(nics).Should(HaveLen(3))
+			Ω(nics[0].Networkid).Should(Equal(dummies.CSFailureDomain1.Spec.Zone.Network.ID))
+			Ω(nics[0].Isdefault).Should(BeTrue())
+			Ω(nics[1].Networkid).Should(Equal(storage.Id))
+			Ω(nics[2].Networkid).Should(Equal(management.Id))
+
+			Ω(dummies.CSMachine1.Status.Addresses).Should(Equal([]corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: nics[0].Ipaddress},
+				{Type: corev1.NodeInternalIP, Address: "10.2.0.50"},
+				{Type: corev1.NodeInternalIP, Address: nics[2].Ipaddress},
+				{Type: corev1.NodeHostName, Address: dummies.CSMachine1.Name},
+			}))
+		})
+
+		It("classifies additional networks outside of the failure domain's zone as an invalid spec", func() {
+			otherZone := server.AddZone(&cloudstack.Zone{Name: "other-zone"})
+			elsewhere := server.AddNetwork(&cloudstack.Network{Name: "elsewhere", Zoneid: otherZone.Id, Type: "Shared"})
+
+			for _, network := range []infrav1.CloudStackMachineNetwork{{Name: elsewhere.Name}, {ID: elsewhere.Id}} {
+				dummies.CSMachine1.Spec.AdditionalNetworks = []infrav1.CloudStackMachineNetwork{network}
+				err := deploy()
+				Ω(errors.Is(err, cloud.ErrInvalidSpec)).Should(BeTrue(), "network %v", network)
+			}
+			Ω(server.VirtualMachines()).Should(BeEmpty())
+		})
+	})
+
+	Context("static IP addresses", func() {
+		It("deploys VMs with the static IP of the machine", func() {
+			dummies.CSMachine1.Spec.IPAddress = "10.0.0.50"
+			Ω(deploy()).Should(Succeed())
+
+			Ω(server.VirtualMachine(*dummies.CSMachine1.Spec.InstanceID).Ipaddress).Should(Equal("10.0.0.50"))
+			Ω(dummies.CSMachine1.Status.Addresses).Should(Equal([]corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.50"},
+				{Type: corev1.NodeHostName, Address: dummies.CSMachine1.Name},
+			}))
+		})
+
+		It("deploys VMs with additional networks with the address claimed by the machine", func() {
+			storage := server.AddNetwork(&cloudstack.Network{
+				Name: "storage", Zoneid: dummies.CSFailureDomain1.Spec.Zone.ID, Type: "Shared", Cidr: "10.2.0.0/24",
+			})
+			dummies.CSMachine1.Spec.AdditionalNetworks = []infrav1.CloudStackMachineNetwork{{Name: storage.Name}}
+			dummies.CSMachine1.Status.IPAddress = "10.0.0.60"
+			Ω(deploy()).Should(Succeed())
+
+			nics := server.VirtualMachine(*dummies.CSMachine1.Spec.InstanceID).Nic
+			Ω(nics).Should(HaveLen(2))
+			Ω(nics[0].Networkid).Should(Equal(dummies.CSFailureDomain1.Spec.Zone.Network.ID))
+			Ω(nics[0].Ipaddress).Should(Equal("10.0.0.60"))
+		})
+
+		It("fails to deploy VMs with a static IP that's already in use", func() {
+			dummies.CSMachine1.Spec.IPAddress = "10.0.0.50"
+			other := dummies.CSMachine1.DeepCopy()
+			other.Name, other.UID = "other-machine", "other-machine-uid"
+			Ω(client.GetOrCreateVMInstance(
+				other, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata",
+			)).Should(Succeed())
+
+			Ω(deploy()).Should(MatchError(ContainSubstring("already in use")))
+			Ω(server.VirtualMachines()).Should(HaveLen(1))
+		})
+	})
+
+	Context("address reporting", func() {
+		It("reports the IPv4 and IPv6 addresses, public IPs and hostnames of VMs", func() {
+			dualStack := server.AddNetwork(&cloudstack.Network{
+				Name: "dual-stack", Zoneid: dummies.CSFailureDomain1.Spec.Zone.ID, Type: "Shared",
+				Cidr: "10.4.0.0/24", Ip6cidr: "fd00:4::/64", Networkdomain: "cluster.internal",
+			})
+			dummies.CSFailureDomain1.Spec.Zone.Network.ID = dualStack.Id
+			Ω(deploy()).Should(Succeed())
+
+			publicIP := server.AddPublicIPAddress(&cloudstack.PublicIpAddress{
+				Ipaddress: "192.0.2.10", Zoneid: dummies.CSFailureDomain1.Spec.Zone.ID, State: "Allocated",
+			})
+			cs := cloudstack.NewAsyncClient(server.URL(), csserver.AdminAPIKey, csserver.AdminSecretKey, false)
+			_, err := cs.NAT.EnableStaticNat(cs.NAT.NewEnableStaticNatParams(publicIP.Id, *dummies.CSMachine1.Spec.InstanceID))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(client.ResolveVMInstanceDetails(dummies.CSMachine1)).Should(Succeed())
+			Ω(dummies.CSMachine1.Status.Addresses).Should(Equal([]corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.4.0.2"},
+				{Type: corev1.NodeInternalIP, Address: "fd00:4::2"},
+				{Type: corev1.NodeExternalIP, Address: "192.0.2.10"},
+				{Type: corev1.NodeHostName, Address: dummies.CSMachine1.Name},
+				{Type: corev1.NodeInternalDNS, Address: dummies.CSMachine1.Name + ".cluster.internal"},
+			}))
+		})
+
+		It("looks the network domain of a network up once", func() {
+			dummies.CSFailureDomain1.Spec.Zone.Network.ID = server.AddNetwork(&cloudstack.Network{
+				Name: "once", Zoneid: dummies.CSFailureDomain1.Spec.Zone.ID, Type: "Shared", Networkdomain: "once.internal",
+			}).Id
+			Ω(deploy()).Should(Succeed())
+			calls := server.CallCount("listNetworks")
+
+			Ω(client.ResolveVMInstanceDetails(dummies.CSMachine1)).Should(Succeed())
+			Ω(client.ResolveVMInstanceDetails(dummies.CSMachine1)).Should(Succeed())
+			Ω(server.CallCount("listNetworks")).Should(Equal(calls))
+			Ω(dummies.CSMachine1.Status.Addresses).Should(ContainElement(
+				corev1.NodeAddress{Type: corev1.NodeInternalDNS, Address: dummies.CSMachine1.Name + ".once.internal"}))
+		})
+
+		It("returns errors looking the network domain up", func() {
+			dummies.CSFailureDomain1.Spec.Zone.Network.ID = server.AddNetwork(&cloudstack.Network{
+				Name: "failing", Zoneid: dummies.CSFailureDomain1.Spec.Zone.ID, Type: "Shared",
+			}).Id
+			server.InjectFault("listNetworks", csserver.Fault{
+				APIError: csserver.APIError{ErrorCode: 530, CSErrorCode: 4250, ErrorText: "boom"},
+			})
+			Ω(deploy()).Should(MatchError(ContainSubstring("getting the network domain")))
+		})
+	})
+
+	Context("data disks", func() {
+		var fast *cloudstack.DiskOffering
+
+		BeforeEach(func() {
+			fast = server.AddDiskOffering(&cloudstack.DiskOffering{Name: "fast", Iscustomized: true, Iscustomizediops: true})
+			dummies.CSMachine1.Spec.DataDisks = []infrav1.CloudStackMachineDataDisk{
+				{Offering: infrav1.CloudStackResourceIdentifier{Name: fast.Name}, CustomSize: 50, MinIOPS: 500, MaxIOPS: 1000},
+				{Offering: infrav1.CloudStackResourceIdentifier{ID: fast.Id}, CustomSize: 100},
+			}
+		})
+
+		dataDisks := func() map[string]*cloudstack.Volume {
+			volumes := map[string]*cloudstack.Volume{}
+			for _, v := range server.Volumes() {
+				if v.Type == "DATADISK" {
+					volumes[v.Name] = v
+				}
+			}
+			return volumes
+		}
+
+		It("attaches the data disks beyond the first to VMs before starting them", func() {
+			Ω(deploy()).Should(Succeed())
+			vmID := *dummies.CSMachine1.Spec.InstanceID
+			Ω(dummies.CSMachine1.Status.InstanceState).Should(Equal("Running"))
+			Ω(server.CallCount("startVirtualMachine")).Should(Equal(1))
+
+			disks := dataDisks()
+			Ω(disks).Should(HaveLen(3))
+			Ω(disks).Should(HaveKeyWithValue("DATA-"+vmID, HaveField("Virtualmachineid", vmID)))
+			first, second := disks[dummies.CSMachine1.Name+"-data-1"], disks[dummies.CSMachine1.Name+"-data-2"]
+			Ω(first).Should(And(HaveField("Virtualmachineid", vmID), HaveField("Size", int64(50<<30)),
+				HaveField("Miniops", int64(500)), HaveField("Maxiops", int64(1000))))
+			Ω(second).Should(And(HaveField("Virtualmachineid", vmID), HaveField("Size", int64(100<<30))))
+			Ω(server.Tags(first.Id)).Should(HaveKeyWithValue(cloud.MachineUIDTagName, string(dummies.CSMachine1.UID)))
+
+			// A running VM is left as is.
+			Ω(deploy()).Should(Succeed())
+			Ω(server.CallCount("createVolume")).Should(Equal(2))
+			Ω(server.CallCount("startVirtualMachine")).Should(Equal(1))
+
+			Ω(client.DestroyVMInstance(dummies.CSMachine1)).Should(Succeed())
+			Ω(server.VirtualMachines()).Should(BeEmpty())
+			Ω(dataDisks()).Should(BeEmpty())
+		})
+
+		It("doesn't start VMs stopped after they were first started", func() {
+			Ω(deploy()).Should(Succeed())
+			Ω(dummies.CSMachine1.Status.DataDisksPending).Should(BeFalse())
+			server.SetVirtualMachineState(*dummies.CSMachine1.Spec.InstanceID, "Stopped")
+
+			Ω(deploy()).Should(Succeed())
+			Ω(server.CallCount("startVirtualMachine")).Should(Equal(1))
+			Ω(dummies.CSMachine1.Status.InstanceState).Should(Equal("Stopped"))
+		})
+
+		It("reattaches data disks detached from VMs stopped after they were first started and starts them", func() {
+			Ω(deploy()).Should(Succeed())
+			vmID := *dummies.CSMachine1.Spec.InstanceID
+			second := dataDisks()[dummies.CSMachine1.Name+"-data-2"]
+			cs := cloudstack.NewAsyncClient(server.URL(), csserver.AdminAPIKey, csserver.AdminSecretKey, false)
+			p := cs.Volume.NewDetachVolumeParams()
+			p.SetId(second.Id)
+			_, err := cs.Volume.DetachVolume(p)
+			Ω(err).ShouldNot(HaveOccurred())
+			server.SetVirtualMachineState(vmID, "Stopped")
+
+			Ω(deploy()).Should(Succeed())
+			Ω(dataDisks()).Should(HaveKeyWithValue(dummies.CSMachine1.Name+"-data-2", HaveField("Virtualmachineid", vmID)))
+			Ω(server.CallCount("startVirtualMachine")).Should(Equal(2))
+			Ω(dummies.CSMachine1.Status.InstanceState).Should(Equal("Running"))
+		})
+
+		It("deploys the IOPS of the first data disk along with the VM", func() {
+			dummies.CSMachine1.Spec.DiskOffering = infrav1.CloudStackResourceDiskOffering{}
+			Ω(deploy()).Should(Succeed())
+			vmID := *dummies.CSMachine1.Spec.InstanceID
+
+			disks := dataDisks()
+			Ω(disks).Should(HaveLen(2))
+			Ω(disks["DATA-"+vmID]).Should(And(HaveField("Size", int64(50<<30)),
+				HaveField("Miniops", int64(500)), HaveField("Maxiops", int64(1000))))
+			Ω(disks[dummies.CSMachine1.Name+"-data-1"]).Should(HaveField("Virtualmachineid", vmID))
+		})
+
+		It("reuses the data disks of an interrupted attempt", func() {
+			cs := cloudstack.NewAsyncClient(server.URL(), csserver.AdminAPIKey, csserver.AdminSecretKey, false)
+			p := cs.Volume.NewCreateVolumeParams()
+			p.SetName(dummies.CSMachine1.Name + "-data-1")
+			p.SetZoneid(dummies.CSFailureDomain1.Spec.Zone.ID)
+			p.SetDiskofferingid(fast.Id)
+			p.SetSize(50)
+			created, err := cs.Volume.CreateVolume(p)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(deploy()).Should(Succeed())
+			Ω(dataDisks()).Should(HaveLen(3))
+			Ω(dataDisks()[dummies.CSMachine1.Name+"-data-1"].Id).Should(Equal(created.Id))
+			Ω(server.Tags(created.Id)).Should(HaveKeyWithValue(cloud.MachineUIDTagName, string(dummies.CSMachine1.UID)))
+		})
+
+		It("deletes the machine's detached data disks along with its VM", func() {
+			Ω(deploy()).Should(Succeed())
+			cs := cloudstack.NewAsyncClient(server.URL(), csserver.AdminAPIKey, csserver.AdminSecretKey, false)
+			p := cs.Volume.NewCreateVolumeParams()
+			p.SetName(dummies.CSMachine1.Name + "-data-3")
+			p.SetZoneid(dummies.CSFailureDomain1.Spec.Zone.ID)
+			p.SetDiskofferingid(fast.Id)
+			detached, err := cs.Volume.CreateVolume(p)
+			Ω(err).ShouldNot(HaveOccurred())
+			_, err = cs.Resourcetags.CreateTags(cs.Resourcetags.NewCreateTagsParams([]string{detached.Id}, "Volume",
+				map[string]string{cloud.MachineUIDTagName: string(dummies.CSMachine1.UID)}))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(client.DestroyVMInstance(dummies.CSMachine1)).Should(Succeed())
+			Ω(dataDisks()).Should(BeEmpty())
+		})
+
+		It("classifies IOPS for disk offerings without customized IOPS as an invalid spec", func() {
+			dummies.CSMachine1.Spec.DataDisks = append(dummies.CSMachine1.Spec.DataDisks, infrav1.CloudStackMachineDataDisk{
+				Offering: infrav1.CloudStackResourceIdentifier{Name: dummies.CSMachine1.Spec.DiskOffering.Name}, MaxIOPS: 1000,
+			})
+			err := deploy()
+			Ω(errors.Is(err, cloud.ErrInvalidSpec)).Should(BeTrue())
+			Ω(server.VirtualMachines()).Should(BeEmpty())
+		})
+	})
+
+	Context("root disks", func() {
+		var fast, ssd, ssdFixed *cloudstack.DiskOffering
+
+		BeforeEach(func() {
+			template := server.AddTemplate(&cloudstack.Template{Name: "large-root", Size: 20 << 30})
+			dummies.CSMachine1.Spec.Template = infrav1.CloudStackResourceIdentifier{Name: template.Name}
+			fast = server.AddDiskOffering(&cloudstack.DiskOffering{Name: "fast-root", Iscustomized: true})
+			ssdFixed = server.AddDiskOffering(&cloudstack.DiskOffering{Name: "ssd-fixed", Tags: "ssd", Disksize: 40})
+			ssd = server.AddDiskOffering(&cloudstack.DiskOffering{Name: "ssd-custom", Tags: "fast,ssd", Iscustomized: true})
+		})
+
+		rootVolume := func() *cloudstack.Volume {
+			for _, v := range server.Volumes() {
+				if v.Type == "ROOT" && v.Virtualmachineid == *dummies.CSMachine1.Spec.InstanceID {
+					return v
+				}
+			}
+			return nil
+		}
+
+		It("deploys VMs with a root disk of the given size and disk offering", func() {
+			dummies.CSMachine1.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{
+				SizeInGB: 50, Offering: infrav1.CloudStackResourceIdentifier{Name: fast.Name},
+			}
+			Ω(deploy()).Should(Succeed())
+			Ω(rootVolume()).Should(And(HaveField("Size", int64(50<<30)), HaveField("Diskofferingid", fast.Id)))
+		})
+
+		It("places root disks with a disk offering with their storage tag", func() {
+			dummies.CSMachine1.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{SizeInGB: 30, StorageTag: "ssd"}
+			Ω(deploy()).Should(Succeed())
+			Ω(rootVolume()).Should(And(HaveField("Size", int64(30<<30)), HaveField("Diskofferingid", ssd.Id)))
+		})
+
+		It("classifies root disks smaller than the template as an invalid spec", func() {
+			dummies.CSMachine1.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{SizeInGB: 10}
+			err := deploy()
+			Ω(errors.Is(err, cloud.ErrInvalidSpec)).Should(BeTrue())
+			Ω(err).Should(MatchError(ContainSubstring("smaller than the 20 GB of template")))
+			Ω(server.CallCount("deployVirtualMachine")).Should(BeZero())
+		})
+
+		It("places root disks of the template's size with a fixed size disk offering with their storage tag", func() {
+			dummies.CSMachine1.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{StorageTag: "ssd"}
+			Ω(deploy()).Should(Succeed())
+			Ω(rootVolume()).Should(HaveField("Diskofferingid", ssdFixed.Id))
+		})
+
+		It("classifies fixed size disk offerings with the storage tag smaller than the template as an invalid spec", func() {
+			small := server.AddDiskOffering(&cloudstack.DiskOffering{Name: "hdd-fixed", Tags: "hdd", Disksize: 10})
+			dummies.CSMachine1.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{StorageTag: "hdd"}
+			err := deploy()
+			Ω(errors.Is(err, cloud.ErrInvalidSpec)).Should(BeTrue())
+			Ω(err).Should(MatchError(And(ContainSubstring(small.Id), ContainSubstring("smaller than the 20 GB of template"))))
+			Ω(server.CallCount("deployVirtualMachine")).Should(BeZero())
+		})
+
+		It("deploys VMs with a root disk of the size of the given fixed size disk offering", func() {
+			dummies.CSMachine1.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{
+				Offering: infrav1.CloudStackResourceIdentifier{ID: ssdFixed.Id},
+			}
+			Ω(deploy()).Should(Succeed())
+			Ω(rootVolume()).Should(HaveField("Diskofferingid", ssdFixed.Id))
+		})
+
+		It("classifies given fixed size disk offerings smaller than the template as an invalid spec", func() {
+			small := server.AddDiskOffering(&cloudstack.DiskOffering{Name: "small-fixed", Disksize: 10})
+			dummies.CSMachine1.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{
+				Offering: infrav1.CloudStackResourceIdentifier{Name: small.Name},
+			}
+			err := deploy()
+			Ω(errors.Is(err, cloud.ErrInvalidSpec)).Should(BeTrue())
+			Ω(err).Should(MatchError(And(ContainSubstring(small.Id), ContainSubstring("smaller than the 20 GB of template"))))
+			Ω(server.CallCount("deployVirtualMachine")).Should(BeZero())
+		})
+
+		It("classifies storage tags without a matching disk offering as an invalid spec", func() {
+			dummies.CSMachine1.Spec.RootDisk = infrav1.CloudStackMachineRootDisk{SizeInGB: 30, StorageTag: "nvme"}
+			err := deploy()
+			Ω(errors.Is(err, cloud.ErrInvalidSpec)).Should(BeTrue())
+			Ω(server.VirtualMachines()).Should(BeEmpty())
+		})
+	})
+
+	Context("failure domain overrides", func() {
+		var fd2 *infrav1.CloudStackFailureDomain
+		var template *cloudstack.Template
+		var offering *cloudstack.ServiceOffering
+		var diskOffering *cloudstack.DiskOffering
+
+		BeforeEach(func() {
+			zone := server.AddZone(&cloudstack.Zone{Name: "zone2"})
+			network := server.AddNetwork(&cloudstack.Network{Name: "zone2-network", Zoneid: zone.Id, Type: "Shared"})
+			template = server.AddTemplate(&cloudstack.Template{Name: "ubuntu-zone2", Zoneid: zone.Id})
+			offering = server.AddServiceOffering(&cloudstack.ServiceOffering{Name: "large-zone2", Cpunumber: 4, Memory: 8192})
+			diskOffering = server.AddDiskOffering(&cloudstack.DiskOffering{Name: "data-zone2", Zoneid: zone.Id, Disksize: 20})
+			fd2 = dummies.CSFailureDomain2.DeepCopy()
+			fd2.Spec.Zone.ID, fd2.Spec.Zone.Network.ID = zone.Id, network.Id
+
+			dummies.CSMachine1.Spec.FailureDomainOverrides = map[string]infrav1.CloudStackMachineOverrides{
+				fd2.Spec.Name: {
+					Offering: infrav1.CloudStackResourceIdentifier{ID: offering.Id},
+					Template: infrav1.CloudStackResourceIdentifier{Name: template.Name},
+					DiskOffering: &infrav1.CloudStackResourceDiskOffering{
+						CloudStackResourceIdentifier: infrav1.CloudStackResourceIdentifier{Name: diskOffering.Name},
+					},
+					Details: map[string]string{"rootDiskController": "scsi"},
+				},
+			}
+		})
+
+		It("deploys VMs with the overrides of their failure domain", func() {
+			dummies.CSMachine1.Spec.FailureDomainName = fd2.Spec.Name
+			Ω(client.GetOrCreateVMInstance(
+				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, fd2, dummies.CSAffinityGroup, "userdata",
+			)).Should(Succeed())
+
+			vm := server.VirtualMachine(*dummies.CSMachine1.Spec.InstanceID)
+			Ω(vm.Templateid).Should(Equal(template.Id))
+			Ω(vm.Serviceofferingid).Should(Equal(offering.Id))
+			Ω(vm.Diskofferingid).Should(Equal(diskOffering.Id))
+			Ω(vm.Details).Should(Equal(map[string]string{"memoryOvercommitRatio": "1.2", "rootDiskController": "scsi"}))
+		})
+
+		It("deploys VMs in other failure domains with the settings of the spec", func() {
+			dummies.CSMachine1.Spec.FailureDomainName = dummies.CSFailureDomain1.Spec.Name
+			Ω(deploy()).Should(Succeed())
+
+			vm := server.VirtualMachine(*dummies.CSMachine1.Spec.InstanceID)
+			Ω(vm.Templatename).Should(Equal(dummies.CSMachine1.Spec.Template.Name))
+			Ω(vm.Serviceofferingname).Should(Equal(dummies.CSMachine1.Spec.Offering.Name))
+			Ω(vm.Details).Should(Equal(dummies.CSMachine1.Spec.Details))
+		})
+	})
 })
@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/metrics"
+)
+
+// Outcomes of API calls, as reported by the API call metrics.
+const (
+	// APICallSucceeded is the outcome of calls answered with a successful response.
+	APICallSucceeded = "success"
+	// APICallFailed is the outcome of calls answered with an API error response.
+	APICallFailed = "error"
+	// APICallUnanswered is the outcome of calls that got no response, e.g. because the connection failed or timed out.
+	APICallUnanswered = "transport_error"
+)
+
+// instrumentedTransport is an http.RoundTripper that records the count and duration of the API calls sent to a
+// management server, by command, host and outcome.
+type instrumentedTransport struct {
+	next          http.RoundTripper
+	customMetrics metrics.ACSCustomMetrics
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	command := apiCommand(req)
+	if command == "" {
+		command = "unknown"
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	outcome := APICallSucceeded
+	if err != nil {
+		outcome = APICallUnanswered
+	} else if resp.StatusCode != http.StatusOK {
+		outcome = APICallFailed
+	}
+	t.customMetrics.ObserveAcsAPICall(command, req.URL.Host, outcome, time.Since(start))
+	return resp, err
+}
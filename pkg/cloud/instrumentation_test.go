@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
+	crtlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var _ = Describe("Metrics against the fake CloudStack API", func() {
+	var (
+		server *csserver.Server
+		client cloud.Client
+	)
+
+	BeforeEach(func() {
+		server, client = NewFakeServerClient()
+	})
+
+	Context("metrics", func() {
+		// apiCalls returns the count of calls recorded by the acs_api_calls_total metric for the labels.
+		apiCalls := func(command, outcome string) float64 {
+			families, err := crtlmetrics.Registry.Gather()
+			Ω(err).ShouldNot(HaveOccurred())
+			host := strings.TrimPrefix(strings.TrimSuffix(server.URL(), "/client/api"), "http://")
+			for _, family := range families {
+				if family.GetName() != "acs_api_calls_total" {
+					continue
+				}
+				for _, metric := range family.GetMetric() {
+					labels := map[string]string{}
+					for _, label := range metric.GetLabel() {
+						labels[label.GetName()] = label.GetValue()
+					}
+					if labels["command"] == command && labels["host"] == host && labels["outcome"] == outcome {
+						return metric.GetCounter().GetValue()
+					}
+				}
+			}
+			return 0
+		}
+
+		It("counts API calls by command, host and outcome", func() {
+			Ω(client.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})).Should(Succeed())
+			Ω(apiCalls("listZones", cloud.APICallSucceeded)).Should(Equal(2.0))
+
+			server.InjectFault("listZones", csserver.Fault{APIError: csserver.APIError{ErrorCode: 431, CSErrorCode: 4350, ErrorText: "bad"}})
+			Ω(client.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})).ShouldNot(Succeed())
+			Ω(apiCalls("listZones", cloud.APICallFailed)).Should(BeNumerically(">=", 1))
+		})
+	})
+})
@@ -45,7 +45,7 @@ func (c *client) getOfferingID() (string, error) {
 	offeringID, count, retErr := c.cs.NetworkOffering.GetNetworkOfferingID(NetOffering)
 	if retErr != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
-		return "", retErr
+		return "", classifyError(retErr)
 	} else if count != 1 {
 		return "", errors.New("found more than one network offering")
 	}
@@ -73,12 +73,19 @@ func (c *client) AssociatePublicIPAddress(
 	}
 
 	// Public IP found, but not yet associated with network -- associate it.
+	// Before retrying a failed association, check whether it went through anyway.
 	p := c.cs.Address.NewAssociateIpAddressParams()
 	p.SetIpaddress(isoNet.Spec.ControlPlaneEndpoint.Host)
 	p.SetNetworkid(isoNet.Spec.ID)
 	setIfNotEmpty(c.user.Project.ID, p.SetProjectid)
-	if _, err := c.cs.Address.AssociateIpAddress(p); err != nil {
+	if err := c.retry("associateIpAddress", func() error {
+		_, err := c.cs.Address.AssociateIpAddress(p)
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return err
+	}, func() bool {
+		ip, count, err := c.cs.Address.GetPublicIpAddressByID(publicAddress.Id, cloudstack.WithProject(c.user.Project.ID))
+		return err == nil && count == 1 && ip.Associatednetworkid == isoNet.Spec.ID
+	}); err != nil {
 		return errors.Wrapf(err,
 			"associating public IP address with ID %s to network with ID %s",
 			publicAddress.Id, isoNet.Spec.ID)
@@ -107,7 +114,7 @@ func (c *client) CreateIsolatedNetwork(fd *infrav1.CloudStackFailureDomain, isoN
 	resp, err := c.cs.Network.CreateNetwork(p)
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return errors.Wrapf(err, "creating network with name %s", isoNet.Spec.Name)
+		return errors.Wrapf(classifyError(err), "creating network with name %s", isoNet.Spec.Name)
 	}
 	isoNet.Spec.ID = resp.Id
 	return c.AddCreatedByCAPCTag(ResourceTypeNetwork, isoNet.Spec.ID)
@@ -125,11 +132,8 @@ func (c *client) OpenFirewallRules(isoNet *infrav1.CloudStackIsolatedNetwork) (r
 		}
 
 		_, err := c.cs.Firewall.CreateEgressFirewallRule(p)
-		if err != nil &&
-			// Ignore errors regarding already existing fw rules for TCP/UDP
-			!strings.Contains(strings.ToLower(err.Error()), "there is already") &&
-			// Ignore errors regarding already existing fw rule for ICMP
-			!strings.Contains(strings.ToLower(err.Error()), "new rule conflicts with existing rule") {
+		// Ignore errors regarding already existing fw rules.
+		if err = classifyError(err); err != nil && !errors.Is(err, ErrAlreadyExists) {
 			retErr = multierror.Append(retErr, errors.Wrapf(
 				err, "failed creating egress firewall rule for network ID %s protocol %s", isoNet.Spec.ID, proto))
 		}
@@ -154,7 +158,7 @@ func (c *client) GetPublicIP(
 	publicAddresses, err := c.cs.Address.ListPublicIpAddresses(p)
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return nil, err
+		return nil, classifyError(err)
 	} else if ip != "" && publicAddresses.Count == 1 { // Endpoint specified and IP found.
 		// Ignore already allocated here since the IP was specified.
 		return publicAddresses.PublicIpAddresses[0], nil
@@ -164,9 +168,9 @@ func (c *client) GetPublicIP(
 				return v, nil
 			}
 		}
-		return nil, errors.New("all Public IP Address(es) found were already allocated")
+		return nil, classifiedErrorf(ErrLimitExceeded, "all Public IP Address(es) found were already allocated")
 	}
-	return nil, errors.New("no public addresses found in available networks")
+	return nil, classifiedErrorf(ErrNotFound, "no public addresses found in available networks")
 }
 
 // GetIsolatedNetwork gets an isolated network in the relevant Zone.
@@ -174,7 +178,7 @@ func (c *client) GetIsolatedNetwork(isoNet *infrav1.CloudStackIsolatedNetwork) (
 	netDetails, count, err := c.cs.Network.GetNetworkByName(isoNet.Spec.Name, cloudstack.WithProject(c.user.Project.ID))
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		retErr = multierror.Append(retErr, errors.Wrapf(err, "could not get Network ID from %s", isoNet.Spec.Name))
+		retErr = multierror.Append(retErr, errors.Wrapf(classifyError(err), "could not get Network ID from %s", isoNet.Spec.Name))
 	} else if count != 1 {
 		retErr = multierror.Append(retErr, errors.Errorf(
 			"expected 1 Network with name %s, but got %d", isoNet.Name, count))
@@ -186,7 +190,7 @@ func (c *client) GetIsolatedNetwork(isoNet *infrav1.CloudStackIsolatedNetwork) (
 	netDetails, count, err = c.cs.Network.GetNetworkByID(isoNet.Spec.ID, cloudstack.WithProject(c.user.Project.ID))
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return multierror.Append(retErr, errors.Wrapf(err, "could not get Network by ID %s", isoNet.Spec.ID))
+		return multierror.Append(retErr, errors.Wrapf(classifyError(err), "could not get Network by ID %s", isoNet.Spec.ID))
 	} else if count != 1 {
 		return multierror.Append(retErr, errors.Errorf("expected 1 Network with UUID %s, but got %d", isoNet.Spec.ID, count))
 	}
@@ -206,7 +210,7 @@ func (c *client) ResolveLoadBalancerRuleDetails(
 	loadBalancerRules, err := c.cs.LoadBalancer.ListLoadBalancerRules(p)
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return errors.Wrap(err, "listing load balancer rules")
+		return errors.Wrap(classifyError(err), "listing load balancer rules")
 	}
 	for _, rule := range loadBalancerRules.LoadBalancerRules {
 		if rule.Publicport == strconv.Itoa(int(isoNet.Spec.ControlPlaneEndpoint.Port)) {
@@ -215,7 +219,7 @@ func (c *client) ResolveLoadBalancerRuleDetails(
 			return nil
 		}
 	}
-	return errors.New("no load balancer rule found")
+	return classifiedErrorf(ErrNotFound, "no load balancer rule found")
 }
 
 // GetOrCreateLoadBalancerRule Create a load balancer rule that can be assigned to instances.
@@ -236,8 +240,7 @@ func (c *client) GetOrCreateLoadBalancerRule(
 	}
 
 	// Check if rule exists.
-	if err := c.ResolveLoadBalancerRuleDetails(fd, isoNet, csCluster); err == nil ||
-		!strings.Contains(strings.ToLower(err.Error()), "no load balancer rule found") {
+	if err := c.ResolveLoadBalancerRuleDetails(fd, isoNet, csCluster); err == nil || !errors.Is(err, ErrNotFound) {
 		return errors.Wrap(err, "resolving load balancer rule details")
 	}
 
@@ -251,7 +254,7 @@ func (c *client) GetOrCreateLoadBalancerRule(
 	resp, err := c.cs.LoadBalancer.CreateLoadBalancerRule(p)
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return err
+		return classifyError(err)
 	}
 	isoNet.Status.LBRuleID = resp.Id
 	return nil
@@ -301,7 +304,7 @@ func (c *client) AssignVMToLoadBalancerRule(isoNet *infrav1.CloudStackIsolatedNe
 		c.cs.LoadBalancer.NewListLoadBalancerRuleInstancesParams(isoNet.Status.LBRuleID))
 	if retErr != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
-		return retErr
+		return classifyError(retErr)
 	}
 	for _, instance := range lbRuleInstances.LoadBalancerRuleInstances {
 		if instance.Id == instanceID { // Already assigned to load balancer..
@@ -314,14 +317,14 @@ func (c *client) AssignVMToLoadBalancerRule(isoNet *infrav1.CloudStackIsolatedNe
 	p.SetVirtualmachineids([]string{instanceID})
 	_, retErr = c.cs.LoadBalancer.AssignToLoadBalancerRule(p)
 	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
-	return retErr
+	return classifyError(retErr)
 }
 
 // DeleteNetwork deletes an isolated network.
 func (c *client) DeleteNetwork(net infrav1.Network) error {
 	_, err := c.cs.Network.DeleteNetwork(c.cs.Network.NewDeleteNetworkParams(net.ID))
 	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-	return errors.Wrapf(err, "deleting network with id %s", net.ID)
+	return errors.Wrapf(classifyError(err), "deleting network with id %s", net.ID)
 }
 
 // DisposeIsoNetResources cleans up isolated network resources.
@@ -376,7 +379,7 @@ func (c *client) DisassociatePublicIPAddressIfNotInUse(isoNet *infrav1.CloudStac
 		return err
 	} else if publicIP, _, err := c.cs.Address.GetPublicIpAddressByID(isoNet.Status.PublicIPID, cloudstack.WithProject(c.user.Project.ID)); err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return err
+		return classifyError(err)
 	} else if publicIP == nil || publicIP.Issourcenat { // Can't disassociate an address if it's the source NAT address.
 		return nil
 	} else if tagsAllowDisposal {
@@ -396,5 +399,5 @@ func (c *client) DisassociatePublicIPAddress(isoNet *infrav1.CloudStackIsolatedN
 	p := c.cs.Address.NewDisassociateIpAddressParams(isoNet.Status.PublicIPID)
 	_, retErr = c.cs.Address.DisassociateIpAddress(p)
 	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
-	return retErr
+	return classifyError(retErr)
 }
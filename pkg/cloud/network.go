@@ -55,7 +55,7 @@ func (c *client) ResolveNetwork(net *infrav1.Network) (retErr error) {
 	netDetails, count, err := c.cs.Network.GetNetworkByName(netName, cloudstack.WithProject(c.user.Project.ID))
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		retErr = multierror.Append(retErr, errors.Wrapf(err, "could not get Network ID from %s", netName))
+		retErr = multierror.Append(retErr, errors.Wrapf(classifyError(err), "could not get Network ID from %s", netName))
 	} else if count != 1 {
 		retErr = multierror.Append(retErr, errors.Errorf(
 			"expected 1 Network with name %s, but got %d", netName, count))
@@ -68,7 +68,7 @@ func (c *client) ResolveNetwork(net *infrav1.Network) (retErr error) {
 	// Now get network details.
 	netDetails, count, err = c.cs.Network.GetNetworkByID(net.ID, cloudstack.WithProject(c.user.Project.ID))
 	if err != nil {
-		return multierror.Append(retErr, errors.Wrapf(err, "could not get Network by ID %s", net.ID))
+		return multierror.Append(retErr, errors.Wrapf(classifyError(err), "could not get Network by ID %s", net.ID))
 	} else if count != 1 {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
 		return multierror.Append(retErr, errors.Errorf("expected 1 Network with UUID %s, but got %d", net.ID, count))
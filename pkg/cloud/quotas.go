@@ -0,0 +1,196 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+)
+
+// The scopes resource limits are set at.
+const (
+	QuotaScopeAccount = "account"
+	QuotaScopeDomain  = "domain"
+	QuotaScopeProject = "project"
+)
+
+// The resources whose limits are collected. Memory is in MiB.
+const (
+	QuotaResourceCPU    = "cpu"
+	QuotaResourceMemory = "memory"
+	QuotaResourceVM     = "vm"
+	QuotaResourceVolume = "volume"
+)
+
+// UnlimitedQuota is the limit and availability of resources without a limit.
+const UnlimitedQuota int64 = -1
+
+type QuotaIface interface {
+	GetResourceQuotas() ([]ResourceQuota, error)
+	GetResourceDemand(*infrav1.CloudStackMachine, string, int64) (ResourceDemand, error)
+}
+
+// ResourceQuota is the limit and current usage of a resource in the account, domain or project of a client's user.
+type ResourceQuota struct {
+	Scope     string
+	Name      string
+	Resource  string
+	Limit     int64
+	Used      int64
+	Available int64
+}
+
+// Unlimited reports whether the resource has no limit.
+func (q ResourceQuota) Unlimited() bool {
+	return q.Limit == UnlimitedQuota
+}
+
+// Headroom returns the share of the limit that's still available, from 0 to 1. Unlimited resources have a headroom of 1.
+func (q ResourceQuota) Headroom() float64 {
+	if q.Unlimited() {
+		return 1
+	}
+	if q.Limit == 0 || q.Available <= 0 {
+		return 0
+	}
+	return float64(q.Available) / float64(q.Limit)
+}
+
+// ResourceDemand is the amount of each resource needed to deploy machines, keyed by resource.
+type ResourceDemand map[string]int64
+
+// Add adds the resources of another demand to the demand.
+func (d ResourceDemand) Add(other ResourceDemand) {
+	for resource, amount := range other {
+		d[resource] += amount
+	}
+}
+
+// InsufficientQuotas returns the limited quotas that don't have enough of their resource available for the demand.
+func InsufficientQuotas(quotas []ResourceQuota, demand ResourceDemand) []ResourceQuota {
+	var insufficient []ResourceQuota
+	for _, q := range quotas {
+		if !q.Unlimited() && demand[q.Resource] > q.Available {
+			insufficient = append(insufficient, q)
+		}
+	}
+	return insufficient
+}
+
+// newResourceQuota parses the limit and availability of a resource as reported by CloudStack, where resources without
+// a limit are reported as Unlimited or -1.
+func newResourceQuota(scope, name, resource, limit string, used int64, available string) ResourceQuota {
+	q := ResourceQuota{Scope: scope, Name: name, Resource: resource, Limit: UnlimitedQuota, Used: used, Available: UnlimitedQuota}
+	parsedLimit, err := strconv.ParseInt(limit, 10, 64)
+	if err != nil || parsedLimit < 0 || strings.EqualFold(limit, "Unlimited") {
+		return q
+	}
+	q.Limit = parsedLimit
+	if q.Available, err = strconv.ParseInt(available, 10, 64); err != nil {
+		q.Available = parsedLimit - used
+	}
+	return q
+}
+
+// GetResourceQuotas reads the current CPU, memory, VM and volume limits and usage of the account, domain and project of
+// the client's user. Domain limits are skipped when the user isn't allowed to list its domain.
+func (c *client) GetResourceQuotas() ([]ResourceQuota, error) {
+	var quotas []ResourceQuota
+
+	ap := c.cs.Account.NewListAccountsParams()
+	ap.SetId(c.user.Account.ID)
+	setIfNotEmpty(c.user.Account.Domain.ID, ap.SetDomainid)
+	accounts, err := c.cs.Account.ListAccounts(ap)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return nil, errors.Wrapf(classifyError(err), "listing limits of account %s", c.user.Account.Name)
+	} else if accounts.Count != 1 {
+		return nil, classifiedErrorf(ErrNotFound, "could not find account %s", c.user.Account.Name)
+	}
+	a := accounts.Accounts[0]
+	quotas = append(quotas,
+		newResourceQuota(QuotaScopeAccount, a.Name, QuotaResourceCPU, a.Cpulimit, a.Cputotal, a.Cpuavailable),
+		newResourceQuota(QuotaScopeAccount, a.Name, QuotaResourceMemory, a.Memorylimit, a.Memorytotal, a.Memoryavailable),
+		newResourceQuota(QuotaScopeAccount, a.Name, QuotaResourceVM, a.Vmlimit, a.Vmtotal, a.Vmavailable),
+		newResourceQuota(QuotaScopeAccount, a.Name, QuotaResourceVolume, a.Volumelimit, a.Volumetotal, a.Volumeavailable))
+
+	dp := c.cs.Domain.NewListDomainsParams()
+	dp.SetId(a.Domainid)
+	if domains, err := c.cs.Domain.ListDomains(dp); err != nil {
+		if classified := classifyError(err); !errors.Is(classified, ErrPermissionDenied) {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return nil, errors.Wrapf(classified, "listing limits of domain %s", a.Domain)
+		}
+	} else if domains.Count == 1 {
+		d := domains.Domains[0]
+		quotas = append(quotas,
+			newResourceQuota(QuotaScopeDomain, d.Path, QuotaResourceCPU, d.Cpulimit, d.Cputotal, d.Cpuavailable),
+			newResourceQuota(QuotaScopeDomain, d.Path, QuotaResourceMemory, d.Memorylimit, d.Memorytotal, d.Memoryavailable),
+			newResourceQuota(QuotaScopeDomain, d.Path, QuotaResourceVM, d.Vmlimit, d.Vmtotal, d.Vmavailable),
+			newResourceQuota(QuotaScopeDomain, d.Path, QuotaResourceVolume, d.Volumelimit, d.Volumetotal, d.Volumeavailable))
+	}
+
+	if c.user.Project.ID == "" {
+		return quotas, nil
+	}
+	pp := c.cs.Project.NewListProjectsParams()
+	pp.SetListall(true)
+	pp.SetId(c.user.Project.ID)
+	projects, err := c.cs.Project.ListProjects(pp)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return nil, errors.Wrapf(classifyError(err), "listing limits of project %s", c.user.Project.Name)
+	} else if projects.Count != 1 {
+		return nil, classifiedErrorf(ErrNotFound, "could not find project %s", c.user.Project.Name)
+	}
+	p := projects.Projects[0]
+	return append(quotas,
+		newResourceQuota(QuotaScopeProject, p.Name, QuotaResourceCPU, p.Cpulimit, p.Cputotal, p.Cpuavailable),
+		newResourceQuota(QuotaScopeProject, p.Name, QuotaResourceMemory, p.Memorylimit, p.Memorytotal, p.Memoryavailable),
+		newResourceQuota(QuotaScopeProject, p.Name, QuotaResourceVM, p.Vmlimit, p.Vmtotal, p.Vmavailable),
+		newResourceQuota(QuotaScopeProject, p.Name, QuotaResourceVolume, p.Volumelimit, p.Volumetotal, p.Volumeavailable)), nil
+}
+
+// GetResourceDemand returns the CPU, memory, VMs and volumes needed to deploy count machines with the service offering
+// of csMachine in a zone. The CPU and memory of customized offerings are taken from the cpuNumber and memory details of
+// the machine. Each machine needs a volume for its root disk and one for each of its data disks.
+func (c *client) GetResourceDemand(csMachine *infrav1.CloudStackMachine, zoneID string, count int64) (ResourceDemand, error) {
+	offering, err := c.ResolveServiceOffering(csMachine, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	cpu, memory := int64(offering.Cpunumber), int64(offering.Memory)
+	if offering.Iscustomized {
+		details := csMachine.Spec.InFailureDomain(csMachine.Spec.FailureDomainName).Details
+		if detail, err := strconv.ParseInt(details["cpuNumber"], 10, 64); err == nil {
+			cpu = detail
+		}
+		if detail, err := strconv.ParseInt(details["memory"], 10, 64); err == nil {
+			memory = detail
+		}
+	}
+	return ResourceDemand{
+		QuotaResourceCPU:    cpu * count,
+		QuotaResourceMemory: memory * count,
+		QuotaResourceVM:     count,
+		QuotaResourceVolume: int64(1+len(dataDisks(csMachine))) * count,
+	}, nil
+}
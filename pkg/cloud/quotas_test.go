@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
+)
+
+var _ = Describe("Quotas against the fake CloudStack API", func() {
+	var (
+		server *csserver.Server
+		client cloud.Client
+	)
+
+	BeforeEach(func() {
+		server, client = NewFakeServerClient()
+	})
+
+	Context("resource quotas", func() {
+		It("reads the limits and usage of the account and domain", func() {
+			domain := server.AddDomain(&cloudstack.Domain{Name: "sub", Cpulimit: "100", Cputotal: 40, Cpuavailable: "60"})
+			account := server.AddAccount(&cloudstack.Account{
+				Name: "tenant", Domainid: domain.Id,
+				Cpulimit: "10", Cputotal: 9, Cpuavailable: "1",
+				Vmlimit: "5", Vmtotal: 2, Vmavailable: "3",
+				Volumelimit: "20", Volumetotal: 4, Volumeavailable: "16",
+			})
+			server.AddUser(&cloudstack.User{Username: "tenant", Accountid: account.Id, Apikey: "tenant-key", Secretkey: "tenant-secret"})
+			tenantClient, err := client.NewClientInDomainAndAccount("ROOT/sub", "tenant", "")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			quotas, err := tenantClient.GetResourceQuotas()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(quotas).Should(ContainElements(
+				cloud.ResourceQuota{Scope: cloud.QuotaScopeAccount, Name: "tenant", Resource: cloud.QuotaResourceCPU, Limit: 10, Used: 9, Available: 1},
+				cloud.ResourceQuota{Scope: cloud.QuotaScopeAccount, Name: "tenant", Resource: cloud.QuotaResourceVM, Limit: 5, Used: 2, Available: 3},
+				cloud.ResourceQuota{Scope: cloud.QuotaScopeAccount, Name: "tenant", Resource: cloud.QuotaResourceVolume, Limit: 20, Used: 4, Available: 16},
+				cloud.ResourceQuota{Scope: cloud.QuotaScopeDomain, Name: "ROOT/sub", Resource: cloud.QuotaResourceCPU, Limit: 100, Used: 40, Available: 60},
+			))
+			for _, q := range quotas {
+				if q.Resource == cloud.QuotaResourceMemory {
+					Ω(q.Unlimited()).Should(BeTrue())
+					Ω(q.Headroom()).Should(Equal(1.0))
+				}
+			}
+		})
+
+		It("adds up the resources of machines and finds the quotas they exceed", func() {
+			demand, err := client.GetResourceDemand(dummies.CSMachine1, dummies.CSFailureDomain1.Spec.Zone.ID, 3)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(demand).Should(Equal(cloud.ResourceDemand{
+				cloud.QuotaResourceCPU: 6, cloud.QuotaResourceMemory: 6144, cloud.QuotaResourceVM: 3, cloud.QuotaResourceVolume: 6,
+			}))
+			demand.Add(cloud.ResourceDemand{cloud.QuotaResourceCPU: 2})
+
+			cpu := cloud.ResourceQuota{Scope: cloud.QuotaScopeAccount, Resource: cloud.QuotaResourceCPU, Limit: 10, Used: 3, Available: 7}
+			memory := cloud.ResourceQuota{Scope: cloud.QuotaScopeAccount, Resource: cloud.QuotaResourceMemory, Limit: 8192, Used: 0, Available: 8192}
+			vms := cloud.ResourceQuota{Scope: cloud.QuotaScopeDomain, Resource: cloud.QuotaResourceVM, Limit: cloud.UnlimitedQuota, Available: cloud.UnlimitedQuota}
+			Ω(cloud.InsufficientQuotas([]cloud.ResourceQuota{cpu, memory, vms}, demand)).Should(ConsistOf(cpu))
+		})
+
+		It("takes the size of customized offerings from the machine's details", func() {
+			server.AddServiceOffering(&cloudstack.ServiceOffering{Name: "custom", Iscustomized: true})
+			dummies.CSMachine1.Spec.Offering = infrav1.CloudStackResourceIdentifier{Name: "custom"}
+			dummies.CSMachine1.Spec.Details = map[string]string{"cpuNumber": "4", "memory": "4096"}
+
+			demand, err := client.GetResourceDemand(dummies.CSMachine1, dummies.CSFailureDomain1.Spec.Zone.ID, 2)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(demand).Should(Equal(cloud.ResourceDemand{
+				cloud.QuotaResourceCPU: 8, cloud.QuotaResourceMemory: 8192, cloud.QuotaResourceVM: 2, cloud.QuotaResourceVolume: 4,
+			}))
+		})
+
+		It("counts a volume for the root disk and each data disk of a machine", func() {
+			dummies.CSMachine1.Spec.DataDisks = []infrav1.CloudStackMachineDataDisk{
+				{Offering: infrav1.CloudStackResourceIdentifier{Name: "DiskOffering"}},
+				{Offering: infrav1.CloudStackResourceIdentifier{Name: "DiskOffering"}},
+			}
+
+			demand, err := client.GetResourceDemand(dummies.CSMachine1, dummies.CSFailureDomain1.Spec.Zone.ID, 2)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(demand).Should(HaveKeyWithValue(cloud.QuotaResourceVolume, int64(8)))
+
+			volumes := cloud.ResourceQuota{Scope: cloud.QuotaScopeAccount, Resource: cloud.QuotaResourceVolume, Limit: 10, Used: 3, Available: 7}
+			Ω(cloud.InsufficientQuotas([]cloud.ResourceQuota{volumes}, demand)).Should(ConsistOf(volumes))
+		})
+	})
+})
@@ -0,0 +1,182 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/metrics"
+)
+
+const DefaultAPIRateLimit = 20.0
+const DefaultAPIRateLimitBurst = 25
+const DefaultAPIMaxInFlight = 10
+
+// RateLimits bounds the rate and concurrency of the calls made to a CloudStack endpoint.
+type RateLimits struct {
+	// QPS is the sustained number of API calls per second.
+	QPS float64
+	// Burst is the number of API calls that can be made at once before QPS applies.
+	Burst int
+	// MaxInFlight is the number of API calls that can be waiting for a response at the same time.
+	MaxInFlight int
+}
+
+// GetRateLimits returns the rate limits from the passed endpoint config, using defaults for missing or invalid values.
+func GetRateLimits(conf Config) RateLimits {
+	limits := RateLimits{
+		QPS:         DefaultAPIRateLimit,
+		Burst:       DefaultAPIRateLimitBurst,
+		MaxInFlight: DefaultAPIMaxInFlight,
+	}
+	if qps, err := strconv.ParseFloat(conf.APIRateLimit, 64); err == nil && qps > 0 {
+		limits.QPS = qps
+	}
+	if burst, err := strconv.Atoi(conf.APIRateLimitBurst); err == nil && burst > 0 {
+		limits.Burst = burst
+	}
+	if maxInFlight, err := strconv.Atoi(conf.APIMaxInFlight); err == nil && maxInFlight > 0 {
+		limits.MaxInFlight = maxInFlight
+	}
+	return limits
+}
+
+// endpointLimiter rate limits and caps the concurrency of the calls made to one CloudStack endpoint.
+type endpointLimiter struct {
+	limiter *rate.Limiter
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	released    chan struct{} // Closed and replaced whenever a call slot may have become available.
+}
+
+// Limiters are shared by all clients of an endpoint, whatever their credentials, as CloudStack throttles API calls
+// per management server.
+var endpointLimiters = map[string]*endpointLimiter{}
+var endpointLimitersMutex sync.Mutex
+
+// getEndpointLimiter returns the limiter of the passed endpoint URL, creating it if needed. The limits of an existing
+// limiter are updated to the passed ones, so the most recently configured endpoint secret wins.
+func getEndpointLimiter(apiURL string, limits RateLimits) *endpointLimiter {
+	endpointLimitersMutex.Lock()
+	defer endpointLimitersMutex.Unlock()
+
+	l, ok := endpointLimiters[apiURL]
+	if !ok {
+		l = &endpointLimiter{
+			limiter:     rate.NewLimiter(rate.Limit(limits.QPS), limits.Burst),
+			maxInFlight: limits.MaxInFlight,
+			released:    make(chan struct{}),
+		}
+		endpointLimiters[apiURL] = l
+		return l
+	}
+	l.limiter.SetLimit(rate.Limit(limits.QPS))
+	l.limiter.SetBurst(limits.Burst)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxInFlight != limits.MaxInFlight {
+		l.maxInFlight = limits.MaxInFlight
+		l.notify()
+	}
+	return l
+}
+
+// wait blocks until the call rate allows another call and a call slot is free, and then takes the slot. The slot must
+// be given back with release.
+func (l *endpointLimiter) wait(ctx context.Context) error {
+	if err := l.limiter.Wait(ctx); err != nil {
+		return classifiedErrorf(ErrThrottled, "waiting for the API rate limit: %s", err)
+	}
+	for {
+		l.mu.Lock()
+		if l.inFlight < l.maxInFlight {
+			l.inFlight++
+			l.mu.Unlock()
+			return nil
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return classifiedErrorf(ErrThrottled, "waiting for a free API call slot: %s", ctx.Err())
+		case <-released:
+		}
+	}
+}
+
+// release gives back a call slot taken by wait.
+func (l *endpointLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	l.notify()
+}
+
+// notify wakes up the calls waiting for a slot. The caller must hold l.mu.
+func (l *endpointLimiter) notify() {
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// rateLimitTransport is an http.RoundTripper that holds API calls back according to the limits of their endpoint, and
+// counts the calls CloudStack rejected because of its own API throttling.
+type rateLimitTransport struct {
+	next          http.RoundTripper
+	limiter       *endpointLimiter
+	customMetrics metrics.ACSCustomMetrics
+}
+
+// RoundTrip holds the call's slot until its response body is closed, since the call isn't done before its response
+// has been read.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.limiter.release()
+		return nil, err
+	}
+	if resp.StatusCode == apiErrorLimitExceeded {
+		t.customMetrics.IncrementAcsAPIThrottledCounter(apiCommand(req))
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: t.limiter.release}
+	return resp, nil
+}
+
+// releasingBody is a response body that gives back the call slot of its response once it's closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
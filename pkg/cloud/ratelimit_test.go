@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
+	crtlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var _ = Describe("Rate limits against the fake CloudStack API", func() {
+	var (
+		server *csserver.Server
+		client cloud.Client
+	)
+
+	BeforeEach(func() {
+		server, client = NewFakeServerClient()
+	})
+
+	Context("rate limits", func() {
+		It("doesn't retry throttled calls", func() {
+			server.InjectFault("listZones", csserver.Fault{
+				APIError: csserver.APIError{ErrorCode: 429, CSErrorCode: 4545, ErrorText: "There are too many API calls. Wait and retry."},
+			})
+			err := client.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})
+			Ω(errors.Is(err, cloud.ErrThrottled)).Should(BeTrue())
+			// One call each to get the zone ID and to get the zone by ID.
+			Ω(server.CallCount("listZones")).Should(Equal(2))
+		})
+
+		It("doesn't count throttled calls as reconciliation errors", func() {
+			reconciliationErrors := func() float64 {
+				families, err := crtlmetrics.Registry.Gather()
+				Ω(err).ShouldNot(HaveOccurred())
+				total := 0.0
+				for _, family := range families {
+					if family.GetName() == "acs_reconciliation_errors" {
+						for _, metric := range family.GetMetric() {
+							total += metric.GetCounter().GetValue()
+						}
+					}
+				}
+				return total
+			}
+
+			before := reconciliationErrors()
+			server.InjectFault("listNetworks", csserver.Fault{
+				APIError: csserver.APIError{ErrorCode: 429, CSErrorCode: 4545, ErrorText: "There are too many API calls. Wait and retry."},
+			})
+			err := client.ResolveNetwork(&infrav1.Network{Name: dummies.Zone1.Network.Name})
+			Ω(errors.Is(err, cloud.ErrThrottled)).Should(BeTrue())
+			Ω(reconciliationErrors()).Should(Equal(before))
+		})
+
+		It("holds calls back to the endpoint's rate limit", func() {
+			limited, err := cloud.NewClientFromConf(cloud.Config{
+				APIUrl:            server.URL(),
+				APIKey:            csserver.AdminAPIKey,
+				SecretKey:         csserver.AdminSecretKey,
+				APIRateLimit:      "10",
+				APIRateLimitBurst: "1",
+			}, nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			start := time.Now()
+			for i := 0; i < 3; i++ {
+				Ω(limited.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})).Should(Succeed())
+			}
+			// Six calls at 10 per second, with a burst of one, take at least half a second.
+			Ω(time.Since(start)).Should(BeNumerically(">=", 500*time.Millisecond))
+		})
+
+		It("holds a call's slot until its response has been read", func() {
+			// Passes calls on to the fake server, with response bodies that trickle in after their headers.
+			slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				resp, err := http.Get(server.URL() + "?" + r.URL.RawQuery)
+				if err != nil {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				defer resp.Body.Close()
+				w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+				w.WriteHeader(resp.StatusCode)
+				w.(http.Flusher).Flush()
+				time.Sleep(100 * time.Millisecond)
+				_, _ = io.Copy(w, resp.Body)
+			}))
+			DeferCleanup(slow.Close)
+			limited, err := cloud.NewClientFromConf(cloud.Config{
+				APIUrl:         slow.URL,
+				APIKey:         csserver.AdminAPIKey,
+				SecretKey:      csserver.AdminSecretKey,
+				APIMaxInFlight: "1",
+			}, nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			start := time.Now()
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					Ω(limited.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})).Should(Succeed())
+				}()
+			}
+			wg.Wait()
+			// Four calls taking a tenth of a second each to read, one at a time.
+			Ω(time.Since(start)).Should(BeNumerically(">=", 400*time.Millisecond))
+		})
+	})
+})
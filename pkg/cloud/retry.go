@@ -0,0 +1,236 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/metrics"
+)
+
+const RetryMaxAttemptsKey = "retry-max-attempts"
+const RetryInitialBackoffKey = "retry-initial-backoff"
+const RetryMaxBackoffKey = "retry-max-backoff"
+const DefaultRetryMaxAttempts = 3
+const DefaultRetryInitialBackoff = time.Duration(500 * time.Millisecond)
+const DefaultRetryMaxBackoff = time.Duration(10 * time.Second)
+
+// MaxRetryWait caps the time a call that changes state waits for its retries in total, as it holds up a reconcile
+// worker meanwhile. When the next retry doesn't fit in it anymore, the transient error is returned for the
+// reconciliation to be requeued instead.
+const MaxRetryWait = time.Duration(5 * time.Second)
+
+// RetryPolicy bounds the retries of CloudStack API calls that failed with a transient error.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a call is attempted, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the base wait before the first retry. It doubles for each further retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the base wait between two attempts, before jitter is added.
+	MaxBackoff time.Duration
+}
+
+// GetRetryPolicy returns the retry policy from the passed config map, using defaults for missing or invalid values.
+func GetRetryPolicy(clientConfig *corev1.ConfigMap) RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts:    DefaultRetryMaxAttempts,
+		InitialBackoff: DefaultRetryInitialBackoff,
+		MaxBackoff:     DefaultRetryMaxBackoff,
+	}
+	if clientConfig == nil {
+		return policy
+	}
+	if attempts, err := strconv.Atoi(clientConfig.Data[RetryMaxAttemptsKey]); err == nil && attempts > 0 {
+		policy.MaxAttempts = attempts
+	}
+	if backoff, err := time.ParseDuration(clientConfig.Data[RetryInitialBackoffKey]); err == nil && backoff > 0 {
+		policy.InitialBackoff = backoff
+	}
+	if backoff, err := time.ParseDuration(clientConfig.Data[RetryMaxBackoffKey]); err == nil && backoff > 0 {
+		policy.MaxBackoff = backoff
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = policy.InitialBackoff
+	}
+	return policy
+}
+
+// backoff returns a jittered exponential backoff for the policy's retries.
+func (p RetryPolicy) backoff() *wait.Backoff {
+	return &wait.Backoff{
+		Duration: p.InitialBackoff,
+		Factor:   2,
+		Jitter:   1,
+		Steps:    p.MaxAttempts,
+		Cap:      p.MaxBackoff,
+	}
+}
+
+// retry calls fn, retrying it as long as it fails with a transient error, the retry policy allows and the waits fit in
+// MaxRetryWait. Before each retry, exists (if not nil) is called to find out whether the failed call took effect anyway,
+// e.g. because the error was returned after CloudStack had accepted the request. If so, retry stops and reports success.
+func (c *client) retry(command string, fn func() error, exists func() bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), MaxRetryWait)
+	defer cancel()
+	backoff := c.retryPolicy.backoff()
+	for attempt := 1; ; attempt++ {
+		err := classifyError(fn())
+		if err == nil || !errors.Is(err, ErrTransient) || attempt >= c.retryPolicy.MaxAttempts {
+			return err
+		}
+		if !waitForRetry(ctx, backoff.Step()) {
+			return err
+		}
+		if exists != nil && exists() {
+			return nil
+		}
+		c.customMetrics.IncrementAcsAPIRetryCounter(command)
+	}
+}
+
+// waitForRetry waits for the backoff before a retry and returns true, or returns false right away if the backoff ends
+// after the deadline of ctx.
+func waitForRetry(ctx context.Context, backoff time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+		return false
+	}
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Prefixes of API commands that don't change anything and can be retried as is.
+var readOnlyCommandPrefixes = []string{"list", "get", "query"}
+
+// HTTP status codes of CloudStack responses that are worth retrying. CloudStack uses its error codes as status codes,
+// e.g. 530 for an internal error or 534 for an unavailable resource.
+var transientStatusCodes = map[int]bool{
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+	apiErrorInternal:               true,
+	apiErrorResourceUnavailable:    true,
+	apiErrorResourceAllocation:     true,
+}
+
+// retryTransport is an http.RoundTripper that retries read-only API commands when they fail with a transient error.
+// Commands that change state aren't retried here, as a failed response doesn't tell whether they took effect.
+type retryTransport struct {
+	next          http.RoundTripper
+	policy        RetryPolicy
+	customMetrics metrics.ACSCustomMetrics
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	command := apiCommand(req)
+	if !isReadOnlyCommand(command) {
+		return t.next.RoundTrip(req)
+	}
+
+	backoff := t.policy.backoff()
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.policy.MaxAttempts || !isTransientResponse(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff.Step()):
+		}
+		if req, err = rewindRequest(req); err != nil {
+			return nil, err
+		}
+		t.customMetrics.IncrementAcsAPIRetryCounter(command)
+	}
+}
+
+// apiCommand returns the API command of a request, which is in the query for GET requests and in the form body for
+// POST requests.
+func apiCommand(req *http.Request) string {
+	return apiParams(req).Get("command")
+}
+
+// apiParams returns the parameters of an API call, which are sent in the query of GET requests and the body of POST
+// requests.
+func apiParams(req *http.Request) url.Values {
+	if query := req.URL.Query(); query.Get("command") != "" || req.GetBody == nil {
+		return query
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return url.Values{}
+	}
+	defer body.Close()
+	form, err := io.ReadAll(body)
+	if err != nil {
+		return url.Values{}
+	}
+	values, _ := url.ParseQuery(string(form))
+	return values
+}
+
+func isReadOnlyCommand(command string) bool {
+	for _, prefix := range readOnlyCommandPrefixes {
+		if strings.HasPrefix(command, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTransientResponse(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !isTLSError(err)
+	}
+	return transientStatusCodes[resp.StatusCode]
+}
+
+// rewindRequest returns a copy of req with a fresh body, so that it can be sent again.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = body
+	return req, nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
+)
+
+var _ = Describe("Retries against the fake CloudStack API", func() {
+	var (
+		server *csserver.Server
+		client cloud.Client
+	)
+
+	BeforeEach(func() {
+		server, client = NewFakeServerClient()
+	})
+
+	Context("retries", func() {
+		transientFault := csserver.Fault{
+			APIError: csserver.APIError{ErrorCode: 530, CSErrorCode: 4250, ErrorText: "Connection reset by peer"},
+			Count:    1,
+		}
+
+		It("retries read-only calls failing with a transient error", func() {
+			server.InjectFault("listZones", transientFault)
+			Ω(client.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})).Should(Succeed())
+			// One failed and one retried call to get the zone ID, and one call to get the zone by ID.
+			Ω(server.CallCount("listZones")).Should(Equal(3))
+		})
+
+		It("gives up after the maximum number of attempts", func() {
+			fault := transientFault
+			fault.Count = 0
+			server.InjectFault("listZones", fault)
+			err := client.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})
+			Ω(errors.Is(err, cloud.ErrTransient)).Should(BeTrue())
+			// Both lookups by name and ID are attempted the default number of times.
+			Ω(server.CallCount("listZones")).Should(Equal(2 * cloud.DefaultRetryMaxAttempts))
+		})
+
+		It("doesn't retry calls failing with other errors", func() {
+			server.InjectFault("listZones", csserver.Fault{
+				APIError: csserver.APIError{ErrorCode: 431, CSErrorCode: 4350, ErrorText: "Unable to execute API command"},
+			})
+			Ω(client.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})).ShouldNot(Succeed())
+			Ω(server.CallCount("listZones")).Should(Equal(2))
+		})
+
+		It("checks for the VM before retrying a failed deployment", func() {
+			server.InjectFault("deployVirtualMachine", transientFault)
+			Ω(client.GetOrCreateVMInstance(
+				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata",
+			)).Should(Succeed())
+			Ω(server.CallCount("deployVirtualMachine")).Should(Equal(2))
+			Ω(server.VirtualMachines()).Should(HaveLen(1))
+			Ω(server.Calls()).Should(ContainElement("listVirtualMachines"))
+		})
+
+		It("returns the transient error rather than wait longer than the retry wait cap", func() {
+			slowClient, err := cloud.NewClientFromConf(cloud.Config{
+				APIUrl:    server.URL(),
+				APIKey:    csserver.AdminAPIKey,
+				SecretKey: csserver.AdminSecretKey,
+				Timeout:   "30s", // Not to get the cached client of the fixture.
+			}, &corev1.ConfigMap{Data: map[string]string{
+				cloud.RetryInitialBackoffKey: "1m",
+				cloud.RetryMaxBackoffKey:     "1m",
+			}}, "")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			server.InjectFault("createTags", transientFault)
+			start := time.Now()
+			err = slowClient.AddTags(cloud.ResourceTypeNetwork, dummies.CSFailureDomain1.Spec.Zone.Network.ID, map[string]string{"key": "value"})
+			Ω(errors.Is(err, cloud.ErrTransient)).Should(BeTrue())
+			Ω(time.Since(start)).Should(BeNumerically("<", cloud.MaxRetryWait))
+			Ω(server.CallCount("createTags")).Should(Equal(1))
+		})
+
+		It("retries adding tags", func() {
+			server.InjectFault("createTags", transientFault)
+			tags := map[string]string{cloud.CreatedByCAPCTagName: "1"}
+			Ω(client.AddTags(cloud.ResourceTypeNetwork, dummies.CSFailureDomain1.Spec.Zone.Network.ID, tags)).Should(Succeed())
+			Ω(server.CallCount("createTags")).Should(Equal(2))
+			Ω(server.Tags(dummies.CSFailureDomain1.Spec.Zone.Network.ID)).Should(Equal(tags))
+		})
+	})
+})
@@ -0,0 +1,276 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+	"github.com/pkg/errors"
+)
+
+// sessionExpiryMargin is how long before the reported timeout of a session it's renewed, so that calls don't race its
+// expiry.
+const sessionExpiryMargin = 30 * time.Second
+
+// usesSession reports whether the endpoint config authenticates with a username and password instead of API keys.
+func (conf Config) usesSession() bool {
+	return conf.APIKey == "" && conf.Username != ""
+}
+
+// apiSession is a login session of the CloudStack API.
+type apiSession struct {
+	key       string
+	cookies   []*http.Cookie
+	expiresAt time.Time
+	user      cloudstack.LoginResponse
+}
+
+// sessionAuth logs in to the CloudStack API with the username and password of an endpoint config, and keeps the
+// session for the calls of a client.
+type sessionAuth struct {
+	next     http.RoundTripper
+	loginURL string
+	username string
+	password string
+	domain   string
+
+	mu      sync.Mutex
+	session *apiSession
+}
+
+// newSessionAuth returns the session authentication for the username and password of the endpoint config, logging in
+// through next.
+func newSessionAuth(conf Config, loginURL string, next http.RoundTripper) (*sessionAuth, error) {
+	if conf.Password == "" {
+		return nil, errors.New("invalid credentials: password must be set along with username")
+	}
+	return &sessionAuth{next: next, loginURL: loginURL, username: conf.Username, password: conf.Password, domain: conf.Domain}, nil
+}
+
+// current returns the current session, logging in if there is none or it's about to expire.
+func (a *sessionAuth) current(ctx context.Context) (*apiSession, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.session != nil && (a.session.expiresAt.IsZero() || time.Now().Before(a.session.expiresAt)) {
+		return a.session, nil
+	}
+	session, err := a.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.session = session
+	return session, nil
+}
+
+// expire drops the passed session if it's still the current one, so that the next call logs in again.
+func (a *sessionAuth) expire(session *apiSession) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.session == session {
+		a.session = nil
+	}
+}
+
+// login logs in through the login API, returning the session key and cookies of the new session.
+func (a *sessionAuth) login(ctx context.Context) (*apiSession, error) {
+	form := url.Values{}
+	form.Set("command", "login")
+	form.Set("response", "json")
+	form.Set("username", a.username)
+	form.Set("password", a.password)
+	if a.domain != "" {
+		form.Set("domain", a.domain)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.loginURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := a.next.RoundTrip(req)
+	if err != nil {
+		return nil, errors.Wrapf(classifyError(err), "logging in as user %s", a.username)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(classifyError(err), "logging in as user %s", a.username)
+	}
+
+	// Responses look like {"loginresponse": {...}}, with the error code and text of failed logins.
+	var wrapped map[string]json.RawMessage
+	if err := json.Unmarshal(body, &wrapped); err != nil {
+		return nil, errors.Wrapf(err, "logging in as user %s: unexpected response", a.username)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var csErr cloudstack.CSError
+		if err := json.Unmarshal(wrapped["loginresponse"], &csErr); err != nil {
+			return nil, errors.Wrapf(err, "logging in as user %s: unexpected response", a.username)
+		}
+		err := fmt.Errorf("CloudStack API error %d (CSExceptionErrorCode: %d): %s", csErr.ErrorCode, csErr.CSErrorCode, csErr.ErrorText)
+		return nil, errors.Wrapf(classifyError(err), "logging in as user %s", a.username)
+	}
+	session := &apiSession{cookies: resp.Cookies()}
+	if err := json.Unmarshal(wrapped["loginresponse"], &session.user); err != nil || session.user.Sessionkey == "" {
+		return nil, errors.Errorf("logging in as user %s: no session key returned", a.username)
+	}
+	session.key = session.user.Sessionkey
+	// Sessions without a reported timeout are only renewed when the API rejects them.
+	if timeout := time.Duration(session.user.Timeout) * time.Second; timeout > 2*sessionExpiryMargin {
+		session.expiresAt = time.Now().Add(timeout - sessionExpiryMargin)
+	} else if timeout > 0 {
+		session.expiresAt = time.Now().Add(timeout)
+	}
+	return session, nil
+}
+
+// sessionTransport is an http.RoundTripper that authenticates API calls with the session of a sessionAuth instead of
+// a request signature. Calls rejected because the session expired are sent again once in a new session.
+type sessionTransport struct {
+	next http.RoundTripper
+	auth *sessionAuth
+}
+
+func (t *sessionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	session, err := t.auth.current(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	attempt, err := withSession(req, session)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(attempt)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	t.auth.expire(session)
+	if session, err = t.auth.current(req.Context()); err != nil {
+		return nil, err
+	}
+	if attempt, err = withSession(req, session); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(attempt)
+}
+
+// withSession returns a copy of the request authenticated with the session key and cookies of the session, instead of
+// the API key and signature set by the cloudstack-go client.
+func withSession(req *http.Request, session *apiSession) (*http.Request, error) {
+	attempt, err := withParams(req, func(values url.Values) {
+		values.Del("apiKey")
+		values.Del("signature")
+		values.Set("sessionkey", session.key)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The session cookies replace any the HTTP client kept from earlier responses.
+	attempt.Header.Del("Cookie")
+	for _, cookie := range session.cookies {
+		attempt.AddCookie(cookie)
+	}
+	return attempt, nil
+}
+
+// withParams returns a copy of the request whose API call parameters, in the query or the form body, are changed by
+// rewrite.
+func withParams(req *http.Request, rewrite func(url.Values)) (*http.Request, error) {
+	attempt := req.Clone(req.Context())
+	if req.Body == nil || req.GetBody == nil {
+		values := req.URL.Query()
+		rewrite(values)
+		attempt.URL.RawQuery = values.Encode()
+		return attempt, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	form, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+	values, err := url.ParseQuery(string(form))
+	if err != nil {
+		return nil, err
+	}
+	rewrite(values)
+	rewritten := []byte(values.Encode())
+	attempt.Body = io.NopCloser(bytes.NewReader(rewritten))
+	attempt.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(rewritten)), nil }
+	attempt.ContentLength = int64(len(rewritten))
+	return attempt, nil
+}
+
+// accountScopeTransport is an http.RoundTripper that makes the API calls of a session act in another account than the
+// logged in user's, by naming the account and its domain in each call that doesn't name an account or project itself.
+// Calls of commands that don't take an account ignore it.
+type accountScopeTransport struct {
+	next     http.RoundTripper
+	account  string
+	domainID string
+}
+
+func (t *accountScopeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	scoped, err := withParams(req, func(values url.Values) {
+		if values.Get("account") == "" && values.Get("projectid") == "" {
+			values.Set("account", t.account)
+			values.Set("domainid", t.domainID)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(scoped)
+}
+
+// newClientInAccountScope returns a copy of the session client whose calls act in the account of the passed user, as
+// sessions can't be moved to another account and the account's users may not have API keys.
+func (c *client) newClientInAccountScope(user *User) (Client, error) {
+	if err := c.ResolveAccount(&user.Account); err != nil {
+		return nil, errors.Wrapf(err, "resolving account %s details", user.Account.Name)
+	}
+	if err := c.ResolveProject(user); err != nil {
+		return nil, errors.Wrapf(err, "resolving project %s details", user.Project.Name)
+	}
+	scoped := *c
+	httpClient := *c.httpClient
+	httpClient.Transport = &accountScopeTransport{
+		next: c.httpClient.Transport, account: user.Account.Name, domainID: user.Account.Domain.ID,
+	}
+	scoped.httpClient = &httpClient
+	scoped.cs, scoped.csAsync = scoped.newCSClients(&httpClient)
+	scoped.user = user
+	return &scoped, nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
+)
+
+var _ = Describe("Sessions against the fake CloudStack API", func() {
+	var server *csserver.Server
+
+	BeforeEach(func() {
+		server, _ = NewFakeServerClient()
+	})
+
+	Context("session authentication", func() {
+		sessionConfig := func(password string) cloud.Config {
+			return cloud.Config{APIUrl: server.URL(), Username: "admin", Password: password}
+		}
+
+		It("logs in with the username and password", func() {
+			sessionClient, err := cloud.NewClientFromConf(sessionConfig(csserver.AdminPassword), nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sessionClient.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})).Should(Succeed())
+			Ω(server.CallCount("login")).Should(Equal(1))
+		})
+
+		It("logs in again when the session expires", func() {
+			sessionClient, err := cloud.NewClientFromConf(sessionConfig(csserver.AdminPassword), nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			server.ExpireSessions()
+			Ω(sessionClient.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})).Should(Succeed())
+			Ω(server.CallCount("login")).Should(Equal(2))
+		})
+
+		It("reports a rejected password as permission denied", func() {
+			_, err := cloud.NewClientFromConf(sessionConfig("wrong"), nil, "")
+			Ω(errors.Is(err, cloud.ErrPermissionDenied)).Should(BeTrue())
+		})
+
+		It("requires a password along with the username", func() {
+			_, err := cloud.NewClientFromConf(sessionConfig(""), nil, "")
+			Ω(err).Should(MatchError(ContainSubstring("password must be set")))
+			Ω(server.CallCount("login")).Should(Equal(0))
+		})
+
+		It("acts in another domain and account without API keys", func() {
+			domain := server.AddDomain(&cloudstack.Domain{Name: "sub"})
+			account := server.AddAccount(&cloudstack.Account{Name: "tenant", Domainid: domain.Id})
+			server.AddUser(&cloudstack.User{Username: "tenant", Accountid: account.Id})
+
+			sessionClient, err := cloud.NewClientFromConf(sessionConfig(csserver.AdminPassword), nil, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			keyCalls := server.CallCount("getUserKeys") + server.CallCount("registerUserKeys")
+			tenantClient, err := sessionClient.NewClientInDomainAndAccount("ROOT/sub", "tenant", "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tenantClient.GetOrCreateVMInstance(
+				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata",
+			)).Should(Succeed())
+
+			vm := server.VirtualMachine(*dummies.CSMachine1.Spec.InstanceID)
+			Ω(vm.Account).Should(Equal("tenant"))
+			Ω(vm.Domainid).Should(Equal(domain.Id))
+			Ω(server.CallCount("login")).Should(Equal(1))
+			Ω(server.CallCount("getUserKeys") + server.CallCount("registerUserKeys")).Should(Equal(keyCalls))
+		})
+	})
+})
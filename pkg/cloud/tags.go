@@ -41,19 +41,13 @@ type ResourceType string
 const (
 	ClusterTagNamePrefix               = "CAPC_cluster_"
 	CreatedByCAPCTagName               = "created_by_CAPC"
+	MachineUIDTagName                  = "CAPC_machine_uid"
 	ResourceTypeNetwork   ResourceType = "Network"
 	ResourceTypeIPAddress ResourceType = "PublicIpAddress"
+	ResourceTypeUserVM    ResourceType = "UserVm"
+	ResourceTypeVolume    ResourceType = "Volume"
 )
 
-// ignoreAlreadyPresentErrors returns nil if the error is an already present tag error.
-func ignoreAlreadyPresentErrors(err error, rType ResourceType, rID string) error {
-	matchSubString := strings.ToLower("already on " + string(rType) + " with id " + rID)
-	if err != nil && !strings.Contains(strings.ToLower(err.Error()), matchSubString) {
-		return err
-	}
-	return nil
-}
-
 func (c *client) IsCapcManaged(resourceType ResourceType, resourceID string) (bool, error) {
 	tags, err := c.GetTags(resourceType, resourceID)
 	if err != nil {
@@ -116,11 +110,31 @@ func (c *client) DoClusterTagsAllowDisposal(resourceType ResourceType, resourceI
 }
 
 // AddTags adds arbitrary tags to a resource.
+// Transient failures are retried, since tags left behind by a failed attempt are ignored. A failure is ignored when the
+// resource has the tags already, since CloudStack rejects tags that are already present.
 func (c *client) AddTags(resourceType ResourceType, resourceID string, tags map[string]string) error {
 	p := c.cs.Resourcetags.NewCreateTagsParams([]string{resourceID}, string(resourceType), tags)
-	_, err := c.cs.Resourcetags.CreateTags(p)
-	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-	return ignoreAlreadyPresentErrors(err, resourceType, resourceID)
+	return c.retry("createTags", func() error {
+		_, err := c.cs.Resourcetags.CreateTags(p)
+		if err == nil {
+			return nil
+		}
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		if present, getErr := c.GetTags(resourceType, resourceID); getErr == nil && hasTags(present, tags) {
+			return nil
+		}
+		return classifyError(err)
+	}, nil)
+}
+
+// hasTags reports whether all the tags are among the present tags, with the same values.
+func hasTags(present, tags map[string]string) bool {
+	for k, v := range tags {
+		if value, found := present[k]; !found || value != v {
+			return false
+		}
+	}
+	return true
 }
 
 // GetTags gets all of a resource's tags.
@@ -133,7 +147,7 @@ func (c *client) GetTags(resourceType ResourceType, resourceID string) (map[stri
 	listTagResponse, err := c.cs.Resourcetags.ListTags(p)
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return nil, err
+		return nil, classifyError(err)
 	}
 	tags := make(map[string]string, listTagResponse.Count)
 	for _, t := range listTagResponse.Tags {
@@ -154,7 +168,7 @@ func (c *client) DeleteTags(resourceType ResourceType, resourceID string, tagsTo
 			if tags, err2 := c.GetTags(resourceType, resourceID); len(tags) != 0 {
 				c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err2)
 				if _, foundTag := tags[tagkey]; foundTag {
-					return errors.Wrapf(multierror.Append(err1, err2),
+					return errors.Wrapf(multierror.Append(classifyError(err1), err2),
 						"could not remove tag %s from %s with ID %s", currTag, resourceType, resourceID)
 				}
 			}
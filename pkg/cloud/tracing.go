@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/tracing"
+)
+
+// Attributes of the spans of API calls.
+const (
+	CommandAttribute    = attribute.Key("cloudstack.command")
+	JobIDAttribute      = attribute.Key("cloudstack.job_id")
+	StatusCodeAttribute = attribute.Key("http.response.status_code")
+)
+
+// WithTracing returns a copy of the client whose API calls are traced as children of the span in the context spanCtx
+// returns at the time of each call. Clients that don't send calls over HTTP themselves, and all clients while tracing
+// isn't set up, are returned as they are.
+func (c *client) WithTracing(spanCtx func() context.Context) Client {
+	if c.httpClient == nil || !tracing.Enabled() {
+		return c
+	}
+	traced := *c
+	httpClient := *c.httpClient
+	httpClient.Transport = &tracingTransport{next: c.httpClient.Transport, spanCtx: spanCtx}
+	traced.httpClient = &httpClient
+	traced.cs, traced.csAsync = traced.newCSClients(&httpClient)
+	return &traced
+}
+
+// tracingTransport is an http.RoundTripper that records each API call, including its retries, in a span named after
+// its command.
+type tracingTransport struct {
+	next    http.RoundTripper
+	spanCtx func() context.Context
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	command := apiCommand(req)
+	if command == "" {
+		command = "unknown"
+	}
+	// Only the span is taken from the span context, so that calls aren't cancelled along with it.
+	parent := trace.ContextWithSpan(req.Context(), trace.SpanFromContext(t.spanCtx()))
+	ctx, span := tracing.Tracer().Start(parent, command, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(CommandAttribute.String(command)))
+	defer span.End()
+	if jobID := apiParams(req).Get("jobid"); jobID != "" {
+		span.SetAttributes(JobIDAttribute.String(jobID))
+	}
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(StatusCodeAttribute.Int(resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		span.SetStatus(codes.Error, resp.Status)
+		return resp, nil
+	}
+	if jobID := responseJobID(resp); jobID != "" {
+		span.SetAttributes(JobIDAttribute.String(jobID))
+	}
+	return resp, nil
+}
+
+// responseJobID returns the ID of the async job an API response reports, if any, leaving the response body to be read
+// again.
+func responseJobID(resp *http.Response) string {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	// Responses look like {"<command>response": {"jobid": "...", ...}}.
+	var wrapped map[string]json.RawMessage
+	if err := json.Unmarshal(body, &wrapped); err != nil {
+		return ""
+	}
+	for _, raw := range wrapped {
+		var job struct {
+			JobID string `json:"jobid"`
+		}
+		if err := json.Unmarshal(raw, &job); err == nil && job.JobID != "" {
+			return job.JobID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+)
+
+var _ = Describe("Tracing against the fake CloudStack API", func() {
+	var client cloud.Client
+
+	BeforeEach(func() {
+		_, client = NewFakeServerClient()
+	})
+
+	It("returns the client as it is while tracing isn't set up", func() {
+		Ω(client.WithTracing(context.Background)).Should(BeIdenticalTo(client))
+	})
+
+	Context("tracing", func() {
+		var (
+			exporter     *tracetest.InMemoryExporter
+			origProvider trace.TracerProvider
+		)
+
+		BeforeEach(func() {
+			exporter = tracetest.NewInMemoryExporter()
+			origProvider = otel.GetTracerProvider()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		})
+
+		AfterEach(func() {
+			otel.SetTracerProvider(origProvider)
+		})
+
+		It("traces API calls as children of the current span", func() {
+			ctx, stage := otel.Tracer("test").Start(context.Background(), "stage")
+			traced := client.WithTracing(func() context.Context { return ctx })
+			Ω(traced.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})).Should(Succeed())
+			stage.End()
+
+			var calls []tracetest.SpanStub
+			for _, span := range exporter.GetSpans() {
+				if span.Name == "listZones" {
+					calls = append(calls, span)
+				}
+			}
+			Ω(calls).ShouldNot(BeEmpty())
+			for _, call := range calls {
+				Ω(call.Parent.SpanID()).Should(Equal(stage.SpanContext().SpanID()))
+				Ω(call.Attributes).Should(ContainElement(cloud.CommandAttribute.String("listZones")))
+			}
+		})
+
+		It("records the job ID of async API calls", func() {
+			ctx, stage := otel.Tracer("test").Start(context.Background(), "stage")
+			traced := client.WithTracing(func() context.Context { return ctx })
+			Ω(traced.GetOrCreateVMInstance(
+				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata",
+			)).Should(Succeed())
+			stage.End()
+
+			jobIDs := map[string]string{}
+			for _, span := range exporter.GetSpans() {
+				for _, attr := range span.Attributes {
+					if attr.Key == cloud.JobIDAttribute {
+						jobIDs[span.Name] = attr.Value.AsString()
+					}
+				}
+			}
+			Ω(jobIDs).Should(HaveKeyWithValue("deployVirtualMachine", Not(BeEmpty())))
+		})
+
+		It("leaves the client untraced", func() {
+			Ω(client.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})).Should(Succeed())
+			Ω(exporter.GetSpans()).Should(BeEmpty())
+		})
+	})
+})
@@ -17,6 +17,9 @@ limitations under the License.
 package cloud
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -25,6 +28,9 @@ import (
 const (
 	rootDomain      = "ROOT"
 	domainDelimiter = "/"
+
+	// ServiceUserPrefix prefixes the name of the user CAPC creates in an account to provision API keys for.
+	ServiceUserPrefix = "capc-"
 )
 
 type UserCredIFace interface {
@@ -33,35 +39,42 @@ type UserCredIFace interface {
 	ResolveUser(*User) error
 	ResolveUserKeys(*User) error
 	GetUserWithKeys(*User) (bool, error)
+	ProvisionUserKeys(*User) error
 }
 
 // Domain contains specifications that identify a domain.
 type Domain struct {
-	Name            string
-	Path            string
-	ID              string
-	CPUAvailable    string
-	MemoryAvailable string
-	VMAvailable     string
+	Name                    string
+	Path                    string
+	ID                      string
+	CPUAvailable            string
+	MemoryAvailable         string
+	VMAvailable             string
+	VolumeAvailable         string
+	PrimaryStorageAvailable string
 }
 
 // Account contains specifications that identify an account.
 type Account struct {
-	Name            string
-	Domain          Domain
-	ID              string
-	CPUAvailable    string
-	MemoryAvailable string
-	VMAvailable     string
+	Name                    string
+	Domain                  Domain
+	ID                      string
+	CPUAvailable            string
+	MemoryAvailable         string
+	VMAvailable             string
+	VolumeAvailable         string
+	PrimaryStorageAvailable string
 }
 
 // Project contains specifications that identify a project.
 type Project struct {
-	Name            string
-	ID              string
-	CPUAvailable    string
-	MemoryAvailable string
-	VMAvailable     string
+	Name                    string
+	ID                      string
+	CPUAvailable            string
+	MemoryAvailable         string
+	VMAvailable             string
+	VolumeAvailable         string
+	PrimaryStorageAvailable string
 }
 
 // User contains information uniquely identifying and scoping a user.
@@ -108,7 +121,7 @@ func (c *client) ResolveDomain(domain *Domain) error {
 	resp, retErr := c.cs.Domain.ListDomains(p)
 	if retErr != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
-		return retErr
+		return classifyError(retErr)
 	}
 
 	// If the Id was provided.
@@ -124,6 +137,8 @@ func (c *client) ResolveDomain(domain *Domain) error {
 		domain.CPUAvailable = resp.Domains[0].Cpuavailable
 		domain.MemoryAvailable = resp.Domains[0].Memoryavailable
 		domain.VMAvailable = resp.Domains[0].Vmavailable
+		domain.VolumeAvailable = resp.Domains[0].Volumeavailable
+		domain.PrimaryStorageAvailable = resp.Domains[0].Primarystorageavailable
 		return nil
 	}
 
@@ -142,18 +157,19 @@ func (c *client) ResolveDomain(domain *Domain) error {
 			domain.CPUAvailable = possibleDomain.Cpuavailable
 			domain.MemoryAvailable = possibleDomain.Memoryavailable
 			domain.VMAvailable = possibleDomain.Vmavailable
+			domain.VolumeAvailable = possibleDomain.Volumeavailable
+			domain.PrimaryStorageAvailable = possibleDomain.Primarystorageavailable
 			return nil
 		}
 	}
 
-	return errors.Errorf("domain not found for domain path %s", domain.Path)
+	return classifiedErrorf(ErrNotFound, "domain not found for domain path %s", domain.Path)
 }
 
 // ResolveAccount resolves an account's information.
 func (c *client) ResolveAccount(account *Account) error {
 	// Resolve domain prior to any account resolution activity.
-	if err := c.ResolveDomain(&account.Domain); err != nil &&
-		!strings.Contains(err.Error(), "The API [listDomains] does not exist or is not available for the account Account") {
+	if err := c.ResolveDomain(&account.Domain); err != nil && !errors.Is(err, ErrPermissionDenied) {
 		return errors.Wrapf(err, "resolving domain %s details", account.Domain.Name)
 	}
 
@@ -164,9 +180,9 @@ func (c *client) ResolveAccount(account *Account) error {
 	resp, retErr := c.cs.Account.ListAccounts(p)
 	if retErr != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
-		return retErr
+		return classifyError(retErr)
 	} else if resp.Count == 0 {
-		return errors.Errorf("could not find account %s", account.Name)
+		return classifiedErrorf(ErrNotFound, "could not find account %s", account.Name)
 	} else if resp.Count != 1 {
 		return errors.Errorf("expected 1 Account with account name %s in domain ID %s, but got %d",
 			account.Name, account.Domain.ID, resp.Count)
@@ -176,6 +192,8 @@ func (c *client) ResolveAccount(account *Account) error {
 	account.CPUAvailable = resp.Accounts[0].Cpuavailable
 	account.MemoryAvailable = resp.Accounts[0].Memoryavailable
 	account.VMAvailable = resp.Accounts[0].Vmavailable
+	account.VolumeAvailable = resp.Accounts[0].Volumeavailable
+	account.PrimaryStorageAvailable = resp.Accounts[0].Primarystorageavailable
 	return nil
 }
 
@@ -194,9 +212,9 @@ func (c *client) ResolveProject(user *User) error {
 	resp, retErr := c.cs.Project.ListProjects(p)
 	if retErr != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
-		return retErr
+		return classifyError(retErr)
 	} else if resp.Count == 0 {
-		return errors.Errorf("could not find project %s", user.Project.Name)
+		return classifiedErrorf(ErrNotFound, "could not find project %s", user.Project.Name)
 	} else if resp.Count != 1 {
 		return errors.Errorf("expected 1 Project with name %s in domain ID %s, but got %d",
 			user.Project.Name, user.Domain.ID, resp.Count)
@@ -206,6 +224,8 @@ func (c *client) ResolveProject(user *User) error {
 	user.Project.CPUAvailable = resp.Projects[0].Cpuavailable
 	user.Project.MemoryAvailable = resp.Projects[0].Memoryavailable
 	user.Project.VMAvailable = resp.Projects[0].Vmavailable
+	user.Project.VolumeAvailable = resp.Projects[0].Volumeavailable
+	user.Project.PrimaryStorageAvailable = resp.Projects[0].Primarystorageavailable
 	return nil
 }
 
@@ -224,7 +244,7 @@ func (c *client) ResolveUser(user *User) error {
 	resp, err := c.cs.User.ListUsers(p)
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return err
+		return classifyError(err)
 	} else if resp.Count != 1 {
 		return errors.Errorf("expected 1 User with username %s but got %d", user.Name, resp.Count)
 	}
@@ -246,7 +266,7 @@ func (c *client) ResolveUserKeys(user *User) error {
 	resp, err := c.cs.User.GetUserKeys(p)
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return errors.Errorf("error encountered when resolving user api keys for user %s", user.Name)
+		return errors.Wrapf(classifyError(err), "error encountered when resolving user api keys for user %s", user.Name)
 	}
 	user.APIKey = resp.Apikey
 	user.SecretKey = resp.Secretkey
@@ -273,7 +293,7 @@ func (c *client) GetUserWithKeys(user *User) (bool, error) {
 	resp, err := c.cs.User.ListUsers(p)
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return false, err
+		return false, classifyError(err)
 	}
 
 	// Return first user with keys.
@@ -286,3 +306,59 @@ func (c *client) GetUserWithKeys(user *User) (bool, error) {
 	user.ID = ""
 	return false, nil
 }
+
+// ProvisionUserKeys generates API keys for the dedicated service user of the user's account, creating the service
+// user if the account has none. Any keys the service user had before are replaced, while the keys of the account's
+// other users are left alone.
+func (c *client) ProvisionUserKeys(user *User) error {
+	if err := c.ResolveAccount(&user.Account); err != nil {
+		return errors.Wrapf(err, "resolving account %s details", user.Account.Name)
+	}
+
+	p := c.cs.User.NewListUsersParams()
+	p.SetAccount(user.Account.Name)
+	p.SetDomainid(user.Account.Domain.ID)
+	p.SetUsername(ServiceUserPrefix + user.Account.Name)
+	p.SetListall(true)
+	resp, err := c.cs.User.ListUsers(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return classifyError(err)
+	}
+	if resp.Count > 0 {
+		user.ID = resp.Users[0].Id
+		user.Name = resp.Users[0].Username
+	} else if err := c.createServiceUser(user); err != nil {
+		return errors.Wrapf(err, "creating service user in account %s", user.Account.Name)
+	}
+
+	keys, err := c.cs.User.RegisterUserKeys(c.cs.User.NewRegisterUserKeysParams(user.ID))
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(classifyError(err), "registering api keys for user %s", user.Name)
+	}
+	user.APIKey = keys.Apikey
+	user.SecretKey = keys.Secretkey
+	return nil
+}
+
+// createServiceUser creates the user CAPC provisions API keys for in the user's account. Its password is random, as
+// it only ever authenticates with API keys.
+func (c *client) createServiceUser(user *User) error {
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return err
+	}
+	name := ServiceUserPrefix + user.Account.Name
+	p := c.cs.User.NewCreateUserParams(user.Account.Name, fmt.Sprintf("%s@localhost", name), "CAPC", "Service User",
+		base64.RawURLEncoding.EncodeToString(password), name)
+	p.SetDomainid(user.Account.Domain.ID)
+	resp, err := c.cs.User.CreateUser(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return classifyError(err)
+	}
+	user.ID = resp.Id
+	user.Name = resp.Username
+	return nil
+}
@@ -23,8 +23,10 @@ import (
 	csapi "github.com/apache/cloudstack-go/v2/cloudstack"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta3"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta3"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/test/helpers"
 
 	. "github.com/onsi/gomega"
@@ -486,3 +488,60 @@ var _ = Describe("User Credentials", func() {
 		})
 	})
 })
+
+var _ = Describe("User Credentials against the fake CloudStack API", func() {
+	var (
+		server *csserver.Server
+		client cloud.Client
+	)
+
+	BeforeEach(func() {
+		server, client = NewFakeServerClient()
+	})
+
+	Context("API key provisioning", func() {
+		var account *csapi.Account
+
+		BeforeEach(func() {
+			domain := server.AddDomain(&csapi.Domain{Name: "sub"})
+			account = server.AddAccount(&csapi.Account{Name: "tenant", Domainid: domain.Id})
+		})
+
+		It("registers API keys for the service user without touching the keys of other users", func() {
+			human := server.AddUser(&csapi.User{Username: "tenant", Accountid: account.Id})
+			service := server.AddUser(&csapi.User{Username: cloud.ServiceUserPrefix + "tenant", Accountid: account.Id})
+			_, err := client.NewClientInDomainAndAccount("ROOT/sub", "tenant", "")
+			Ω(errors.Is(err, cloud.ErrNotFound)).Should(BeTrue())
+
+			user := &cloud.User{Account: cloud.Account{Name: "tenant", Domain: cloud.Domain{Path: "ROOT/sub"}}}
+			Ω(client.ProvisionUserKeys(user)).Should(Succeed())
+			Ω(user.ID).Should(Equal(service.Id))
+			Ω(user.APIKey).ShouldNot(BeEmpty())
+			Ω(human.Apikey).Should(BeEmpty())
+			Ω(server.CallCount("createUser")).Should(Equal(0))
+
+			tenantClient, err := client.NewClientWithAPIKeys(user.APIKey, user.SecretKey, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tenantClient.ResolveZone(&infrav1.CloudStackZoneSpec{Name: dummies.Zone1.Name})).Should(Succeed())
+		})
+
+		It("creates the service user next to the human users of the account", func() {
+			human := server.AddUser(&csapi.User{Username: "tenant", Accountid: account.Id})
+			user := &cloud.User{Account: cloud.Account{Name: "tenant", Domain: cloud.Domain{Path: "ROOT/sub"}}}
+			Ω(client.ProvisionUserKeys(user)).Should(Succeed())
+			Ω(user.Name).Should(Equal(cloud.ServiceUserPrefix + "tenant"))
+			Ω(user.ID).ShouldNot(Equal(human.Id))
+			Ω(human.Apikey).Should(BeEmpty())
+		})
+
+		It("creates a service user in an account without users", func() {
+			user := &cloud.User{Account: cloud.Account{Name: "tenant", Domain: cloud.Domain{Path: "ROOT/sub"}}}
+			Ω(client.ProvisionUserKeys(user)).Should(Succeed())
+			Ω(user.Name).Should(Equal(cloud.ServiceUserPrefix + "tenant"))
+			Ω(server.CallCount("createUser")).Should(Equal(1))
+
+			_, err := client.NewClientInDomainAndAccount("ROOT/sub", "tenant", "")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+	})
+})
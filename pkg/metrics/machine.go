@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	crtlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// machineProvisioningBuckets are the buckets of the machine provisioning histograms, in seconds, from 10s to 1h.
+var machineProvisioningBuckets = []float64{10, 30, 60, 120, 180, 300, 450, 600, 900, 1800, 3600}
+
+// MachineMetrics encapsulates the CloudStack machine lifecycle metrics defined for the controller.
+type MachineMetrics struct {
+	instanceCreatedSeconds  *prometheus.HistogramVec
+	instanceRunningSeconds  *prometheus.HistogramVec
+	machineReadySeconds     *prometheus.HistogramVec
+	machinesByInstanceState *prometheus.GaugeVec
+}
+
+// NewMachineMetrics constructs a MachineMetrics with all CloudStack machine lifecycle metrics.
+func NewMachineMetrics() MachineMetrics {
+	machineLabels := []string{"failure_domain", "offering", "template"}
+	return MachineMetrics{
+		instanceCreatedSeconds: register(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "acs_machine_instance_created_seconds",
+				Help:    "Time from CloudStackMachine creation to its instance ID being set, bucketed by failure domain, offering and template",
+				Buckets: machineProvisioningBuckets,
+			},
+			machineLabels,
+		)),
+		instanceRunningSeconds: register(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "acs_machine_instance_running_seconds",
+				Help:    "Time from CloudStackMachine creation to its instance state being Running, bucketed by failure domain, offering and template",
+				Buckets: machineProvisioningBuckets,
+			},
+			machineLabels,
+		)),
+		machineReadySeconds: register(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "acs_machine_ready_seconds",
+				Help:    "Time from CloudStackMachine creation to it being ready, bucketed by failure domain, offering and template",
+				Buckets: machineProvisioningBuckets,
+			},
+			machineLabels,
+		)),
+		machinesByInstanceState: register(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "acs_machines",
+				Help: "Number of CloudStackMachines, bucketed by namespace, cluster and instance state",
+			},
+			[]string{"namespace", "cluster", "instance_state"},
+		)),
+	}
+}
+
+// register registers a collector with the controller-runtime registry, returning the collector registered before
+// under the same name if there is one.
+func register[T prometheus.Collector](collector T) T {
+	if err := crtlmetrics.Registry.Register(collector); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector.(T)
+		}
+		// Something else went wrong!
+		panic(err)
+	}
+	return collector
+}
+
+// ObserveInstanceCreated records the time a machine took to get an instance ID in the custom
+// acs_machine_instance_created_seconds histogram.
+func (m *MachineMetrics) ObserveInstanceCreated(failureDomain, offering, template string, d time.Duration) {
+	m.instanceCreatedSeconds.WithLabelValues(failureDomain, offering, template).Observe(d.Seconds())
+}
+
+// ObserveInstanceRunning records the time a machine took for its instance to be running in the custom
+// acs_machine_instance_running_seconds histogram.
+func (m *MachineMetrics) ObserveInstanceRunning(failureDomain, offering, template string, d time.Duration) {
+	m.instanceRunningSeconds.WithLabelValues(failureDomain, offering, template).Observe(d.Seconds())
+}
+
+// ObserveMachineReady records the time a machine took to be ready in the custom acs_machine_ready_seconds histogram.
+func (m *MachineMetrics) ObserveMachineReady(failureDomain, offering, template string, d time.Duration) {
+	m.machineReadySeconds.WithLabelValues(failureDomain, offering, template).Observe(d.Seconds())
+}
+
+// SetMachinesByInstanceState sets the custom acs_machines gauges of a cluster to the passed counts of machines per
+// instance state, dropping those of states no machine is in anymore.
+func (m *MachineMetrics) SetMachinesByInstanceState(namespace, cluster string, counts map[string]int) {
+	m.machinesByInstanceState.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "cluster": cluster})
+	for state, count := range counts {
+		m.machinesByInstanceState.WithLabelValues(namespace, cluster, state).Set(float64(count))
+	}
+}
+
+// DeleteMachinesByInstanceState drops the custom acs_machines gauges of a cluster.
+func (m *MachineMetrics) DeleteMachinesByInstanceState(namespace, cluster string) {
+	m.machinesByInstanceState.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "cluster": cluster})
+}
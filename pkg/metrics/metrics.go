@@ -18,14 +18,31 @@ limitations under the License.
 package metrics
 
 import (
+	"errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"regexp"
+	"time"
+
 	crtlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+// ThrottledError is implemented by errors of API calls that were throttled, by CloudStack or by the client's own rate
+// limits. They're counted in acs_api_throttled_requests rather than as reconciliation errors.
+type ThrottledError interface {
+	Throttled() bool
+}
+
+// throttledErrorRegexp matches the messages of API calls rejected by CloudStack's API throttling, which come with the
+// ApiErrorCode 429 or the CSExceptionErrorCode 4545.
+var throttledErrorRegexp = regexp.MustCompile(`CloudStack API error 429 |CSExceptionErrorCode: 4545\b`)
+
 // AcsCustomMetrics encapsulates all CloudStack custom metrics defined for the controller.
 type ACSCustomMetrics struct {
 	acsReconciliationErrorCount *prometheus.CounterVec
+	acsAPIRetryCount            *prometheus.CounterVec
+	acsAPIThrottledCount        *prometheus.CounterVec
+	acsAPICallCount             *prometheus.CounterVec
+	acsAPICallDuration          *prometheus.HistogramVec
 	errorCodeRegexp             *regexp.Regexp
 }
 
@@ -48,6 +65,71 @@ func NewCustomMetrics() ACSCustomMetrics {
 		}
 	}
 
+	customMetrics.acsAPIRetryCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "acs_api_retries",
+			Help: "Count of ACS API calls retried after a transient failure, bucketed by API command",
+		},
+		[]string{"command"},
+	)
+	if err := crtlmetrics.Registry.Register(customMetrics.acsAPIRetryCount); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			customMetrics.acsAPIRetryCount = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			// Something else went wrong!
+			panic(err)
+		}
+	}
+
+	customMetrics.acsAPIThrottledCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "acs_api_throttled_requests",
+			Help: "Count of ACS API calls rejected by ACS API throttling, bucketed by API command",
+		},
+		[]string{"command"},
+	)
+	if err := crtlmetrics.Registry.Register(customMetrics.acsAPIThrottledCount); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			customMetrics.acsAPIThrottledCount = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			// Something else went wrong!
+			panic(err)
+		}
+	}
+
+	customMetrics.acsAPICallCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "acs_api_calls_total",
+			Help: "Count of ACS API calls, bucketed by API command, endpoint host and outcome",
+		},
+		[]string{"command", "host", "outcome"},
+	)
+	if err := crtlmetrics.Registry.Register(customMetrics.acsAPICallCount); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			customMetrics.acsAPICallCount = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			// Something else went wrong!
+			panic(err)
+		}
+	}
+
+	customMetrics.acsAPICallDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "acs_api_call_duration_seconds",
+			Help:    "Duration of ACS API calls in seconds, bucketed by API command, endpoint host and outcome",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"command", "host", "outcome"},
+	)
+	if err := crtlmetrics.Registry.Register(customMetrics.acsAPICallDuration); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			customMetrics.acsAPICallDuration = are.ExistingCollector.(*prometheus.HistogramVec)
+		} else {
+			// Something else went wrong!
+			panic(err)
+		}
+	}
+
 	// ACS standard error messages of the form "CloudStack API error 431 (CSExceptionErrorCode: 9999):..."
 	//  This regexp is used to extract CSExceptionCodes from the message.
 	customMetrics.errorCodeRegexp, _ = regexp.Compile(".+CSExceptionErrorCode: ([0-9]+).+")
@@ -57,8 +139,16 @@ func NewCustomMetrics() ACSCustomMetrics {
 
 // EvaluateErrorAndIncrementAcsReconciliationErrorCounter accepts a CloudStack error message and increments
 // the custom acs_reconciliation_errors counter, labeled with the error code if present in the error message.
+// Throttled API calls aren't counted.
 func (m *ACSCustomMetrics) EvaluateErrorAndIncrementAcsReconciliationErrorCounter(acsError error) {
+	var throttled ThrottledError
+	if errors.As(acsError, &throttled) && throttled.Throttled() {
+		return
+	}
 	if acsError != nil {
+		if throttledErrorRegexp.MatchString(acsError.Error()) {
+			return
+		}
 		matches := m.errorCodeRegexp.FindStringSubmatch(acsError.Error())
 		if len(matches) > 1 {
 			m.acsReconciliationErrorCount.WithLabelValues(matches[1]).Inc()
@@ -67,3 +157,20 @@ func (m *ACSCustomMetrics) EvaluateErrorAndIncrementAcsReconciliationErrorCounte
 		}
 	}
 }
+
+// IncrementAcsAPIRetryCounter increments the custom acs_api_retries counter for the given API command.
+func (m *ACSCustomMetrics) IncrementAcsAPIRetryCounter(command string) {
+	m.acsAPIRetryCount.WithLabelValues(command).Inc()
+}
+
+// IncrementAcsAPIThrottledCounter increments the custom acs_api_throttled_requests counter for the given API command.
+func (m *ACSCustomMetrics) IncrementAcsAPIThrottledCounter(command string) {
+	m.acsAPIThrottledCount.WithLabelValues(command).Inc()
+}
+
+// ObserveAcsAPICall increments the custom acs_api_calls_total counter and records the duration of an API call in the
+// custom acs_api_call_duration_seconds histogram, for the given API command, endpoint host and outcome.
+func (m *ACSCustomMetrics) ObserveAcsAPICall(command, host, outcome string, duration time.Duration) {
+	m.acsAPICallCount.WithLabelValues(command, host, outcome).Inc()
+	m.acsAPICallDuration.WithLabelValues(command, host, outcome).Observe(duration.Seconds())
+}
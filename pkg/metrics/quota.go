@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// QuotaMetrics encapsulates the CloudStack resource limit metrics defined for the controller.
+type QuotaMetrics struct {
+	limit     *prometheus.GaugeVec
+	used      *prometheus.GaugeVec
+	available *prometheus.GaugeVec
+
+	// failureDomains holds the names of the failure domains with gauges by namespace and cluster.
+	failureDomains *failureDomainSet
+}
+
+// failureDomainSet is a set of failure domain names per namespace and cluster.
+type failureDomainSet struct {
+	sync.Mutex
+	names map[[2]string]map[string]bool
+}
+
+// NewQuotaMetrics constructs a QuotaMetrics with all CloudStack resource limit metrics.
+func NewQuotaMetrics() QuotaMetrics {
+	quotaLabels := []string{"namespace", "cluster", "failure_domain", "scope", "resource"}
+	return QuotaMetrics{
+		limit: register(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "acs_quota_limit",
+				Help: "Limit of a CloudStack resource in the account, domain or project of a failure domain, -1 when unlimited",
+			},
+			quotaLabels,
+		)),
+		used: register(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "acs_quota_used",
+				Help: "Usage of a CloudStack resource in the account, domain or project of a failure domain",
+			},
+			quotaLabels,
+		)),
+		available: register(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "acs_quota_available",
+				Help: "Availability of a CloudStack resource in the account, domain or project of a failure domain, -1 when unlimited",
+			},
+			quotaLabels,
+		)),
+		failureDomains: &failureDomainSet{names: map[[2]string]map[string]bool{}},
+	}
+}
+
+// SetResourceQuota sets the custom acs_quota_limit, acs_quota_used and acs_quota_available gauges of a resource in
+// the account, domain or project of a failure domain.
+func (m *QuotaMetrics) SetResourceQuota(namespace, cluster, failureDomain, scope, resource string, limit, used, available int64) {
+	m.limit.WithLabelValues(namespace, cluster, failureDomain, scope, resource).Set(float64(limit))
+	m.used.WithLabelValues(namespace, cluster, failureDomain, scope, resource).Set(float64(used))
+	m.available.WithLabelValues(namespace, cluster, failureDomain, scope, resource).Set(float64(available))
+
+	m.failureDomains.Lock()
+	defer m.failureDomains.Unlock()
+	key := [2]string{namespace, cluster}
+	if m.failureDomains.names[key] == nil {
+		m.failureDomains.names[key] = map[string]bool{}
+	}
+	m.failureDomains.names[key][failureDomain] = true
+}
+
+// RetainResourceQuotas drops the resource limit gauges of the failure domains of the cluster other than the passed
+// ones.
+func (m *QuotaMetrics) RetainResourceQuotas(namespace, cluster string, failureDomains []string) {
+	retained := map[string]bool{}
+	for _, name := range failureDomains {
+		retained[name] = true
+	}
+	m.failureDomains.Lock()
+	var stale []string
+	for name := range m.failureDomains.names[[2]string{namespace, cluster}] {
+		if !retained[name] {
+			stale = append(stale, name)
+		}
+	}
+	m.failureDomains.Unlock()
+	for _, name := range stale {
+		m.DeleteResourceQuotas(namespace, cluster, name)
+	}
+}
+
+// DeleteResourceQuotas drops the resource limit gauges of a failure domain, or of all failure domains of the cluster
+// if failureDomain is empty.
+func (m *QuotaMetrics) DeleteResourceQuotas(namespace, cluster, failureDomain string) {
+	labels := prometheus.Labels{"namespace": namespace, "cluster": cluster}
+	if failureDomain != "" {
+		labels["failure_domain"] = failureDomain
+	}
+	for _, gauges := range []*prometheus.GaugeVec{m.limit, m.used, m.available} {
+		gauges.DeletePartialMatch(labels)
+	}
+
+	m.failureDomains.Lock()
+	defer m.failureDomains.Unlock()
+	key := [2]string{namespace, cluster}
+	if failureDomain == "" {
+		delete(m.failureDomains.names, key)
+	} else {
+		delete(m.failureDomains.names[key], failureDomain)
+	}
+}
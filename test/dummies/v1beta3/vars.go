@@ -221,6 +221,7 @@ func SetDummyCSMachineVars() {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "test-machine-1",
 			Namespace: "default",
+			UID:       "0",
 			Labels:    ClusterLabel,
 		},
 		Spec: infrav1.CloudStackMachineSpec{
@@ -0,0 +1,1389 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package csserver
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+)
+
+// command is a CloudStack API command served by the fake. The handler runs with the server
+// lock held. Async commands complete immediately; their result is returned through
+// queryAsyncJobResult.
+type command struct {
+	handler func(*Server, url.Values) (interface{}, error)
+	async   bool
+}
+
+var commands = map[string]command{
+	"listcapabilities":              {handler: (*Server).listCapabilities},
+	"queryasyncjobresult":           {handler: (*Server).queryAsyncJobResult},
+	"listdomains":                   {handler: (*Server).listDomains},
+	"listaccounts":                  {handler: (*Server).listAccounts},
+	"listusers":                     {handler: (*Server).listUsers},
+	"getuser":                       {handler: (*Server).getUser},
+	"getuserkeys":                   {handler: (*Server).getUserKeys},
+	"createuser":                    {handler: (*Server).createUser},
+	"registeruserkeys":              {handler: (*Server).registerUserKeys},
+	"listprojects":                  {handler: (*Server).listProjects},
+	"listzones":                     {handler: (*Server).listZones},
+	"listnetworks":                  {handler: (*Server).listNetworks},
+	"createnetwork":                 {handler: (*Server).createNetwork},
+	"deletenetwork":                 {handler: (*Server).deleteNetwork, async: true},
+	"listnetworkofferings":          {handler: (*Server).listNetworkOfferings},
+	"listserviceofferings":          {handler: (*Server).listServiceOfferings},
+	"listdiskofferings":             {handler: (*Server).listDiskOfferings},
+	"listtemplates":                 {handler: (*Server).listTemplates},
+	"listvirtualmachines":           {handler: (*Server).listVirtualMachines},
+	"listvirtualmachinesmetrics":    {handler: (*Server).listVirtualMachines},
+	"deployvirtualmachine":          {handler: (*Server).deployVirtualMachine, async: true},
+	"destroyvirtualmachine":         {handler: (*Server).destroyVirtualMachine, async: true},
+	"startvirtualmachine":           {handler: (*Server).startVirtualMachine, async: true},
+	"stopvirtualmachine":            {handler: (*Server).stopVirtualMachine, async: true},
+	"updatevmaffinitygroup":         {handler: (*Server).updateVMAffinityGroup, async: true},
+	"listvolumes":                   {handler: (*Server).listVolumes},
+	"createvolume":                  {handler: (*Server).createVolume, async: true},
+	"attachvolume":                  {handler: (*Server).attachVolume, async: true},
+	"detachvolume":                  {handler: (*Server).detachVolume, async: true},
+	"deletevolume":                  {handler: (*Server).deleteVolume},
+	"listtags":                      {handler: (*Server).listTags},
+	"createtags":                    {handler: (*Server).createTags, async: true},
+	"deletetags":                    {handler: (*Server).deleteTags, async: true},
+	"listpublicipaddresses":         {handler: (*Server).listPublicIPAddresses},
+	"associateipaddress":            {handler: (*Server).associateIPAddress, async: true},
+	"disassociateipaddress":         {handler: (*Server).disassociateIPAddress, async: true},
+	"enablestaticnat":               {handler: (*Server).enableStaticNat},
+	"createegressfirewallrule":      {handler: (*Server).createEgressFirewallRule, async: true},
+	"listloadbalancerrules":         {handler: (*Server).listLoadBalancerRules},
+	"createloadbalancerrule":        {handler: (*Server).createLoadBalancerRule, async: true},
+	"deleteloadbalancerrule":        {handler: (*Server).deleteLoadBalancerRule, async: true},
+	"assigntoloadbalancerrule":      {handler: (*Server).assignToLoadBalancerRule, async: true},
+	"removefromloadbalancerrule":    {handler: (*Server).removeFromLoadBalancerRule, async: true},
+	"listloadbalancerruleinstances": {handler: (*Server).listLoadBalancerRuleInstances},
+	"listaffinitygroups":            {handler: (*Server).listAffinityGroups},
+	"createaffinitygroup":           {handler: (*Server).createAffinityGroup, async: true},
+	"deleteaffinitygroup":           {handler: (*Server).deleteAffinityGroup, async: true},
+}
+
+var successResult = map[string]interface{}{"success": true}
+
+// listResult builds a list response. CloudStack omits both the count and the list when empty.
+func listResult(key string, count int, items interface{}) map[string]interface{} {
+	if count == 0 {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{"count": count, key: items}
+}
+
+// parseInt parses an integer parameter, returning 0 for empty or invalid values.
+func parseInt(v string) int64 {
+	i, _ := strconv.ParseInt(v, 10, 64)
+	return i
+}
+
+// filter returns the items for which keep returns true.
+func filter[T any](items []T, keep func(T) bool) []T {
+	kept := []T{}
+	for _, item := range items {
+		if keep(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// matches returns true if the parameter is unset or equal to value.
+func matches(p url.Values, param, value string) bool {
+	return p.Get(param) == "" || p.Get(param) == value
+}
+
+// matchesProject returns true if the resource is visible given the projectid parameter.
+// Without a projectid only resources outside of projects are listed; -1 lists all.
+func matchesProject(p url.Values, projectID string) bool {
+	switch p.Get("projectid") {
+	case "-1":
+		return true
+	case "":
+		return projectID == ""
+	default:
+		return p.Get("projectid") == projectID
+	}
+}
+
+// checkIDFound returns the error CloudStack reports when listing an id that does not exist.
+func checkIDFound(p url.Values, count int) error {
+	if id := p.Get("id"); id != "" && count == 0 {
+		return entityNotFoundError("id", id)
+	}
+	return nil
+}
+
+// required returns an error if any of the parameters are missing.
+func required(p url.Values, params ...string) error {
+	for _, param := range params {
+		if p.Get(param) == "" {
+			return paramError("Unable to execute API command %s due to missing parameter %s", strings.ToLower(p.Get("command")), param)
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma separated list parameter.
+func splitList(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+var indexedParamRegexp = regexp.MustCompile(`^([a-z]+)\[(\d+)\]\.(.+)$`)
+
+// indexedParams decodes map and list parameters such as tags[0].key=k&tags[0].value=v.
+func indexedParams(p url.Values, name string) []map[string]string {
+	byIndex := map[int]map[string]string{}
+	for k := range p {
+		m := indexedParamRegexp.FindStringSubmatch(k)
+		if m == nil || m[1] != name {
+			continue
+		}
+		i, _ := strconv.Atoi(m[2])
+		if byIndex[i] == nil {
+			byIndex[i] = map[string]string{}
+		}
+		byIndex[i][m[3]] = p.Get(k)
+	}
+	indices := make([]int, 0, len(byIndex))
+	for i := range byIndex {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	list := make([]map[string]string, 0, len(indices))
+	for _, i := range indices {
+		list = append(list, byIndex[i])
+	}
+	return list
+}
+
+func now() string {
+	return time.Now().Format("2006-01-02T15:04:05-0700")
+}
+
+func (s *Server) listCapabilities(_ url.Values) (interface{}, error) {
+	return map[string]interface{}{"capability": s.Capabilities}, nil
+}
+
+func (s *Server) queryAsyncJobResult(p url.Values) (interface{}, error) {
+	if err := required(p, "jobid"); err != nil {
+		return nil, err
+	}
+	job, ok := s.jobs[p.Get("jobid")]
+	if !ok {
+		return nil, entityNotFoundError("jobid", p.Get("jobid"))
+	}
+	return map[string]interface{}{
+		"jobid":         job.ID,
+		"cmd":           job.Command,
+		"jobinstanceid": job.InstanceID,
+		"jobstatus":     job.Status,
+		"jobresultcode": 0,
+		"jobresulttype": "object",
+		"jobresult":     job.Result,
+	}, nil
+}
+
+func (s *Server) listDomains(p url.Values) (interface{}, error) {
+	domains := filter(s.domains, func(d *cloudstack.Domain) bool {
+		return matches(p, "id", d.Id) && matches(p, "name", d.Name) && matches(p, "level", strconv.Itoa(d.Level))
+	})
+	if err := checkIDFound(p, len(domains)); err != nil {
+		return nil, err
+	}
+	return listResult("domain", len(domains), domains), nil
+}
+
+func (s *Server) listAccounts(p url.Values) (interface{}, error) {
+	accounts := filter(s.accounts, func(a *cloudstack.Account) bool {
+		return matches(p, "id", a.Id) && matches(p, "name", a.Name) && matches(p, "domainid", a.Domainid)
+	})
+	if err := checkIDFound(p, len(accounts)); err != nil {
+		return nil, err
+	}
+	return listResult("account", len(accounts), accounts), nil
+}
+
+// userView hides the keys of a user, which CloudStack only returns from getUserKeys.
+func userView(u *cloudstack.User) *cloudstack.User {
+	c := *u
+	c.Apikey = ""
+	c.Secretkey = ""
+	return &c
+}
+
+func (s *Server) listUsers(p url.Values) (interface{}, error) {
+	users := []*cloudstack.User{}
+	for _, u := range s.users {
+		if !s.callerMaySee(u.Accountid) {
+			continue
+		}
+		if matches(p, "id", u.Id) && matches(p, "account", u.Account) &&
+			matches(p, "domainid", u.Domainid) && matches(p, "username", u.Username) {
+			users = append(users, userView(u))
+		}
+	}
+	if err := checkIDFound(p, len(users)); err != nil {
+		return nil, err
+	}
+	return listResult("user", len(users), users), nil
+}
+
+// callerMaySee reports whether the calling user may list the resources of an account. Only admins see the resources
+// of other accounts.
+func (s *Server) callerMaySee(accountID string) bool {
+	if s.caller == nil || s.caller.Accountid == accountID {
+		return true
+	}
+	account := s.accountByID(s.caller.Accountid)
+	return account != nil && account.Accounttype == accountTypeAdmin
+}
+
+func (s *Server) getUser(p url.Values) (interface{}, error) {
+	if err := required(p, "userapikey"); err != nil {
+		return nil, err
+	}
+	u := s.userByAPIKey(p.Get("userapikey"))
+	if u == nil {
+		return nil, paramError("Unable to find user by API key %s", p.Get("userapikey"))
+	}
+	return userView(u), nil
+}
+
+func (s *Server) getUserKeys(p url.Values) (interface{}, error) {
+	if err := required(p, "id"); err != nil {
+		return nil, err
+	}
+	for _, u := range s.users {
+		if u.Id == p.Get("id") {
+			// CloudStack returns an empty response for users without keys.
+			if u.Apikey == "" {
+				return map[string]interface{}{}, nil
+			}
+			return map[string]interface{}{"userkeys": map[string]string{"apikey": u.Apikey, "secretkey": u.Secretkey}}, nil
+		}
+	}
+	return nil, entityNotFoundError("id", p.Get("id"))
+}
+
+func (s *Server) createUser(p url.Values) (interface{}, error) {
+	if err := required(p, "account", "email", "firstname", "lastname", "password", "username"); err != nil {
+		return nil, err
+	}
+	var account *cloudstack.Account
+	for _, a := range s.accounts {
+		if a.Name == p.Get("account") && matches(p, "domainid", a.Domainid) {
+			account = a
+			break
+		}
+	}
+	if account == nil {
+		return nil, paramError("Unable to find account %s", p.Get("account"))
+	}
+	for _, u := range s.users {
+		if u.Username == p.Get("username") && u.Domainid == account.Domainid {
+			return nil, paramError("The user %s already exists in domain %s", p.Get("username"), account.Domainid)
+		}
+	}
+	u := &cloudstack.User{
+		Id:        s.newID(),
+		Username:  p.Get("username"),
+		Email:     p.Get("email"),
+		Firstname: p.Get("firstname"),
+		Lastname:  p.Get("lastname"),
+		Accountid: account.Id,
+		Account:   account.Name,
+		Domainid:  account.Domainid,
+		Domain:    account.Domain,
+		State:     "enabled",
+		Created:   now(),
+	}
+	s.users = append(s.users, u)
+	s.passwords[u.Id] = p.Get("password")
+	return map[string]interface{}{"user": userView(u)}, nil
+}
+
+func (s *Server) registerUserKeys(p url.Values) (interface{}, error) {
+	if err := required(p, "id"); err != nil {
+		return nil, err
+	}
+	for _, u := range s.users {
+		if u.Id == p.Get("id") {
+			u.Apikey = "api-key-" + s.newID()
+			u.Secretkey = "secret-key-" + s.newID()
+			return map[string]interface{}{"userkeys": map[string]string{"apikey": u.Apikey, "secretkey": u.Secretkey}}, nil
+		}
+	}
+	return nil, entityNotFoundError("id", p.Get("id"))
+}
+
+func (s *Server) listProjects(p url.Values) (interface{}, error) {
+	projects := filter(s.projects, func(pr *cloudstack.Project) bool {
+		return matches(p, "id", pr.Id) && matches(p, "name", pr.Name) && matches(p, "domainid", pr.Domainid)
+	})
+	if err := checkIDFound(p, len(projects)); err != nil {
+		return nil, err
+	}
+	return listResult("project", len(projects), projects), nil
+}
+
+func (s *Server) listZones(p url.Values) (interface{}, error) {
+	zones := filter(s.zones, func(z *cloudstack.Zone) bool {
+		return matches(p, "id", z.Id) && matches(p, "name", z.Name)
+	})
+	if err := checkIDFound(p, len(zones)); err != nil {
+		return nil, err
+	}
+	return listResult("zone", len(zones), zones), nil
+}
+
+func (s *Server) listNetworks(p url.Values) (interface{}, error) {
+	networks := filter(s.networks, func(n *cloudstack.Network) bool {
+		return matches(p, "id", n.Id) && matches(p, "name", n.Name) && matches(p, "zoneid", n.Zoneid) &&
+			matches(p, "type", n.Type) && (p.Get("id") != "" || matchesProject(p, n.Projectid))
+	})
+	if err := checkIDFound(p, len(networks)); err != nil {
+		return nil, err
+	}
+	return listResult("network", len(networks), networks), nil
+}
+
+func (s *Server) createNetwork(p url.Values) (interface{}, error) {
+	if err := required(p, "name", "networkofferingid", "zoneid"); err != nil {
+		return nil, err
+	}
+	zone := s.zoneByID(p.Get("zoneid"))
+	if zone == nil {
+		return nil, entityNotFoundError("zoneid", p.Get("zoneid"))
+	}
+	offering := filter(s.networkOfferings, func(o *cloudstack.NetworkOffering) bool { return o.Id == p.Get("networkofferingid") })
+	if len(offering) == 0 {
+		return nil, entityNotFoundError("networkofferingid", p.Get("networkofferingid"))
+	}
+	n := &cloudstack.Network{
+		Id:                s.newID(),
+		Name:              p.Get("name"),
+		Displaytext:       p.Get("displaytext"),
+		Networkofferingid: offering[0].Id,
+		Zoneid:            zone.Id,
+		Zonename:          zone.Name,
+		Projectid:         p.Get("projectid"),
+		Type:              offering[0].Guestiptype,
+		Cidr:              fmt.Sprintf("10.1.%d.0/24", len(s.networks)%256),
+		State:             "Allocated",
+	}
+	s.networks = append(s.networks, n)
+	return map[string]interface{}{"network": n}, nil
+}
+
+func (s *Server) deleteNetwork(p url.Values) (interface{}, error) {
+	if err := required(p, "id"); err != nil {
+		return nil, err
+	}
+	id := p.Get("id")
+	if s.networkByID(id) == nil {
+		return nil, entityNotFoundError("id", id)
+	}
+	for _, vm := range s.vms {
+		for _, nic := range vm.Nic {
+			if nic.Networkid == id {
+				return nil, paramError("Network %s has active virtual machines and cannot be deleted", id)
+			}
+		}
+	}
+	s.networks = filter(s.networks, func(n *cloudstack.Network) bool { return n.Id != id })
+	s.lbRules = filter(s.lbRules, func(r *cloudstack.LoadBalancerRule) bool { return r.Networkid != id })
+	s.firewallRules = filter(s.firewallRules, func(r *cloudstack.FirewallRule) bool { return r.Networkid != id })
+	for _, ip := range s.publicIPs {
+		if ip.Associatednetworkid == id {
+			releasePublicIP(ip)
+		}
+	}
+	s.deleteResourceTags(id)
+	return successResult, nil
+}
+
+func (s *Server) listNetworkOfferings(p url.Values) (interface{}, error) {
+	offerings := filter(s.networkOfferings, func(o *cloudstack.NetworkOffering) bool {
+		return matches(p, "id", o.Id) && matches(p, "name", o.Name)
+	})
+	if err := checkIDFound(p, len(offerings)); err != nil {
+		return nil, err
+	}
+	return listResult("networkoffering", len(offerings), offerings), nil
+}
+
+func (s *Server) listServiceOfferings(p url.Values) (interface{}, error) {
+	offerings := filter(s.serviceOfferings, func(o *cloudstack.ServiceOffering) bool {
+		return matches(p, "id", o.Id) && matches(p, "name", o.Name) && (o.Zoneid == "" || matches(p, "zoneid", o.Zoneid))
+	})
+	if err := checkIDFound(p, len(offerings)); err != nil {
+		return nil, err
+	}
+	return listResult("serviceoffering", len(offerings), offerings), nil
+}
+
+func (s *Server) listDiskOfferings(p url.Values) (interface{}, error) {
+	offerings := filter(s.diskOfferings, func(o *cloudstack.DiskOffering) bool {
+		return matches(p, "id", o.Id) && matches(p, "name", o.Name) && (o.Zoneid == "" || matches(p, "zoneid", o.Zoneid))
+	})
+	if err := checkIDFound(p, len(offerings)); err != nil {
+		return nil, err
+	}
+	return listResult("diskoffering", len(offerings), offerings), nil
+}
+
+func (s *Server) listTemplates(p url.Values) (interface{}, error) {
+	if err := required(p, "templatefilter"); err != nil {
+		return nil, err
+	}
+	templates := filter(s.templates, func(t *cloudstack.Template) bool {
+		return matches(p, "id", t.Id) && matches(p, "name", t.Name) && (t.Zoneid == "" || matches(p, "zoneid", t.Zoneid))
+	})
+	if err := checkIDFound(p, len(templates)); err != nil {
+		return nil, err
+	}
+	return listResult("template", len(templates), templates), nil
+}
+
+func (s *Server) listVirtualMachines(p url.Values) (interface{}, error) {
+	vms := filter(s.vms, func(vm *cloudstack.VirtualMachinesMetric) bool {
+		inNetwork := p.Get("networkid") == ""
+		for _, nic := range vm.Nic {
+			inNetwork = inNetwork || nic.Networkid == p.Get("networkid")
+		}
+		return inNetwork && matches(p, "id", vm.Id) && matches(p, "name", vm.Name) &&
+			matches(p, "zoneid", vm.Zoneid) && matches(p, "templateid", vm.Templateid) &&
+			matches(p, "state", vm.State) && matchesProject(p, vm.Projectid) && s.hasTags(p, vm.Id)
+	})
+	if err := checkIDFound(p, len(vms)); err != nil {
+		return nil, err
+	}
+	listed := make([]*cloudstack.VirtualMachinesMetric, 0, len(vms))
+	for _, vm := range vms {
+		listed = append(listed, s.withTags(vm))
+	}
+	return listResult("virtualmachine", len(listed), listed), nil
+}
+
+// hasTags returns true if the resource has all the tags of the tags parameter.
+func (s *Server) hasTags(p url.Values, resourceID string) bool {
+	for _, tag := range indexedParams(p, "tags") {
+		found := false
+		for _, t := range s.tags {
+			found = found || (t.Resourceid == resourceID && t.Key == tag["key"] && t.Value == tag["value"])
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// withTags returns a copy of the virtual machine listing its tags.
+func (s *Server) withTags(vm *cloudstack.VirtualMachinesMetric) *cloudstack.VirtualMachinesMetric {
+	listed := *vm
+	listed.Tags = s.resourceTags(vm.Id)
+	return &listed
+}
+
+// resourceTags returns the tags of the resource.
+func (s *Server) resourceTags(resourceID string) []cloudstack.Tags {
+	var tags []cloudstack.Tags
+	for _, t := range s.tags {
+		if t.Resourceid == resourceID {
+			tags = append(tags, cloudstack.Tags{
+				Key: t.Key, Value: t.Value, Resourceid: t.Resourceid, Resourcetype: t.Resourcetype,
+			})
+		}
+	}
+	return tags
+}
+
+// nextIPAddress returns the next unused address in the network's CIDR.
+func (s *Server) nextIPAddress(n *cloudstack.Network) (string, error) {
+	cidr := n.Cidr
+	if cidr == "" {
+		cidr = "10.0.0.0/16"
+	}
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	ip = ip.Mask(ipNet.Mask).To4()
+	for candidate := 2; candidate < 1<<16; candidate++ {
+		next := make(net.IP, len(ip))
+		copy(next, ip)
+		next[2] += byte(candidate >> 8)
+		next[3] += byte(candidate)
+		if !ipNet.Contains(next) {
+			break
+		}
+		if !s.ipAddressInUse(n, next.String()) {
+			return next.String(), nil
+		}
+	}
+	return "", &APIError{ErrorCode: 533, CSErrorCode: csErrorCodeServerAPI, ErrorText: fmt.Sprintf("Insufficient address capacity in network %s", n.Id)}
+}
+
+// nextIP6Address returns the next unused address in the network's IPv6 CIDR, or an empty string for networks without
+// one.
+func (s *Server) nextIP6Address(n *cloudstack.Network) (string, error) {
+	if n.Ip6cidr == "" {
+		return "", nil
+	}
+	prefix, err := netip.ParsePrefix(n.Ip6cidr)
+	if err != nil {
+		return "", err
+	}
+	used := map[string]bool{}
+	for _, vm := range s.vms {
+		for _, nic := range vm.Nic {
+			if nic.Networkid == n.Id {
+				used[nic.Ip6address] = true
+			}
+		}
+	}
+	for addr := prefix.Masked().Addr().Next().Next(); prefix.Contains(addr); addr = addr.Next() {
+		if !used[addr.String()] {
+			return addr.String(), nil
+		}
+	}
+	return "", &APIError{ErrorCode: 533, CSErrorCode: csErrorCodeServerAPI, ErrorText: fmt.Sprintf("Insufficient IPv6 address capacity in network %s", n.Id)}
+}
+
+// ipAddressInUse tells whether a NIC of a virtual machine on the network has the address.
+func (s *Server) ipAddressInUse(n *cloudstack.Network, ip string) bool {
+	for _, vm := range s.vms {
+		for _, nic := range vm.Nic {
+			if nic.Networkid == n.Id && nic.Ipaddress == ip {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deployNics builds the NICs of a new virtual machine from the network parameters.
+func (s *Server) deployNics(p url.Values, zone *cloudstack.Zone) ([]cloudstack.Nic, error) {
+	type request struct{ networkID, ip string }
+	var requests []request
+	for _, m := range indexedParams(p, "iptonetworklist") {
+		requests = append(requests, request{networkID: m["networkid"], ip: m["ip"]})
+	}
+	for i, id := range splitList(p.Get("networkids")) {
+		ip := ""
+		if i == 0 {
+			ip = p.Get("ipaddress")
+		}
+		requests = append(requests, request{networkID: id, ip: ip})
+	}
+	if len(requests) == 0 {
+		for _, n := range s.networks {
+			if n.Zoneid == zone.Id {
+				requests = append(requests, request{networkID: n.Id})
+				break
+			}
+		}
+	}
+	if len(requests) == 0 {
+		return nil, paramError("Can't deploy a VM without a network in zone %s", zone.Id)
+	}
+
+	nics := []cloudstack.Nic{}
+	for i, r := range requests {
+		n := s.networkByID(r.networkID)
+		if n == nil {
+			return nil, entityNotFoundError("networkids", r.networkID)
+		}
+		ip := r.ip
+		if ip == "" {
+			var err error
+			if ip, err = s.nextIPAddress(n); err != nil {
+				return nil, err
+			}
+		} else if s.ipAddressInUse(n, ip) {
+			return nil, &APIError{ErrorCode: 533, CSErrorCode: csErrorCodeServerAPI,
+				ErrorText: fmt.Sprintf("The IP address %s is already in use in network %s", ip, n.Id)}
+		}
+		ip6, err := s.nextIP6Address(n)
+		if err != nil {
+			return nil, err
+		}
+		nics = append(nics, cloudstack.Nic{
+			Id:          s.newID(),
+			Networkid:   n.Id,
+			Networkname: n.Name,
+			Ipaddress:   ip,
+			Ip6address:  ip6,
+			Isdefault:   i == 0,
+			Macaddress:  fmt.Sprintf("02:00:00:00:%02x:%02x", (s.nextID>>8)&0xff, s.nextID&0xff),
+			Traffictype: "Guest",
+			Type:        n.Type,
+		})
+	}
+	return nics, nil
+}
+
+func (s *Server) deployVirtualMachine(p url.Values) (interface{}, error) {
+	if err := required(p, "serviceofferingid", "templateid", "zoneid"); err != nil {
+		return nil, err
+	}
+	zone := s.zoneByID(p.Get("zoneid"))
+	if zone == nil {
+		return nil, entityNotFoundError("zoneid", p.Get("zoneid"))
+	}
+	offerings := filter(s.serviceOfferings, func(o *cloudstack.ServiceOffering) bool { return o.Id == p.Get("serviceofferingid") })
+	if len(offerings) == 0 {
+		return nil, entityNotFoundError("serviceofferingid", p.Get("serviceofferingid"))
+	}
+	templates := filter(s.templates, func(t *cloudstack.Template) bool { return t.Id == p.Get("templateid") })
+	if len(templates) == 0 {
+		return nil, entityNotFoundError("templateid", p.Get("templateid"))
+	}
+	offering, template := offerings[0], templates[0]
+
+	nics, err := s.deployNics(p, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	id := s.newID()
+	name := p.Get("name")
+	if name == "" {
+		name = "VM-" + id
+	}
+	for _, vm := range s.vms {
+		if vm.Name == name && vm.Nic[0].Networkid == nics[0].Networkid {
+			return nil, paramError("The vm with hostName %s already exists in the network domain: cs1cloud.internal; network=%s", name, nics[0].Networkid)
+		}
+	}
+
+	var affinityGroups []cloudstack.VirtualMachinesMetricAffinitygroup
+	for _, agID := range splitList(p.Get("affinitygroupids")) {
+		ag := s.affinityGroupByID(agID)
+		if ag == nil {
+			return nil, entityNotFoundError("affinitygroupids", agID)
+		}
+		affinityGroups = append(affinityGroups, cloudstack.VirtualMachinesMetricAffinitygroup{Id: ag.Id, Name: ag.Name, Type: ag.Type})
+		ag.VirtualmachineIds = append(ag.VirtualmachineIds, id)
+	}
+
+	details := map[string]string{}
+	for _, m := range indexedParams(p, "details") {
+		for k, v := range m {
+			details[k] = v
+		}
+	}
+
+	rootDiskSize := template.Size
+	if v, err := strconv.ParseInt(p.Get("rootdisksize"), 10, 64); err == nil {
+		if v<<30 < template.Size {
+			return nil, paramError("Unsupported: rootdisksize of %d GB is smaller than template size of %d B", v, template.Size)
+		}
+		rootDiskSize = v << 30
+	}
+	rootDiskOfferingID := p.Get("overridediskofferingid")
+	if rootDiskOfferingID != "" && len(filter(s.diskOfferings, func(o *cloudstack.DiskOffering) bool { return o.Id == rootDiskOfferingID })) == 0 {
+		return nil, entityNotFoundError("overridediskofferingid", rootDiskOfferingID)
+	}
+
+	// VMs belong to the account named in the call, or to the caller's.
+	var owner *cloudstack.Account
+	if p.Get("account") != "" {
+		accounts := filter(s.accounts, func(a *cloudstack.Account) bool {
+			return a.Name == p.Get("account") && matches(p, "domainid", a.Domainid)
+		})
+		if len(accounts) == 0 {
+			return nil, paramError("Unable to find account %s", p.Get("account"))
+		}
+		owner = accounts[0]
+	} else if s.caller != nil {
+		owner = s.accountByID(s.caller.Accountid)
+	}
+
+	state := "Running"
+	if p.Get("startvm") == "false" {
+		state = "Stopped"
+	}
+
+	displayName := p.Get("displayname")
+	if displayName == "" {
+		displayName = name
+	}
+	vm := &cloudstack.VirtualMachinesMetric{
+		Id:                  id,
+		Name:                name,
+		Displayname:         displayName,
+		State:               state,
+		Created:             now(),
+		Zoneid:              zone.Id,
+		Zonename:            zone.Name,
+		Templateid:          template.Id,
+		Templatename:        template.Name,
+		Serviceofferingid:   offering.Id,
+		Serviceofferingname: offering.Name,
+		Cpunumber:           offering.Cpunumber,
+		Memory:              offering.Memory,
+		Diskofferingid:      p.Get("diskofferingid"),
+		Projectid:           p.Get("projectid"),
+		Keypairs:            p.Get("keypair"),
+		Userdata:            p.Get("userdata"),
+		Affinitygroup:       affinityGroups,
+		Nic:                 nics,
+		Ipaddress:           nics[0].Ipaddress,
+		Details:             details,
+	}
+	if owner != nil {
+		vm.Account, vm.Domainid = owner.Name, owner.Domainid
+	}
+	s.vms = append(s.vms, vm)
+
+	s.volumes = append(s.volumes, &cloudstack.Volume{
+		Id:               s.newID(),
+		Name:             "ROOT-" + id,
+		Type:             "ROOT",
+		Virtualmachineid: id,
+		Vmname:           name,
+		Zoneid:           zone.Id,
+		Projectid:        vm.Projectid,
+		Diskofferingid:   rootDiskOfferingID,
+		Size:             rootDiskSize,
+		State:            "Ready",
+	})
+	if diskOfferingID := p.Get("diskofferingid"); diskOfferingID != "" {
+		diskOfferings := filter(s.diskOfferings, func(o *cloudstack.DiskOffering) bool { return o.Id == diskOfferingID })
+		if len(diskOfferings) == 0 {
+			return nil, entityNotFoundError("diskofferingid", diskOfferingID)
+		}
+		size := diskOfferings[0].Disksize
+		if v, err := strconv.ParseInt(p.Get("size"), 10, 64); err == nil {
+			size = v
+		}
+		s.volumes = append(s.volumes, &cloudstack.Volume{
+			Id:               s.newID(),
+			Name:             "DATA-" + id,
+			Type:             "DATADISK",
+			Virtualmachineid: id,
+			Vmname:           name,
+			Zoneid:           zone.Id,
+			Projectid:        vm.Projectid,
+			Diskofferingid:   diskOfferingID,
+			Size:             size << 30,
+			Miniops:          parseInt(details["minIopsDo"]),
+			Maxiops:          parseInt(details["maxIopsDo"]),
+			Deviceid:         1,
+			State:            "Ready",
+		})
+	}
+
+	return map[string]interface{}{"virtualmachine": vm}, nil
+}
+
+func (s *Server) vmForCommand(p url.Values) (*cloudstack.VirtualMachinesMetric, error) {
+	if err := required(p, "id"); err != nil {
+		return nil, err
+	}
+	vm := s.vmByID(p.Get("id"))
+	if vm == nil {
+		return nil, entityNotFoundError("id", p.Get("id"))
+	}
+	return vm, nil
+}
+
+func (s *Server) destroyVirtualMachine(p url.Values) (interface{}, error) {
+	vm, err := s.vmForCommand(p)
+	if err != nil {
+		return nil, err
+	}
+	volumeIDs := map[string]bool{}
+	for _, id := range splitList(p.Get("volumeids")) {
+		volumeIDs[id] = true
+	}
+	s.volumes = filter(s.volumes, func(v *cloudstack.Volume) bool {
+		if v.Virtualmachineid != vm.Id {
+			return true
+		}
+		if volumeIDs[v.Id] {
+			return false
+		}
+		// Data disks that were not destroyed along with the VM are detached.
+		v.Virtualmachineid = ""
+		v.Vmname = ""
+		return v.Type != "ROOT"
+	})
+
+	if p.Get("expunge") != "true" {
+		vm.State = "Destroyed"
+		return map[string]interface{}{"virtualmachine": vm}, nil
+	}
+	vm.State = "Expunging"
+	s.vms = filter(s.vms, func(v *cloudstack.VirtualMachinesMetric) bool { return v.Id != vm.Id })
+	for ruleID, vmIDs := range s.lbRuleInstances {
+		s.lbRuleInstances[ruleID] = filter(vmIDs, func(id string) bool { return id != vm.Id })
+	}
+	for _, ag := range s.affinityGroups {
+		ag.VirtualmachineIds = filter(ag.VirtualmachineIds, func(id string) bool { return id != vm.Id })
+	}
+	s.deleteResourceTags(vm.Id)
+	return map[string]interface{}{"virtualmachine": vm}, nil
+}
+
+func (s *Server) startVirtualMachine(p url.Values) (interface{}, error) {
+	vm, err := s.vmForCommand(p)
+	if err != nil {
+		return nil, err
+	}
+	vm.State = "Running"
+	return map[string]interface{}{"virtualmachine": vm}, nil
+}
+
+func (s *Server) stopVirtualMachine(p url.Values) (interface{}, error) {
+	vm, err := s.vmForCommand(p)
+	if err != nil {
+		return nil, err
+	}
+	vm.State = "Stopped"
+	return map[string]interface{}{"virtualmachine": vm}, nil
+}
+
+func (s *Server) updateVMAffinityGroup(p url.Values) (interface{}, error) {
+	vm, err := s.vmForCommand(p)
+	if err != nil {
+		return nil, err
+	}
+	if vm.State != "Stopped" {
+		return nil, paramError("Unable to update affinity groups of the virtual machine %s in state %s", vm.Id, vm.State)
+	}
+	var groups []*cloudstack.AffinityGroup
+	for _, agID := range splitList(p.Get("affinitygroupids")) {
+		ag := s.affinityGroupByID(agID)
+		if ag == nil {
+			return nil, entityNotFoundError("affinitygroupids", agID)
+		}
+		groups = append(groups, ag)
+	}
+	for _, ag := range s.affinityGroups {
+		ag.VirtualmachineIds = filter(ag.VirtualmachineIds, func(id string) bool { return id != vm.Id })
+	}
+	vm.Affinitygroup = nil
+	for _, ag := range groups {
+		ag.VirtualmachineIds = append(ag.VirtualmachineIds, vm.Id)
+		vm.Affinitygroup = append(vm.Affinitygroup, cloudstack.VirtualMachinesMetricAffinitygroup{Id: ag.Id, Name: ag.Name, Type: ag.Type})
+	}
+	return map[string]interface{}{"virtualmachine": vm}, nil
+}
+
+func (s *Server) listVolumes(p url.Values) (interface{}, error) {
+	volumes := filter(s.volumes, func(v *cloudstack.Volume) bool {
+		return matches(p, "id", v.Id) && matches(p, "name", v.Name) && matches(p, "type", v.Type) &&
+			matches(p, "virtualmachineid", v.Virtualmachineid) && matchesProject(p, v.Projectid) && s.hasTags(p, v.Id)
+	})
+	if err := checkIDFound(p, len(volumes)); err != nil {
+		return nil, err
+	}
+	listed := make([]*cloudstack.Volume, len(volumes))
+	for i, v := range volumes {
+		volume := *v
+		volume.Tags = s.resourceTags(v.Id)
+		listed[i] = &volume
+	}
+	return listResult("volume", len(listed), listed), nil
+}
+
+func (s *Server) createVolume(p url.Values) (interface{}, error) {
+	if err := required(p, "name", "diskofferingid", "zoneid"); err != nil {
+		return nil, err
+	}
+	if s.zoneByID(p.Get("zoneid")) == nil {
+		return nil, entityNotFoundError("zoneid", p.Get("zoneid"))
+	}
+	diskOfferings := filter(s.diskOfferings, func(o *cloudstack.DiskOffering) bool { return o.Id == p.Get("diskofferingid") })
+	if len(diskOfferings) == 0 {
+		return nil, entityNotFoundError("diskofferingid", p.Get("diskofferingid"))
+	}
+	size := diskOfferings[0].Disksize
+	if v, err := strconv.ParseInt(p.Get("size"), 10, 64); err == nil {
+		size = v
+	}
+	volume := &cloudstack.Volume{
+		Id:             s.newID(),
+		Name:           p.Get("name"),
+		Type:           "DATADISK",
+		Zoneid:         p.Get("zoneid"),
+		Projectid:      p.Get("projectid"),
+		Diskofferingid: diskOfferings[0].Id,
+		Size:           size << 30,
+		Miniops:        parseInt(p.Get("miniops")),
+		Maxiops:        parseInt(p.Get("maxiops")),
+		State:          "Allocated",
+	}
+	s.volumes = append(s.volumes, volume)
+	return map[string]interface{}{"volume": volume}, nil
+}
+
+func (s *Server) attachVolume(p url.Values) (interface{}, error) {
+	if err := required(p, "id", "virtualmachineid"); err != nil {
+		return nil, err
+	}
+	volumes := filter(s.volumes, func(v *cloudstack.Volume) bool { return v.Id == p.Get("id") })
+	if len(volumes) == 0 {
+		return nil, entityNotFoundError("id", p.Get("id"))
+	}
+	vm := s.vmByID(p.Get("virtualmachineid"))
+	if vm == nil {
+		return nil, entityNotFoundError("virtualmachineid", p.Get("virtualmachineid"))
+	}
+	volume := volumes[0]
+	if volume.Virtualmachineid != "" {
+		return nil, paramError("Please specify a volume that is not attached to any VM.")
+	}
+	deviceID := int64(1)
+	for _, v := range s.volumes {
+		if v.Virtualmachineid == vm.Id && v.Deviceid >= deviceID {
+			deviceID = v.Deviceid + 1
+		}
+	}
+	volume.Virtualmachineid = vm.Id
+	volume.Vmname = vm.Name
+	volume.Deviceid = deviceID
+	volume.State = "Ready"
+	return map[string]interface{}{"volume": volume}, nil
+}
+
+func (s *Server) detachVolume(p url.Values) (interface{}, error) {
+	if err := required(p, "id"); err != nil {
+		return nil, err
+	}
+	volumes := filter(s.volumes, func(v *cloudstack.Volume) bool { return v.Id == p.Get("id") })
+	if len(volumes) == 0 {
+		return nil, entityNotFoundError("id", p.Get("id"))
+	}
+	volume := volumes[0]
+	if volume.Virtualmachineid == "" {
+		return nil, paramError("Please specify a volume that is attached to a VM.")
+	}
+	volume.Virtualmachineid = ""
+	volume.Vmname = ""
+	volume.Deviceid = 0
+	return map[string]interface{}{"volume": volume}, nil
+}
+
+func (s *Server) deleteVolume(p url.Values) (interface{}, error) {
+	if err := required(p, "id"); err != nil {
+		return nil, err
+	}
+	volumes := filter(s.volumes, func(v *cloudstack.Volume) bool { return v.Id == p.Get("id") })
+	if len(volumes) == 0 {
+		return nil, entityNotFoundError("id", p.Get("id"))
+	} else if volumes[0].Virtualmachineid != "" {
+		return nil, paramError("Please specify a volume that is not attached to any VM.")
+	}
+	s.volumes = filter(s.volumes, func(v *cloudstack.Volume) bool { return v.Id != p.Get("id") })
+	s.deleteResourceTags(p.Get("id"))
+	return successResult, nil
+}
+
+func (s *Server) listTags(p url.Values) (interface{}, error) {
+	tags := filter(s.tags, func(t *cloudstack.Tag) bool {
+		return matches(p, "resourceid", t.Resourceid) && matches(p, "key", t.Key) && matches(p, "value", t.Value) &&
+			(p.Get("resourcetype") == "" || strings.EqualFold(t.Resourcetype, p.Get("resourcetype")))
+	})
+	return listResult("tag", len(tags), tags), nil
+}
+
+func (s *Server) createTags(p url.Values) (interface{}, error) {
+	if err := required(p, "resourceids", "resourcetype"); err != nil {
+		return nil, err
+	}
+	resourceType := p.Get("resourcetype")
+	newTags := indexedParams(p, "tags")
+	if len(newTags) == 0 {
+		return nil, paramError("Unable to execute API command createtags due to missing parameter tags")
+	}
+	for _, resourceID := range splitList(p.Get("resourceids")) {
+		for _, tag := range newTags {
+			for _, t := range s.tags {
+				if t.Resourceid == resourceID && t.Key == tag["key"] {
+					return nil, paramError("tag %s already on %s with id %s", tag["key"], resourceType, resourceID)
+				}
+			}
+		}
+	}
+	for _, resourceID := range splitList(p.Get("resourceids")) {
+		for _, tag := range newTags {
+			s.tags = append(s.tags, &cloudstack.Tag{Resourceid: resourceID, Resourcetype: resourceType, Key: tag["key"], Value: tag["value"]})
+		}
+	}
+	return successResult, nil
+}
+
+func (s *Server) deleteTags(p url.Values) (interface{}, error) {
+	if err := required(p, "resourceids", "resourcetype"); err != nil {
+		return nil, err
+	}
+	resourceIDs := map[string]bool{}
+	for _, id := range splitList(p.Get("resourceids")) {
+		resourceIDs[id] = true
+	}
+	toDelete := indexedParams(p, "tags")
+	s.tags = filter(s.tags, func(t *cloudstack.Tag) bool {
+		if !resourceIDs[t.Resourceid] {
+			return true
+		}
+		if len(toDelete) == 0 {
+			return false
+		}
+		for _, tag := range toDelete {
+			if tag["key"] == t.Key && (tag["value"] == "" || tag["value"] == t.Value) {
+				return false
+			}
+		}
+		return true
+	})
+	return successResult, nil
+}
+
+func (s *Server) deleteResourceTags(resourceID string) {
+	s.tags = filter(s.tags, func(t *cloudstack.Tag) bool { return t.Resourceid != resourceID })
+}
+
+func (s *Server) listPublicIPAddresses(p url.Values) (interface{}, error) {
+	ips := filter(s.publicIPs, func(ip *cloudstack.PublicIpAddress) bool {
+		allocated := ip.State == "Allocated"
+		return matches(p, "id", ip.Id) && matches(p, "ipaddress", ip.Ipaddress) && matches(p, "zoneid", ip.Zoneid) &&
+			matches(p, "associatednetworkid", ip.Associatednetworkid) &&
+			(allocated || p.Get("allocatedonly") == "false") &&
+			(!allocated || p.Get("id") != "" || matchesProject(p, ip.Projectid))
+	})
+	if err := checkIDFound(p, len(ips)); err != nil {
+		return nil, err
+	}
+	return listResult("publicipaddress", len(ips), ips), nil
+}
+
+func (s *Server) enableStaticNat(p url.Values) (interface{}, error) {
+	if err := required(p, "ipaddressid", "virtualmachineid"); err != nil {
+		return nil, err
+	}
+	ip := s.publicIPByID(p.Get("ipaddressid"))
+	if ip == nil {
+		return nil, entityNotFoundError("ipaddressid", p.Get("ipaddressid"))
+	}
+	vm := s.vmByID(p.Get("virtualmachineid"))
+	if vm == nil {
+		return nil, entityNotFoundError("virtualmachineid", p.Get("virtualmachineid"))
+	}
+	if ip.Isstaticnat {
+		return nil, paramError("Failed to enable static nat for the IP address id=%s as it's already assigned to a virtual machine", ip.Id)
+	}
+	ip.Isstaticnat = true
+	ip.Virtualmachineid = vm.Id
+	ip.Virtualmachinename = vm.Name
+	vm.Publicip = ip.Ipaddress
+	vm.Publicipid = ip.Id
+	return successResult, nil
+}
+
+func (s *Server) associateIPAddress(p url.Values) (interface{}, error) {
+	var network *cloudstack.Network
+	if id := p.Get("networkid"); id != "" {
+		if network = s.networkByID(id); network == nil {
+			return nil, entityNotFoundError("networkid", id)
+		}
+	}
+	zoneID := p.Get("zoneid")
+	if zoneID == "" && network != nil {
+		zoneID = network.Zoneid
+	}
+	for _, ip := range s.publicIPs {
+		if !matches(p, "ipaddress", ip.Ipaddress) || (ip.Zoneid != "" && ip.Zoneid != zoneID) {
+			continue
+		}
+		if ip.State == "Allocated" {
+			if p.Get("ipaddress") != "" {
+				return nil, paramError("IP address %s is already allocated", ip.Ipaddress)
+			}
+			continue
+		}
+		ip.State = "Allocated"
+		ip.Allocated = now()
+		ip.Projectid = p.Get("projectid")
+		if network != nil {
+			ip.Associatednetworkid = network.Id
+			ip.Associatednetworkname = network.Name
+		}
+		return map[string]interface{}{"ipaddress": ip}, nil
+	}
+	return nil, &APIError{ErrorCode: 533, CSErrorCode: csErrorCodeServerAPI, ErrorText: fmt.Sprintf("Insufficient address capacity in zone %s", zoneID)}
+}
+
+func releasePublicIP(ip *cloudstack.PublicIpAddress) {
+	ip.State = "Free"
+	ip.Allocated = ""
+	ip.Associatednetworkid = ""
+	ip.Associatednetworkname = ""
+	ip.Projectid = ""
+}
+
+func (s *Server) disassociateIPAddress(p url.Values) (interface{}, error) {
+	if err := required(p, "id"); err != nil {
+		return nil, err
+	}
+	ip := s.publicIPByID(p.Get("id"))
+	if ip == nil {
+		return nil, entityNotFoundError("id", p.Get("id"))
+	}
+	s.lbRules = filter(s.lbRules, func(r *cloudstack.LoadBalancerRule) bool { return r.Publicipid != ip.Id })
+	releasePublicIP(ip)
+	s.deleteResourceTags(ip.Id)
+	return successResult, nil
+}
+
+func (s *Server) createEgressFirewallRule(p url.Values) (interface{}, error) {
+	if err := required(p, "networkid", "protocol"); err != nil {
+		return nil, err
+	}
+	if s.networkByID(p.Get("networkid")) == nil {
+		return nil, entityNotFoundError("networkid", p.Get("networkid"))
+	}
+	startPort, _ := strconv.Atoi(p.Get("startport"))
+	endPort, _ := strconv.Atoi(p.Get("endport"))
+	for _, r := range s.firewallRules {
+		if r.Networkid == p.Get("networkid") && strings.EqualFold(r.Protocol, p.Get("protocol")) &&
+			r.Startport == startPort && r.Endport == endPort {
+			return nil, &APIError{
+				ErrorCode:   537,
+				CSErrorCode: csErrorCodeServerAPI,
+				ErrorText:   fmt.Sprintf("There is already a firewall rule specified with protocol=%s and no ports", r.Protocol),
+			}
+		}
+	}
+	rule := &cloudstack.FirewallRule{
+		Id:          s.newID(),
+		Networkid:   p.Get("networkid"),
+		Protocol:    strings.ToLower(p.Get("protocol")),
+		Cidrlist:    p.Get("cidrlist"),
+		Startport:   startPort,
+		Endport:     endPort,
+		Traffictype: "Egress",
+		State:       "Active",
+	}
+	s.firewallRules = append(s.firewallRules, rule)
+	return map[string]interface{}{"firewallrule": rule}, nil
+}
+
+func (s *Server) listLoadBalancerRules(p url.Values) (interface{}, error) {
+	rules := filter(s.lbRules, func(r *cloudstack.LoadBalancerRule) bool {
+		return matches(p, "id", r.Id) && matches(p, "name", r.Name) && matches(p, "publicipid", r.Publicipid) &&
+			matches(p, "networkid", r.Networkid) && (p.Get("id") != "" || matchesProject(p, r.Projectid))
+	})
+	if err := checkIDFound(p, len(rules)); err != nil {
+		return nil, err
+	}
+	return listResult("loadbalancerrule", len(rules), rules), nil
+}
+
+func (s *Server) createLoadBalancerRule(p url.Values) (interface{}, error) {
+	if err := required(p, "name", "privateport", "publicport", "algorithm"); err != nil {
+		return nil, err
+	}
+	ip := s.publicIPByID(p.Get("publicipid"))
+	if ip == nil {
+		return nil, entityNotFoundError("publicipid", p.Get("publicipid"))
+	}
+	for _, r := range s.lbRules {
+		if r.Publicipid == ip.Id && r.Publicport == p.Get("publicport") {
+			return nil, &APIError{
+				ErrorCode:   537,
+				CSErrorCode: csErrorCodeServerAPI,
+				ErrorText: fmt.Sprintf("The range specified, %s-%s, conflicts with rule %s which has %s-%s",
+					p.Get("publicport"), p.Get("publicport"), r.Id, r.Publicport, r.Publicport),
+			}
+		}
+	}
+	networkID := p.Get("networkid")
+	if networkID == "" {
+		networkID = ip.Associatednetworkid
+	}
+	protocol := p.Get("protocol")
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	rule := &cloudstack.LoadBalancerRule{
+		Id:          s.newID(),
+		Name:        p.Get("name"),
+		Algorithm:   p.Get("algorithm"),
+		Privateport: p.Get("privateport"),
+		Publicport:  p.Get("publicport"),
+		Publicip:    ip.Ipaddress,
+		Publicipid:  ip.Id,
+		Networkid:   networkID,
+		Protocol:    protocol,
+		Projectid:   ip.Projectid,
+		Zoneid:      ip.Zoneid,
+		State:       "Add",
+	}
+	s.lbRules = append(s.lbRules, rule)
+	return map[string]interface{}{"loadbalancer": rule}, nil
+}
+
+func (s *Server) lbRuleForCommand(p url.Values) (*cloudstack.LoadBalancerRule, error) {
+	if err := required(p, "id"); err != nil {
+		return nil, err
+	}
+	for _, r := range s.lbRules {
+		if r.Id == p.Get("id") {
+			return r, nil
+		}
+	}
+	return nil, entityNotFoundError("id", p.Get("id"))
+}
+
+func (s *Server) deleteLoadBalancerRule(p url.Values) (interface{}, error) {
+	rule, err := s.lbRuleForCommand(p)
+	if err != nil {
+		return nil, err
+	}
+	s.lbRules = filter(s.lbRules, func(r *cloudstack.LoadBalancerRule) bool { return r.Id != rule.Id })
+	delete(s.lbRuleInstances, rule.Id)
+	return successResult, nil
+}
+
+func (s *Server) assignToLoadBalancerRule(p url.Values) (interface{}, error) {
+	rule, err := s.lbRuleForCommand(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, vmID := range splitList(p.Get("virtualmachineids")) {
+		if s.vmByID(vmID) == nil {
+			return nil, entityNotFoundError("virtualmachineids", vmID)
+		}
+		for _, assigned := range s.lbRuleInstances[rule.Id] {
+			if assigned == vmID {
+				return nil, paramError("VM %s is already assigned to load balancer rule %s", vmID, rule.Id)
+			}
+		}
+		s.lbRuleInstances[rule.Id] = append(s.lbRuleInstances[rule.Id], vmID)
+	}
+	rule.State = "Active"
+	return successResult, nil
+}
+
+func (s *Server) removeFromLoadBalancerRule(p url.Values) (interface{}, error) {
+	rule, err := s.lbRuleForCommand(p)
+	if err != nil {
+		return nil, err
+	}
+	toRemove := map[string]bool{}
+	for _, id := range splitList(p.Get("virtualmachineids")) {
+		toRemove[id] = true
+	}
+	s.lbRuleInstances[rule.Id] = filter(s.lbRuleInstances[rule.Id], func(id string) bool { return !toRemove[id] })
+	return successResult, nil
+}
+
+func (s *Server) listLoadBalancerRuleInstances(p url.Values) (interface{}, error) {
+	rule, err := s.lbRuleForCommand(p)
+	if err != nil {
+		return nil, err
+	}
+	vms := []*cloudstack.VirtualMachinesMetric{}
+	for _, id := range s.lbRuleInstances[rule.Id] {
+		if vm := s.vmByID(id); vm != nil {
+			vms = append(vms, vm)
+		}
+	}
+	return listResult("loadbalancerruleinstance", len(vms), vms), nil
+}
+
+func (s *Server) affinityGroupByID(id string) *cloudstack.AffinityGroup {
+	for _, ag := range s.affinityGroups {
+		if ag.Id == id {
+			return ag
+		}
+	}
+	return nil
+}
+
+func (s *Server) listAffinityGroups(p url.Values) (interface{}, error) {
+	groups := filter(s.affinityGroups, func(ag *cloudstack.AffinityGroup) bool {
+		return matches(p, "id", ag.Id) && matches(p, "name", ag.Name) && matches(p, "type", ag.Type) &&
+			(p.Get("id") != "" || matchesProject(p, ag.Projectid))
+	})
+	if err := checkIDFound(p, len(groups)); err != nil {
+		return nil, err
+	}
+	return listResult("affinitygroup", len(groups), groups), nil
+}
+
+func (s *Server) createAffinityGroup(p url.Values) (interface{}, error) {
+	if err := required(p, "name", "type"); err != nil {
+		return nil, err
+	}
+	for _, ag := range s.affinityGroups {
+		if ag.Name == p.Get("name") && ag.Projectid == p.Get("projectid") {
+			return nil, paramError("Unable to create affinity group, a group with name %s already exists.", ag.Name)
+		}
+	}
+	ag := &cloudstack.AffinityGroup{
+		Id:          s.newID(),
+		Name:        p.Get("name"),
+		Type:        p.Get("type"),
+		Description: p.Get("description"),
+		Projectid:   p.Get("projectid"),
+	}
+	s.affinityGroups = append(s.affinityGroups, ag)
+	return map[string]interface{}{"affinitygroup": ag}, nil
+}
+
+func (s *Server) deleteAffinityGroup(p url.Values) (interface{}, error) {
+	if p.Get("id") == "" && p.Get("name") == "" {
+		return nil, paramError("Either the affinity group id or name must be specified")
+	}
+	groups := filter(s.affinityGroups, func(ag *cloudstack.AffinityGroup) bool {
+		return matches(p, "id", ag.Id) && matches(p, "name", ag.Name) && ag.Projectid == p.Get("projectid")
+	})
+	if len(groups) == 0 {
+		if p.Get("id") != "" {
+			return nil, entityNotFoundError("id", p.Get("id"))
+		}
+		return nil, paramError("Unable to find affinity group by name %s", p.Get("name"))
+	}
+	ag := groups[0]
+	if len(ag.VirtualmachineIds) > 0 {
+		return nil, paramError("Cannot delete affinity group %s as it has virtual machines in it", ag.Name)
+	}
+	s.affinityGroups = filter(s.affinityGroups, func(g *cloudstack.AffinityGroup) bool { return g.Id != ag.Id })
+	return successResult, nil
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package csserver
+
+import (
+	"strings"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+)
+
+// The Add* methods seed the server with resources. A resource without an ID is assigned one.
+// The stored resource is returned and may be inspected, but must not be modified concurrently
+// with requests to the server.
+
+// AddDomain adds a domain. Its level is derived from its path.
+func (s *Server) AddDomain(d *cloudstack.Domain) *cloudstack.Domain {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureID(&d.Id)
+	if d.Path == "" {
+		d.Path = "ROOT/" + d.Name
+	}
+	d.Level = strings.Count(d.Path, "/")
+	d.Cpuavailable = orUnlimited(d.Cpuavailable)
+	d.Memoryavailable = orUnlimited(d.Memoryavailable)
+	d.Vmavailable = orUnlimited(d.Vmavailable)
+	d.Volumeavailable = orUnlimited(d.Volumeavailable)
+	d.Primarystorageavailable = orUnlimited(d.Primarystorageavailable)
+	d.Cpulimit = orUnlimited(d.Cpulimit)
+	d.Memorylimit = orUnlimited(d.Memorylimit)
+	d.Vmlimit = orUnlimited(d.Vmlimit)
+	d.Volumelimit = orUnlimited(d.Volumelimit)
+	d.Primarystoragelimit = orUnlimited(d.Primarystoragelimit)
+	s.domains = append(s.domains, d)
+	return d
+}
+
+// AddAccount adds an account. Domainid must reference an existing domain.
+func (s *Server) AddAccount(a *cloudstack.Account) *cloudstack.Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureID(&a.Id)
+	if d := s.domainByID(a.Domainid); d != nil {
+		a.Domain = d.Name
+		a.Domainpath = d.Path
+	}
+	a.Cpuavailable = orUnlimited(a.Cpuavailable)
+	a.Memoryavailable = orUnlimited(a.Memoryavailable)
+	a.Vmavailable = orUnlimited(a.Vmavailable)
+	a.Volumeavailable = orUnlimited(a.Volumeavailable)
+	a.Primarystorageavailable = orUnlimited(a.Primarystorageavailable)
+	a.Cpulimit = orUnlimited(a.Cpulimit)
+	a.Memorylimit = orUnlimited(a.Memorylimit)
+	a.Vmlimit = orUnlimited(a.Vmlimit)
+	a.Volumelimit = orUnlimited(a.Volumelimit)
+	a.Primarystoragelimit = orUnlimited(a.Primarystoragelimit)
+	s.accounts = append(s.accounts, a)
+	return a
+}
+
+// AddUser adds a user. Accountid must reference an existing account. Users with an Apikey
+// and Secretkey may sign requests.
+func (s *Server) AddUser(u *cloudstack.User) *cloudstack.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureID(&u.Id)
+	if a := s.accountByID(u.Accountid); a != nil {
+		u.Account = a.Name
+		u.Domainid = a.Domainid
+		u.Domain = a.Domain
+	}
+	s.users = append(s.users, u)
+	return u
+}
+
+// SetPassword sets the password of a user, so that they may log in.
+func (s *Server) SetPassword(userID, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.passwords[userID] = password
+}
+
+// AddProject adds a project.
+func (s *Server) AddProject(p *cloudstack.Project) *cloudstack.Project {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureID(&p.Id)
+	p.Cpuavailable = orUnlimited(p.Cpuavailable)
+	p.Memoryavailable = orUnlimited(p.Memoryavailable)
+	p.Vmavailable = orUnlimited(p.Vmavailable)
+	p.Volumeavailable = orUnlimited(p.Volumeavailable)
+	p.Primarystorageavailable = orUnlimited(p.Primarystorageavailable)
+	p.Cpulimit = orUnlimited(p.Cpulimit)
+	p.Memorylimit = orUnlimited(p.Memorylimit)
+	p.Vmlimit = orUnlimited(p.Vmlimit)
+	p.Volumelimit = orUnlimited(p.Volumelimit)
+	p.Primarystoragelimit = orUnlimited(p.Primarystoragelimit)
+	s.projects = append(s.projects, p)
+	return p
+}
+
+// AddZone adds a zone.
+func (s *Server) AddZone(z *cloudstack.Zone) *cloudstack.Zone {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureID(&z.Id)
+	if z.Networktype == "" {
+		z.Networktype = "Advanced"
+	}
+	s.zones = append(s.zones, z)
+	return z
+}
+
+// AddNetwork adds a network. Zoneid should reference an existing zone.
+func (s *Server) AddNetwork(n *cloudstack.Network) *cloudstack.Network {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureID(&n.Id)
+	if z := s.zoneByID(n.Zoneid); z != nil {
+		n.Zonename = z.Name
+	}
+	if n.State == "" {
+		n.State = "Implemented"
+	}
+	s.networks = append(s.networks, n)
+	return n
+}
+
+// AddNetworkOffering adds a network offering.
+func (s *Server) AddNetworkOffering(o *cloudstack.NetworkOffering) *cloudstack.NetworkOffering {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureID(&o.Id)
+	s.networkOfferings = append(s.networkOfferings, o)
+	return o
+}
+
+// AddServiceOffering adds a service offering.
+func (s *Server) AddServiceOffering(o *cloudstack.ServiceOffering) *cloudstack.ServiceOffering {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureID(&o.Id)
+	s.serviceOfferings = append(s.serviceOfferings, o)
+	return o
+}
+
+// AddDiskOffering adds a disk offering.
+func (s *Server) AddDiskOffering(o *cloudstack.DiskOffering) *cloudstack.DiskOffering {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureID(&o.Id)
+	s.diskOfferings = append(s.diskOfferings, o)
+	return o
+}
+
+// AddTemplate adds a template. Templates are executable and ready unless stated otherwise.
+func (s *Server) AddTemplate(t *cloudstack.Template) *cloudstack.Template {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureID(&t.Id)
+	t.Isready = true
+	s.templates = append(s.templates, t)
+	return t
+}
+
+// AddVirtualMachine adds a virtual machine that wasn't deployed through the API. Zoneid should reference an existing
+// zone.
+func (s *Server) AddVirtualMachine(vm *cloudstack.VirtualMachinesMetric) *cloudstack.VirtualMachinesMetric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureID(&vm.Id)
+	if z := s.zoneByID(vm.Zoneid); z != nil {
+		vm.Zonename = z.Name
+	}
+	if vm.State == "" {
+		vm.State = "Running"
+	}
+	s.vms = append(s.vms, vm)
+	return vm
+}
+
+// AddPublicIPAddress adds a public IP address that may later be associated with a network.
+func (s *Server) AddPublicIPAddress(ip *cloudstack.PublicIpAddress) *cloudstack.PublicIpAddress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureID(&ip.Id)
+	if ip.State == "" {
+		ip.State = "Free"
+	}
+	s.publicIPs = append(s.publicIPs, ip)
+	return ip
+}
+
+// VirtualMachine returns a copy of the virtual machine with the given ID, or nil.
+func (s *Server) VirtualMachine(id string) *cloudstack.VirtualMachinesMetric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if vm := s.vmByID(id); vm != nil {
+		c := *vm
+		return &c
+	}
+	return nil
+}
+
+// VirtualMachines returns copies of all virtual machines that have not been expunged.
+func (s *Server) VirtualMachines() []*cloudstack.VirtualMachinesMetric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vms := make([]*cloudstack.VirtualMachinesMetric, 0, len(s.vms))
+	for _, vm := range s.vms {
+		c := *vm
+		vms = append(vms, &c)
+	}
+	return vms
+}
+
+// Volumes returns copies of all volumes.
+func (s *Server) Volumes() []*cloudstack.Volume {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	volumes := make([]*cloudstack.Volume, 0, len(s.volumes))
+	for _, v := range s.volumes {
+		c := *v
+		volumes = append(volumes, &c)
+	}
+	return volumes
+}
+
+// SetVirtualMachineState sets the state of the virtual machine with the given ID.
+func (s *Server) SetVirtualMachineState(id, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if vm := s.vmByID(id); vm != nil {
+		vm.State = state
+	}
+}
+
+// Tags returns copies of the tags on the given resource.
+func (s *Server) Tags(resourceID string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tags := map[string]string{}
+	for _, t := range s.tags {
+		if t.Resourceid == resourceID {
+			tags[t.Key] = t.Value
+		}
+	}
+	return tags
+}
+
+func (s *Server) ensureID(id *string) {
+	if *id == "" {
+		*id = s.newID()
+	}
+}
+
+func orUnlimited(v string) string {
+	if v == "" {
+		return "Unlimited"
+	}
+	return v
+}
+
+func (s *Server) userByAPIKey(key string) *cloudstack.User {
+	if key == "" {
+		return nil
+	}
+	for _, u := range s.users {
+		if u.Apikey == key {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *Server) domainByID(id string) *cloudstack.Domain {
+	for _, d := range s.domains {
+		if d.Id == id {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *Server) accountByID(id string) *cloudstack.Account {
+	for _, a := range s.accounts {
+		if a.Id == id {
+			return a
+		}
+	}
+	return nil
+}
+
+func (s *Server) zoneByID(id string) *cloudstack.Zone {
+	for _, z := range s.zones {
+		if z.Id == id {
+			return z
+		}
+	}
+	return nil
+}
+
+func (s *Server) networkByID(id string) *cloudstack.Network {
+	for _, n := range s.networks {
+		if n.Id == id {
+			return n
+		}
+	}
+	return nil
+}
+
+func (s *Server) vmByID(id string) *cloudstack.VirtualMachinesMetric {
+	for _, vm := range s.vms {
+		if vm.Id == id {
+			return vm
+		}
+	}
+	return nil
+}
+
+func (s *Server) publicIPByID(id string) *cloudstack.PublicIpAddress {
+	for _, ip := range s.publicIPs {
+		if ip.Id == id {
+			return ip
+		}
+	}
+	return nil
+}
@@ -0,0 +1,457 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package csserver provides an in-process, stateful fake of the CloudStack API for tests.
+//
+// The fake serves the same wire protocol as a CloudStack management server: requests are
+// signed with an API key and secret key, or carry the session key of a login, responses are wrapped in a <command>response
+// object, and asynchronous commands return a job ID that is resolved through
+// queryAsyncJobResult. This lets tests point cloudstack-go clients at the fake and exercise
+// request signing, JSON decoding and async job handling without any gomock expectations.
+package csserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505 -- CloudStack request signatures are HMAC-SHA1.
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+)
+
+const (
+	// AdminAPIKey is the API key of the admin user the server is seeded with.
+	AdminAPIKey = "fake-admin-api-key"
+	// AdminSecretKey is the secret key of the admin user the server is seeded with.
+	AdminSecretKey = "fake-admin-secret-key"
+	// AdminPassword is the password of the admin user the server is seeded with.
+	AdminPassword = "fake-admin-password"
+
+	// sessionTimeout is the session timeout reported by login, in seconds.
+	sessionTimeout = 1800
+
+	// accountTypeAdmin is the account type of root admins, who see the resources of all accounts.
+	accountTypeAdmin = 1
+
+	errorCodeParamError     = 431
+	errorCodeUnknownCommand = 432
+	errorCodeUnauthorized   = 401
+	errorCodeInternal       = 530
+	errorCodeAccount        = 531
+
+	csErrorCodeInvalidParameterValue = 4350
+	csErrorCodeServerAPI             = 9999
+)
+
+// APIError is an error response returned by the fake server.
+type APIError struct {
+	ErrorCode   int    `json:"errorcode"`
+	CSErrorCode int    `json:"cserrorcode"`
+	ErrorText   string `json:"errortext"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("CloudStack API error %d (CSExceptionErrorCode: %d): %s", e.ErrorCode, e.CSErrorCode, e.ErrorText)
+}
+
+// paramError returns the error CloudStack reports for invalid or missing parameters.
+func paramError(format string, args ...interface{}) *APIError {
+	return &APIError{ErrorCode: errorCodeParamError, CSErrorCode: csErrorCodeInvalidParameterValue, ErrorText: fmt.Sprintf(format, args...)}
+}
+
+// entityNotFoundError returns the error CloudStack reports when a UUID parameter does not resolve.
+func entityNotFoundError(param, id string) *APIError {
+	return paramError("Invalid parameter %s value=%s due to incorrect long value format, "+
+		"or entity does not exist or due to incorrect parameter annotation for the field in api cmd class.", param, id)
+}
+
+// Fault is an error injected for a command. Count is the number of calls that should fail;
+// zero or less fails every call until the fault is cleared.
+type Fault struct {
+	APIError
+	Count int
+}
+
+// session is a login session, identified by its session key and JSESSIONID cookie.
+type session struct {
+	userID     string
+	jSessionID string
+}
+
+type asyncJob struct {
+	ID      string
+	Command string
+	Status  int
+	Result  interface{}
+	// InstanceID is the ID of the resource the job created or changed, e.g. the VM of a deployVirtualMachine job.
+	InstanceID string
+}
+
+// Server is an in-process fake of the CloudStack API.
+type Server struct {
+	mu  sync.Mutex
+	srv *httptest.Server
+
+	nextID int
+	calls  []string
+	faults map[string]*Fault
+	jobs   map[string]*asyncJob
+	// heldJobs are the commands whose jobs are left pending until CompleteJobs is called.
+	heldJobs map[string]bool
+
+	passwords map[string]string
+	sessions  map[string]*session
+	// caller is the user making the request being served.
+	caller *cloudstack.User
+
+	Capabilities cloudstack.Capability
+
+	domains          []*cloudstack.Domain
+	accounts         []*cloudstack.Account
+	users            []*cloudstack.User
+	projects         []*cloudstack.Project
+	zones            []*cloudstack.Zone
+	networks         []*cloudstack.Network
+	networkOfferings []*cloudstack.NetworkOffering
+	serviceOfferings []*cloudstack.ServiceOffering
+	diskOfferings    []*cloudstack.DiskOffering
+	templates        []*cloudstack.Template
+	vms              []*cloudstack.VirtualMachinesMetric
+	volumes          []*cloudstack.Volume
+	tags             []*cloudstack.Tag
+	publicIPs        []*cloudstack.PublicIpAddress
+	lbRules          []*cloudstack.LoadBalancerRule
+	lbRuleInstances  map[string][]string
+	firewallRules    []*cloudstack.FirewallRule
+	affinityGroups   []*cloudstack.AffinityGroup
+}
+
+// NewServer starts a fake CloudStack API server seeded with the ROOT domain and an admin
+// account and user, whose keys are AdminAPIKey and AdminSecretKey and whose password is
+// AdminPassword. Callers must Close it.
+func NewServer() *Server {
+	s := newServer()
+	s.srv = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer starts a fake CloudStack API server like NewServer, but serving HTTPS with a self-signed certificate,
+// see CACertificate. If clientCAs isn't nil, clients must present a certificate signed by one of them.
+func NewTLSServer(clientCAs *x509.CertPool) *Server {
+	s := newServer()
+	s.srv = httptest.NewUnstartedServer(s)
+	if clientCAs != nil {
+		s.srv.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert, MinVersion: tls.VersionTLS12}
+	}
+	s.srv.StartTLS()
+	return s
+}
+
+// CACertificate returns the PEM encoded certificate of a server started with NewTLSServer.
+func (s *Server) CACertificate() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.srv.Certificate().Raw})
+}
+
+func newServer() *Server {
+	s := &Server{
+		faults:          map[string]*Fault{},
+		jobs:            map[string]*asyncJob{},
+		heldJobs:        map[string]bool{},
+		passwords:       map[string]string{},
+		sessions:        map[string]*session{},
+		lbRuleInstances: map[string][]string{},
+		Capabilities: cloudstack.Capability{
+			Allowuserexpungerecovervm: true,
+			Cloudstackversion:         "4.18.0.0",
+		},
+	}
+	root := s.AddDomain(&cloudstack.Domain{Name: "ROOT", Path: "ROOT"})
+	admin := s.AddAccount(&cloudstack.Account{Name: "admin", Domainid: root.Id, Accounttype: accountTypeAdmin})
+	user := s.AddUser(&cloudstack.User{
+		Username:  "admin",
+		Accountid: admin.Id,
+		Apikey:    AdminAPIKey,
+		Secretkey: AdminSecretKey,
+	})
+	s.SetPassword(user.Id, AdminPassword)
+	return s
+}
+
+// URL returns the API endpoint of the server, suitable for use as a cloud.Config APIUrl.
+func (s *Server) URL() string {
+	return s.srv.URL + "/client/api"
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// InjectFault makes the next calls of command fail with the given error.
+func (s *Server) InjectFault(command string, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := fault
+	s.faults[strings.ToLower(command)] = &f
+}
+
+// ClearFaults removes all injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = map[string]*Fault{}
+}
+
+// HoldJobs leaves the async jobs of the next calls of command pending, as if they took a while, until CompleteJobs is
+// called. The calls take effect right away regardless.
+func (s *Server) HoldJobs(command string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.heldJobs[strings.ToLower(command)] = true
+}
+
+// CompleteJobs completes all pending async jobs successfully, and stops holding new ones.
+func (s *Server) CompleteJobs() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		job.Status = 1
+	}
+	s.heldJobs = map[string]bool{}
+}
+
+// ExpireSessions ends all login sessions, as a management server does when they time out.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = map[string]*session{}
+}
+
+// Calls returns the commands served so far, in order.
+func (s *Server) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.calls...)
+}
+
+// CallCount returns how many times command was served.
+func (s *Server) CallCount(command string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, c := range s.calls {
+		if strings.EqualFold(c, command) {
+			count++
+		}
+	}
+	return count
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, "error", &APIError{ErrorCode: errorCodeParamError, CSErrorCode: csErrorCodeServerAPI, ErrorText: err.Error()})
+		return
+	}
+	params := r.Form
+	command := params.Get("command")
+	key := strings.ToLower(command)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, command)
+
+	if key == "login" {
+		s.login(w, params)
+		return
+	}
+	caller, err := s.authenticate(r, params)
+	if err != nil {
+		writeError(w, key, err)
+		return
+	}
+	s.caller = caller
+
+	if fault, ok := s.faults[key]; ok {
+		if fault.Count > 0 {
+			fault.Count--
+			if fault.Count == 0 {
+				delete(s.faults, key)
+			}
+		}
+		writeError(w, key, &fault.APIError)
+		return
+	}
+
+	cmd, ok := commands[key]
+	if !ok {
+		writeError(w, "error", &APIError{
+			ErrorCode:   errorCodeUnknownCommand,
+			CSErrorCode: csErrorCodeServerAPI,
+			ErrorText:   "The given command does not exist or it is not available for user",
+		})
+		return
+	}
+
+	result, err := cmd.handler(s, params)
+	if err != nil {
+		writeError(w, key, err)
+		return
+	}
+	if cmd.async {
+		// Snapshot the result so that later changes to the resource don't alter the job result.
+		b, err := json.Marshal(result)
+		if err != nil {
+			writeError(w, key, err)
+			return
+		}
+		job := &asyncJob{ID: s.newID(), Command: command, Status: 1, Result: json.RawMessage(b), InstanceID: resultID(b)}
+		if s.heldJobs[key] {
+			job.Status = 0
+		}
+		s.jobs[job.ID] = job
+		// Like CloudStack, commands creating a resource return its ID along with the job's.
+		response := map[string]interface{}{"jobid": job.ID}
+		if job.InstanceID != "" {
+			response["id"] = job.InstanceID
+		}
+		result = response
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{key + "response": result})
+}
+
+// resultID returns the ID of the resource a command result like {"virtualmachine": {"id": ...}} describes, if any.
+func resultID(result []byte) string {
+	var wrapped map[string]struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(result, &wrapped); err != nil || len(wrapped) != 1 {
+		return ""
+	}
+	for _, resource := range wrapped {
+		return resource.ID
+	}
+	return ""
+}
+
+// login starts a session for the user with the passed credentials. Later requests authenticate with the session key
+// it returns, along with the JSESSIONID cookie it sets.
+func (s *Server) login(w http.ResponseWriter, params url.Values) {
+	// The domain is passed as a path below ROOT, e.g. /sub; no domain is ROOT.
+	domainPath := strings.Trim(params.Get("domain"), "/")
+	if domainPath != "ROOT" && !strings.HasPrefix(domainPath, "ROOT/") {
+		domainPath = strings.TrimSuffix("ROOT/"+domainPath, "/")
+	}
+	for _, u := range s.users {
+		d := s.domainByID(u.Domainid)
+		if u.Username != params.Get("username") || d == nil || d.Path != domainPath {
+			continue
+		}
+		if password, ok := s.passwords[u.Id]; !ok || password != params.Get("password") {
+			break
+		}
+		sess := &session{userID: u.Id, jSessionID: s.newID()}
+		key := s.newID()
+		s.sessions[key] = sess
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: sess.jSessionID, Path: "/client", HttpOnly: true})
+		writeJSON(w, http.StatusOK, map[string]interface{}{"loginresponse": cloudstack.LoginResponse{
+			Account:    u.Account,
+			Domainid:   u.Domainid,
+			Sessionkey: key,
+			Timeout:    sessionTimeout,
+			Type:       "ADMIN",
+			Userid:     u.Id,
+			Username:   u.Username,
+		}})
+		return
+	}
+	writeError(w, "login", &APIError{
+		ErrorCode: errorCodeAccount,
+		ErrorText: "Failed to authenticate user " + params.Get("username") + " in domain " + domainPath + "; please provide valid credentials",
+	})
+}
+
+// authenticate verifies the session key and cookie of the request, or its signature against the secret key of the
+// calling user, and returns the calling user.
+func (s *Server) authenticate(r *http.Request, params url.Values) (*cloudstack.User, error) {
+	if key := params.Get("sessionkey"); key != "" {
+		sess, ok := s.sessions[key]
+		cookie, err := r.Cookie("JSESSIONID")
+		if !ok || err != nil || cookie.Value != sess.jSessionID {
+			return nil, &APIError{ErrorCode: errorCodeUnauthorized, ErrorText: "unable to verify user credentials"}
+		}
+		for _, u := range s.users {
+			if u.Id == sess.userID {
+				return u, nil
+			}
+		}
+		return nil, &APIError{ErrorCode: errorCodeUnauthorized, ErrorText: "unable to verify user credentials"}
+	}
+
+	signature := params.Get("signature")
+	user := s.userByAPIKey(params.Get("apiKey"))
+	if user == nil || signature == "" {
+		return nil, &APIError{ErrorCode: errorCodeUnauthorized, ErrorText: "unable to verify user credentials and/or request signature"}
+	}
+
+	unsigned := url.Values{}
+	for k, v := range params {
+		if k != "signature" {
+			unsigned[k] = v
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(user.Secretkey))
+	mac.Write([]byte(strings.ToLower(cloudstack.EncodeValues(unsigned))))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, &APIError{ErrorCode: errorCodeUnauthorized, ErrorText: "unable to verify user credentials and/or request signature"}
+	}
+	return user, nil
+}
+
+// newID returns a new, unique UUID-formatted identifier.
+func (s *Server) newID() string {
+	s.nextID++
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", s.nextID)
+}
+
+func writeError(w http.ResponseWriter, key string, err error) {
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		apiErr = &APIError{ErrorCode: errorCodeInternal, CSErrorCode: csErrorCodeServerAPI, ErrorText: err.Error()}
+	}
+	writeJSON(w, apiErr.ErrorCode, map[string]interface{}{key + "response": apiErr})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
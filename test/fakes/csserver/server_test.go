@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package csserver_test
+
+import (
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes/csserver"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		server  *csserver.Server
+		cs      *cloudstack.CloudStackClient
+		zone    *cloudstack.Zone
+		network *cloudstack.Network
+		offer   *cloudstack.ServiceOffering
+		tmpl    *cloudstack.Template
+	)
+
+	BeforeEach(func() {
+		server = csserver.NewServer()
+		cs = cloudstack.NewAsyncClient(server.URL(), csserver.AdminAPIKey, csserver.AdminSecretKey, false)
+		zone = server.AddZone(&cloudstack.Zone{Name: "zone1"})
+		network = server.AddNetwork(&cloudstack.Network{Name: "net1", Zoneid: zone.Id, Type: "Shared", Cidr: "10.1.1.0/24"})
+		offer = server.AddServiceOffering(&cloudstack.ServiceOffering{Name: "small", Cpunumber: 2, Memory: 2048})
+		tmpl = server.AddTemplate(&cloudstack.Template{Name: "ubuntu"})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("rejects requests signed with the wrong secret key", func() {
+		bad := cloudstack.NewAsyncClient(server.URL(), csserver.AdminAPIKey, "wrong", false)
+		_, err := bad.Zone.ListZones(bad.Zone.NewListZonesParams())
+		Ω(err).Should(MatchError(ContainSubstring("CloudStack API error 401")))
+	})
+
+	It("logs users in with their password", func() {
+		anonymous := cloudstack.NewAsyncClient(server.URL(), "", "", false)
+		resp, err := anonymous.Authentication.Login(anonymous.Authentication.NewLoginParams(csserver.AdminPassword, "admin"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(resp.Sessionkey).ShouldNot(BeEmpty())
+
+		_, err = anonymous.Authentication.Login(anonymous.Authentication.NewLoginParams("wrong", "admin"))
+		Ω(err).Should(MatchError(ContainSubstring("CloudStack API error 531")))
+	})
+
+	It("resolves resources by name and by ID", func() {
+		id, count, err := cs.Zone.GetZoneID("zone1")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(count).Should(Equal(1))
+		Ω(id).Should(Equal(zone.Id))
+
+		_, count, err = cs.Zone.GetZoneByID("00000000-0000-4000-8000-999999999999")
+		Ω(count).Should(Equal(0))
+		Ω(err).Should(MatchError(ContainSubstring("No match found")))
+	})
+
+	It("deploys, lists and destroys a virtual machine through async jobs", func() {
+		p := cs.VirtualMachine.NewDeployVirtualMachineParams(offer.Id, tmpl.Id, zone.Id)
+		p.SetName("vm1")
+		p.SetNetworkids([]string{network.Id})
+		p.SetDetails(map[string]string{"memoryOvercommitRatio": "1.2"})
+		deployed, err := cs.VirtualMachine.DeployVirtualMachine(p)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(deployed.Id).ShouldNot(BeEmpty())
+		Ω(deployed.State).Should(Equal("Running"))
+		Ω(deployed.Nic).Should(HaveLen(1))
+		Ω(deployed.Nic[0].Ipaddress).Should(Equal("10.1.1.2"))
+		Ω(deployed.Details).Should(HaveKeyWithValue("memoryOvercommitRatio", "1.2"))
+
+		vm, count, err := cs.VirtualMachine.GetVirtualMachinesMetricByName("vm1")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(count).Should(Equal(1))
+		Ω(vm.Ipaddress).Should(Equal("10.1.1.2"))
+
+		// A second VM with the same name in the same network is rejected.
+		_, err = cs.VirtualMachine.DeployVirtualMachine(p)
+		Ω(err).Should(MatchError(ContainSubstring("already exists")))
+
+		dp := cs.VirtualMachine.NewDestroyVirtualMachineParams(deployed.Id)
+		dp.SetExpunge(true)
+		_, err = cs.VirtualMachine.DestroyVirtualMachine(dp)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(server.VirtualMachines()).Should(BeEmpty())
+
+		_, count, err = cs.VirtualMachine.GetVirtualMachinesMetricByID(deployed.Id)
+		Ω(count).Should(Equal(0))
+		Ω(err).Should(MatchError(ContainSubstring("No match found")))
+	})
+
+	It("returns job IDs without waiting from a non-waiting client", func() {
+		nowait := cloudstack.NewClient(server.URL(), csserver.AdminAPIKey, csserver.AdminSecretKey, false)
+		p := nowait.VirtualMachine.NewDeployVirtualMachineParams(offer.Id, tmpl.Id, zone.Id)
+		resp, err := nowait.VirtualMachine.DeployVirtualMachine(p)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(resp.JobID).ShouldNot(BeEmpty())
+		Ω(resp.Id).Should(Equal(server.VirtualMachines()[0].Id))
+
+		job, err := nowait.Asyncjob.QueryAsyncJobResult(nowait.Asyncjob.NewQueryAsyncJobResultParams(resp.JobID))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(job.Jobstatus).Should(Equal(1))
+		Ω(job.Jobinstanceid).Should(Equal(resp.Id))
+	})
+
+	It("leaves held jobs pending until they're completed", func() {
+		nowait := cloudstack.NewClient(server.URL(), csserver.AdminAPIKey, csserver.AdminSecretKey, false)
+		server.HoldJobs("deployVirtualMachine")
+		resp, err := nowait.VirtualMachine.DeployVirtualMachine(nowait.VirtualMachine.NewDeployVirtualMachineParams(offer.Id, tmpl.Id, zone.Id))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		job, err := nowait.Asyncjob.QueryAsyncJobResult(nowait.Asyncjob.NewQueryAsyncJobResultParams(resp.JobID))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(job.Jobstatus).Should(Equal(0))
+
+		server.CompleteJobs()
+		job, err = nowait.Asyncjob.QueryAsyncJobResult(nowait.Asyncjob.NewQueryAsyncJobResultParams(resp.JobID))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(job.Jobstatus).Should(Equal(1))
+	})
+
+	It("tracks tags and reports duplicates", func() {
+		p := cs.Resourcetags.NewCreateTagsParams([]string{network.Id}, "Network", map[string]string{"created_by_CAPC": "1"})
+		_, err := cs.Resourcetags.CreateTags(p)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(server.Tags(network.Id)).Should(Equal(map[string]string{"created_by_CAPC": "1"}))
+
+		_, err = cs.Resourcetags.CreateTags(p)
+		Ω(err).Should(MatchError(ContainSubstring("already on Network with id " + network.Id)))
+
+		_, err = cs.Resourcetags.DeleteTags(cs.Resourcetags.NewDeleteTagsParams([]string{network.Id}, "Network"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(server.Tags(network.Id)).Should(BeEmpty())
+	})
+
+	It("lists virtual machines with and by their tags", func() {
+		p := cs.VirtualMachine.NewDeployVirtualMachineParams(offer.Id, tmpl.Id, zone.Id)
+		p.SetNetworkids([]string{network.Id})
+		p.SetName("vm1")
+		tagged, err := cs.VirtualMachine.DeployVirtualMachine(p)
+		Ω(err).ShouldNot(HaveOccurred())
+		p.SetName("vm2")
+		_, err = cs.VirtualMachine.DeployVirtualMachine(p)
+		Ω(err).ShouldNot(HaveOccurred())
+		_, err = cs.Resourcetags.CreateTags(
+			cs.Resourcetags.NewCreateTagsParams([]string{tagged.Id}, "UserVm", map[string]string{"owner": "me"}))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		lp := cs.VirtualMachine.NewListVirtualMachinesMetricsParams()
+		lp.SetTags(map[string]string{"owner": "me"})
+		resp, err := cs.VirtualMachine.ListVirtualMachinesMetrics(lp)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(resp.VirtualMachinesMetrics).Should(HaveLen(1))
+		Ω(resp.VirtualMachinesMetrics[0].Id).Should(Equal(tagged.Id))
+		Ω(resp.VirtualMachinesMetrics[0].Tags).Should(ConsistOf(
+			HaveField("Key", "owner"),
+		))
+	})
+
+	It("assigns IPv6 addresses and statically NATs public IPs to virtual machines", func() {
+		dualStack := server.AddNetwork(&cloudstack.Network{Name: "net6", Zoneid: zone.Id, Type: "Shared", Ip6cidr: "fd00::/64"})
+		p := cs.VirtualMachine.NewDeployVirtualMachineParams(offer.Id, tmpl.Id, zone.Id)
+		p.SetNetworkids([]string{dualStack.Id})
+		deployed, err := cs.VirtualMachine.DeployVirtualMachine(p)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(deployed.Nic[0].Ip6address).Should(Equal("fd00::2"))
+
+		ip := server.AddPublicIPAddress(&cloudstack.PublicIpAddress{Ipaddress: "192.0.2.10", Zoneid: zone.Id, State: "Allocated"})
+		_, err = cs.NAT.EnableStaticNat(cs.NAT.NewEnableStaticNatParams(ip.Id, deployed.Id))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(server.VirtualMachine(deployed.Id).Publicip).Should(Equal("192.0.2.10"))
+
+		_, err = cs.NAT.EnableStaticNat(cs.NAT.NewEnableStaticNatParams(ip.Id, deployed.Id))
+		Ω(err).Should(MatchError(ContainSubstring("already assigned")))
+	})
+
+	It("fails calls with injected faults", func() {
+		server.InjectFault("listZones", csserver.Fault{
+			APIError: csserver.APIError{ErrorCode: 530, CSErrorCode: 4250, ErrorText: "boom"},
+			Count:    1,
+		})
+		_, err := cs.Zone.ListZones(cs.Zone.NewListZonesParams())
+		Ω(err).Should(MatchError("CloudStack API error 530 (CSExceptionErrorCode: 4250): boom"))
+
+		_, err = cs.Zone.ListZones(cs.Zone.NewListZonesParams())
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(server.CallCount("listZones")).Should(Equal(2))
+	})
+})